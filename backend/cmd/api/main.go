@@ -17,6 +17,8 @@ import (
 	"github.com/meta-boy/mech-alligator/internal/database"
 	"github.com/meta-boy/mech-alligator/internal/queue"
 	"github.com/meta-boy/mech-alligator/internal/repository/postgres"
+	"github.com/meta-boy/mech-alligator/internal/scraper"
+	"github.com/meta-boy/mech-alligator/internal/scraper/plugins"
 	"github.com/meta-boy/mech-alligator/internal/service"
 )
 
@@ -47,19 +49,33 @@ func main() {
 	jobRepo := postgres.NewJobRepository(db)
 	productRepo := postgres.NewProductRepository(db)
 	userRepo := postgres.NewUserRepository(db)
+	resellerRepo := postgres.NewResellerRepository(db)
+	brandRepo := postgres.NewBrandRepository(db)
+	webhookRepo := postgres.NewWebhookRepository(db)
 
 	// Create queue (same as worker)
 	jobQueue := queue.NewDatabaseQueue(jobRepo)
 
+	// The API never scrapes; the plugins are registered to describe and
+	// validate their options and to probe new resellers
+	scraperManager := plugins.NewManager(scraper.NewFetcher(scraper.FetcherConfig{}))
+
 	// Create services
-	jobService := service.NewJobService(db, jobQueue) // scheduler not needed for API
+	jobService := service.NewJobService(db, jobQueue, resellerRepo, scraperManager) // scheduler not needed for API
 	productService := service.NewProductService(productRepo)
 	userService := service.NewUserService(userRepo)
+	resellerService := service.NewResellerService(resellerRepo, scraperManager)
+	brandService := service.NewBrandService(brandRepo, productRepo)
+	webhookService := service.NewWebhookService(webhookRepo, jobQueue)
 
 	// Create handlers
 	jobHandler := handlers.NewJobHandler(jobService)
 	productHandler := handlers.NewProductHandler(productService)
 	userHandler := handlers.NewUserHandler(userService)
+	pluginHandler := handlers.NewPluginHandler(scraperManager)
+	resellerHandler := handlers.NewResellerHandler(resellerService)
+	brandHandler := handlers.NewBrandHandler(brandService)
+	webhookHandler := handlers.NewWebhookHandler(webhookService)
 
 	// Setup routes
 	mux := http.NewServeMux()
@@ -79,6 +95,18 @@ func main() {
 	// Setup user routes
 	routes.SetupUserRoutes(mux, userHandler)
 
+	// Setup plugin routes
+	routes.SetupPluginRoutes(mux, pluginHandler)
+
+	// Setup reseller routes
+	routes.SetupResellerRoutes(mux, resellerHandler)
+
+	// Setup brand routes
+	routes.SetupBrandRoutes(mux, brandHandler)
+
+	// Setup webhook routes
+	routes.SetupWebhookRoutes(mux, webhookHandler)
+
 	// Add basic logging middleware
 	loggedMux := loggingMiddleware(mux)
 
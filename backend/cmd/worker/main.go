@@ -6,6 +6,7 @@ import (
 	"os"
 	"os/signal"
 	"syscall"
+	"time"
 
 	"github.com/meta-boy/mech-alligator/internal/config"
 	"github.com/meta-boy/mech-alligator/internal/database"
@@ -13,7 +14,7 @@ import (
 	"github.com/meta-boy/mech-alligator/internal/queue/jobs"
 	"github.com/meta-boy/mech-alligator/internal/repository/postgres"
 	"github.com/meta-boy/mech-alligator/internal/scraper"
-	"github.com/meta-boy/mech-alligator/internal/scraper/plugins/shopify"
+	"github.com/meta-boy/mech-alligator/internal/service"
 )
 
 func main() {
@@ -45,20 +46,32 @@ func main() {
 	// Create queue
 	jobQueue := queue.NewDatabaseQueue(jobRepo)
 
-	// Create scraper manager and register plugins
-	scraperManager := scraper.NewManager()
-
-	// Register Shopify plugin
-	shopifyPlugin := shopify.NewShopifyPlugin()
-	if err := scraperManager.RegisterPlugin(shopifyPlugin); err != nil {
-		log.Fatalf("Failed to register Shopify plugin: %v", err)
+	// Create the HTTP fetcher shared by all scraper plugins
+	scraperCfg := config.LoadScraperConfig()
+	var responseCache *scraper.ResponseCache
+	if scraperCfg.CacheDir != "" {
+		responseCache, err = scraper.NewResponseCache(scraperCfg.CacheDir, scraperCfg.CacheMaxSize, scraperCfg.CacheMaxAge)
+		if err != nil {
+			log.Fatalf("Failed to open scraper cache: %v", err)
+		}
+		log.Printf("Caching scraper responses in %s (up to %d MB)", scraperCfg.CacheDir, scraperCfg.CacheMaxSize>>20)
 	}
 
-	log.Printf("Registered scraper plugins: Shopify")
+	fetcher := scraper.NewFetcher(scraper.FetcherConfig{
+		UserAgent:            scraperCfg.UserAgent,
+		Timeout:              scraperCfg.RequestTimeout,
+		RequestInterval:      scraperCfg.RequestInterval,
+		MaxConcurrentPerHost: scraperCfg.MaxConcurrentPerHost,
+		MaxRetries:           scraperCfg.MaxRetries,
+		MaxResponseSize:      scraperCfg.MaxResponseSize,
+		Cache:                responseCache,
+	})
 
 	// Create job handlers
 	productRepo := postgres.NewProductRepository(db)
-	scrapeHandler := jobs.NewScrapeJobHandler(db, productRepo)
+	brandRepo := postgres.NewBrandRepository(db)
+	webhookRepo := postgres.NewWebhookRepository(db)
+	scrapeHandler := jobs.NewScrapeJobHandler(db, productRepo, brandRepo, fetcher, scraperCfg.Concurrency)
 	tagHandler, err := jobs.NewTagJobHandler(db.DB)
 	if err != nil {
 		log.Fatalf("Failed to create tag job handler: %v", err)
@@ -71,9 +84,16 @@ func main() {
 	// Register handlers
 	scheduler.RegisterHandler(scrapeHandler)
 	scheduler.RegisterHandler(tagHandler)
+	scheduler.RegisterHandler(jobs.NewWebhookDeliveryHandler(webhookRepo))
 
 	log.Printf("Job scheduler configured with %d workers", workers)
 
+	// Publish product change events from the outbox
+	eventRepo := postgres.NewEventRepository(db)
+	dispatcher := queue.NewEventDispatcher(eventRepo, 5*time.Second)
+	dispatcher.RegisterConsumer(queue.LogConsumer{})
+	dispatcher.RegisterConsumer(service.NewWebhookService(webhookRepo, jobQueue))
+
 	// Create context for graceful shutdown
 	ctx, cancel := context.WithCancel(context.Background())
 	defer cancel()
@@ -82,6 +102,9 @@ func main() {
 	if err := scheduler.Start(ctx); err != nil {
 		log.Fatalf("Failed to start scheduler: %v", err)
 	}
+	if err := dispatcher.Start(ctx); err != nil {
+		log.Fatalf("Failed to start event dispatcher: %v", err)
+	}
 
 	log.Println("Worker started successfully")
 
@@ -97,6 +120,9 @@ func main() {
 	if err := scheduler.Stop(); err != nil {
 		log.Printf("Error stopping scheduler: %v", err)
 	}
+	if err := dispatcher.Stop(); err != nil {
+		log.Printf("Error stopping event dispatcher: %v", err)
+	}
 
 	log.Println("Worker stopped")
 }
@@ -0,0 +1,130 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/meta-boy/mech-alligator/internal/domain/brand"
+	"github.com/meta-boy/mech-alligator/internal/service"
+)
+
+type BrandHandler struct {
+	brandService *service.BrandService
+}
+
+func NewBrandHandler(brandService *service.BrandService) *BrandHandler {
+	return &BrandHandler{
+		brandService: brandService,
+	}
+}
+
+// GET /api/brands
+func (h *BrandHandler) ListBrands(w http.ResponseWriter, r *http.Request) {
+	brands, err := h.brandService.ListBrands(r.Context())
+	if err != nil {
+		writeServiceError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"brands": brands,
+		"count":  len(brands),
+	})
+}
+
+// POST /api/brands
+func (h *BrandHandler) CreateBrand(w http.ResponseWriter, r *http.Request) {
+	var b brand.Brand
+	if err := json.NewDecoder(r.Body).Decode(&b); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	if err := h.brandService.CreateBrand(r.Context(), &b); err != nil {
+		writeServiceError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(b)
+}
+
+// GET /api/brands/{id}
+func (h *BrandHandler) GetBrand(w http.ResponseWriter, r *http.Request) {
+	brandID := pathID(r, "/api/brands/")
+	if brandID == "" {
+		http.Error(w, "brand id required", http.StatusBadRequest)
+		return
+	}
+
+	b, err := h.brandService.GetBrand(r.Context(), brandID)
+	if err != nil {
+		writeServiceError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(b)
+}
+
+// PATCH /api/brands/{id}
+func (h *BrandHandler) UpdateBrand(w http.ResponseWriter, r *http.Request) {
+	brandID := pathID(r, "/api/brands/")
+	if brandID == "" {
+		http.Error(w, "brand id required", http.StatusBadRequest)
+		return
+	}
+
+	var update service.BrandUpdate
+	if err := json.NewDecoder(r.Body).Decode(&update); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	b, err := h.brandService.UpdateBrand(r.Context(), brandID, update)
+	if err != nil {
+		writeServiceError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(b)
+}
+
+// POST /api/brands/{id}/merge
+func (h *BrandHandler) MergeBrands(w http.ResponseWriter, r *http.Request) {
+	brandID := pathID(r, "/api/brands/")
+	if brandID == "" {
+		http.Error(w, "brand id required", http.StatusBadRequest)
+		return
+	}
+
+	var req brand.MergeRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	b, err := h.brandService.MergeBrands(r.Context(), brandID, req)
+	if err != nil {
+		writeServiceError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(b)
+}
+
+// POST /api/brands/renormalize
+func (h *BrandHandler) Renormalize(w http.ResponseWriter, r *http.Request) {
+	result, err := h.brandService.Renormalize(r.Context())
+	if err != nil {
+		writeServiceError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(result)
+}
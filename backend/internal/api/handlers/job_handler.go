@@ -2,10 +2,12 @@ package handlers
 
 import (
 	"encoding/json"
+	"errors"
 	"net/http"
 	"strconv"
 
 	"github.com/meta-boy/mech-alligator/internal/domain/job"
+	"github.com/meta-boy/mech-alligator/internal/scraper"
 	"github.com/meta-boy/mech-alligator/internal/service"
 )
 
@@ -56,6 +58,11 @@ func (h *JobHandler) CreateScrapeJob(w http.ResponseWriter, r *http.Request) {
 	ctx := r.Context()
 	j, err := h.jobService.CreateScrapeJob(ctx, req.ConfigID, req.Options)
 	if err != nil {
+		var optionsErr *scraper.OptionsError
+		if errors.As(err, &optionsErr) {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
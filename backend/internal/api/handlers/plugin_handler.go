@@ -0,0 +1,31 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/meta-boy/mech-alligator/internal/scraper"
+)
+
+type PluginHandler struct {
+	manager *scraper.Manager
+}
+
+func NewPluginHandler(manager *scraper.Manager) *PluginHandler {
+	return &PluginHandler{
+		manager: manager,
+	}
+}
+
+// ListPlugins returns every scraper plugin with the options it understands,
+// plus the options every plugin understands
+func (h *PluginHandler) ListPlugins(w http.ResponseWriter, r *http.Request) {
+	plugins := h.manager.ListAvailablePlugins()
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"plugins":        plugins,
+		"common_options": scraper.CommonOptions(),
+		"count":          len(plugins),
+	})
+}
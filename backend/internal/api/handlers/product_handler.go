@@ -5,6 +5,7 @@ import (
 	"net/http"
 	"strconv"
 	"strings"
+	"time"
 
 	"github.com/meta-boy/mech-alligator/internal/domain/product"
 	"github.com/meta-boy/mech-alligator/internal/service"
@@ -34,6 +35,127 @@ func (h *ProductHandler) ListProducts(w http.ResponseWriter, r *http.Request) {
 	json.NewEncoder(w).Encode(response)
 }
 
+// GET /api/products/new?category=KEYBOARD&reseller=&since=2024-01-01
+func (h *ProductHandler) ListNewArrivals(w http.ResponseWriter, r *http.Request) {
+	req := h.parseListRequest(r)
+	if since := r.URL.Query().Get("since"); since != "" {
+		t, err := parseDate(since)
+		if err != nil {
+			http.Error(w, "since must be a date (2006-01-02) or an RFC 3339 time", http.StatusBadRequest)
+			return
+		}
+		req.Since = &t
+	}
+
+	response, err := h.productService.ListNewArrivals(r.Context(), *req)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
+
+// GET /api/products/{id}/offers
+func (h *ProductHandler) GetOffers(w http.ResponseWriter, r *http.Request) {
+	productID := pathID(r, "/api/products/")
+	if productID == "" {
+		http.Error(w, "product id required", http.StatusBadRequest)
+		return
+	}
+
+	offers, err := h.productService.GetOffers(r.Context(), productID)
+	if err != nil {
+		writeServiceError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(offers)
+}
+
+// GET /api/products/{id}/price-history?from=2024-01-01&to=2024-02-01&interval=day&variant_id=
+func (h *ProductHandler) GetPriceHistory(w http.ResponseWriter, r *http.Request) {
+	productID := pathID(r, "/api/products/")
+	if productID == "" {
+		http.Error(w, "product id required", http.StatusBadRequest)
+		return
+	}
+
+	query := r.URL.Query()
+	req := product.PriceHistoryRequest{
+		VariantID: query.Get("variant_id"),
+		Interval:  query.Get("interval"),
+	}
+	for _, param := range []struct {
+		name  string
+		field **time.Time
+	}{{"from", &req.From}, {"to", &req.To}} {
+		value := query.Get(param.name)
+		if value == "" {
+			continue
+		}
+		t, err := parseDate(value)
+		if err != nil {
+			http.Error(w, param.name+" must be a date (2006-01-02) or an RFC 3339 time", http.StatusBadRequest)
+			return
+		}
+		*param.field = &t
+	}
+
+	history, err := h.productService.GetPriceHistory(r.Context(), productID, req)
+	if err != nil {
+		writeServiceError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(history)
+}
+
+// PUT /api/products/{id}/canonical
+func (h *ProductHandler) OverrideCanonical(w http.ResponseWriter, r *http.Request) {
+	productID := pathID(r, "/api/products/")
+	if productID == "" {
+		http.Error(w, "product id required", http.StatusBadRequest)
+		return
+	}
+
+	var override product.CanonicalOverride
+	if err := json.NewDecoder(r.Body).Decode(&override); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	p, err := h.productService.OverrideCanonical(r.Context(), productID, override)
+	if err != nil {
+		writeServiceError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(p)
+}
+
+// DELETE /api/products/{id}/canonical
+func (h *ProductHandler) ResetCanonical(w http.ResponseWriter, r *http.Request) {
+	productID := pathID(r, "/api/products/")
+	if productID == "" {
+		http.Error(w, "product id required", http.StatusBadRequest)
+		return
+	}
+
+	p, err := h.productService.ResetCanonical(r.Context(), productID)
+	if err != nil {
+		writeServiceError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(p)
+}
+
 // GET /api/products/{id}
 func (h *ProductHandler) GetProduct(w http.ResponseWriter, r *http.Request) {
 	productID := strings.TrimPrefix(r.URL.Path, "/api/products/")
@@ -71,12 +193,19 @@ func (h *ProductHandler) GetFilterOptions(w http.ResponseWriter, r *http.Request
 	json.NewEncoder(w).Encode(filters)
 }
 
+// parseDate accepts a date, meaning midnight UTC, or an RFC 3339 time
+func parseDate(value string) (time.Time, error) {
+	if t, err := time.Parse("2006-01-02", value); err == nil {
+		return t, nil
+	}
+	return time.Parse(time.RFC3339, value)
+}
+
 func (h *ProductHandler) parseListRequest(r *http.Request) *product.ListRequest {
 	req := &product.ListRequest{
-		Page:      1,
-		PageSize:  20,
-		SortBy:    "name",
-		SortOrder: "asc",
+		Page:     1,
+		PageSize: 20,
+		SortBy:   "name",
 	}
 
 	// Parse query parameters
@@ -115,6 +244,10 @@ func (h *ProductHandler) parseListRequest(r *http.Request) *product.ListRequest
 		}
 	}
 
+	if includeDelisted, err := strconv.ParseBool(query.Get("include_delisted")); err == nil {
+		req.IncludeDelisted = includeDelisted
+	}
+
 	if page := query.Get("page"); page != "" {
 		if p, err := strconv.Atoi(page); err == nil && p > 0 {
 			req.Page = p
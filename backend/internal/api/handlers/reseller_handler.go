@@ -0,0 +1,312 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/meta-boy/mech-alligator/internal/config"
+	"github.com/meta-boy/mech-alligator/internal/service"
+)
+
+type ResellerHandler struct {
+	resellerService *service.ResellerService
+}
+
+func NewResellerHandler(resellerService *service.ResellerService) *ResellerHandler {
+	return &ResellerHandler{
+		resellerService: resellerService,
+	}
+}
+
+type DiscoverResellerRequest struct {
+	URL string `json:"url"`
+}
+
+// Discover probes a reseller's homepage and proposes configs for it
+func (h *ResellerHandler) Discover(w http.ResponseWriter, r *http.Request) {
+	var req DiscoverResellerRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	if req.URL == "" {
+		http.Error(w, "url is required", http.StatusBadRequest)
+		return
+	}
+
+	discovery, err := h.resellerService.Discover(r.Context(), req.URL)
+	if err != nil {
+		writeServiceError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(discovery)
+}
+
+// AcceptDiscovery creates the reseller and configs of a discovery
+func (h *ResellerHandler) AcceptDiscovery(w http.ResponseWriter, r *http.Request) {
+	var req service.AcceptDiscoveryRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	accepted, err := h.resellerService.AcceptDiscovery(r.Context(), req)
+	if err != nil {
+		writeServiceError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(accepted)
+}
+
+// GET /api/resellers?include_inactive=true
+func (h *ResellerHandler) ListResellers(w http.ResponseWriter, r *http.Request) {
+	includeInactive, _ := strconv.ParseBool(r.URL.Query().Get("include_inactive"))
+
+	resellers, err := h.resellerService.ListResellers(r.Context(), includeInactive)
+	if err != nil {
+		writeServiceError(w, err)
+		return
+	}
+	if resellers == nil {
+		resellers = []config.Reseller{}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"resellers": resellers,
+		"count":     len(resellers),
+	})
+}
+
+// POST /api/resellers
+func (h *ResellerHandler) CreateReseller(w http.ResponseWriter, r *http.Request) {
+	reseller := config.Reseller{Active: true}
+	if err := json.NewDecoder(r.Body).Decode(&reseller); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	if err := h.resellerService.CreateReseller(r.Context(), &reseller); err != nil {
+		writeServiceError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(reseller)
+}
+
+// GET /api/resellers/{id}
+func (h *ResellerHandler) GetReseller(w http.ResponseWriter, r *http.Request) {
+	resellerID := pathID(r, "/api/resellers/")
+	if resellerID == "" {
+		http.Error(w, "reseller id required", http.StatusBadRequest)
+		return
+	}
+
+	reseller, err := h.resellerService.GetReseller(r.Context(), resellerID)
+	if err != nil {
+		writeServiceError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(reseller)
+}
+
+// PATCH /api/resellers/{id}
+func (h *ResellerHandler) UpdateReseller(w http.ResponseWriter, r *http.Request) {
+	resellerID := pathID(r, "/api/resellers/")
+	if resellerID == "" {
+		http.Error(w, "reseller id required", http.StatusBadRequest)
+		return
+	}
+
+	var update service.ResellerUpdate
+	if err := json.NewDecoder(r.Body).Decode(&update); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	reseller, err := h.resellerService.UpdateReseller(r.Context(), resellerID, update)
+	if err != nil {
+		writeServiceError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(reseller)
+}
+
+// DELETE /api/resellers/{id} deactivates the reseller
+func (h *ResellerHandler) DeactivateReseller(w http.ResponseWriter, r *http.Request) {
+	resellerID := pathID(r, "/api/resellers/")
+	if resellerID == "" {
+		http.Error(w, "reseller id required", http.StatusBadRequest)
+		return
+	}
+
+	if err := h.resellerService.DeactivateReseller(r.Context(), resellerID); err != nil {
+		writeServiceError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{
+		"message": "reseller deactivated successfully",
+	})
+}
+
+// GET /api/resellers/{id}/configs
+func (h *ResellerHandler) ListConfigs(w http.ResponseWriter, r *http.Request) {
+	resellerID := pathID(r, "/api/resellers/")
+	if resellerID == "" {
+		http.Error(w, "reseller id required", http.StatusBadRequest)
+		return
+	}
+
+	configs, err := h.resellerService.ListConfigs(r.Context(), resellerID)
+	if err != nil {
+		writeServiceError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"configs": configs,
+		"count":   len(configs),
+	})
+}
+
+// POST /api/resellers/{id}/configs
+func (h *ResellerHandler) CreateConfig(w http.ResponseWriter, r *http.Request) {
+	resellerID := pathID(r, "/api/resellers/")
+	if resellerID == "" {
+		http.Error(w, "reseller id required", http.StatusBadRequest)
+		return
+	}
+
+	c := config.ResellerConfig{Active: true}
+	if err := json.NewDecoder(r.Body).Decode(&c); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	if err := h.resellerService.CreateConfig(r.Context(), resellerID, &c); err != nil {
+		writeServiceError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(c)
+}
+
+// GET /api/reseller-configs/{id}
+func (h *ResellerHandler) GetConfig(w http.ResponseWriter, r *http.Request) {
+	configID := pathID(r, "/api/reseller-configs/")
+	if configID == "" {
+		http.Error(w, "config id required", http.StatusBadRequest)
+		return
+	}
+
+	c, err := h.resellerService.GetConfig(r.Context(), configID)
+	if err != nil {
+		writeServiceError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(c)
+}
+
+// PATCH /api/reseller-configs/{id}
+func (h *ResellerHandler) UpdateConfig(w http.ResponseWriter, r *http.Request) {
+	configID := pathID(r, "/api/reseller-configs/")
+	if configID == "" {
+		http.Error(w, "config id required", http.StatusBadRequest)
+		return
+	}
+
+	var update service.ConfigUpdate
+	if err := json.NewDecoder(r.Body).Decode(&update); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	c, err := h.resellerService.UpdateConfig(r.Context(), configID, update)
+	if err != nil {
+		writeServiceError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(c)
+}
+
+// POST /api/reseller-configs/{id}/detect probes the config's platform again
+func (h *ResellerHandler) DetectConfig(w http.ResponseWriter, r *http.Request) {
+	configID := pathID(r, "/api/reseller-configs/")
+	if configID == "" {
+		http.Error(w, "config id required", http.StatusBadRequest)
+		return
+	}
+
+	result, err := h.resellerService.DetectConfig(r.Context(), configID)
+	if err != nil {
+		writeServiceError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(result)
+}
+
+// DELETE /api/reseller-configs/{id} deactivates the config
+func (h *ResellerHandler) DeactivateConfig(w http.ResponseWriter, r *http.Request) {
+	configID := pathID(r, "/api/reseller-configs/")
+	if configID == "" {
+		http.Error(w, "config id required", http.StatusBadRequest)
+		return
+	}
+
+	if err := h.resellerService.DeactivateConfig(r.Context(), configID); err != nil {
+		writeServiceError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{
+		"message": "config deactivated successfully",
+	})
+}
+
+// pathID returns the path segment after prefix, e.g. the ID in
+// /api/resellers/{id}/configs
+func pathID(r *http.Request, prefix string) string {
+	id, _, _ := strings.Cut(strings.TrimPrefix(r.URL.Path, prefix), "/")
+	return id
+}
+
+// writeServiceError answers 400 for problems with the request, 404 for
+// missing records and 500 for everything else
+func writeServiceError(w http.ResponseWriter, err error) {
+	var validationErr *service.ValidationError
+	switch {
+	case errors.As(err, &validationErr):
+		http.Error(w, err.Error(), http.StatusBadRequest)
+	case errors.Is(err, service.ErrNotFound):
+		http.Error(w, err.Error(), http.StatusNotFound)
+	default:
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
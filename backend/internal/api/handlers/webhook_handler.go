@@ -0,0 +1,152 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+
+	"github.com/meta-boy/mech-alligator/internal/domain/webhook"
+	"github.com/meta-boy/mech-alligator/internal/service"
+)
+
+type WebhookHandler struct {
+	webhookService *service.WebhookService
+}
+
+func NewWebhookHandler(webhookService *service.WebhookService) *WebhookHandler {
+	return &WebhookHandler{
+		webhookService: webhookService,
+	}
+}
+
+// GET /api/webhooks
+func (h *WebhookHandler) ListSubscriptions(w http.ResponseWriter, r *http.Request) {
+	subscriptions, err := h.webhookService.ListSubscriptions(r.Context())
+	if err != nil {
+		writeServiceError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"subscriptions": subscriptions,
+		"count":         len(subscriptions),
+	})
+}
+
+// POST /api/webhooks
+func (h *WebhookHandler) CreateSubscription(w http.ResponseWriter, r *http.Request) {
+	var subscription webhook.Subscription
+	if err := json.NewDecoder(r.Body).Decode(&subscription); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	if err := h.webhookService.CreateSubscription(r.Context(), &subscription); err != nil {
+		writeServiceError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(subscription)
+}
+
+// GET /api/webhooks/{id}
+func (h *WebhookHandler) GetSubscription(w http.ResponseWriter, r *http.Request) {
+	subscriptionID := pathID(r, "/api/webhooks/")
+	if subscriptionID == "" {
+		http.Error(w, "subscription id required", http.StatusBadRequest)
+		return
+	}
+
+	subscription, err := h.webhookService.GetSubscription(r.Context(), subscriptionID)
+	if err != nil {
+		writeServiceError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(subscription)
+}
+
+// DELETE /api/webhooks/{id}
+func (h *WebhookHandler) DeleteSubscription(w http.ResponseWriter, r *http.Request) {
+	subscriptionID := pathID(r, "/api/webhooks/")
+	if subscriptionID == "" {
+		http.Error(w, "subscription id required", http.StatusBadRequest)
+		return
+	}
+
+	if err := h.webhookService.DeleteSubscription(r.Context(), subscriptionID); err != nil {
+		writeServiceError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{
+		"message": "subscription deleted successfully",
+	})
+}
+
+// POST /api/webhooks/{id}/test
+func (h *WebhookHandler) TestSubscription(w http.ResponseWriter, r *http.Request) {
+	subscriptionID := pathID(r, "/api/webhooks/")
+	if subscriptionID == "" {
+		http.Error(w, "subscription id required", http.StatusBadRequest)
+		return
+	}
+
+	delivery, err := h.webhookService.TestSubscription(r.Context(), subscriptionID)
+	if err != nil {
+		writeServiceError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusAccepted)
+	json.NewEncoder(w).Encode(delivery)
+}
+
+// GET /api/webhooks/{id}/deliveries?status=failed&limit=50
+func (h *WebhookHandler) ListDeliveries(w http.ResponseWriter, r *http.Request) {
+	subscriptionID := pathID(r, "/api/webhooks/")
+	if subscriptionID == "" {
+		http.Error(w, "subscription id required", http.StatusBadRequest)
+		return
+	}
+
+	status := webhook.DeliveryStatus(r.URL.Query().Get("status"))
+	limit, _ := strconv.Atoi(r.URL.Query().Get("limit"))
+
+	deliveries, err := h.webhookService.ListDeliveries(r.Context(), subscriptionID, status, limit)
+	if err != nil {
+		writeServiceError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"deliveries": deliveries,
+		"count":      len(deliveries),
+	})
+}
+
+// POST /api/webhooks/deliveries/{id}/replay
+func (h *WebhookHandler) ReplayDelivery(w http.ResponseWriter, r *http.Request) {
+	deliveryID := pathID(r, "/api/webhooks/deliveries/")
+	if deliveryID == "" {
+		http.Error(w, "delivery id required", http.StatusBadRequest)
+		return
+	}
+
+	delivery, err := h.webhookService.ReplayDelivery(r.Context(), deliveryID)
+	if err != nil {
+		writeServiceError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusAccepted)
+	json.NewEncoder(w).Encode(delivery)
+}
@@ -0,0 +1,53 @@
+package routes
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/meta-boy/mech-alligator/internal/api/handlers"
+)
+
+func SetupBrandRoutes(mux *http.ServeMux, brandHandler *handlers.BrandHandler) {
+	// Brand registry endpoints
+	mux.HandleFunc("/api/brands", func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case http.MethodGet:
+			brandHandler.ListBrands(w, r)
+		case http.MethodPost:
+			brandHandler.CreateBrand(w, r)
+		default:
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		}
+	})
+
+	mux.HandleFunc("/api/brands/renormalize", func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case http.MethodPost:
+			brandHandler.Renormalize(w, r)
+		default:
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		}
+	})
+
+	mux.HandleFunc("/api/brands/", func(w http.ResponseWriter, r *http.Request) {
+		// /api/brands/{id}/merge
+		if strings.HasSuffix(strings.TrimSuffix(r.URL.Path, "/"), "/merge") {
+			switch r.Method {
+			case http.MethodPost:
+				brandHandler.MergeBrands(w, r)
+			default:
+				http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			}
+			return
+		}
+
+		switch r.Method {
+		case http.MethodGet:
+			brandHandler.GetBrand(w, r)
+		case http.MethodPatch:
+			brandHandler.UpdateBrand(w, r)
+		default:
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		}
+	})
+}
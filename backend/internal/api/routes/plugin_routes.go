@@ -0,0 +1,19 @@
+package routes
+
+import (
+	"net/http"
+
+	"github.com/meta-boy/mech-alligator/internal/api/handlers"
+)
+
+func SetupPluginRoutes(mux *http.ServeMux, pluginHandler *handlers.PluginHandler) {
+	// Scraper plugin endpoints
+	mux.HandleFunc("/api/plugins", func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case http.MethodGet:
+			pluginHandler.ListPlugins(w, r)
+		default:
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		}
+	})
+}
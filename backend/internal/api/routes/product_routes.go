@@ -2,6 +2,7 @@ package routes
 
 import (
 	"net/http"
+	"strings"
 
 	"github.com/meta-boy/mech-alligator/internal/api/handlers"
 )
@@ -18,6 +19,43 @@ func SetupProductRoutes(mux *http.ServeMux, productHandler *handlers.ProductHand
 	})
 
 	mux.HandleFunc("/api/products/", func(w http.ResponseWriter, r *http.Request) {
+		path := strings.TrimSuffix(r.URL.Path, "/")
+
+		// /api/products/{id}/offers
+		if strings.HasSuffix(path, "/offers") {
+			switch r.Method {
+			case http.MethodGet:
+				productHandler.GetOffers(w, r)
+			default:
+				http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			}
+			return
+		}
+
+		// /api/products/{id}/price-history
+		if strings.HasSuffix(path, "/price-history") {
+			switch r.Method {
+			case http.MethodGet:
+				productHandler.GetPriceHistory(w, r)
+			default:
+				http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			}
+			return
+		}
+
+		// /api/products/{id}/canonical
+		if strings.HasSuffix(path, "/canonical") {
+			switch r.Method {
+			case http.MethodPut:
+				productHandler.OverrideCanonical(w, r)
+			case http.MethodDelete:
+				productHandler.ResetCanonical(w, r)
+			default:
+				http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			}
+			return
+		}
+
 		switch r.Method {
 		case http.MethodGet:
 			productHandler.GetProduct(w, r)
@@ -26,6 +64,15 @@ func SetupProductRoutes(mux *http.ServeMux, productHandler *handlers.ProductHand
 		}
 	})
 
+	mux.HandleFunc("/api/products/new", func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case http.MethodGet:
+			productHandler.ListNewArrivals(w, r)
+		default:
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		}
+	})
+
 	mux.HandleFunc("/api/products/filter-options", func(w http.ResponseWriter, r *http.Request) {
 		switch r.Method {
 		case http.MethodGet:
@@ -0,0 +1,91 @@
+package routes
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/meta-boy/mech-alligator/internal/api/handlers"
+)
+
+func SetupResellerRoutes(mux *http.ServeMux, resellerHandler *handlers.ResellerHandler) {
+	// Reseller management endpoints
+	mux.HandleFunc("/api/resellers", func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case http.MethodGet:
+			resellerHandler.ListResellers(w, r)
+		case http.MethodPost:
+			resellerHandler.CreateReseller(w, r)
+		default:
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		}
+	})
+
+	mux.HandleFunc("/api/resellers/", func(w http.ResponseWriter, r *http.Request) {
+		// /api/resellers/{id}/configs
+		if strings.HasSuffix(strings.TrimSuffix(r.URL.Path, "/"), "/configs") {
+			switch r.Method {
+			case http.MethodGet:
+				resellerHandler.ListConfigs(w, r)
+			case http.MethodPost:
+				resellerHandler.CreateConfig(w, r)
+			default:
+				http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			}
+			return
+		}
+
+		switch r.Method {
+		case http.MethodGet:
+			resellerHandler.GetReseller(w, r)
+		case http.MethodPatch:
+			resellerHandler.UpdateReseller(w, r)
+		case http.MethodDelete:
+			resellerHandler.DeactivateReseller(w, r)
+		default:
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		}
+	})
+
+	mux.HandleFunc("/api/reseller-configs/", func(w http.ResponseWriter, r *http.Request) {
+		// /api/reseller-configs/{id}/detect
+		if strings.HasSuffix(strings.TrimSuffix(r.URL.Path, "/"), "/detect") {
+			switch r.Method {
+			case http.MethodPost:
+				resellerHandler.DetectConfig(w, r)
+			default:
+				http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			}
+			return
+		}
+
+		switch r.Method {
+		case http.MethodGet:
+			resellerHandler.GetConfig(w, r)
+		case http.MethodPatch:
+			resellerHandler.UpdateConfig(w, r)
+		case http.MethodDelete:
+			resellerHandler.DeactivateConfig(w, r)
+		default:
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		}
+	})
+
+	// Reseller onboarding endpoints
+	mux.HandleFunc("/api/resellers/discover", func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case http.MethodPost:
+			resellerHandler.Discover(w, r)
+		default:
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		}
+	})
+
+	mux.HandleFunc("/api/resellers/discover/accept", func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case http.MethodPost:
+			resellerHandler.AcceptDiscovery(w, r)
+		default:
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		}
+	})
+}
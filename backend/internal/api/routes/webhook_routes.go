@@ -0,0 +1,72 @@
+package routes
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/meta-boy/mech-alligator/internal/api/handlers"
+)
+
+func SetupWebhookRoutes(mux *http.ServeMux, webhookHandler *handlers.WebhookHandler) {
+	// Webhook subscription endpoints
+	mux.HandleFunc("/api/webhooks", func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case http.MethodGet:
+			webhookHandler.ListSubscriptions(w, r)
+		case http.MethodPost:
+			webhookHandler.CreateSubscription(w, r)
+		default:
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		}
+	})
+
+	mux.HandleFunc("/api/webhooks/", func(w http.ResponseWriter, r *http.Request) {
+		path := strings.TrimSuffix(r.URL.Path, "/")
+
+		// /api/webhooks/{id}/test
+		if strings.HasSuffix(path, "/test") {
+			switch r.Method {
+			case http.MethodPost:
+				webhookHandler.TestSubscription(w, r)
+			default:
+				http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			}
+			return
+		}
+
+		// /api/webhooks/{id}/deliveries
+		if strings.HasSuffix(path, "/deliveries") {
+			switch r.Method {
+			case http.MethodGet:
+				webhookHandler.ListDeliveries(w, r)
+			default:
+				http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			}
+			return
+		}
+
+		switch r.Method {
+		case http.MethodGet:
+			webhookHandler.GetSubscription(w, r)
+		case http.MethodDelete:
+			webhookHandler.DeleteSubscription(w, r)
+		default:
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		}
+	})
+
+	// /api/webhooks/deliveries/{id}/replay
+	mux.HandleFunc("/api/webhooks/deliveries/", func(w http.ResponseWriter, r *http.Request) {
+		if !strings.HasSuffix(strings.TrimSuffix(r.URL.Path, "/"), "/replay") {
+			http.NotFound(w, r)
+			return
+		}
+
+		switch r.Method {
+		case http.MethodPost:
+			webhookHandler.ReplayDelivery(w, r)
+		default:
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		}
+	})
+}
@@ -1,5 +1,7 @@
 package config
 
+import "time"
+
 // Reseller represents a store/website that sells products from multiple brands
 type Reseller struct {
 	ID       string `json:"id" db:"id"`
@@ -8,6 +10,8 @@ type Reseller struct {
 	Website  string `json:"website" db:"website"`   // Base website URL
 	Currency string `json:"currency" db:"currency"` // Default currency for this reseller
 	Active   bool   `json:"active" db:"active"`
+
+	Configs []ResellerConfig `json:"configs,omitempty" db:"-"` // Filled in when listing resellers
 }
 
 // ResellerConfig represents a specific scraping configuration for a reseller
@@ -22,6 +26,29 @@ type ResellerConfig struct {
 
 	// Scraping options specific to this config
 	Options map[string]string `json:"options,omitempty" db:"options"`
+
+	// The platform found for an AUTO config, kept until it is detected again
+	DetectedSourceType  string     `json:"detected_source_type,omitempty" db:"detected_source_type"`
+	DetectionConfidence float64    `json:"detection_confidence,omitempty" db:"detection_confidence"`
+	DetectedAt          *time.Time `json:"detected_at,omitempty" db:"detected_at"` // Nil when carried over from the old URL guess
+
+	LastScrape *ScrapeStatus `json:"last_scrape,omitempty" db:"-"` // Most recent scrape job, nil if never scraped
+}
+
+// ScrapeStatus summarises a config's most recent scrape job
+type ScrapeStatus struct {
+	JobID             string     `json:"job_id"`
+	Status            string     `json:"status"`
+	Error             string     `json:"error,omitempty"`
+	ProductsCreated   int        `json:"products_created"`
+	ProductsUpdated   int        `json:"products_updated"`
+	ProductsUnchanged int        `json:"products_unchanged"`
+	ProductsDelisted  int        `json:"products_delisted"`
+	TotalErrors       int        `json:"total_errors"`
+	Partial           bool       `json:"partial,omitempty"`
+	ScheduledAt       time.Time  `json:"scheduled_at"`
+	StartedAt         *time.Time `json:"started_at,omitempty"`
+	CompletedAt       *time.Time `json:"completed_at,omitempty"`
 }
 
 // Brand represents actual product manufacturers/brands
@@ -41,5 +68,6 @@ type ScrapeJobPayload struct {
 	URL          string            `json:"url"`
 	SourceType   string            `json:"source_type"`
 	Category     string            `json:"category"`
+	Currency     string            `json:"currency"`
 	Options      map[string]string `json:"options,omitempty"`
 }
@@ -0,0 +1,34 @@
+package config
+
+import "time"
+
+// ScraperConfig holds the HTTP politeness settings shared by all scraper
+// plugins. Zero values fall back to the fetcher defaults; a negative
+// interval or retry count disables it.
+type ScraperConfig struct {
+	UserAgent            string
+	RequestTimeout       time.Duration
+	RequestInterval      time.Duration
+	MaxConcurrentPerHost int
+	MaxRetries           int
+	MaxResponseSize      int64
+	CacheDir             string        // On-disk response cache, disabled when empty
+	CacheMaxSize         int64         // Bytes the cache may use before its least recently used entries are pruned
+	CacheMaxAge          time.Duration // Cached responses unused for this long are pruned
+	Concurrency          int           // Pages or product pages of one scrape fetched at once
+}
+
+func LoadScraperConfig() *ScraperConfig {
+	return &ScraperConfig{
+		UserAgent:            getEnv("SCRAPER_USER_AGENT", ""),
+		RequestTimeout:       getEnvAsDuration("SCRAPER_REQUEST_TIMEOUT", 30*time.Second),
+		RequestInterval:      getEnvAsDuration("SCRAPER_REQUEST_INTERVAL", 500*time.Millisecond),
+		MaxConcurrentPerHost: getEnvAsInt("SCRAPER_MAX_CONCURRENT_PER_HOST", 2),
+		MaxRetries:           getEnvAsInt("SCRAPER_MAX_RETRIES", 3),
+		MaxResponseSize:      int64(getEnvAsInt("SCRAPER_MAX_RESPONSE_MB", 20)) << 20,
+		CacheDir:             getEnv("SCRAPER_CACHE_DIR", ""),
+		CacheMaxSize:         int64(getEnvAsInt("SCRAPER_CACHE_MAX_MB", 1024)) << 20,
+		CacheMaxAge:          getEnvAsDuration("SCRAPER_CACHE_MAX_AGE", 30*24*time.Hour),
+		Concurrency:          getEnvAsInt("SCRAPER_CONCURRENCY", 4),
+	}
+}
@@ -0,0 +1,8 @@
+DROP INDEX IF EXISTS idx_products_brand_id;
+
+ALTER TABLE products
+    DROP COLUMN IF EXISTS brand_id,
+    DROP COLUMN IF EXISTS raw_brand;
+
+DROP TABLE IF EXISTS brand_rules;
+DROP TABLE IF EXISTS brand_aliases;
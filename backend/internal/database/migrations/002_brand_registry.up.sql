@@ -0,0 +1,60 @@
+-- Brand registry: canonical brands, the other names they appear under and
+-- rules that find them in product names
+
+CREATE TABLE brand_aliases (
+                               brand_id UUID NOT NULL REFERENCES brands(id) ON DELETE CASCADE,
+                               alias VARCHAR(255) NOT NULL,      -- As entered, e.g. "GMK Electronics"
+                               alias_key VARCHAR(255) NOT NULL UNIQUE, -- Normalised for matching, e.g. "gmk"
+                               PRIMARY KEY (brand_id, alias_key)
+);
+
+CREATE TABLE brand_rules (
+                             id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+                             brand_id UUID NOT NULL REFERENCES brands(id) ON DELETE CASCADE,
+                             pattern TEXT NOT NULL,            -- Case-insensitive regexp matched against the product name
+                             priority INTEGER NOT NULL DEFAULT 0 -- Higher priority rules are tried first
+);
+
+CREATE INDEX idx_brand_rules_brand_id ON brand_rules (brand_id);
+
+-- Products keep the brand as scraped so they can be re-normalised when the
+-- registry changes
+ALTER TABLE products
+    ADD COLUMN brand_id UUID REFERENCES brands(id) ON DELETE SET NULL,
+    ADD COLUMN raw_brand VARCHAR(255);
+
+UPDATE products SET raw_brand = brand;
+
+CREATE INDEX idx_products_brand_id ON products (brand_id);
+
+-- Brands that used to be detected from product titles by the plugins
+INSERT INTO brands (name) VALUES
+    ('GMK'), ('ePBT'), ('Cherry'), ('Gateron'), ('Kailh'), ('Akko'), ('Keychron'),
+    ('Drop'), ('Wuque Studio'), ('Ducky'), ('Leopold'), ('Varmilo'), ('Filco'),
+    ('Topre'), ('HHKB'), ('NovelKeys'), ('ZealPC'), ('Durock'), ('JWK')
+ON CONFLICT (name) DO NOTHING;
+
+INSERT INTO brand_rules (brand_id, pattern, priority)
+SELECT b.id, r.pattern, r.priority
+FROM (VALUES
+    ('GMK', '\bGMK\b', 10),
+    ('ePBT', '\bePBT\b', 10),
+    ('Cherry', '\bCherry\s+MX\b', 5),
+    ('Gateron', '\bGateron\b', 10),
+    ('Kailh', '\bKailh\b', 10),
+    ('Akko', '\bAkko\b', 10),
+    ('Keychron', '\bKeychron\b', 10),
+    ('Drop', '^Drop\b', 5),
+    ('Wuque Studio', '\bWuque\b', 10),
+    ('Ducky', '\bDucky\b', 10),
+    ('Leopold', '\bLeopold\b', 10),
+    ('Varmilo', '\bVarmilo\b', 10),
+    ('Filco', '\bFilco\b', 10),
+    ('Topre', '\bRealforce\b|\bTopre\b', 5),
+    ('HHKB', '\bHHKB\b|\bHappy\s+Hacking\b', 10),
+    ('NovelKeys', '\bNovelKeys\b', 10),
+    ('ZealPC', '\bZeal(PC|ios)\b', 10),
+    ('Durock', '\bDurock\b', 10),
+    ('JWK', '\bJWK\b', 10)
+) AS r(name, pattern, priority)
+JOIN brands b ON b.name = r.name;
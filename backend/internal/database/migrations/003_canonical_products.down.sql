@@ -0,0 +1,13 @@
+DROP INDEX IF EXISTS idx_product_variants_sku;
+DROP INDEX IF EXISTS idx_product_variants_gtin;
+
+ALTER TABLE product_variants
+    DROP COLUMN IF EXISTS gtin;
+
+DROP INDEX IF EXISTS idx_products_canonical_id;
+
+ALTER TABLE products
+    DROP COLUMN IF EXISTS canonical_id,
+    DROP COLUMN IF EXISTS canonical_locked;
+
+DROP TABLE IF EXISTS canonical_products;
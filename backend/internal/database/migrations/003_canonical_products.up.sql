@@ -0,0 +1,24 @@
+-- Canonical products group the listings of one product across resellers.
+-- Products are matched to one when they are next saved.
+
+CREATE TABLE canonical_products (
+                                    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+                                    name TEXT NOT NULL,
+                                    brand_id UUID REFERENCES brands(id) ON DELETE SET NULL,
+                                    match_key TEXT, -- Normalised brand and name, NULL when the brand is unknown
+                                    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX idx_canonical_products_match_key ON canonical_products (match_key);
+
+ALTER TABLE products
+    ADD COLUMN canonical_id UUID REFERENCES canonical_products(id) ON DELETE SET NULL,
+    ADD COLUMN canonical_locked BOOLEAN NOT NULL DEFAULT false; -- Set by a manual override; matching leaves the product alone
+
+CREATE INDEX idx_products_canonical_id ON products (canonical_id);
+
+ALTER TABLE product_variants
+    ADD COLUMN gtin VARCHAR(14); -- EAN/UPC barcode
+
+CREATE INDEX idx_product_variants_gtin ON product_variants (gtin) WHERE gtin IS NOT NULL;
+CREATE INDEX idx_product_variants_sku ON product_variants (upper(sku)) WHERE sku IS NOT NULL AND sku != '';
@@ -0,0 +1,19 @@
+-- Price and availability of each variant, one row per change
+
+CREATE TABLE variant_price_history (
+                                       id BIGSERIAL PRIMARY KEY,
+                                       variant_id UUID NOT NULL REFERENCES product_variants(id) ON DELETE CASCADE,
+                                       product_id UUID NOT NULL REFERENCES products(id) ON DELETE CASCADE,
+                                       price DECIMAL(10,2) NOT NULL,
+                                       currency VARCHAR(3),
+                                       available BOOLEAN NOT NULL,
+                                       recorded_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX idx_variant_price_history_product ON variant_price_history (product_id, recorded_at);
+CREATE INDEX idx_variant_price_history_variant ON variant_price_history (variant_id, recorded_at);
+
+-- Start the history from what the variants are now
+INSERT INTO variant_price_history (variant_id, product_id, price, currency, available)
+SELECT id, product_id, price, currency, COALESCE(available, false)
+FROM product_variants;
@@ -0,0 +1,5 @@
+DROP INDEX IF EXISTS idx_product_variants_source;
+
+ALTER TABLE product_variants
+    DROP COLUMN IF EXISTS gone_at,
+    ALTER COLUMN source_id DROP NOT NULL;
@@ -0,0 +1,21 @@
+-- Variants are upserted by their source ID and marked gone, rather than
+-- deleted, when a scrape no longer lists them
+
+-- Variants without a source ID are identified by their name, as the
+-- scraper does for sources that give none
+UPDATE product_variants
+SET source_id = 'name-' || md5(COALESCE(name, ''))
+WHERE source_id IS NULL OR source_id = '';
+
+-- Keep one variant of each source ID per product
+DELETE FROM product_variants v
+    USING product_variants d
+WHERE v.product_id = d.product_id
+  AND v.source_id = d.source_id
+  AND v.id > d.id;
+
+ALTER TABLE product_variants
+    ALTER COLUMN source_id SET NOT NULL,
+    ADD COLUMN gone_at TIMESTAMP; -- When a scrape stopped listing the variant
+
+CREATE UNIQUE INDEX idx_product_variants_source ON product_variants (product_id, source_id);
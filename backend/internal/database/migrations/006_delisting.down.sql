@@ -0,0 +1,9 @@
+DROP INDEX IF EXISTS idx_products_config_id;
+
+ALTER TABLE product_variants
+    DROP COLUMN IF EXISTS last_seen_job_id;
+
+ALTER TABLE products
+    DROP COLUMN IF EXISTS config_id,
+    DROP COLUMN IF EXISTS last_seen_job_id,
+    DROP COLUMN IF EXISTS delisted_at;
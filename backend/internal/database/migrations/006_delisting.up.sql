@@ -0,0 +1,24 @@
+-- Products a complete scrape of their config no longer lists are marked
+-- delisted rather than deleted
+
+ALTER TABLE products
+    ADD COLUMN config_id UUID REFERENCES reseller_configs(id) ON DELETE SET NULL, -- Config that last scraped the product
+    ADD COLUMN last_seen_job_id VARCHAR(255), -- Last scrape job that listed the product
+    ADD COLUMN delisted_at TIMESTAMP;         -- When a complete scrape stopped listing the product
+
+ALTER TABLE product_variants
+    ADD COLUMN last_seen_job_id VARCHAR(255);
+
+-- Existing products belong to their reseller's config for their category,
+-- where there is only one
+UPDATE products p
+SET config_id = c.id
+FROM reseller_configs c
+WHERE c.reseller_id = p.reseller_id
+  AND c.category = p.category
+  AND NOT EXISTS (
+      SELECT 1 FROM reseller_configs o
+      WHERE o.reseller_id = c.reseller_id AND o.category = c.category AND o.id != c.id
+  );
+
+CREATE INDEX idx_products_config_id ON products (config_id) WHERE delisted_at IS NULL;
@@ -0,0 +1,12 @@
+DROP INDEX IF EXISTS idx_products_first_seen_at;
+DROP INDEX IF EXISTS idx_products_last_changed_at;
+
+ALTER TABLE product_variants
+    DROP COLUMN IF EXISTS first_seen_at,
+    DROP COLUMN IF EXISTS last_seen_at,
+    DROP COLUMN IF EXISTS last_changed_at;
+
+ALTER TABLE products
+    DROP COLUMN IF EXISTS first_seen_at,
+    DROP COLUMN IF EXISTS last_seen_at,
+    DROP COLUMN IF EXISTS last_changed_at;
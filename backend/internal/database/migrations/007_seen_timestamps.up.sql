@@ -0,0 +1,34 @@
+-- When products and variants were first listed, last listed by a scrape
+-- and last changed
+
+ALTER TABLE products
+    ADD COLUMN first_seen_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    ADD COLUMN last_seen_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    ADD COLUMN last_changed_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP;
+
+ALTER TABLE product_variants
+    ADD COLUMN first_seen_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    ADD COLUMN last_seen_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    ADD COLUMN last_changed_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP;
+
+-- Price history is the best record of existing variants' past
+UPDATE product_variants v
+SET first_seen_at = h.first_recorded, last_changed_at = h.last_recorded
+FROM (
+    SELECT variant_id, MIN(recorded_at) AS first_recorded, MAX(recorded_at) AS last_recorded
+    FROM variant_price_history
+    GROUP BY variant_id
+) h
+WHERE h.variant_id = v.id;
+
+UPDATE products p
+SET first_seen_at = v.first_seen, last_changed_at = v.last_changed
+FROM (
+    SELECT product_id, MIN(first_seen_at) AS first_seen, MAX(last_changed_at) AS last_changed
+    FROM product_variants
+    GROUP BY product_id
+) v
+WHERE v.product_id = p.id;
+
+CREATE INDEX idx_products_first_seen_at ON products (first_seen_at);
+CREATE INDEX idx_products_last_changed_at ON products (last_changed_at);
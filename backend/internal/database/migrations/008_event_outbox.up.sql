@@ -0,0 +1,18 @@
+-- Product change events, written in the transaction that made the change
+-- and published to consumers by the worker
+
+CREATE TABLE event_outbox (
+                              id BIGSERIAL PRIMARY KEY,
+                              type VARCHAR(50) NOT NULL,
+                              product_id UUID,
+                              variant_id UUID,
+                              data JSONB NOT NULL DEFAULT '{}',
+                              created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+                              attempts INTEGER NOT NULL DEFAULT 0,
+                              next_attempt_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP, -- Also holds a claimed event back from other dispatchers
+                              last_error TEXT,
+                              dispatched_at TIMESTAMP
+);
+
+CREATE INDEX idx_event_outbox_pending ON event_outbox (next_attempt_at) WHERE dispatched_at IS NULL;
+CREATE INDEX idx_event_outbox_product ON event_outbox (product_id, created_at);
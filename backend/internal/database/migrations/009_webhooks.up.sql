@@ -0,0 +1,33 @@
+-- Webhook subscriptions to product change events and every delivery made
+-- to them
+
+CREATE TABLE webhook_subscriptions (
+                                       id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+                                       url TEXT NOT NULL,
+                                       event_types TEXT[] NOT NULL,
+                                       categories TEXT[] NOT NULL DEFAULT '{}',
+                                       brands TEXT[] NOT NULL DEFAULT '{}',
+                                       resellers TEXT[] NOT NULL DEFAULT '{}',
+                                       secret TEXT NOT NULL, -- Key deliveries are signed with
+                                       created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE TABLE webhook_deliveries (
+                                    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+                                    subscription_id UUID NOT NULL REFERENCES webhook_subscriptions(id) ON DELETE CASCADE,
+                                    event_id BIGINT, -- NULL for test deliveries
+                                    event_type VARCHAR(50) NOT NULL,
+                                    payload JSONB NOT NULL,
+                                    status VARCHAR(20) NOT NULL DEFAULT 'pending', -- pending, succeeded, failed
+                                    attempts INTEGER NOT NULL DEFAULT 0,
+                                    response_status INTEGER,
+                                    last_error TEXT,
+                                    job_id VARCHAR(255),
+                                    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+                                    delivered_at TIMESTAMP
+);
+
+-- An event is delivered to a subscription once, however often it is
+-- published
+CREATE UNIQUE INDEX idx_webhook_deliveries_event ON webhook_deliveries (subscription_id, event_id);
+CREATE INDEX idx_webhook_deliveries_status ON webhook_deliveries (subscription_id, status, created_at);
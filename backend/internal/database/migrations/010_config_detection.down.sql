@@ -0,0 +1,4 @@
+ALTER TABLE reseller_configs
+    DROP COLUMN IF EXISTS detected_source_type,
+    DROP COLUMN IF EXISTS detection_confidence,
+    DROP COLUMN IF EXISTS detected_at;
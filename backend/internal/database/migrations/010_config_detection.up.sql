@@ -0,0 +1,19 @@
+-- The platform detected for AUTO configs, so it is probed once rather than
+-- on every scrape
+
+ALTER TABLE reseller_configs
+    ADD COLUMN detected_source_type VARCHAR(20),
+    ADD COLUMN detection_confidence REAL,
+    ADD COLUMN detected_at TIMESTAMP; -- NULL when carried over from the URL guess below
+
+-- AUTO configs used to be scraped with a guess from their URL, which fell
+-- back to SHOPIFY. Keep scraping them the way they were until someone asks
+-- for a detection, rather than failing the ones no probe recognises.
+UPDATE reseller_configs c
+SET detected_source_type = CASE
+        WHEN lower(c.url) LIKE '%stackskb.com%' OR lower(r.name) LIKE '%stackskb%' THEN 'STACKS'
+        ELSE 'SHOPIFY'
+    END,
+    detection_confidence = 0
+FROM resellers r
+WHERE r.id = c.reseller_id AND c.source_type = 'AUTO';
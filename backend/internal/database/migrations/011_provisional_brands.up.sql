@@ -0,0 +1,15 @@
+-- Brands registered by scrapes are provisional until an admin creates,
+-- edits or merges into them. Product names are checked against the rules
+-- of real brands before a provisional brand is taken from the raw brand,
+-- so a store whose own name was registered does not claim "GMK Olivia".
+
+ALTER TABLE brands
+    ADD COLUMN provisional BOOLEAN NOT NULL DEFAULT false;
+
+-- Brands nobody has described, aliased or given a rule were registered by
+-- scrapes
+UPDATE brands b
+SET provisional = true
+WHERE b.country IS NULL AND b.website IS NULL AND b.description IS NULL
+  AND NOT EXISTS (SELECT 1 FROM brand_aliases a WHERE a.brand_id = b.id)
+  AND NOT EXISTS (SELECT 1 FROM brand_rules r WHERE r.brand_id = b.id);
@@ -0,0 +1,192 @@
+package brand
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"unicode"
+)
+
+// Match is the canonical brand a product was filed under
+type Match struct {
+	BrandID string
+	Name    string
+}
+
+// Normalizer files products under canonical brands. The brand a source
+// gives is looked up by its key among brand names and aliases; failing
+// that, rules are tried against it and the product name. Provisional
+// brands, which scrapes registered from whatever the source gave, are
+// only looked up once the rules found nothing, since the source may have
+// given its own store name.
+type Normalizer struct {
+	mu          sync.RWMutex
+	byKey       map[string]Match
+	provisional map[string]Match
+	rules       []compiledRule
+}
+
+type compiledRule struct {
+	re       *regexp.Regexp
+	priority int
+	match    Match
+}
+
+// corporateSuffixes are left out of keys so that "GMK Electronics" and
+// "GMK" are the same brand
+var corporateSuffixes = map[string]bool{
+	"co": true, "corp": true, "corporation": true, "electronics": true,
+	"gmbh": true, "inc": true, "llc": true, "ltd": true, "limited": true,
+	"official": true,
+}
+
+// NewNormalizer builds a normalizer over brands with their aliases and
+// rules. A rule whose pattern does not compile is logged and skipped.
+func NewNormalizer(brands []Brand) *Normalizer {
+	n := &Normalizer{
+		byKey:       make(map[string]Match),
+		provisional: make(map[string]Match),
+	}
+
+	// Sorting makes collisions between unmerged duplicates resolve the
+	// same way every time
+	sorted := append([]Brand(nil), brands...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].Name < sorted[j].Name })
+
+	for _, b := range sorted {
+		n.addKey(n.keysFor(b), Key(b.Name), Match{BrandID: b.ID, Name: b.Name})
+	}
+	for _, b := range sorted {
+		match := Match{BrandID: b.ID, Name: b.Name}
+		for _, alias := range b.Aliases {
+			n.addKey(n.keysFor(b), Key(alias), match)
+		}
+		for _, rule := range b.Rules {
+			re, err := CompileRule(rule.Pattern)
+			if err != nil {
+				log.Printf("Warning: skipping brand rule %q of %s: %v", rule.Pattern, b.Name, err)
+				continue
+			}
+			n.rules = append(n.rules, compiledRule{re: re, priority: rule.Priority, match: match})
+		}
+	}
+	sort.SliceStable(n.rules, func(i, j int) bool {
+		return n.rules[i].priority > n.rules[j].priority
+	})
+
+	return n
+}
+
+// Normalize returns the canonical brand for a product, or false when
+// neither its brand nor its name identify one
+func (n *Normalizer) Normalize(rawBrand, productName string) (Match, bool) {
+	n.mu.RLock()
+	defer n.mu.RUnlock()
+
+	raw := CleanName(rawBrand)
+	if raw != "" {
+		if match, ok := n.byKey[Key(raw)]; ok {
+			return match, true
+		}
+	}
+
+	for _, rule := range n.rules {
+		if (raw != "" && rule.re.MatchString(raw)) || rule.re.MatchString(productName) {
+			return rule.match, true
+		}
+	}
+
+	if raw != "" {
+		if match, ok := n.provisional[Key(raw)]; ok {
+			return match, true
+		}
+	}
+
+	return Match{}, false
+}
+
+// Registrar adds brands the registry has not seen yet
+type Registrar interface {
+	Register(ctx context.Context, name string) (Match, error)
+}
+
+// Resolve is Normalize, registering the brand the source gives as a
+// provisional brand when it is not known yet. Products with no brand at
+// all get an empty match.
+func (n *Normalizer) Resolve(ctx context.Context, registrar Registrar, rawBrand, productName string) (Match, error) {
+	if match, ok := n.Normalize(rawBrand, productName); ok {
+		return match, nil
+	}
+
+	raw := CleanName(rawBrand)
+	if raw == "" {
+		return Match{}, nil
+	}
+
+	match, err := registrar.Register(ctx, raw)
+	if err != nil {
+		return Match{}, fmt.Errorf("failed to register brand %q: %w", raw, err)
+	}
+	n.Add(match)
+	return match, nil
+}
+
+// Add makes a brand registered after the normalizer was built known to it
+// as a provisional brand
+func (n *Normalizer) Add(match Match) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	n.addKey(n.provisional, Key(match.Name), match)
+}
+
+// keysFor returns the keys b's names are looked up in
+func (n *Normalizer) keysFor(b Brand) map[string]Match {
+	if b.Provisional {
+		return n.provisional
+	}
+	return n.byKey
+}
+
+func (n *Normalizer) addKey(keys map[string]Match, key string, match Match) {
+	if key == "" {
+		return
+	}
+	if _, exists := keys[key]; !exists {
+		keys[key] = match
+	}
+}
+
+// CleanName tidies the whitespace of a brand as scraped, returning an
+// empty string for the placeholders plugins use when there is no brand
+func CleanName(raw string) string {
+	name := strings.Join(strings.Fields(raw), " ")
+	if strings.EqualFold(name, "unknown") {
+		return ""
+	}
+	return name
+}
+
+// Key reduces a brand name to what identifies it: lower case letters and
+// digits, without corporate suffixes
+func Key(name string) string {
+	words := strings.FieldsFunc(strings.ToLower(name), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+
+	kept := words
+	for len(kept) > 1 && corporateSuffixes[kept[len(kept)-1]] {
+		kept = kept[:len(kept)-1]
+	}
+
+	return strings.Join(kept, " ")
+}
+
+// CompileRule compiles a rule pattern the way the normalizer matches it
+func CompileRule(pattern string) (*regexp.Regexp, error) {
+	return regexp.Compile("(?i)" + pattern)
+}
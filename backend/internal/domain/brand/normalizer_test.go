@@ -0,0 +1,127 @@
+package brand
+
+import (
+	"context"
+	"testing"
+)
+
+func TestKey(t *testing.T) {
+	tests := []struct {
+		name string
+		want string
+	}{
+		{"GMK", "gmk"},
+		{"GMK Electronics", "gmk"},
+		{"gmk electronics co., ltd.", "gmk"},
+		{"Wuque Studio", "wuque studio"},
+		{"Wuque-Studio", "wuque studio"},
+		{"  Keychron\tOfficial ", "keychron"},
+		{"Electronics", "electronics"}, // A suffix on its own is the name
+		{"Co Ltd", "co"},
+		{"Akko 3098", "akko 3098"},
+		{"Ducky™", "ducky"},
+		{"", ""},
+		{"---", ""},
+	}
+
+	for _, tt := range tests {
+		if got := Key(tt.name); got != tt.want {
+			t.Errorf("Key(%q) = %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestNewNormalizer(t *testing.T) {
+	n := NewNormalizer([]Brand{
+		{ID: "gmk", Name: "GMK", Aliases: []string{"GMK Electronics"}, Rules: []Rule{{Pattern: `\bGMK\b`, Priority: 10}}},
+		{ID: "epbt", Name: "ePBT", Rules: []Rule{{Pattern: `\bePBT\b`, Priority: 10}, {Pattern: `(`, Priority: 20}}},
+		{ID: "cherry", Name: "Cherry", Rules: []Rule{{Pattern: `\bCherry\b`, Priority: 5}}},
+		{ID: "gmk-cherry", Name: "GMK Cherry Profile", Rules: []Rule{{Pattern: `\bGMK\b.*\bCherry\b`, Priority: 20}}},
+		{ID: "wuque-2", Name: "Wuque-Studio"},
+		{ID: "wuque-1", Name: "Wuque Studio"},
+	})
+
+	tests := []struct {
+		rawBrand    string
+		productName string
+		want        string // Brand ID, empty when nothing matches
+	}{
+		{"GMK", "Olivia", "gmk"},
+		{"gmk electronics", "Olivia", "gmk"},            // Alias
+		{"GMK Electronics Co.", "Olivia", "gmk"},        // Alias key drops suffixes
+		{"", "GMK Olivia", "gmk"},                       // Rule on the name
+		{"Unknown", "ePBT Kuro Shiro", "epbt"},          // Placeholder brand, rule on the name
+		{"", "GMK Cherry Profile Olivia", "gmk-cherry"}, // Higher priority rule first
+		{"", "Cherry MX Black", "cherry"},
+		{"Cherry", "GMK Cherry Profile Olivia", "cherry"}, // Raw brand beats rules
+		{"", "Keyboard (", ""},                            // Pattern that does not compile is skipped
+		{"Wuque Studio", "ONI", "wuque-1"},                // Colliding keys go to the first name
+		{"Some Store", "Switch Opener", ""},
+	}
+
+	for _, tt := range tests {
+		match, ok := n.Normalize(tt.rawBrand, tt.productName)
+		if got := match.BrandID; got != tt.want || ok != (tt.want != "") {
+			t.Errorf("Normalize(%q, %q) = %q, %v, want %q", tt.rawBrand, tt.productName, got, ok, tt.want)
+		}
+	}
+}
+
+// registrar registers brands under their names as IDs
+type registrar struct {
+	registered []string
+}
+
+func (r *registrar) Register(ctx context.Context, name string) (Match, error) {
+	r.registered = append(r.registered, name)
+	return Match{BrandID: name, Name: name}, nil
+}
+
+// A store's own name registered as a brand must not claim products whose
+// names identify their real brand, whichever order they are scraped in
+func TestProvisionalBrandsAfterRules(t *testing.T) {
+	brands := []Brand{
+		{ID: "gmk", Name: "GMK", Rules: []Rule{{Pattern: `\bGMK\b`, Priority: 10}}},
+		{ID: "store", Name: "Keys Example", Provisional: true},
+	}
+
+	tests := []struct {
+		rawBrand    string
+		productName string
+		want        string
+	}{
+		{"Keys Example", "GMK Olivia", "gmk"},
+		{"Keys Example", "Switch Opener", "store"},
+		{"GMK", "Switch Opener", "gmk"},
+	}
+
+	n := NewNormalizer(brands)
+	for _, tt := range tests {
+		match, ok := n.Normalize(tt.rawBrand, tt.productName)
+		if !ok || match.BrandID != tt.want {
+			t.Errorf("Normalize(%q, %q) = %q, %v, want %q", tt.rawBrand, tt.productName, match.BrandID, ok, tt.want)
+		}
+	}
+
+	// Scraping the store's own product first registers its name; the GMK
+	// set scraped after it still goes to GMK
+	for _, order := range [][]string{{"Switch Opener", "GMK Olivia"}, {"GMK Olivia", "Switch Opener"}} {
+		n := NewNormalizer(brands[:1])
+		r := &registrar{}
+		got := make(map[string]string)
+		for _, productName := range order {
+			match, err := n.Resolve(context.Background(), r, "Another Store", productName)
+			if err != nil {
+				t.Fatalf("Resolve() error = %v", err)
+			}
+			got[productName] = match.BrandID
+		}
+
+		if got["GMK Olivia"] != "gmk" || got["Switch Opener"] != "Another Store" {
+			t.Errorf("Resolve() in order %v = %v, want GMK Olivia under gmk and Switch Opener under Another Store", order, got)
+		}
+		if len(r.registered) != 1 {
+			t.Errorf("Resolve() in order %v registered %v, want Another Store once", order, r.registered)
+		}
+	}
+}
@@ -0,0 +1,35 @@
+package brand
+
+// Brand is a canonical product manufacturer. Products found under any of
+// its aliases, or whose names match one of its rules, are filed under it.
+type Brand struct {
+	ID           string   `json:"id" db:"id"`
+	Name         string   `json:"name" db:"name"`
+	Country      string   `json:"country,omitempty" db:"country"`
+	Website      string   `json:"website,omitempty" db:"website"`
+	Description  string   `json:"description,omitempty" db:"description"`
+	Aliases      []string `json:"aliases"`
+	Rules        []Rule   `json:"rules"`
+	Provisional  bool     `json:"provisional"` // Registered by a scrape and not yet confirmed by an admin
+	ProductCount int      `json:"product_count"`
+}
+
+// Rule finds a brand in product names, for products whose source gives no
+// usable brand
+type Rule struct {
+	ID       string `json:"id,omitempty" db:"id"`
+	Pattern  string `json:"pattern" db:"pattern"`   // Case-insensitive regexp matched against the product name
+	Priority int    `json:"priority" db:"priority"` // Higher priority rules are tried first
+}
+
+// MergeRequest moves the products, aliases and rules of other brands into
+// one and deletes them
+type MergeRequest struct {
+	BrandIDs []string `json:"brand_ids"`
+}
+
+// RenormalizeResult counts the products whose brand was recomputed
+type RenormalizeResult struct {
+	Scanned int `json:"scanned"`
+	Updated int `json:"updated"`
+}
@@ -0,0 +1,102 @@
+package event
+
+import (
+	"context"
+	"math"
+	"time"
+
+	"github.com/meta-boy/mech-alligator/internal/domain/product"
+)
+
+// Type is what happened to a product
+type Type string
+
+const (
+	ProductCreated      Type = "product.created"
+	ProductDelisted     Type = "product.delisted"
+	VariantPriceChanged Type = "variant.price_changed"
+	VariantBackInStock  Type = "variant.back_in_stock"
+)
+
+// Types lists every event type
+var Types = []Type{ProductCreated, ProductDelisted, VariantPriceChanged, VariantBackInStock}
+
+// Event is a change to a product, written to the outbox in the transaction
+// that made it and published to consumers afterwards
+type Event struct {
+	ID        int64     `json:"id"`
+	Type      Type      `json:"type"`
+	Data      Data      `json:"data"`
+	CreatedAt time.Time `json:"created_at"`
+	Attempts  int       `json:"-"` // Times the dispatcher has published it, this time included
+}
+
+// Data describes the product, and for variant events the variant, as it was
+// after the change
+type Data struct {
+	ProductID     string  `json:"product_id"`
+	ProductName   string  `json:"product_name"`
+	Brand         string  `json:"brand,omitempty"`
+	Reseller      string  `json:"reseller,omitempty"`
+	Category      string  `json:"category,omitempty"`
+	URL           string  `json:"url,omitempty"`
+	VariantID     string  `json:"variant_id,omitempty"`
+	VariantName   string  `json:"variant_name,omitempty"`
+	Price         float64 `json:"price,omitempty"`
+	PreviousPrice float64 `json:"previous_price,omitempty"`
+	Currency      string  `json:"currency,omitempty"`
+}
+
+// Consumer is told about every published event. An event is published
+// again if any consumer fails, so consumers must tolerate duplicates.
+type Consumer interface {
+	Name() string
+	Consume(ctx context.Context, e *Event) error
+}
+
+// Valid reports whether t is a known event type
+func Valid(t Type) bool {
+	for _, known := range Types {
+		if t == known {
+			return true
+		}
+	}
+	return false
+}
+
+// ForProduct returns a product event
+func ForProduct(t Type, p *product.Product) Event {
+	return Event{Type: t, Data: productData(p)}
+}
+
+// VariantChanges returns the events saving a variant over its previous
+// state makes: a price change, and a return to stock
+func VariantChanges(p *product.Product, previous, current product.Variant) []Event {
+	data := productData(p)
+	data.VariantID = current.ID
+	data.VariantName = current.Name
+	data.Price = current.Price
+	data.Currency = current.Currency
+
+	var events []Event
+	if math.Round(previous.Price*100) != math.Round(current.Price*100) || previous.Currency != current.Currency {
+		priceData := data
+		priceData.PreviousPrice = previous.Price
+		events = append(events, Event{Type: VariantPriceChanged, Data: priceData})
+	}
+	if !previous.Available && current.Available {
+		events = append(events, Event{Type: VariantBackInStock, Data: data})
+	}
+	return events
+}
+
+func productData(p *product.Product) Data {
+	return Data{
+		ProductID:   p.ID,
+		ProductName: p.Name,
+		Brand:       p.Brand,
+		Reseller:    p.Reseller,
+		Category:    p.Category,
+		URL:         p.URL,
+	}
+}
@@ -18,9 +18,10 @@ const (
 type JobType string
 
 const (
-	JobTypeScrapeProducts JobType = "scrape_products"
-	JobTypeScrapeAllSites JobType = "scrape_all_sites"
-	JobTypeTagProduct     JobType = "tag_product"
+	JobTypeScrapeProducts  JobType = "scrape_products"
+	JobTypeScrapeAllSites  JobType = "scrape_all_sites"
+	JobTypeTagProduct      JobType = "tag_product"
+	JobTypeWebhookDelivery JobType = "webhook_delivery"
 )
 
 type Job struct {
@@ -62,5 +63,6 @@ type ScrapeJobPayload struct {
 	URL          string            `json:"url"`
 	SourceType   string            `json:"source_type"`
 	Category     string            `json:"category"`
+	Currency     string            `json:"currency"`
 	Options      map[string]string `json:"options,omitempty"`
 }
@@ -0,0 +1,175 @@
+package product
+
+import (
+	"sort"
+	"strings"
+	"unicode"
+
+	"github.com/meta-boy/mech-alligator/internal/domain/brand"
+)
+
+// MinOptionSimilarity is how alike the option values of two listings with
+// the same brand and name must be for them to be the same product
+const MinOptionSimilarity = 0.3
+
+// Offer is one reseller's variant of a canonical product
+type Offer struct {
+	ProductID   string            `json:"product_id"`
+	ProductName string            `json:"product_name"`
+	Reseller    string            `json:"reseller"`
+	ResellerID  string            `json:"reseller_id"`
+	URL         string            `json:"url"`
+	VariantID   string            `json:"variant_id"`
+	VariantName string            `json:"variant_name,omitempty"`
+	SKU         string            `json:"sku,omitempty"`
+	GTIN        string            `json:"gtin,omitempty"`
+	Price       float64           `json:"price"`
+	Currency    string            `json:"currency"`
+	Available   bool              `json:"available"`
+	Options     map[string]string `json:"options,omitempty"`
+}
+
+// OffersResponse lists every reseller's offers for a canonical product,
+// cheapest first
+type OffersResponse struct {
+	CanonicalID string  `json:"canonical_id,omitempty"`
+	Name        string  `json:"name"`
+	Brand       string  `json:"brand"`
+	Offers      []Offer `json:"offers"`
+	Count       int     `json:"count"`
+	Resellers   int     `json:"resellers"`
+}
+
+// CanonicalOverride files a product under a canonical product by hand.
+// Exactly one of the fields is set: the canonical product to join, another
+// product to group with, or Separate to give the product one of its own.
+type CanonicalOverride struct {
+	CanonicalID string `json:"canonical_id,omitempty"`
+	ProductID   string `json:"product_id,omitempty"`
+	Separate    bool   `json:"separate,omitempty"`
+}
+
+// noiseWords say nothing about which product a listing is
+var noiseWords = map[string]bool{
+	"a": true, "an": true, "and": true, "the": true, "with": true, "of": true, "for": true,
+	"new": true, "sale": true, "pre": true, "order": true, "preorder": true, "in": true, "stock": true,
+}
+
+// MatchKey identifies a product by its brand and the words of its name,
+// whatever their order, case and punctuation. Products of unknown brand
+// get no key, since their names alone are too generic to match on.
+func MatchKey(brandName, name string) string {
+	brandKey := brand.Key(brand.CleanName(brandName))
+	if brandKey == "" {
+		return ""
+	}
+
+	brandWords := make(map[string]bool)
+	for _, word := range strings.Fields(brandKey) {
+		brandWords[word] = true
+	}
+
+	seen := make(map[string]bool)
+	var words []string
+	for _, word := range keyWords(name) {
+		if brandWords[word] || noiseWords[word] || seen[word] {
+			continue
+		}
+		seen[word] = true
+		words = append(words, word)
+	}
+	if len(words) == 0 {
+		return ""
+	}
+
+	sort.Strings(words)
+	return brandKey + "|" + strings.Join(words, " ")
+}
+
+// GTINs returns the distinct barcodes of the variants, digits only
+func GTINs(variants []Variant) []string {
+	var gtins []string
+	seen := make(map[string]bool)
+	for _, v := range variants {
+		gtin := NormalizeGTIN(v.GTIN)
+		if gtin != "" && !seen[gtin] {
+			seen[gtin] = true
+			gtins = append(gtins, gtin)
+		}
+	}
+	return gtins
+}
+
+// NormalizeGTIN strips a barcode to its digits, returning an empty string
+// if it is not a valid length for an EAN, UPC or GTIN-14
+func NormalizeGTIN(gtin string) string {
+	digits := strings.Map(func(r rune) rune {
+		if r >= '0' && r <= '9' {
+			return r
+		}
+		return -1
+	}, gtin)
+
+	switch len(digits) {
+	case 8, 12, 13, 14:
+		if strings.Trim(digits, "0") == "" {
+			return ""
+		}
+		return digits
+	}
+	return ""
+}
+
+// SKUs returns the distinct SKUs of the variants in upper case, leaving out
+// ones too short to tell products apart
+func SKUs(variants []Variant) []string {
+	var skus []string
+	seen := make(map[string]bool)
+	for _, v := range variants {
+		sku := strings.ToUpper(strings.TrimSpace(v.SKU))
+		if len(sku) < 4 || seen[sku] {
+			continue
+		}
+		seen[sku] = true
+		skus = append(skus, sku)
+	}
+	return skus
+}
+
+// OptionValues returns the set of option values the variants come in, such
+// as switch types or colours
+func OptionValues(variants []Variant) map[string]bool {
+	values := make(map[string]bool)
+	for _, v := range variants {
+		for _, value := range v.Options {
+			value = strings.Join(keyWords(value), " ")
+			if value == "" || value == "default" || value == "default title" {
+				continue
+			}
+			values[value] = true
+		}
+	}
+	return values
+}
+
+// OptionSimilarity returns the Jaccard similarity of two sets of option
+// values, or 1 when either product has no options to compare
+func OptionSimilarity(a, b map[string]bool) float64 {
+	if len(a) == 0 || len(b) == 0 {
+		return 1
+	}
+
+	shared := 0
+	for value := range a {
+		if b[value] {
+			shared++
+		}
+	}
+	return float64(shared) / float64(len(a)+len(b)-shared)
+}
+
+func keyWords(s string) []string {
+	return strings.FieldsFunc(strings.ToLower(s), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+}
@@ -0,0 +1,120 @@
+package product
+
+import (
+	"math"
+	"reflect"
+	"testing"
+)
+
+func TestMatchKey(t *testing.T) {
+	tests := []struct {
+		brand string
+		name  string
+		want  string
+	}{
+		{"GMK", "GMK Olivia", "gmk|olivia"},
+		{"GMK Electronics", "Olivia", "gmk|olivia"},         // Brand key drops suffixes
+		{"GMK", "Olivia++ Base Kit", "gmk|base kit olivia"}, // Order and punctuation ignored
+		{"GMK", "Base Kit - Olivia++", "gmk|base kit olivia"},
+		{"GMK", "[Pre-Order] New GMK Olivia", "gmk|olivia"}, // Noise words
+		{"GMK", "The Olivia Set of Keycaps for Sale", "gmk|keycaps olivia set"},
+		{"Wuque Studio", "Wuque Studio ONI Studio", "wuque studio|oni"}, // Every brand word, repeated
+		{"Akko", "Akko 3098 3098", "akko|3098"},                         // Repeated words
+		{"GMK", "GMK", ""},                                              // Nothing but the brand
+		{"GMK", "Pre-Order", ""},                                        // Nothing but noise
+		{"", "GMK Olivia", ""},                                          // Unknown brand
+		{"Unknown", "GMK Olivia", ""},
+	}
+
+	for _, tt := range tests {
+		if got := MatchKey(tt.brand, tt.name); got != tt.want {
+			t.Errorf("MatchKey(%q, %q) = %q, want %q", tt.brand, tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestNormalizeGTIN(t *testing.T) {
+	tests := []struct {
+		gtin string
+		want string
+	}{
+		{"96385074", "96385074"},             // EAN-8
+		{"036000291452", "036000291452"},     // UPC-A
+		{"4006381333931", "4006381333931"},   // EAN-13
+		{"10036000291459", "10036000291459"}, // GTIN-14
+		{"400-6381 333931", "4006381333931"}, // Separators stripped
+		{" EAN: 4006381333931 ", "4006381333931"},
+		{"4006381", ""},         // 7 digits
+		{"40063813339", ""},     // 11 digits
+		{"400638133393123", ""}, // 15 digits
+		{"0000000000000", ""},   // All zeros
+		{"00000000", ""},
+		{"", ""},
+		{"N/A", ""},
+	}
+
+	for _, tt := range tests {
+		if got := NormalizeGTIN(tt.gtin); got != tt.want {
+			t.Errorf("NormalizeGTIN(%q) = %q, want %q", tt.gtin, got, tt.want)
+		}
+	}
+}
+
+func TestGTINs(t *testing.T) {
+	variants := []Variant{
+		{GTIN: "4006381333931"},
+		{GTIN: "400-6381-333931"},
+		{GTIN: "0000000000000"},
+		{GTIN: ""},
+		{GTIN: "036000291452"},
+	}
+
+	want := []string{"4006381333931", "036000291452"}
+	if got := GTINs(variants); !reflect.DeepEqual(got, want) {
+		t.Errorf("GTINs() = %v, want %v", got, want)
+	}
+}
+
+func TestOptionSimilarity(t *testing.T) {
+	set := func(values ...string) map[string]bool {
+		s := make(map[string]bool)
+		for _, v := range values {
+			s[v] = true
+		}
+		return s
+	}
+
+	tests := []struct {
+		name string
+		a, b map[string]bool
+		want float64
+	}{
+		{"same", set("red", "blue"), set("blue", "red"), 1},
+		{"disjoint", set("red"), set("blue"), 0},
+		{"overlap", set("red", "blue", "green"), set("blue", "green", "black"), 0.5},
+		{"subset", set("red"), set("red", "blue", "green", "black"), 0.25},
+		{"first empty", set(), set("red"), 1},
+		{"second empty", set("red"), nil, 1},
+		{"both empty", nil, nil, 1},
+	}
+
+	for _, tt := range tests {
+		if got := OptionSimilarity(tt.a, tt.b); math.Abs(got-tt.want) > 1e-9 {
+			t.Errorf("OptionSimilarity() %s = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestOptionValues(t *testing.T) {
+	variants := []Variant{
+		{Options: map[string]string{"Switch": "Gateron Oil King", "Title": "Default Title"}},
+		{Options: map[string]string{"Switch": "gateron  oil-king"}},
+		{Options: map[string]string{"Switch": "Cherry MX Black", "Layout": "Default"}},
+		{Options: nil},
+	}
+
+	want := map[string]bool{"gateron oil king": true, "cherry mx black": true}
+	if got := OptionValues(variants); !reflect.DeepEqual(got, want) {
+		t.Errorf("OptionValues() = %v, want %v", got, want)
+	}
+}
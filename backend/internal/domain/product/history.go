@@ -0,0 +1,134 @@
+package product
+
+import (
+	"math"
+	"time"
+)
+
+// Intervals price history can be downsampled to. IntervalRaw returns every
+// recorded change.
+const (
+	IntervalRaw  = "raw"
+	IntervalHour = "hour"
+	IntervalDay  = "day"
+	IntervalWeek = "week"
+)
+
+// PriceChange is a variant's price and availability from RecordedAt until
+// its next change
+type PriceChange struct {
+	VariantID   string
+	VariantName string
+	Price       float64
+	Currency    string
+	Available   bool
+	RecordedAt  time.Time
+}
+
+type PriceHistoryRequest struct {
+	VariantID string     `json:"variant_id,omitempty"`
+	From      *time.Time `json:"from,omitempty"`
+	To        *time.Time `json:"to,omitempty"`
+	Interval  string     `json:"interval"` // raw, hour, day or week
+}
+
+// PricePoint is a variant's price at At. Downsampled points give the last
+// price of their interval, with the lowest and highest it reached.
+type PricePoint struct {
+	At        time.Time `json:"at"`
+	Price     float64   `json:"price"`
+	MinPrice  float64   `json:"min_price"`
+	MaxPrice  float64   `json:"max_price"`
+	Currency  string    `json:"currency"`
+	Available bool      `json:"available"`
+}
+
+type VariantPriceHistory struct {
+	VariantID    string       `json:"variant_id"`
+	Name         string       `json:"name"`
+	LowestPrice  float64      `json:"lowest_price"`
+	HighestPrice float64      `json:"highest_price"`
+	Points       []PricePoint `json:"points"`
+}
+
+type PriceHistoryResponse struct {
+	ProductID string                `json:"product_id"`
+	From      *time.Time            `json:"from,omitempty"`
+	To        *time.Time            `json:"to,omitempty"`
+	Interval  string                `json:"interval"`
+	Variants  []VariantPriceHistory `json:"variants"`
+}
+
+// ValidInterval reports whether interval is one price history can be
+// downsampled to
+func ValidInterval(interval string) bool {
+	switch interval {
+	case IntervalRaw, IntervalHour, IntervalDay, IntervalWeek:
+		return true
+	}
+	return false
+}
+
+// BuildPriceHistory groups changes, ordered by variant and time, into a
+// history per variant. A change from before from is the price the variant
+// had when the range starts, so it is moved to from.
+func BuildPriceHistory(changes []PriceChange, from *time.Time, interval string) []VariantPriceHistory {
+	histories := []VariantPriceHistory{}
+	for _, c := range changes {
+		if len(histories) == 0 || histories[len(histories)-1].VariantID != c.VariantID {
+			histories = append(histories, VariantPriceHistory{
+				VariantID:    c.VariantID,
+				Name:         c.VariantName,
+				LowestPrice:  c.Price,
+				HighestPrice: c.Price,
+				Points:       []PricePoint{},
+			})
+		}
+		h := &histories[len(histories)-1]
+
+		at := c.RecordedAt
+		if from != nil && at.Before(*from) {
+			at = *from
+		}
+		h.LowestPrice = math.Min(h.LowestPrice, c.Price)
+		h.HighestPrice = math.Max(h.HighestPrice, c.Price)
+
+		bucket := bucketStart(at, interval)
+		if n := len(h.Points); n > 0 && interval != IntervalRaw && h.Points[n-1].At.Equal(bucket) {
+			last := &h.Points[n-1]
+			last.Price = c.Price
+			last.Currency = c.Currency
+			last.Available = c.Available
+			last.MinPrice = math.Min(last.MinPrice, c.Price)
+			last.MaxPrice = math.Max(last.MaxPrice, c.Price)
+			continue
+		}
+
+		h.Points = append(h.Points, PricePoint{
+			At:        bucket,
+			Price:     c.Price,
+			MinPrice:  c.Price,
+			MaxPrice:  c.Price,
+			Currency:  c.Currency,
+			Available: c.Available,
+		})
+	}
+
+	return histories
+}
+
+// bucketStart returns the start of the interval t falls in, in UTC. Weeks
+// start on Monday.
+func bucketStart(t time.Time, interval string) time.Time {
+	t = t.UTC()
+	switch interval {
+	case IntervalHour:
+		return t.Truncate(time.Hour)
+	case IntervalDay:
+		return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+	case IntervalWeek:
+		day := time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+		return day.AddDate(0, 0, -(int(day.Weekday())+6)%7)
+	}
+	return t
+}
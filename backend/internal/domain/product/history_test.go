@@ -0,0 +1,121 @@
+package product
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestBucketStart(t *testing.T) {
+	ist := time.FixedZone("IST", 5*60*60+30*60)
+
+	tests := []struct {
+		t        time.Time
+		interval string
+		want     time.Time
+	}{
+		{time.Date(2024, 1, 10, 14, 35, 12, 0, time.UTC), IntervalRaw, time.Date(2024, 1, 10, 14, 35, 12, 0, time.UTC)},
+		{time.Date(2024, 1, 10, 14, 35, 12, 0, time.UTC), IntervalHour, time.Date(2024, 1, 10, 14, 0, 0, 0, time.UTC)},
+		{time.Date(2024, 1, 10, 14, 35, 12, 0, time.UTC), IntervalDay, time.Date(2024, 1, 10, 0, 0, 0, 0, time.UTC)},
+		// 2024-01-08 is a Monday
+		{time.Date(2024, 1, 8, 0, 0, 0, 0, time.UTC), IntervalWeek, time.Date(2024, 1, 8, 0, 0, 0, 0, time.UTC)},
+		{time.Date(2024, 1, 10, 14, 35, 12, 0, time.UTC), IntervalWeek, time.Date(2024, 1, 8, 0, 0, 0, 0, time.UTC)},
+		{time.Date(2024, 1, 14, 23, 59, 59, 0, time.UTC), IntervalWeek, time.Date(2024, 1, 8, 0, 0, 0, 0, time.UTC)}, // Sunday ends the week
+		{time.Date(2024, 1, 15, 0, 0, 0, 0, time.UTC), IntervalWeek, time.Date(2024, 1, 15, 0, 0, 0, 0, time.UTC)},
+		{time.Date(2024, 1, 1, 9, 0, 0, 0, time.UTC), IntervalWeek, time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)},
+		{time.Date(2023, 12, 31, 9, 0, 0, 0, time.UTC), IntervalWeek, time.Date(2023, 12, 25, 0, 0, 0, 0, time.UTC)}, // Across a year
+		// Buckets are in UTC: Monday 03:00 in India is still Sunday in UTC
+		{time.Date(2024, 1, 15, 3, 0, 0, 0, ist), IntervalWeek, time.Date(2024, 1, 8, 0, 0, 0, 0, time.UTC)},
+		{time.Date(2024, 1, 15, 3, 0, 0, 0, ist), IntervalDay, time.Date(2024, 1, 14, 0, 0, 0, 0, time.UTC)},
+	}
+
+	for _, tt := range tests {
+		if got := bucketStart(tt.t, tt.interval); !got.Equal(tt.want) {
+			t.Errorf("bucketStart(%v, %q) = %v, want %v", tt.t, tt.interval, got, tt.want)
+		}
+	}
+}
+
+func TestBuildPriceHistory(t *testing.T) {
+	at := func(day, hour int) time.Time {
+		return time.Date(2024, 1, day, hour, 0, 0, 0, time.UTC)
+	}
+	from := at(10, 0)
+
+	changes := []PriceChange{
+		// Price v1 had when the range starts
+		{VariantID: "v1", VariantName: "Base", Price: 100, Currency: "INR", Available: true, RecordedAt: at(2, 9)},
+		{VariantID: "v1", Price: 90, Currency: "INR", Available: true, RecordedAt: at(10, 9)},
+		{VariantID: "v1", Price: 120, Currency: "INR", Available: true, RecordedAt: at(10, 12)},
+		{VariantID: "v1", Price: 110, Currency: "INR", Available: false, RecordedAt: at(10, 18)},
+		{VariantID: "v1", Price: 80, Currency: "INR", Available: true, RecordedAt: at(12, 9)},
+		{VariantID: "v2", VariantName: "Novelties", Price: 50, Currency: "INR", Available: true, RecordedAt: at(11, 9)},
+	}
+
+	tests := []struct {
+		name     string
+		from     *time.Time
+		interval string
+		want     []VariantPriceHistory
+	}{
+		{
+			name:     "raw moves the carried over change to from",
+			from:     &from,
+			interval: IntervalRaw,
+			want: []VariantPriceHistory{
+				{VariantID: "v1", Name: "Base", LowestPrice: 80, HighestPrice: 120, Points: []PricePoint{
+					{At: from, Price: 100, MinPrice: 100, MaxPrice: 100, Currency: "INR", Available: true},
+					{At: at(10, 9), Price: 90, MinPrice: 90, MaxPrice: 90, Currency: "INR", Available: true},
+					{At: at(10, 12), Price: 120, MinPrice: 120, MaxPrice: 120, Currency: "INR", Available: true},
+					{At: at(10, 18), Price: 110, MinPrice: 110, MaxPrice: 110, Currency: "INR", Available: false},
+					{At: at(12, 9), Price: 80, MinPrice: 80, MaxPrice: 80, Currency: "INR", Available: true},
+				}},
+				{VariantID: "v2", Name: "Novelties", LowestPrice: 50, HighestPrice: 50, Points: []PricePoint{
+					{At: at(11, 9), Price: 50, MinPrice: 50, MaxPrice: 50, Currency: "INR", Available: true},
+				}},
+			},
+		},
+		{
+			name:     "day keeps the last price of each day with its range",
+			from:     &from,
+			interval: IntervalDay,
+			want: []VariantPriceHistory{
+				{VariantID: "v1", Name: "Base", LowestPrice: 80, HighestPrice: 120, Points: []PricePoint{
+					// The carried over 100 falls in the first day with the day's changes
+					{At: at(10, 0), Price: 110, MinPrice: 90, MaxPrice: 120, Currency: "INR", Available: false},
+					{At: at(12, 0), Price: 80, MinPrice: 80, MaxPrice: 80, Currency: "INR", Available: true},
+				}},
+				{VariantID: "v2", Name: "Novelties", LowestPrice: 50, HighestPrice: 50, Points: []PricePoint{
+					{At: at(11, 0), Price: 50, MinPrice: 50, MaxPrice: 50, Currency: "INR", Available: true},
+				}},
+			},
+		},
+		{
+			name:     "week starts on Monday",
+			interval: IntervalWeek,
+			want: []VariantPriceHistory{
+				{VariantID: "v1", Name: "Base", LowestPrice: 80, HighestPrice: 120, Points: []PricePoint{
+					{At: at(1, 0), Price: 100, MinPrice: 100, MaxPrice: 100, Currency: "INR", Available: true},
+					{At: at(8, 0), Price: 80, MinPrice: 80, MaxPrice: 120, Currency: "INR", Available: true},
+				}},
+				{VariantID: "v2", Name: "Novelties", LowestPrice: 50, HighestPrice: 50, Points: []PricePoint{
+					{At: at(8, 0), Price: 50, MinPrice: 50, MaxPrice: 50, Currency: "INR", Available: true},
+				}},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		got := BuildPriceHistory(changes, tt.from, tt.interval)
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("BuildPriceHistory() %s =\n%+v\nwant\n%+v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestBuildPriceHistoryEmpty(t *testing.T) {
+	got := BuildPriceHistory(nil, nil, IntervalDay)
+	if got == nil || len(got) != 0 {
+		t.Errorf("BuildPriceHistory(nil) = %#v, want an empty list", got)
+	}
+}
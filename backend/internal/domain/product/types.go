@@ -1,23 +1,35 @@
 package product
 
+import "time"
+
 type Product struct {
-	ID           string    `json:"id" db:"id"`
-	Name         string    `json:"name" db:"name"`
-	Description  string    `json:"description" db:"description"`
-	Handle       string    `json:"handle" db:"handle"` // URL-friendly identifier
-	URL          string    `json:"url" db:"url"`
-	Brand        string    `json:"brand" db:"brand"`       // Actual product brand/vendor (Wuque Studio, GMK, etc.)
-	Reseller     string    `json:"reseller" db:"reseller"` // Where it was scraped from (StacksKB, Meckeys, etc.)
-	Category     string    `json:"category" db:"category"` // KEYBOARD, KEYCAPS, SWITCHES, etc.
-	Tags         []string  `json:"tags" db:"tags"`
-	Images       []string  `json:"images" db:"images"`
-	Variants     []Variant `json:"variants,omitempty"`
-	VariantCount int       `json:"variant_count" db:"variant_count"`
+	ID           string     `json:"id" db:"id"`
+	Name         string     `json:"name" db:"name"`
+	Description  string     `json:"description" db:"description"`
+	Handle       string     `json:"handle" db:"handle"` // URL-friendly identifier
+	URL          string     `json:"url" db:"url"`
+	Brand        string     `json:"brand" db:"brand"`                   // Actual product brand/vendor (Wuque Studio, GMK, etc.)
+	BrandID      string     `json:"brand_id,omitempty" db:"brand_id"`   // Canonical brand, empty when none was recognised
+	RawBrand     string     `json:"raw_brand,omitempty" db:"raw_brand"` // Brand as the source gave it
+	Reseller     string     `json:"reseller" db:"reseller"`             // Where it was scraped from (StacksKB, Meckeys, etc.)
+	Category     string     `json:"category" db:"category"`             // KEYBOARD, KEYCAPS, SWITCHES, etc.
+	Tags         []string   `json:"tags" db:"tags"`
+	Images       []string   `json:"images" db:"images"`
+	Variants     []Variant  `json:"variants,omitempty"`
+	VariantCount int        `json:"variant_count" db:"variant_count"`
+	CanonicalID  string     `json:"canonical_id,omitempty" db:"canonical_id"` // Groups the listings of this product across resellers
+	DelistedAt   *time.Time `json:"delisted_at,omitempty" db:"delisted_at"`   // When a complete scrape stopped listing the product
+
+	FirstSeenAt   time.Time `json:"first_seen_at" db:"first_seen_at"`
+	LastSeenAt    time.Time `json:"last_seen_at" db:"last_seen_at"`       // Last saved from a scrape
+	LastChangedAt time.Time `json:"last_changed_at" db:"last_changed_at"` // Last time saving changed the product or its variants
 
 	// Source tracking
-	SourceType     string            `json:"source_type" db:"source_type"` // SHOPIFY, WORDPRESS, etc.
-	SourceID       string            `json:"source_id" db:"source_id"`     // Original ID from source
-	ResellerID     string            `json:"reseller_id" db:"reseller_id"` // Config/reseller ID
+	SourceType     string            `json:"source_type" db:"source_type"`       // SHOPIFY, WORDPRESS, etc.
+	SourceID       string            `json:"source_id" db:"source_id"`           // Original ID from source
+	ResellerID     string            `json:"reseller_id" db:"reseller_id"`       // Config/reseller ID
+	ConfigID       string            `json:"config_id,omitempty" db:"config_id"` // Reseller config that scraped the product
+	ScrapeJobID    string            `json:"-" db:"last_seen_job_id"`            // Scrape run saving the product: job ID and attempt
 	SourceMetadata map[string]string `json:"source_metadata,omitempty" db:"source_metadata"`
 }
 
@@ -26,6 +38,7 @@ type Variant struct {
 	ProductID string   `json:"product_id" db:"product_id"`
 	Name      string   `json:"name" db:"name"`
 	SKU       string   `json:"sku,omitempty" db:"sku"`
+	GTIN      string   `json:"gtin,omitempty" db:"gtin"` // EAN/UPC barcode
 	Price     float64  `json:"price" db:"price"`
 	Currency  string   `json:"currency" db:"currency"`
 	Available bool     `json:"available" db:"available"`
@@ -35,10 +48,42 @@ type Variant struct {
 	// Variant options (color, size, etc.)
 	Options map[string]string `json:"options,omitempty" db:"options"`
 
+	FirstSeenAt   time.Time `json:"first_seen_at" db:"first_seen_at"`
+	LastSeenAt    time.Time `json:"last_seen_at" db:"last_seen_at"`
+	LastChangedAt time.Time `json:"last_changed_at" db:"last_changed_at"`
+
 	// Source tracking
 	SourceID string `json:"source_id" db:"source_id"` // Original variant ID from source
 }
 
+// SaveStatus says whether saving a scraped product created it, changed it
+// or found it as it was
+type SaveStatus string
+
+const (
+	SaveCreated   SaveStatus = "created"
+	SaveUpdated   SaveStatus = "updated"
+	SaveUnchanged SaveStatus = "unchanged"
+)
+
+// SaveOutcome is what saving a scraped product changed
+type SaveOutcome struct {
+	Status            SaveStatus
+	VariantsCreated   int
+	VariantsUpdated   int
+	VariantsUnchanged int
+	VariantsGone      int // Stored variants the scrape no longer lists
+}
+
+// BrandSource is what a product's brand is worked out from
+type BrandSource struct {
+	ID       string
+	Name     string
+	RawBrand string
+	Brand    string
+	BrandID  string
+}
+
 // Request/Response types for API
 type ListRequest struct {
 	Search    string   `json:"search,omitempty"`
@@ -50,13 +95,16 @@ type ListRequest struct {
 	MaxPrice  *float64 `json:"max_price,omitempty"`
 	Available *bool    `json:"available,omitempty"` // Filter by availability
 
+	IncludeDelisted bool       `json:"include_delisted,omitempty"` // Also list products resellers no longer list
+	Since           *time.Time `json:"since,omitempty"`            // Only products first seen since
+
 	// Pagination
 	Page     int `json:"page"`
 	PageSize int `json:"page_size"`
 
 	// Sorting
-	SortBy    string `json:"sort_by"`    // name, price, brand, reseller
-	SortOrder string `json:"sort_order"` // asc, desc
+	SortBy    string `json:"sort_by"`    // name, price, brand, reseller, newest, recently_updated
+	SortOrder string `json:"sort_order"` // asc, desc; desc by default for newest and recently_updated
 }
 
 type ProductListResponse struct {
@@ -86,5 +134,8 @@ func (r *ListRequest) Validate() {
 	}
 	if r.SortOrder != "asc" && r.SortOrder != "desc" {
 		r.SortOrder = "asc"
+		if r.SortBy == "newest" || r.SortBy == "recently_updated" {
+			r.SortOrder = "desc"
+		}
 	}
 }
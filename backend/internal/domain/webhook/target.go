@@ -0,0 +1,70 @@
+package webhook
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
+	"syscall"
+)
+
+// ErrBlockedAddress is returned for subscription URLs that point into the
+// network the server runs in rather than at the internet
+var ErrBlockedAddress = errors.New("address is not publicly routable")
+
+// sharedAddressSpace is the carrier-grade NAT range, private in all but name
+var sharedAddressSpace = &net.IPNet{IP: net.IPv4(100, 64, 0, 0), Mask: net.CIDRMask(10, 32)}
+
+// AllowedIP reports whether deliveries may be sent to ip. Loopback,
+// private, link-local, unspecified and multicast addresses are refused so
+// a subscription cannot be used to reach services behind the server.
+func AllowedIP(ip net.IP) bool {
+	return !(ip.IsLoopback() || ip.IsPrivate() || ip.IsUnspecified() ||
+		ip.IsLinkLocalUnicast() || ip.IsLinkLocalMulticast() || ip.IsInterfaceLocalMulticast() ||
+		ip.IsMulticast() || sharedAddressSpace.Contains(ip))
+}
+
+// CheckURL checks that rawURL is an absolute http(s) URL whose host only
+// resolves to allowed addresses
+func CheckURL(ctx context.Context, rawURL string) error {
+	u, err := url.Parse(rawURL)
+	if err != nil || u.Hostname() == "" || (u.Scheme != "http" && u.Scheme != "https") {
+		return fmt.Errorf("must be an absolute http(s) URL")
+	}
+
+	host := u.Hostname()
+	if ip := net.ParseIP(host); ip != nil {
+		if !AllowedIP(ip) {
+			return fmt.Errorf("%s: %w", host, ErrBlockedAddress)
+		}
+		return nil
+	}
+
+	addrs, err := net.DefaultResolver.LookupIPAddr(ctx, host)
+	if err != nil {
+		return fmt.Errorf("cannot resolve %s", host)
+	}
+	for _, addr := range addrs {
+		if !AllowedIP(addr.IP) {
+			return fmt.Errorf("%s resolves to %s: %w", host, addr.IP, ErrBlockedAddress)
+		}
+	}
+	return nil
+}
+
+// DialControl is a net.Dialer Control hook refusing connections to
+// addresses AllowedIP rejects. It runs after DNS resolution, on the
+// address actually dialled, so a host that resolved to a public address
+// when the subscription was created cannot be rebound to a private one.
+func DialControl(network, address string, c syscall.RawConn) error {
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return err
+	}
+	ip := net.ParseIP(host)
+	if ip == nil || !AllowedIP(ip) {
+		return fmt.Errorf("%s: %w", host, ErrBlockedAddress)
+	}
+	return nil
+}
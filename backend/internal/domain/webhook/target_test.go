@@ -0,0 +1,104 @@
+package webhook
+
+import (
+	"context"
+	"errors"
+	"net"
+	"testing"
+)
+
+func TestAllowedIP(t *testing.T) {
+	tests := []struct {
+		ip   string
+		want bool
+	}{
+		{"93.184.216.34", true},
+		{"8.8.8.8", true},
+		{"2606:2800:220:1:248:1893:25c8:1946", true},
+		{"127.0.0.1", false},
+		{"127.10.0.1", false},
+		{"::1", false},
+		{"10.1.2.3", false},
+		{"172.16.0.1", false},
+		{"172.31.255.255", false},
+		{"172.32.0.1", true},
+		{"192.168.1.1", false},
+		{"fd00::1", false},
+		{"169.254.169.254", false}, // Cloud metadata service
+		{"fe80::1", false},
+		{"0.0.0.0", false},
+		{"::", false},
+		{"224.0.0.1", false},
+		{"ff02::1", false},
+		{"100.64.0.1", false},
+		{"100.128.0.1", true},
+		{"::ffff:127.0.0.1", false}, // IPv4-mapped loopback
+		{"::ffff:10.0.0.1", false},
+	}
+
+	for _, tt := range tests {
+		if got := AllowedIP(net.ParseIP(tt.ip)); got != tt.want {
+			t.Errorf("AllowedIP(%s) = %v, want %v", tt.ip, got, tt.want)
+		}
+	}
+}
+
+func TestCheckURL(t *testing.T) {
+	tests := []struct {
+		url     string
+		blocked bool // Rejected as pointing into the server's network
+		ok      bool
+	}{
+		{"https://93.184.216.34/hooks", false, true},
+		{"http://[2606:2800:220:1:248:1893:25c8:1946]:8080/hooks", false, true},
+		{"http://127.0.0.1:8080/hooks", true, false},
+		{"http://[::1]/hooks", true, false},
+		{"http://169.254.169.254/latest/meta-data/", true, false},
+		{"http://10.0.0.5/hooks", true, false},
+		{"http://localhost:8080/hooks", true, false},
+		{"ftp://93.184.216.34/hooks", false, false},
+		{"/hooks", false, false},
+		{"not a url", false, false},
+	}
+
+	for _, tt := range tests {
+		err := CheckURL(context.Background(), tt.url)
+		if (err == nil) != tt.ok {
+			t.Errorf("CheckURL(%q) error = %v, want ok %v", tt.url, err, tt.ok)
+		}
+		if got := errors.Is(err, ErrBlockedAddress); got != tt.blocked {
+			t.Errorf("CheckURL(%q) error = %v, want blocked %v", tt.url, err, tt.blocked)
+		}
+	}
+}
+
+func TestDialControl(t *testing.T) {
+	tests := []struct {
+		address string
+		ok      bool
+	}{
+		{"93.184.216.34:443", true},
+		{"[2606:2800:220:1:248:1893:25c8:1946]:443", true},
+		{"127.0.0.1:80", false},
+		{"[::1]:80", false},
+		{"169.254.169.254:80", false},
+		{"192.168.0.10:8080", false},
+		{"example.com:80", false}, // Only resolved addresses are dialled
+		{"127.0.0.1", false},
+	}
+
+	for _, tt := range tests {
+		if err := DialControl("tcp", tt.address, nil); (err == nil) != tt.ok {
+			t.Errorf("DialControl(%q) error = %v, want ok %v", tt.address, err, tt.ok)
+		}
+	}
+
+	// A rebound or redirected host is refused when it is dialled
+	dialer := &net.Dialer{Control: DialControl}
+	if conn, err := dialer.Dial("tcp", "127.0.0.1:9"); err == nil || !errors.Is(err, ErrBlockedAddress) {
+		if conn != nil {
+			conn.Close()
+		}
+		t.Errorf("Dial(127.0.0.1:9) error = %v, want %v", err, ErrBlockedAddress)
+	}
+}
@@ -0,0 +1,104 @@
+package webhook
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/meta-boy/mech-alligator/internal/domain/event"
+)
+
+// TestEvent is the type of the event sent to check a subscription works
+const TestEvent event.Type = "webhook.test"
+
+// MaxAttempts is how often a delivery is sent before it is marked failed
+const MaxAttempts = 5
+
+// Headers sent with every delivery
+const (
+	EventHeader     = "X-Webhook-Event"
+	DeliveryHeader  = "X-Webhook-Delivery"
+	TimestampHeader = "X-Webhook-Timestamp"
+	SignatureHeader = "X-Webhook-Signature"
+)
+
+// Subscription asks for events of the given types to be posted to URL.
+// Each filter that is set must match the event's product, ignoring case.
+type Subscription struct {
+	ID         string       `json:"id"`
+	URL        string       `json:"url"`
+	EventTypes []event.Type `json:"event_types"`
+	Categories []string     `json:"categories,omitempty"`
+	Brands     []string     `json:"brands,omitempty"`
+	Resellers  []string     `json:"resellers,omitempty"`
+	Secret     string       `json:"secret,omitempty"` // Only returned when the subscription is created
+	CreatedAt  time.Time    `json:"created_at"`
+}
+
+type DeliveryStatus string
+
+const (
+	DeliveryPending   DeliveryStatus = "pending"
+	DeliverySucceeded DeliveryStatus = "succeeded"
+	DeliveryFailed    DeliveryStatus = "failed"
+)
+
+// Delivery is one event sent, or to be sent, to one subscription
+type Delivery struct {
+	ID             string          `json:"id"`
+	SubscriptionID string          `json:"subscription_id"`
+	EventID        int64           `json:"event_id,omitempty"` // Unset for test deliveries
+	EventType      event.Type      `json:"event_type"`
+	Payload        json.RawMessage `json:"payload"`
+	Status         DeliveryStatus  `json:"status"`
+	Attempts       int             `json:"attempts"`
+	ResponseStatus int             `json:"response_status,omitempty"` // HTTP status of the last attempt
+	LastError      string          `json:"last_error,omitempty"`
+	JobID          string          `json:"job_id,omitempty"` // Job sending the delivery
+	CreatedAt      time.Time       `json:"created_at"`
+	DeliveredAt    *time.Time      `json:"delivered_at,omitempty"`
+}
+
+// Matches reports whether the subscription wants the event
+func (s *Subscription) Matches(e *event.Event) bool {
+	wanted := false
+	for _, t := range s.EventTypes {
+		if t == e.Type {
+			wanted = true
+			break
+		}
+	}
+
+	return wanted &&
+		matchesAny(s.Categories, e.Data.Category) &&
+		matchesAny(s.Brands, e.Data.Brand) &&
+		matchesAny(s.Resellers, e.Data.Reseller)
+}
+
+// Sign returns the signature of a delivery body sent at timestamp: the hex
+// HMAC-SHA256, keyed with the subscription's secret, of the Unix timestamp,
+// a dot and the body
+func Sign(secret string, timestamp int64, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(strconv.FormatInt(timestamp, 10)))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// matchesAny reports whether value is one of filter, or filter is empty
+func matchesAny(filter []string, value string) bool {
+	if len(filter) == 0 {
+		return true
+	}
+	for _, f := range filter {
+		if strings.EqualFold(f, value) {
+			return true
+		}
+	}
+	return false
+}
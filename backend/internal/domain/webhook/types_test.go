@@ -0,0 +1,83 @@
+package webhook
+
+import (
+	"testing"
+
+	"github.com/meta-boy/mech-alligator/internal/domain/event"
+)
+
+func TestSign(t *testing.T) {
+	tests := []struct {
+		secret    string
+		timestamp int64
+		body      string
+		want      string
+	}{
+		{"s3cret", 1700000000, `{"id":1}`, "sha256=ee0658aa4e37018df69c24227df01e0f680eb3b87c7f1f9bd936e283cfe01d9b"},
+		{"", 0, "", "sha256=b849d5a581847b281957065739df36df2463d1977ea8d6e1e4e6cf33fadc68c3"},
+	}
+
+	for _, tt := range tests {
+		if got := Sign(tt.secret, tt.timestamp, []byte(tt.body)); got != tt.want {
+			t.Errorf("Sign(%q, %d, %q) = %q, want %q", tt.secret, tt.timestamp, tt.body, got, tt.want)
+		}
+	}
+
+	// Every part of the signed message changes the signature
+	base := Sign("s3cret", 1700000000, []byte(`{"id":1}`))
+	for name, got := range map[string]string{
+		"secret":    Sign("other", 1700000000, []byte(`{"id":1}`)),
+		"timestamp": Sign("s3cret", 1700000001, []byte(`{"id":1}`)),
+		"body":      Sign("s3cret", 1700000000, []byte(`{"id":2}`)),
+	} {
+		if got == base {
+			t.Errorf("Sign() with a different %s gave the same signature", name)
+		}
+	}
+}
+
+func TestSubscriptionMatches(t *testing.T) {
+	priceChange := &event.Event{
+		Type: event.VariantPriceChanged,
+		Data: event.Data{Category: "KEYCAPS", Brand: "GMK", Reseller: "StacksKB"},
+	}
+
+	tests := []struct {
+		name         string
+		subscription Subscription
+		want         bool
+	}{
+		{"type only", Subscription{EventTypes: []event.Type{event.VariantPriceChanged}}, true},
+		{"one of several types", Subscription{EventTypes: []event.Type{event.ProductCreated, event.VariantPriceChanged}}, true},
+		{"other type", Subscription{EventTypes: []event.Type{event.ProductCreated}}, false},
+		{"no types", Subscription{Categories: []string{"KEYCAPS"}}, false},
+		{"category ignoring case", Subscription{EventTypes: []event.Type{event.VariantPriceChanged}, Categories: []string{"keycaps"}}, true},
+		{"one of several categories", Subscription{EventTypes: []event.Type{event.VariantPriceChanged}, Categories: []string{"SWITCHES", "KEYCAPS"}}, true},
+		{"other category", Subscription{EventTypes: []event.Type{event.VariantPriceChanged}, Categories: []string{"SWITCHES"}}, false},
+		{"every filter matches", Subscription{
+			EventTypes: []event.Type{event.VariantPriceChanged},
+			Categories: []string{"KEYCAPS"},
+			Brands:     []string{"gmk"},
+			Resellers:  []string{"StacksKB"},
+		}, true},
+		{"one filter misses", Subscription{
+			EventTypes: []event.Type{event.VariantPriceChanged},
+			Categories: []string{"KEYCAPS"},
+			Brands:     []string{"ePBT"},
+			Resellers:  []string{"StacksKB"},
+		}, false},
+	}
+
+	for _, tt := range tests {
+		if got := tt.subscription.Matches(priceChange); got != tt.want {
+			t.Errorf("Matches() %s = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+
+	// Filters need the event to carry the field they filter on
+	noBrand := &event.Event{Type: event.VariantPriceChanged, Data: event.Data{Category: "KEYCAPS"}}
+	s := Subscription{EventTypes: []event.Type{event.VariantPriceChanged}, Brands: []string{"GMK"}}
+	if s.Matches(noBrand) {
+		t.Errorf("Matches() with a brand filter matched an event without a brand")
+	}
+}
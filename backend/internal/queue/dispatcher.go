@@ -0,0 +1,147 @@
+package queue
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/meta-boy/mech-alligator/internal/domain/event"
+	"github.com/meta-boy/mech-alligator/internal/repository/postgres"
+)
+
+const (
+	// dispatchBatchSize is how many events one pass claims
+	dispatchBatchSize = 100
+	// maxDispatchAttempts is how often an event is published before it is
+	// left in the outbox with its last error
+	maxDispatchAttempts = 10
+	// dispatchLease holds claimed events back from other dispatchers while
+	// they are published
+	dispatchLease = 5 * time.Minute
+)
+
+// EventDispatcher publishes the events in the outbox to every registered
+// consumer, oldest first. An event a consumer fails is published to all of
+// them again after a backoff, so each sees it at least once.
+type EventDispatcher struct {
+	events    *postgres.EventRepository
+	consumers []event.Consumer
+	interval  time.Duration
+	stopCh    chan struct{}
+	wg        sync.WaitGroup
+	mu        sync.RWMutex
+}
+
+func NewEventDispatcher(events *postgres.EventRepository, interval time.Duration) *EventDispatcher {
+	return &EventDispatcher{
+		events:   events,
+		interval: interval,
+		stopCh:   make(chan struct{}),
+	}
+}
+
+func (d *EventDispatcher) RegisterConsumer(consumer event.Consumer) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.consumers = append(d.consumers, consumer)
+}
+
+func (d *EventDispatcher) Start(ctx context.Context) error {
+	log.Printf("Starting event dispatcher with %d consumers", len(d.consumers))
+
+	d.wg.Add(1)
+	go d.run(ctx)
+
+	return nil
+}
+
+func (d *EventDispatcher) Stop() error {
+	close(d.stopCh)
+	d.wg.Wait()
+	log.Println("Event dispatcher stopped")
+	return nil
+}
+
+func (d *EventDispatcher) run(ctx context.Context) {
+	defer d.wg.Done()
+
+	ticker := time.NewTicker(d.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-d.stopCh:
+			return
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			// Keep going while there is a backlog
+			for ctx.Err() == nil {
+				if d.dispatchPending(ctx) < dispatchBatchSize {
+					break
+				}
+			}
+		}
+	}
+}
+
+// dispatchPending publishes one batch of due events, returning how many
+// there were
+func (d *EventDispatcher) dispatchPending(ctx context.Context) int {
+	events, err := d.events.ClaimPending(ctx, dispatchBatchSize, maxDispatchAttempts, dispatchLease)
+	if err != nil {
+		log.Printf("Event dispatcher: failed to claim events: %v", err)
+		return 0
+	}
+
+	for i := range events {
+		e := &events[i]
+		if err := d.publish(ctx, e); err != nil {
+			backoff := time.Duration(e.Attempts*e.Attempts) * time.Minute
+			log.Printf("Event %d (%s) failed, retrying in %v: %v", e.ID, e.Type, backoff, err)
+			if err := d.events.MarkFailed(ctx, e.ID, err.Error(), backoff); err != nil {
+				log.Printf("Failed to update failed event %d: %v", e.ID, err)
+			}
+			continue
+		}
+
+		if err := d.events.MarkDispatched(ctx, e.ID); err != nil {
+			log.Printf("Failed to mark event %d dispatched: %v", e.ID, err)
+		}
+	}
+
+	return len(events)
+}
+
+// publish hands the event to every consumer, returning the failures
+func (d *EventDispatcher) publish(ctx context.Context, e *event.Event) error {
+	d.mu.RLock()
+	consumers := d.consumers
+	d.mu.RUnlock()
+
+	var failures []string
+	for _, consumer := range consumers {
+		if err := consumer.Consume(ctx, e); err != nil {
+			failures = append(failures, fmt.Sprintf("%s: %v", consumer.Name(), err))
+		}
+	}
+	if len(failures) > 0 {
+		return fmt.Errorf("%s", strings.Join(failures, "; "))
+	}
+	return nil
+}
+
+// LogConsumer logs every event
+type LogConsumer struct{}
+
+func (LogConsumer) Name() string {
+	return "log"
+}
+
+func (LogConsumer) Consume(ctx context.Context, e *event.Event) error {
+	log.Printf("Event %d: %s %s %q", e.ID, e.Type, e.Data.ProductID, e.Data.ProductName)
+	return nil
+}
@@ -5,42 +5,45 @@ import (
 	"encoding/json"
 	"fmt"
 	"log"
-	"strings"
+	"math"
+	"strconv"
 	"time"
 
 	"github.com/meta-boy/mech-alligator/internal/config"
 	"github.com/meta-boy/mech-alligator/internal/database"
+	"github.com/meta-boy/mech-alligator/internal/domain/brand"
 	"github.com/meta-boy/mech-alligator/internal/domain/job"
 	"github.com/meta-boy/mech-alligator/internal/domain/product"
 	"github.com/meta-boy/mech-alligator/internal/repository/postgres"
 	"github.com/meta-boy/mech-alligator/internal/scraper"
-	"github.com/meta-boy/mech-alligator/internal/scraper/plugins/shopify"
-	"github.com/meta-boy/mech-alligator/internal/scraper/plugins/stackskb"
+	"github.com/meta-boy/mech-alligator/internal/scraper/plugins"
 )
 
+// saveBufferSize is how many scraped products may wait to be saved before
+// the scraper is made to wait
+const saveBufferSize = 50
+
+// maxDelistShare is the largest share of a config's listed products one
+// scrape may delist. A run that misses more than that is more likely broken
+// than the reseller emptying its catalog.
+const maxDelistShare = 0.5
+
 type ScrapeJobHandler struct {
 	db          *database.DB
 	manager     *scraper.Manager
 	productRepo *postgres.ProductRepository
+	brandRepo   *postgres.BrandRepository
 }
 
-func NewScrapeJobHandler(db *database.DB, productRepo *postgres.ProductRepository) *ScrapeJobHandler {
-	// Initialize scraper manager with plugins
-	manager := scraper.NewManager()
-
-	// Register plugins
-	if err := manager.RegisterPlugin(shopify.NewShopifyPlugin()); err != nil {
-		log.Printf("Warning: Failed to register Shopify plugin: %v", err)
-	}
-
-	if err := manager.RegisterPlugin(stackskb.NewStacksKBPlugin()); err != nil {
-		log.Printf("Warning: Failed to register StacksKB plugin: %v", err)
-	}
+func NewScrapeJobHandler(db *database.DB, productRepo *postgres.ProductRepository, brandRepo *postgres.BrandRepository, fetcher *scraper.Fetcher, concurrency int) *ScrapeJobHandler {
+	manager := plugins.NewManager(fetcher)
+	manager.SetConcurrency(concurrency)
 
 	return &ScrapeJobHandler{
 		db:          db,
 		manager:     manager,
 		productRepo: productRepo,
+		brandRepo:   brandRepo,
 	}
 }
 
@@ -69,36 +72,83 @@ func (h *ScrapeJobHandler) Handle(ctx context.Context, j *job.Job) error {
 		Reseller:   payload.ResellerName,
 		ResellerID: payload.ResellerID,
 		Category:   payload.Category,
+		Currency:   payload.Currency,
 		Options:    payload.Options,
 	}
 
+	// Brands registered while saving are added to this job's copy of the
+	// registry
+	brands, err := h.brandRepo.List(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to load brand registry: %w", err)
+	}
+	normalizer := brand.NewNormalizer(brands)
+
 	start := time.Now()
 	log.Printf("Starting scrape of %s (%s)", payload.URL, payload.SourceType)
 
+	// Save products while the scrape is still running. The bounded channel
+	// holds the scraper back when saving falls behind, and everything saved
+	// before a failure is kept.
+	products := make(chan scraper.ScrapedProduct, saveBufferSize)
+	saved := make(chan saveOutcome, 1)
+	runID := scrapeRunID(j)
+	go func() {
+		stats, errors := h.saveProducts(ctx, products, runID, payload, normalizer)
+		saved <- saveOutcome{stats: stats, errors: errors}
+	}()
+
 	// Perform scraping using the manager (will auto-select the right plugin)
-	result, err := h.manager.ScrapeByType(ctx, scrapeReq)
-	if err != nil {
-		return fmt.Errorf("scraping failed: %w", err)
+	result, scrapeErr := h.manager.StreamByType(ctx, scrapeReq, scraper.NewChannelEmitter(ctx, products))
+	close(products)
+	outcome := <-saved
+	saveStats, saveErrors := outcome.stats, outcome.errors
+
+	if result == nil {
+		return scrapeErr
 	}
 
-	log.Printf("Scraped %d products with %d total variants from %s",
-		len(result.Products), result.Stats.VariantsFound, payload.ResellerName)
+	log.Printf("Scraped %d products with %d total variants from %s using %s",
+		result.Stats.ProductsFound, result.Stats.VariantsFound, payload.ResellerName, result.Plugin)
 
-	// Convert and save products
-	saveStats, saveErrors := h.saveProducts(ctx, result.Products, payload)
+	// Only a run that completed can tell which products are gone
+	var delisted int
+	var delistSkipped string
+	if scrapeErr == nil {
+		delisted, delistSkipped = h.delistUnseen(ctx, runID, payload, result, saveErrors)
+	}
 
 	// Create job result
 	jobResult := ScrapeJobResult{
-		ProductsCreated: saveStats.Created,
-		ProductsUpdated: saveStats.Updated,
-		VariantsTotal:   result.Stats.VariantsFound,
-		TotalErrors:     len(result.Errors) + len(saveErrors),
-		ScrapeErrors:    result.Errors,
-		SaveErrors:      saveErrors,
-		Duration:        time.Since(start).String(),
-		ScrapedAt:       start.Format(time.RFC3339),
-		Source:          payload.ResellerName,
-		Category:        payload.Category,
+		ProductsCreated:   saveStats.Created,
+		ProductsUpdated:   saveStats.Updated,
+		ProductsUnchanged: saveStats.Unchanged,
+		VariantsTotal:     result.Stats.VariantsFound,
+		VariantsCreated:   saveStats.VariantsCreated,
+		VariantsUpdated:   saveStats.VariantsUpdated,
+		VariantsGone:      saveStats.VariantsGone,
+		ProductsDelisted:  delisted,
+		DelistSkipped:     delistSkipped,
+		URLsFound:         result.Stats.URLsFound,
+		URLsUnchanged:     result.Stats.URLsUnchanged,
+		HTTPRequests:      result.Stats.HTTPRequests,
+		HTTPRetries:       result.Stats.HTTPRetries,
+		HTTPFailures:      result.Stats.HTTPFailures,
+		CacheHits:         result.Stats.CacheHits,
+		CacheMisses:       result.Stats.CacheMisses,
+		CacheHitRate:      cacheHitRate(result.Stats),
+		BytesFromCache:    result.Stats.BytesFromCache,
+		TotalErrors:       len(result.Errors) + len(saveErrors),
+		ScrapeErrors:      result.Errors,
+		BlockedURLs:       result.BlockedURLs,
+		SaveErrors:        saveErrors,
+		Partial:           scrapeErr != nil,
+		Plugin:            result.Plugin,
+		PluginAttempts:    result.Attempts,
+		Duration:          time.Since(start).String(),
+		ScrapedAt:         start.Format(time.RFC3339),
+		Source:            payload.ResellerName,
+		Category:          payload.Category,
 	}
 
 	// Convert result to map for storage
@@ -114,8 +164,18 @@ func (h *ScrapeJobHandler) Handle(ctx context.Context, j *job.Job) error {
 
 	j.Result = resultMap
 
-	log.Printf("Job %s completed: %d created, %d updated, %d total errors",
-		j.ID, saveStats.Created, saveStats.Updated, jobResult.TotalErrors)
+	// The scheduler keeps the result of a failed job, so the progress
+	// made before the failure is still recorded
+	if scrapeErr != nil {
+		log.Printf("Job %s failed after saving %d products: %v", j.ID, saveStats.Created, scrapeErr)
+		return scrapeErr
+	}
+
+	log.Printf("Job %s completed: %d created, %d updated, %d unchanged, %d delisted, %d total errors",
+		j.ID, saveStats.Created, saveStats.Updated, saveStats.Unchanged, delisted, jobResult.TotalErrors)
+	if delistSkipped != "" {
+		log.Printf("Skipped delisting for job %s: %s", j.ID, delistSkipped)
+	}
 
 	// print errors
 	if len(jobResult.ScrapeErrors) > 0 {
@@ -124,32 +184,39 @@ func (h *ScrapeJobHandler) Handle(ctx context.Context, j *job.Job) error {
 	if len(jobResult.SaveErrors) > 0 {
 		log.Printf("Save errors: %v", jobResult.SaveErrors)
 	}
+	if len(jobResult.BlockedURLs) > 0 {
+		log.Printf("Skipped %d URLs disallowed by robots.txt", len(jobResult.BlockedURLs))
+	}
 
 	return nil
 }
 
-func (h *ScrapeJobHandler) saveProducts(ctx context.Context, scrapedProducts []scraper.ScrapedProduct, payload config.ScrapeJobPayload) (*SaveStats, []string) {
+// scrapeRunID identifies one attempt at a scrape job. Products are stamped
+// with it as they are seen, so a retry does not count the products an
+// earlier, failed attempt saved as seen by itself.
+func scrapeRunID(j *job.Job) string {
+	return fmt.Sprintf("%s#%d", j.ID, j.Attempts)
+}
+
+// saveProducts files products under their canonical brand and saves them
+// as they arrive until the channel is closed, stamping them with runID
+func (h *ScrapeJobHandler) saveProducts(ctx context.Context, scrapedProducts <-chan scraper.ScrapedProduct, runID string, payload config.ScrapeJobPayload, normalizer *brand.Normalizer) (*SaveStats, []string) {
 	stats := &SaveStats{}
 	var errors []string
 
-	for _, sp := range scrapedProducts {
+	for sp := range scrapedProducts {
 		// Convert scraped product to domain product
 		domainProduct := h.convertToProduct(sp, payload)
+		domainProduct.ScrapeJobID = runID
+		h.normalizeBrand(ctx, normalizer, domainProduct)
 
 		// Save to database
-		err := h.productRepo.Save(ctx, domainProduct)
+		outcome, err := h.productRepo.Save(ctx, domainProduct)
 		if err != nil {
 			errors = append(errors, fmt.Sprintf("Failed to save product '%s': %v", sp.Name, err))
 			continue
 		}
-
-		// For simplicity, count all as created (repository handles update logic internally)
-		stats.Created++
-
-		// Update brand if it's new (optional: maintain brand registry)
-		if sp.Brand != "" && sp.Brand != "Unknown" {
-			h.updateBrandRegistry(ctx, sp.Brand)
-		}
+		stats.add(outcome)
 	}
 
 	return stats, errors
@@ -163,8 +230,10 @@ func (h *ScrapeJobHandler) convertToProduct(sp scraper.ScrapedProduct, payload c
 		Handle:         sp.Handle,
 		URL:            sp.URL,
 		Brand:          sp.Brand,
+		RawBrand:       brand.CleanName(sp.Brand),
 		Reseller:       payload.ResellerName,
 		ResellerID:     payload.ResellerID,
+		ConfigID:       payload.ConfigID,
 		Category:       payload.Category,
 		Tags:           sp.Tags,
 		Images:         sp.Images,
@@ -178,6 +247,7 @@ func (h *ScrapeJobHandler) convertToProduct(sp scraper.ScrapedProduct, payload c
 		variant := product.Variant{
 			Name:      sv.Name,
 			SKU:       sv.SKU,
+			GTIN:      sv.GTIN,
 			Price:     sv.Price,
 			Currency:  sv.Currency,
 			Available: sv.Available,
@@ -194,49 +264,152 @@ func (h *ScrapeJobHandler) convertToProduct(sp scraper.ScrapedProduct, payload c
 	return domainProduct
 }
 
-func (h *ScrapeJobHandler) updateBrandRegistry(ctx context.Context, brandName string) {
-	// Optional: Keep a registry of brands for analytics/filtering
-	// This is a simple insert-ignore operation using UUID generation
-	query := `
-		INSERT INTO brands (id, name) 
-		VALUES (gen_random_uuid(), $1) 
-		ON CONFLICT (name) DO NOTHING
-	`
+// delistUnseen delists the config's products the completed scrape run did
+// not see, returning how many it delisted or why it did not. Runs that cannot
+// have seen the whole catalog, and runs that would delist too much of it,
+// delist nothing.
+func (h *ScrapeJobHandler) delistUnseen(ctx context.Context, runID string, payload config.ScrapeJobPayload, result *scraper.ScrapeResult, saveErrors []string) (int, string) {
+	var listed, unseen int
+	if payload.ConfigID != "" {
+		var err error
+		listed, unseen, err = h.productRepo.CountUnseen(ctx, payload.ConfigID, runID)
+		if err != nil {
+			log.Printf("Warning: failed to count unseen products: %v", err)
+			return 0, "failed to count unseen products"
+		}
+	}
+
+	if reason := delistSkipReason(payload, result, len(saveErrors), listed, unseen); reason != "" {
+		return 0, reason
+	}
+	if unseen == 0 {
+		return 0, ""
+	}
 
-	_, err := h.db.ExecContext(ctx, query, brandName)
+	delisted, err := h.productRepo.DelistUnseen(ctx, payload.ConfigID, runID)
 	if err != nil {
-		log.Printf("Warning: Failed to update brand registry for '%s': %v", brandName, err)
+		log.Printf("Warning: failed to delist products: %v", err)
+		return 0, "failed to delist products"
 	}
+	return delisted, ""
 }
 
-func (h *ScrapeJobHandler) generateBrandID(name string) string {
-	// Simple ID generation from name
-	// In production, you might want more sophisticated ID generation
-	return fmt.Sprintf("brand_%s",
-		strings.ToLower(
-			strings.ReplaceAll(
-				strings.ReplaceAll(name, " ", "_"),
-				".", "")))
+// delistSkipReason returns why a run must not delist the products it did
+// not see, or "" when it may. listed counts the config's listed products
+// and unseen those of them the run did not see.
+func delistSkipReason(payload config.ScrapeJobPayload, result *scraper.ScrapeResult, saveErrors, listed, unseen int) string {
+	switch {
+	case payload.ConfigID == "":
+		return "job has no config"
+	case len(result.Errors) > 0 || saveErrors > 0:
+		return "scrape had errors"
+	case len(result.BlockedURLs) > 0:
+		return "robots.txt blocked some URLs"
+	case result.Stats.URLsUnchanged > 0:
+		return "incremental scrape skipped unchanged URLs"
+	case limited(payload.Options, "max_urls") || limited(payload.Options, "max_pages"):
+		return "scrape was limited by max_urls or max_pages"
+	case result.Stats.ProductsFound == 0:
+		return "scrape found no products"
+	case float64(unseen) > float64(listed)*maxDelistShare:
+		return fmt.Sprintf("would delist %d of %d products", unseen, listed)
+	}
+	return ""
+}
+
+// limited reports whether an option caps the scrape at a positive number
+func limited(options map[string]string, key string) bool {
+	n, err := strconv.Atoi(options[key])
+	return err == nil && n > 0
+}
+
+// normalizeBrand replaces the brand the source gave with the canonical one.
+// If the registry cannot be updated the product keeps the source's brand.
+func (h *ScrapeJobHandler) normalizeBrand(ctx context.Context, normalizer *brand.Normalizer, p *product.Product) {
+	match, err := normalizer.Resolve(ctx, h.brandRepo, p.RawBrand, p.Name)
+	if err != nil {
+		log.Printf("Warning: %v", err)
+		return
+	}
+
+	p.BrandID = match.BrandID
+	p.Brand = match.Name
+	if p.Brand == "" {
+		p.Brand = "Unknown"
+	}
 }
 
 // Job result structure
 type ScrapeJobResult struct {
-	ProductsCreated int      `json:"products_created"`
-	ProductsUpdated int      `json:"products_updated"`
-	VariantsTotal   int      `json:"variants_total"`
-	TotalErrors     int      `json:"total_errors"`
-	ScrapeErrors    []string `json:"scrape_errors,omitempty"`
-	SaveErrors      []string `json:"save_errors,omitempty"`
-	Duration        string   `json:"duration"`
-	ScrapedAt       string   `json:"scraped_at"`
-	Source          string   `json:"source"`
-	Category        string   `json:"category"`
+	ProductsCreated   int                     `json:"products_created"`
+	ProductsUpdated   int                     `json:"products_updated"`
+	ProductsUnchanged int                     `json:"products_unchanged"`
+	VariantsTotal     int                     `json:"variants_total"`
+	VariantsCreated   int                     `json:"variants_created"`
+	VariantsUpdated   int                     `json:"variants_updated"`
+	VariantsGone      int                     `json:"variants_gone"` // No longer listed by the source
+	ProductsDelisted  int                     `json:"products_delisted"`
+	DelistSkipped     string                  `json:"delist_skipped,omitempty"` // Why unseen products were not delisted
+	URLsFound         int                     `json:"urls_found,omitempty"`
+	URLsUnchanged     int                     `json:"urls_unchanged,omitempty"`
+	HTTPRequests      int                     `json:"http_requests,omitempty"`
+	HTTPRetries       int                     `json:"http_retries,omitempty"`
+	HTTPFailures      int                     `json:"http_failures,omitempty"`
+	CacheHits         int                     `json:"cache_hits,omitempty"`
+	CacheMisses       int                     `json:"cache_misses,omitempty"`
+	CacheHitRate      float64                 `json:"cache_hit_rate,omitempty"` // Share of cached fetches answered with 304
+	BytesFromCache    int64                   `json:"bytes_from_cache,omitempty"`
+	TotalErrors       int                     `json:"total_errors"`
+	ScrapeErrors      []string                `json:"scrape_errors,omitempty"`
+	BlockedURLs       []string                `json:"blocked_urls,omitempty"` // Skipped because robots.txt disallows them
+	SaveErrors        []string                `json:"save_errors,omitempty"`
+	Partial           bool                    `json:"partial,omitempty"`         // Scraping failed part way; the counts cover what was saved before
+	Plugin            string                  `json:"plugin,omitempty"`          // Plugin that produced the products
+	PluginAttempts    []scraper.PluginAttempt `json:"plugin_attempts,omitempty"` // Every plugin run, when the chain fell back
+	Duration          string                  `json:"duration"`
+	ScrapedAt         string                  `json:"scraped_at"`
+	Source            string                  `json:"source"`
+	Category          string                  `json:"category"`
+}
+
+// cacheHitRate returns the share of fetches answered from the response cache
+func cacheHitRate(stats scraper.ScrapeStats) float64 {
+	total := stats.CacheHits + stats.CacheMisses
+	if total == 0 {
+		return 0
+	}
+	return math.Round(float64(stats.CacheHits)/float64(total)*1000) / 1000
 }
 
 type SaveStats struct {
-	Created int
-	Updated int
-	Errors  int
+	Created         int
+	Updated         int
+	Unchanged       int
+	Errors          int
+	VariantsCreated int
+	VariantsUpdated int
+	VariantsGone    int
+}
+
+// add counts a saved product
+func (s *SaveStats) add(outcome *product.SaveOutcome) {
+	switch outcome.Status {
+	case product.SaveCreated:
+		s.Created++
+	case product.SaveUpdated:
+		s.Updated++
+	default:
+		s.Unchanged++
+	}
+	s.VariantsCreated += outcome.VariantsCreated
+	s.VariantsUpdated += outcome.VariantsUpdated
+	s.VariantsGone += outcome.VariantsGone
+}
+
+// saveOutcome carries the saver's totals back to Handle
+type saveOutcome struct {
+	stats  *SaveStats
+	errors []string
 }
 
 type ScrapeAllSitesHandler struct{}
@@ -0,0 +1,63 @@
+package jobs
+
+import (
+	"testing"
+
+	"github.com/meta-boy/mech-alligator/internal/config"
+	"github.com/meta-boy/mech-alligator/internal/scraper"
+)
+
+func TestDelistSkipReason(t *testing.T) {
+	complete := func() *scraper.ScrapeResult {
+		return &scraper.ScrapeResult{Stats: scraper.ScrapeStats{ProductsFound: 10}}
+	}
+	payload := config.ScrapeJobPayload{ConfigID: "config-1"}
+
+	tests := []struct {
+		name       string
+		payload    config.ScrapeJobPayload
+		result     func(*scraper.ScrapeResult)
+		saveErrors int
+		listed     int
+		unseen     int
+		want       string
+	}{
+		{name: "complete run", payload: payload, listed: 10, unseen: 2, want: ""},
+		{name: "nothing unseen", payload: payload, listed: 10, want: ""},
+		{name: "no config", listed: 10, unseen: 2, want: "job has no config"},
+		{name: "scrape errors", payload: payload, listed: 10, unseen: 2,
+			result: func(r *scraper.ScrapeResult) { r.Errors = []string{"page 2: timeout"} },
+			want:   "scrape had errors"},
+		{name: "save errors", payload: payload, saveErrors: 1, listed: 10, unseen: 2,
+			want: "scrape had errors"},
+		{name: "robots.txt", payload: payload, listed: 10, unseen: 2,
+			result: func(r *scraper.ScrapeResult) { r.BlockedURLs = []string{"https://shop.example.com/private"} },
+			want:   "robots.txt blocked some URLs"},
+		{name: "incremental", payload: payload, listed: 10, unseen: 2,
+			result: func(r *scraper.ScrapeResult) { r.Stats.URLsUnchanged = 3 },
+			want:   "incremental scrape skipped unchanged URLs"},
+		{name: "max_urls", payload: config.ScrapeJobPayload{ConfigID: "config-1", Options: map[string]string{"max_urls": "50"}},
+			listed: 10, unseen: 2, want: "scrape was limited by max_urls or max_pages"},
+		{name: "max_pages", payload: config.ScrapeJobPayload{ConfigID: "config-1", Options: map[string]string{"max_pages": "2"}},
+			listed: 10, unseen: 2, want: "scrape was limited by max_urls or max_pages"},
+		{name: "unlimited max_pages", payload: config.ScrapeJobPayload{ConfigID: "config-1", Options: map[string]string{"max_pages": "0"}},
+			listed: 10, unseen: 2, want: ""},
+		{name: "no products", payload: payload, listed: 10, unseen: 10,
+			result: func(r *scraper.ScrapeResult) { r.Stats.ProductsFound = 0 },
+			want:   "scrape found no products"},
+		// Up to half of the listed products may go in one run
+		{name: "exactly half", payload: payload, listed: 10, unseen: 5, want: ""},
+		{name: "over half", payload: payload, listed: 10, unseen: 6, want: "would delist 6 of 10 products"},
+		{name: "half of an odd count", payload: payload, listed: 9, unseen: 5, want: "would delist 5 of 9 products"},
+	}
+
+	for _, tt := range tests {
+		result := complete()
+		if tt.result != nil {
+			tt.result(result)
+		}
+		if got := delistSkipReason(tt.payload, result, tt.saveErrors, tt.listed, tt.unseen); got != tt.want {
+			t.Errorf("delistSkipReason() %s = %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
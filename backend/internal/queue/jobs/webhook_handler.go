@@ -0,0 +1,142 @@
+package jobs
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/meta-boy/mech-alligator/internal/domain/job"
+	"github.com/meta-boy/mech-alligator/internal/domain/webhook"
+	"github.com/meta-boy/mech-alligator/internal/repository/postgres"
+)
+
+// webhookTimeout is how long a subscriber has to answer a delivery
+const webhookTimeout = 10 * time.Second
+
+type WebhookPayload struct {
+	DeliveryID string `json:"delivery_id"`
+}
+
+// WebhookDeliveryHandler posts a delivery to its subscription's URL,
+// signed with the subscription's secret. A delivery that is not answered
+// with a 2xx status fails the job, so the scheduler retries it with
+// backoff; once the job is out of attempts the delivery is marked failed.
+type WebhookDeliveryHandler struct {
+	webhooks *postgres.WebhookRepository
+	client   *http.Client
+}
+
+func NewWebhookDeliveryHandler(webhooks *postgres.WebhookRepository) *WebhookDeliveryHandler {
+	// Every connection, redirects included, is checked against the address
+	// it dials. Proxies are not used since they would be dialled instead.
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.Proxy = nil
+	transport.DialContext = (&net.Dialer{
+		Timeout: webhookTimeout,
+		Control: webhook.DialControl,
+	}).DialContext
+
+	return &WebhookDeliveryHandler{
+		webhooks: webhooks,
+		client:   &http.Client{Timeout: webhookTimeout, Transport: transport},
+	}
+}
+
+func (h *WebhookDeliveryHandler) GetType() job.JobType {
+	return job.JobTypeWebhookDelivery
+}
+
+func (h *WebhookDeliveryHandler) Handle(ctx context.Context, j *job.Job) error {
+	var payload WebhookPayload
+	payloadBytes, err := json.Marshal(j.Payload)
+	if err != nil {
+		return fmt.Errorf("failed to marshal payload: %w", err)
+	}
+	if err := json.Unmarshal(payloadBytes, &payload); err != nil {
+		return fmt.Errorf("failed to unmarshal payload: %w", err)
+	}
+
+	d, err := h.webhooks.GetDelivery(ctx, payload.DeliveryID)
+	if err != nil {
+		return err
+	}
+	if d == nil {
+		// Deleting a subscription deletes its deliveries
+		log.Printf("Webhook delivery %s no longer exists", payload.DeliveryID)
+		return nil
+	}
+	if d.Status == webhook.DeliverySucceeded {
+		return nil
+	}
+
+	subscription, err := h.webhooks.GetSubscription(ctx, d.SubscriptionID)
+	if err != nil {
+		return err
+	}
+	if subscription == nil {
+		log.Printf("Webhook subscription %s no longer exists", d.SubscriptionID)
+		return nil
+	}
+
+	d.Attempts++
+	d.JobID = j.ID
+	status, sendErr := h.send(ctx, subscription, d)
+	d.ResponseStatus = status
+
+	if sendErr == nil {
+		now := time.Now()
+		d.Status = webhook.DeliverySucceeded
+		d.LastError = ""
+		d.DeliveredAt = &now
+	} else {
+		d.LastError = sendErr.Error()
+		d.Status = webhook.DeliveryPending
+		if j.Attempts >= j.MaxAttempts {
+			d.Status = webhook.DeliveryFailed
+		}
+	}
+
+	if err := h.webhooks.UpdateDelivery(ctx, d); err != nil {
+		return fmt.Errorf("failed to update webhook delivery: %w", err)
+	}
+
+	if sendErr != nil {
+		return fmt.Errorf("webhook delivery %s to %s failed: %w", d.ID, subscription.URL, sendErr)
+	}
+	return nil
+}
+
+// send posts the delivery, returning the response status
+func (h *WebhookDeliveryHandler) send(ctx context.Context, subscription *webhook.Subscription, d *webhook.Delivery) (int, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, subscription.URL, bytes.NewReader(d.Payload))
+	if err != nil {
+		return 0, err
+	}
+
+	timestamp := time.Now().Unix()
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "mech-alligator-webhooks/1.0")
+	req.Header.Set(webhook.EventHeader, string(d.EventType))
+	req.Header.Set(webhook.DeliveryHeader, d.ID)
+	req.Header.Set(webhook.TimestampHeader, strconv.FormatInt(timestamp, 10))
+	req.Header.Set(webhook.SignatureHeader, webhook.Sign(subscription.Secret, timestamp, d.Payload))
+
+	resp, err := h.client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return resp.StatusCode, fmt.Errorf("subscriber answered %s", resp.Status)
+	}
+	return resp.StatusCode, nil
+}
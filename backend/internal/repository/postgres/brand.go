@@ -0,0 +1,293 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+
+	"github.com/lib/pq"
+	"github.com/meta-boy/mech-alligator/internal/database"
+	"github.com/meta-boy/mech-alligator/internal/domain/brand"
+)
+
+type BrandRepository struct {
+	db *database.DB
+}
+
+func NewBrandRepository(db *database.DB) *BrandRepository {
+	return &BrandRepository{db: db}
+}
+
+// List returns every brand with its aliases, rules and product count,
+// ordered by name
+func (r *BrandRepository) List(ctx context.Context) ([]brand.Brand, error) {
+	return r.query(ctx, "")
+}
+
+func (r *BrandRepository) GetByID(ctx context.Context, id string) (*brand.Brand, error) {
+	brands, err := r.query(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if len(brands) == 0 {
+		return nil, nil
+	}
+	return &brands[0], nil
+}
+
+// Create inserts the brand with its aliases and rules, filling in the
+// generated IDs
+func (r *BrandRepository) Create(ctx context.Context, b *brand.Brand) error {
+	tx, err := r.db.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	query := `
+		INSERT INTO brands (name, country, website, description)
+		VALUES ($1, $2, $3, $4)
+		RETURNING id
+	`
+
+	err = tx.QueryRowContext(ctx, query,
+		b.Name, nullString(b.Country), nullString(b.Website), nullString(b.Description),
+	).Scan(&b.ID)
+	if err != nil {
+		return fmt.Errorf("failed to insert brand: %w", err)
+	}
+
+	if err := r.insertAliasesAndRules(ctx, tx, b); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// Update saves the brand's fields and replaces its aliases and rules,
+// returning false if it does not exist. Products filed under the brand
+// take its new name.
+func (r *BrandRepository) Update(ctx context.Context, b *brand.Brand) (bool, error) {
+	tx, err := r.db.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return false, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	query := `
+		UPDATE brands
+		SET name = $2, country = $3, website = $4, description = $5, provisional = false
+		WHERE id = $1
+	`
+
+	found, err := rowsAffected(tx.ExecContext(ctx, query,
+		b.ID, b.Name, nullString(b.Country), nullString(b.Website), nullString(b.Description),
+	))
+	if err != nil || !found {
+		return found, err
+	}
+
+	if _, err := tx.ExecContext(ctx, `UPDATE products SET brand = $2 WHERE brand_id = $1`, b.ID, b.Name); err != nil {
+		return false, fmt.Errorf("failed to rename products' brand: %w", err)
+	}
+	if _, err := tx.ExecContext(ctx, `DELETE FROM brand_aliases WHERE brand_id = $1`, b.ID); err != nil {
+		return false, fmt.Errorf("failed to delete aliases: %w", err)
+	}
+	if _, err := tx.ExecContext(ctx, `DELETE FROM brand_rules WHERE brand_id = $1`, b.ID); err != nil {
+		return false, fmt.Errorf("failed to delete rules: %w", err)
+	}
+	if err := r.insertAliasesAndRules(ctx, tx, b); err != nil {
+		return false, err
+	}
+
+	return true, tx.Commit()
+}
+
+// Register returns the brand with the given name, creating it as a
+// provisional brand if there is none. Scrape jobs use it for brands the
+// registry does not know yet.
+func (r *BrandRepository) Register(ctx context.Context, name string) (brand.Match, error) {
+	// The no-op update makes RETURNING give the existing row on conflict
+	query := `
+		INSERT INTO brands (name, provisional) VALUES ($1, true)
+		ON CONFLICT (name) DO UPDATE SET name = EXCLUDED.name
+		RETURNING id, name
+	`
+
+	var match brand.Match
+	err := r.db.QueryRowContext(ctx, query, name).Scan(&match.BrandID, &match.Name)
+	return match, err
+}
+
+// Merge moves the products, aliases and rules of the source brands to the
+// target and deletes the sources, whose names become aliases of the
+// target. It returns the number of products moved.
+func (r *BrandRepository) Merge(ctx context.Context, target *brand.Brand, sources []brand.Brand) (int64, error) {
+	tx, err := r.db.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	sourceIDs := make([]string, len(sources))
+	for i, source := range sources {
+		sourceIDs[i] = source.ID
+	}
+
+	if _, err := tx.ExecContext(ctx, `UPDATE brand_aliases SET brand_id = $1 WHERE brand_id = ANY($2)`,
+		target.ID, pq.Array(sourceIDs)); err != nil {
+		return 0, fmt.Errorf("failed to move aliases: %w", err)
+	}
+	if _, err := tx.ExecContext(ctx, `UPDATE brand_rules SET brand_id = $1 WHERE brand_id = ANY($2)`,
+		target.ID, pq.Array(sourceIDs)); err != nil {
+		return 0, fmt.Errorf("failed to move rules: %w", err)
+	}
+
+	targetKey := brand.Key(target.Name)
+	for _, source := range sources {
+		if brand.Key(source.Name) == targetKey {
+			continue
+		}
+		if err := r.insertAlias(ctx, tx, target.ID, source.Name); err != nil {
+			return 0, fmt.Errorf("failed to add alias %s: %w", source.Name, err)
+		}
+	}
+
+	result, err := tx.ExecContext(ctx, `UPDATE products SET brand_id = $1, brand = $2 WHERE brand_id = ANY($3)`,
+		target.ID, target.Name, pq.Array(sourceIDs))
+	if err != nil {
+		return 0, fmt.Errorf("failed to move products: %w", err)
+	}
+	moved, err := result.RowsAffected()
+	if err != nil {
+		return 0, err
+	}
+
+	if _, err := tx.ExecContext(ctx, `DELETE FROM brands WHERE id = ANY($1)`, pq.Array(sourceIDs)); err != nil {
+		return 0, fmt.Errorf("failed to delete merged brands: %w", err)
+	}
+	// Merging into a brand confirms it
+	if _, err := tx.ExecContext(ctx, `UPDATE brands SET provisional = false WHERE id = $1`, target.ID); err != nil {
+		return 0, fmt.Errorf("failed to confirm brand: %w", err)
+	}
+
+	return moved, tx.Commit()
+}
+
+// query loads the brand with the given ID, or every brand if id is empty
+func (r *BrandRepository) query(ctx context.Context, id string) ([]brand.Brand, error) {
+	query := `
+		SELECT b.id, b.name, COALESCE(b.country, ''), COALESCE(b.website, ''), COALESCE(b.description, ''),
+			b.provisional, (SELECT COUNT(*) FROM products p WHERE p.brand_id = b.id)
+		FROM brands b
+		WHERE $1 = '' OR b.id::text = $1
+		ORDER BY b.name
+	`
+
+	rows, err := r.db.QueryContext(ctx, query, id)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var brands []brand.Brand
+	index := make(map[string]int)
+	for rows.Next() {
+		b := brand.Brand{Aliases: []string{}, Rules: []brand.Rule{}}
+		if err := rows.Scan(&b.ID, &b.Name, &b.Country, &b.Website, &b.Description, &b.Provisional, &b.ProductCount); err != nil {
+			return nil, err
+		}
+		index[b.ID] = len(brands)
+		brands = append(brands, b)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	if len(brands) == 0 {
+		return brands, nil
+	}
+
+	aliasRows, err := r.db.QueryContext(ctx, `
+		SELECT brand_id, alias FROM brand_aliases
+		WHERE $1 = '' OR brand_id::text = $1
+		ORDER BY alias
+	`, id)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query aliases: %w", err)
+	}
+	defer aliasRows.Close()
+
+	for aliasRows.Next() {
+		var brandID, alias string
+		if err := aliasRows.Scan(&brandID, &alias); err != nil {
+			return nil, err
+		}
+		if i, ok := index[brandID]; ok {
+			brands[i].Aliases = append(brands[i].Aliases, alias)
+		}
+	}
+	if err := aliasRows.Err(); err != nil {
+		return nil, err
+	}
+
+	ruleRows, err := r.db.QueryContext(ctx, `
+		SELECT id, brand_id, pattern, priority FROM brand_rules
+		WHERE $1 = '' OR brand_id::text = $1
+		ORDER BY priority DESC, pattern
+	`, id)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query rules: %w", err)
+	}
+	defer ruleRows.Close()
+
+	for ruleRows.Next() {
+		var brandID string
+		var rule brand.Rule
+		if err := ruleRows.Scan(&rule.ID, &brandID, &rule.Pattern, &rule.Priority); err != nil {
+			return nil, err
+		}
+		if i, ok := index[brandID]; ok {
+			brands[i].Rules = append(brands[i].Rules, rule)
+		}
+	}
+
+	return brands, ruleRows.Err()
+}
+
+func (r *BrandRepository) insertAliasesAndRules(ctx context.Context, tx *sql.Tx, b *brand.Brand) error {
+	for _, alias := range b.Aliases {
+		if err := r.insertAlias(ctx, tx, b.ID, alias); err != nil {
+			return fmt.Errorf("failed to insert alias %s: %w", alias, err)
+		}
+	}
+
+	for i := range b.Rules {
+		rule := &b.Rules[i]
+		err := tx.QueryRowContext(ctx,
+			`INSERT INTO brand_rules (brand_id, pattern, priority) VALUES ($1, $2, $3) RETURNING id`,
+			b.ID, rule.Pattern, rule.Priority,
+		).Scan(&rule.ID)
+		if err != nil {
+			return fmt.Errorf("failed to insert rule %s: %w", rule.Pattern, err)
+		}
+	}
+
+	return nil
+}
+
+// insertAlias adds an alias unless its key is already taken
+func (r *BrandRepository) insertAlias(ctx context.Context, tx *sql.Tx, brandID, alias string) error {
+	query := `
+		INSERT INTO brand_aliases (brand_id, alias, alias_key)
+		VALUES ($1, $2, $3)
+		ON CONFLICT (alias_key) DO NOTHING
+	`
+
+	_, err := tx.ExecContext(ctx, query, brandID, alias, brand.Key(alias))
+	return err
+}
+
+// nullString stores empty strings as NULL
+func nullString(s string) sql.NullString {
+	return sql.NullString{String: s, Valid: s != ""}
+}
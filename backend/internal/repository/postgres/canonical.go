@@ -0,0 +1,335 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+
+	"github.com/lib/pq"
+	"github.com/meta-boy/mech-alligator/internal/domain/product"
+)
+
+// ListOffers returns every variant of the products grouped under
+// canonicalID, cheapest first. A product that has not been matched yet is
+// its own group.
+func (r *ProductRepository) ListOffers(ctx context.Context, canonicalID, productID string) ([]product.Offer, error) {
+	query := `
+		SELECT
+			p.id, p.name, COALESCE(p.reseller, ''), COALESCE(p.reseller_id::text, ''), p.url,
+			v.id, COALESCE(v.name, ''), COALESCE(v.sku, ''), COALESCE(v.gtin, ''),
+			v.price, COALESCE(v.currency, ''), COALESCE(v.available, false), COALESCE(v.url, ''), v.options
+		FROM products p
+		JOIN product_variants v ON v.product_id = p.id AND v.gone_at IS NULL
+		WHERE ($1 != '' AND p.canonical_id::text = $1 AND p.delisted_at IS NULL) OR p.id::text = $2
+		ORDER BY v.price ASC, v.available DESC, p.reseller
+	`
+
+	rows, err := r.db.QueryContext(ctx, query, canonicalID, productID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	offers := []product.Offer{}
+	for rows.Next() {
+		var o product.Offer
+		var variantURL string
+		var optionsJSON []byte
+		if err := rows.Scan(
+			&o.ProductID, &o.ProductName, &o.Reseller, &o.ResellerID, &o.URL,
+			&o.VariantID, &o.VariantName, &o.SKU, &o.GTIN,
+			&o.Price, &o.Currency, &o.Available, &variantURL, &optionsJSON,
+		); err != nil {
+			return nil, err
+		}
+		if variantURL != "" {
+			o.URL = variantURL
+		}
+		if len(optionsJSON) > 0 {
+			if err := json.Unmarshal(optionsJSON, &o.Options); err != nil {
+				o.Options = nil
+			}
+		}
+		offers = append(offers, o)
+	}
+
+	return offers, rows.Err()
+}
+
+// CanonicalExists reports whether a canonical product exists
+func (r *ProductRepository) CanonicalExists(ctx context.Context, id string) (bool, error) {
+	var exists bool
+	err := r.db.QueryRowContext(ctx, `SELECT EXISTS (SELECT 1 FROM canonical_products WHERE id::text = $1)`, id).Scan(&exists)
+	return exists, err
+}
+
+// SetCanonical files a product under a canonical product and locks it
+// there, returning false if the product does not exist
+func (r *ProductRepository) SetCanonical(ctx context.Context, productID, canonicalID string) (bool, error) {
+	tx, err := r.db.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return false, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	found, err := r.setCanonical(ctx, tx, productID, canonicalID, true)
+	if err != nil || !found {
+		return found, err
+	}
+
+	return true, tx.Commit()
+}
+
+// SeparateCanonical gives a product a canonical product of its own and
+// locks it there, for listings matching wrongly grouped together
+func (r *ProductRepository) SeparateCanonical(ctx context.Context, p *product.Product) error {
+	tx, err := r.db.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	canonicalID, err := r.createCanonical(ctx, tx, p, "")
+	if err != nil {
+		return err
+	}
+	if _, err := r.setCanonical(ctx, tx, p.ID, canonicalID, true); err != nil {
+		return err
+	}
+	p.CanonicalID = canonicalID
+
+	return tx.Commit()
+}
+
+// ResetCanonical removes a manual override and matches the product again
+func (r *ProductRepository) ResetCanonical(ctx context.Context, p *product.Product) error {
+	tx, err := r.db.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx, `UPDATE products SET canonical_locked = false WHERE id = $1`, p.ID); err != nil {
+		return fmt.Errorf("failed to unlock product: %w", err)
+	}
+	if err := r.assignCanonical(ctx, tx, p); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// assignCanonical groups a saved product with the same product at other
+// resellers, matching on barcodes, then on SKUs of the same brand, then on
+// brand and name when the options are alike. A product that matches none
+// gets a canonical product of its own. Manually filed products are left
+// where they are.
+func (r *ProductRepository) assignCanonical(ctx context.Context, tx *sql.Tx, p *product.Product) error {
+	var current sql.NullString
+	var locked bool
+	err := tx.QueryRowContext(ctx,
+		`SELECT canonical_id, canonical_locked FROM products WHERE id = $1`, p.ID,
+	).Scan(&current, &locked)
+	if err != nil {
+		return err
+	}
+	if locked {
+		p.CanonicalID = current.String
+		return nil
+	}
+
+	key := product.MatchKey(p.Brand, p.Name)
+	canonicalID, err := r.findCanonical(ctx, tx, p, current.String, key)
+	if err != nil {
+		return err
+	}
+	if canonicalID == "" {
+		if canonicalID, err = r.createCanonical(ctx, tx, p, current.String); err != nil {
+			return err
+		}
+	}
+
+	if canonicalID != current.String {
+		if _, err := r.setCanonical(ctx, tx, p.ID, canonicalID, false); err != nil {
+			return err
+		}
+	}
+	p.CanonicalID = canonicalID
+
+	return nil
+}
+
+func (r *ProductRepository) findCanonical(ctx context.Context, tx *sql.Tx, p *product.Product, current, key string) (string, error) {
+	// Products sharing a barcode are the same whoever sells them; the
+	// current group wins ties so products do not move back and forth
+	if gtins := product.GTINs(p.Variants); len(gtins) > 0 {
+		query := `
+			SELECT p.canonical_id FROM product_variants v
+			JOIN products p ON p.id = v.product_id
+			WHERE v.gtin = ANY($1) AND p.id != $2 AND p.canonical_id IS NOT NULL
+			ORDER BY p.canonical_id::text = $3 DESC
+			LIMIT 1
+		`
+		id, err := queryID(ctx, tx, query, pq.Array(gtins), p.ID, current)
+		if err != nil || id != "" {
+			return id, err
+		}
+	}
+
+	// SKUs are only unique within a brand
+	if skus := product.SKUs(p.Variants); len(skus) > 0 && p.BrandID != "" {
+		query := `
+			SELECT p.canonical_id FROM product_variants v
+			JOIN products p ON p.id = v.product_id
+			WHERE upper(v.sku) = ANY($1) AND p.brand_id::text = $4 AND p.id != $2 AND p.canonical_id IS NOT NULL
+			ORDER BY p.canonical_id::text = $3 DESC
+			LIMIT 1
+		`
+		id, err := queryID(ctx, tx, query, pq.Array(skus), p.ID, current, p.BrandID)
+		if err != nil || id != "" {
+			return id, err
+		}
+	}
+
+	if key == "" {
+		return "", nil
+	}
+
+	rows, err := tx.QueryContext(ctx, `SELECT id FROM canonical_products WHERE match_key = $1 ORDER BY created_at`, key)
+	if err != nil {
+		return "", err
+	}
+	var candidates []string
+	for rows.Next() {
+		var id string
+		if err := rows.Scan(&id); err != nil {
+			rows.Close()
+			return "", err
+		}
+		candidates = append(candidates, id)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return "", err
+	}
+
+	values := product.OptionValues(p.Variants)
+	best, bestScore := "", 0.0
+	for _, id := range candidates {
+		if id == current {
+			return id, nil
+		}
+		others, err := r.canonicalOptionValues(ctx, tx, id, p.ID)
+		if err != nil {
+			return "", err
+		}
+		if score := product.OptionSimilarity(values, others); score >= product.MinOptionSimilarity && score > bestScore {
+			best, bestScore = id, score
+		}
+	}
+
+	return best, nil
+}
+
+// canonicalOptionValues returns the option values of the other products in
+// a canonical product
+func (r *ProductRepository) canonicalOptionValues(ctx context.Context, tx *sql.Tx, canonicalID, productID string) (map[string]bool, error) {
+	query := `
+		SELECT v.options FROM product_variants v
+		JOIN products p ON p.id = v.product_id
+		WHERE p.canonical_id = $1 AND p.id != $2
+	`
+
+	rows, err := tx.QueryContext(ctx, query, canonicalID, productID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var variants []product.Variant
+	for rows.Next() {
+		var optionsJSON []byte
+		if err := rows.Scan(&optionsJSON); err != nil {
+			return nil, err
+		}
+		var v product.Variant
+		if len(optionsJSON) > 0 {
+			if err := json.Unmarshal(optionsJSON, &v.Options); err != nil {
+				continue
+			}
+		}
+		variants = append(variants, v)
+	}
+
+	return product.OptionValues(variants), rows.Err()
+}
+
+// createCanonical returns a canonical product for p alone. The product's
+// current one is reused if no other product is in it.
+func (r *ProductRepository) createCanonical(ctx context.Context, tx *sql.Tx, p *product.Product, current string) (string, error) {
+	key := nullString(product.MatchKey(p.Brand, p.Name))
+
+	if current != "" {
+		query := `
+			UPDATE canonical_products SET name = $2, brand_id = $3, match_key = $4
+			WHERE id = $1 AND NOT EXISTS (
+				SELECT 1 FROM products WHERE canonical_id = $1 AND id != $5
+			)
+		`
+		reused, err := rowsAffected(tx.ExecContext(ctx, query, current, p.Name, nullString(p.BrandID), key, p.ID))
+		if err != nil || reused {
+			return current, err
+		}
+	}
+
+	var id string
+	err := tx.QueryRowContext(ctx,
+		`INSERT INTO canonical_products (name, brand_id, match_key) VALUES ($1, $2, $3) RETURNING id`,
+		p.Name, nullString(p.BrandID), key,
+	).Scan(&id)
+	if err != nil {
+		return "", fmt.Errorf("failed to create canonical product: %w", err)
+	}
+	return id, nil
+}
+
+// setCanonical moves a product to a canonical product, deleting the one it
+// leaves if that is now empty
+func (r *ProductRepository) setCanonical(ctx context.Context, tx *sql.Tx, productID, canonicalID string, locked bool) (bool, error) {
+	var previous sql.NullString
+	err := tx.QueryRowContext(ctx, `
+		UPDATE products p SET canonical_id = $2, canonical_locked = $3
+		FROM products old
+		WHERE p.id = $1 AND old.id = p.id
+		RETURNING old.canonical_id
+	`, productID, canonicalID, locked).Scan(&previous)
+	if err == sql.ErrNoRows {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+
+	if previous.Valid && previous.String != canonicalID {
+		_, err := tx.ExecContext(ctx, `
+			DELETE FROM canonical_products c
+			WHERE c.id = $1 AND NOT EXISTS (SELECT 1 FROM products WHERE canonical_id = c.id)
+		`, previous.String)
+		if err != nil {
+			return false, fmt.Errorf("failed to delete empty canonical product: %w", err)
+		}
+	}
+
+	return true, nil
+}
+
+// queryID returns the single ID a query selects, or an empty string
+func queryID(ctx context.Context, tx *sql.Tx, query string, args ...interface{}) (string, error) {
+	var id string
+	err := tx.QueryRowContext(ctx, query, args...).Scan(&id)
+	if err == sql.ErrNoRows {
+		return "", nil
+	}
+	return id, err
+}
@@ -0,0 +1,85 @@
+package postgres
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/lib/pq"
+	"github.com/meta-boy/mech-alligator/internal/domain/event"
+	"github.com/meta-boy/mech-alligator/internal/domain/product"
+)
+
+// CountUnseen returns how many of a config's listed products there are,
+// and how many of them the scrape run did not see
+func (r *ProductRepository) CountUnseen(ctx context.Context, configID, runID string) (listed, unseen int, err error) {
+	query := `
+		SELECT COUNT(*), COUNT(*) FILTER (WHERE last_seen_job_id IS DISTINCT FROM $2)
+		FROM products
+		WHERE config_id = $1::uuid AND delisted_at IS NULL
+	`
+
+	err = r.db.QueryRowContext(ctx, query, configID, runID).Scan(&listed, &unseen)
+	return listed, unseen, err
+}
+
+// DelistUnseen marks the config's products the scrape run did not see as
+// delisted, returning how many there were. Their variants are kept, so
+// their IDs and history survive if the product comes back, but are made
+// unavailable.
+func (r *ProductRepository) DelistUnseen(ctx context.Context, configID, runID string) (int, error) {
+	tx, err := r.db.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	rows, err := tx.QueryContext(ctx, `
+		UPDATE products SET delisted_at = CURRENT_TIMESTAMP, last_changed_at = CURRENT_TIMESTAMP
+		WHERE config_id = $1::uuid AND delisted_at IS NULL AND last_seen_job_id IS DISTINCT FROM $2
+		RETURNING id, name, COALESCE(brand, ''), COALESCE(reseller, ''), COALESCE(category, ''), url
+	`, configID, runID)
+	if err != nil {
+		return 0, fmt.Errorf("failed to delist products: %w", err)
+	}
+	var ids []string
+	var events []event.Event
+	for rows.Next() {
+		var p product.Product
+		if err := rows.Scan(&p.ID, &p.Name, &p.Brand, &p.Reseller, &p.Category, &p.URL); err != nil {
+			rows.Close()
+			return 0, err
+		}
+		ids = append(ids, p.ID)
+		events = append(events, event.ForProduct(event.ProductDelisted, &p))
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, err
+	}
+	if len(ids) == 0 {
+		return 0, nil
+	}
+
+	_, err = tx.ExecContext(ctx, `
+		INSERT INTO variant_price_history (variant_id, product_id, price, currency, available)
+		SELECT id, product_id, price, currency, false
+		FROM product_variants
+		WHERE product_id = ANY($1::uuid[]) AND gone_at IS NULL AND available
+	`, pq.Array(ids))
+	if err != nil {
+		return 0, fmt.Errorf("failed to record price history: %w", err)
+	}
+
+	_, err = tx.ExecContext(ctx,
+		`UPDATE product_variants SET available = false, last_changed_at = CURRENT_TIMESTAMP WHERE product_id = ANY($1::uuid[]) AND gone_at IS NULL AND available`,
+		pq.Array(ids))
+	if err != nil {
+		return 0, fmt.Errorf("failed to mark variants unavailable: %w", err)
+	}
+
+	if err := insertEvents(ctx, tx, events); err != nil {
+		return 0, err
+	}
+
+	return len(ids), tx.Commit()
+}
@@ -0,0 +1,100 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/meta-boy/mech-alligator/internal/database"
+	"github.com/meta-boy/mech-alligator/internal/domain/event"
+)
+
+// EventRepository reads the outbox product changes write their events to
+type EventRepository struct {
+	db *database.DB
+}
+
+func NewEventRepository(db *database.DB) *EventRepository {
+	return &EventRepository{db: db}
+}
+
+// ClaimPending returns up to limit events that are due, oldest first, and
+// holds them back from other dispatchers for lease. Events that failed
+// maxAttempts times are left alone.
+func (r *EventRepository) ClaimPending(ctx context.Context, limit, maxAttempts int, lease time.Duration) ([]event.Event, error) {
+	query := `
+		UPDATE event_outbox SET attempts = attempts + 1, next_attempt_at = CURRENT_TIMESTAMP + $3 * interval '1 second'
+		WHERE id IN (
+			SELECT id FROM event_outbox
+			WHERE dispatched_at IS NULL AND next_attempt_at <= CURRENT_TIMESTAMP AND attempts < $2
+			ORDER BY id
+			LIMIT $1
+			FOR UPDATE SKIP LOCKED
+		)
+		RETURNING id, type, data, created_at, attempts
+	`
+
+	rows, err := r.db.QueryContext(ctx, query, limit, maxAttempts, lease.Seconds())
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var events []event.Event
+	for rows.Next() {
+		var e event.Event
+		var dataJSON []byte
+		if err := rows.Scan(&e.ID, &e.Type, &dataJSON, &e.CreatedAt, &e.Attempts); err != nil {
+			return nil, err
+		}
+		if err := json.Unmarshal(dataJSON, &e.Data); err != nil {
+			return nil, fmt.Errorf("failed to unmarshal event %d: %w", e.ID, err)
+		}
+		events = append(events, e)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	// RETURNING gives no order
+	sort.Slice(events, func(i, j int) bool { return events[i].ID < events[j].ID })
+	return events, nil
+}
+
+// MarkDispatched records that every consumer took the event
+func (r *EventRepository) MarkDispatched(ctx context.Context, id int64) error {
+	_, err := r.db.ExecContext(ctx,
+		`UPDATE event_outbox SET dispatched_at = CURRENT_TIMESTAMP, last_error = NULL WHERE id = $1`, id)
+	return err
+}
+
+// MarkFailed records why the event could not be dispatched and how long
+// to wait before trying again
+func (r *EventRepository) MarkFailed(ctx context.Context, id int64, reason string, retryIn time.Duration) error {
+	_, err := r.db.ExecContext(ctx,
+		`UPDATE event_outbox SET last_error = $2, next_attempt_at = CURRENT_TIMESTAMP + $3 * interval '1 second' WHERE id = $1`,
+		id, reason, retryIn.Seconds())
+	return err
+}
+
+// insertEvents writes events to the outbox in the transaction that made
+// the changes they describe
+func insertEvents(ctx context.Context, tx *sql.Tx, events []event.Event) error {
+	for _, e := range events {
+		dataJSON, err := json.Marshal(e.Data)
+		if err != nil {
+			return fmt.Errorf("failed to marshal %s event: %w", e.Type, err)
+		}
+
+		_, err = tx.ExecContext(ctx,
+			`INSERT INTO event_outbox (type, product_id, variant_id, data) VALUES ($1, $2, $3, $4)`,
+			e.Type, nullString(e.Data.ProductID), nullString(e.Data.VariantID), dataJSON)
+		if err != nil {
+			return fmt.Errorf("failed to write %s event: %w", e.Type, err)
+		}
+	}
+	return nil
+}
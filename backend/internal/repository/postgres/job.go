@@ -195,13 +195,13 @@ func (r *JobRepository) GetNextPendingAndMarkRunning(ctx context.Context) (*job.
 	}
 	defer tx.Rollback()
 
-	// Simple query - just get pending jobs
+	// Pending jobs that are due, so retries wait out their backoff
 	query := `
 		SELECT id, type, status, payload, result, error_message,
 			   attempts, max_attempts, scheduled_at,
 			   started_at, completed_at, created_at, updated_at
 		FROM jobs
-		WHERE status = 'pending' AND attempts < max_attempts
+		WHERE status = 'pending' AND scheduled_at <= CURRENT_TIMESTAMP AND attempts < max_attempts
 		ORDER BY created_at ASC
 		LIMIT 1
 		FOR UPDATE SKIP LOCKED
@@ -0,0 +1,52 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/meta-boy/mech-alligator/internal/domain/product"
+)
+
+// ListPriceChanges returns the recorded changes of a product's variants,
+// or of one variant, ordered by variant and time. When from is set, the
+// last change before it is included as the price at the start of the range.
+func (r *ProductRepository) ListPriceChanges(ctx context.Context, productID, variantID string, from, to *time.Time) ([]product.PriceChange, error) {
+	query := `
+		SELECT h.variant_id, COALESCE(v.name, ''), h.price, COALESCE(h.currency, ''), h.available, h.recorded_at
+		FROM variant_price_history h
+		JOIN product_variants v ON v.id = h.variant_id
+		WHERE h.product_id = $1
+			AND ($2 = '' OR h.variant_id::text = $2)
+			AND ($4::timestamp IS NULL OR h.recorded_at <= $4)
+			AND ($3::timestamp IS NULL OR h.recorded_at >= $3 OR h.recorded_at = (
+				SELECT MAX(prev.recorded_at) FROM variant_price_history prev
+				WHERE prev.variant_id = h.variant_id AND prev.recorded_at < $3
+			))
+		ORDER BY v.name, h.variant_id, h.recorded_at, h.id
+	`
+
+	rows, err := r.db.QueryContext(ctx, query, productID, variantID, nullTime(from), nullTime(to))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var changes []product.PriceChange
+	for rows.Next() {
+		var c product.PriceChange
+		if err := rows.Scan(&c.VariantID, &c.VariantName, &c.Price, &c.Currency, &c.Available, &c.RecordedAt); err != nil {
+			return nil, err
+		}
+		changes = append(changes, c)
+	}
+
+	return changes, rows.Err()
+}
+
+func nullTime(t *time.Time) sql.NullTime {
+	if t == nil {
+		return sql.NullTime{}
+	}
+	return sql.NullTime{Time: *t, Valid: true}
+}
@@ -2,13 +2,17 @@ package postgres
 
 import (
 	"context"
+	"crypto/md5"
 	"database/sql"
+	"encoding/hex"
 	"encoding/json"
 	"fmt"
+	"math"
 	"strings"
 
 	"github.com/lib/pq"
 	"github.com/meta-boy/mech-alligator/internal/database"
+	"github.com/meta-boy/mech-alligator/internal/domain/event"
 	"github.com/meta-boy/mech-alligator/internal/domain/product"
 )
 
@@ -23,9 +27,12 @@ func NewProductRepository(db *database.DB) *ProductRepository {
 func (r *ProductRepository) GetByID(ctx context.Context, id string) (*product.Product, error) {
 	query := `
 		SELECT 
-			p.id, p.name, p.description, p.handle, p.url, p.brand, p.reseller,
+			p.id, p.name, p.description, p.handle, p.url, p.brand,
+			COALESCE(p.brand_id::text, ''), COALESCE(p.raw_brand, ''), p.reseller,
 			p.reseller_id, p.category, p.tags, p.images, p.source_type, 
-			p.source_id, p.source_metadata
+			p.source_id, p.source_metadata, COALESCE(p.canonical_id::text, ''),
+			COALESCE(p.config_id::text, ''), p.delisted_at,
+			p.first_seen_at, p.last_seen_at, p.last_changed_at
 		FROM products p
 		WHERE p.id = $1
 	`
@@ -33,11 +40,15 @@ func (r *ProductRepository) GetByID(ctx context.Context, id string) (*product.Pr
 	var p product.Product
 	var tagsArray, imagesArray pq.StringArray
 	var sourceMetadataJSON []byte
+	var delistedAt sql.NullTime
 
 	err := r.db.QueryRowContext(ctx, query, id).Scan(
-		&p.ID, &p.Name, &p.Description, &p.Handle, &p.URL, &p.Brand, &p.Reseller,
+		&p.ID, &p.Name, &p.Description, &p.Handle, &p.URL, &p.Brand,
+		&p.BrandID, &p.RawBrand, &p.Reseller,
 		&p.ResellerID, &p.Category, &tagsArray, &imagesArray, &p.SourceType,
-		&p.SourceID, &sourceMetadataJSON,
+		&p.SourceID, &sourceMetadataJSON, &p.CanonicalID,
+		&p.ConfigID, &delistedAt,
+		&p.FirstSeenAt, &p.LastSeenAt, &p.LastChangedAt,
 	)
 
 	if err != nil {
@@ -50,6 +61,9 @@ func (r *ProductRepository) GetByID(ctx context.Context, id string) (*product.Pr
 	// Convert arrays
 	p.Tags = []string(tagsArray)
 	p.Images = []string(imagesArray)
+	if delistedAt.Valid {
+		p.DelistedAt = &delistedAt.Time
+	}
 
 	// Parse source metadata
 	if len(sourceMetadataJSON) > 0 {
@@ -77,7 +91,7 @@ func (r *ProductRepository) List(ctx context.Context, req product.ListRequest) (
 	countQuery := fmt.Sprintf(`
 		SELECT COUNT(DISTINCT p.id) 
 		FROM products p 
-		LEFT JOIN product_variants pv ON p.id = pv.product_id
+		LEFT JOIN product_variants pv ON p.id = pv.product_id AND pv.gone_at IS NULL
 		%s`, whereClause)
 
 	var total int64
@@ -92,12 +106,15 @@ func (r *ProductRepository) List(ctx context.Context, req product.ListRequest) (
 
 	query := fmt.Sprintf(`
 		SELECT DISTINCT
-			p.id, p.name, p.description, p.handle, p.url, p.brand, p.reseller,
+			p.id, p.name, p.description, p.handle, p.url, p.brand,
+			COALESCE(p.brand_id::text, ''), COALESCE(p.raw_brand, ''), p.reseller,
 			p.reseller_id, p.category, p.tags, p.images, p.source_type, 
-			p.source_id, p.source_metadata,
-			(SELECT COUNT(*) FROM product_variants WHERE product_id = p.id) as variant_count
+			p.source_id, p.source_metadata, COALESCE(p.canonical_id::text, ''),
+			COALESCE(p.config_id::text, ''), p.delisted_at,
+			p.first_seen_at, p.last_seen_at, p.last_changed_at,
+			(SELECT COUNT(*) FROM product_variants WHERE product_id = p.id AND gone_at IS NULL) as variant_count
 		FROM products p
-		LEFT JOIN product_variants pv ON p.id = pv.product_id
+		LEFT JOIN product_variants pv ON p.id = pv.product_id AND pv.gone_at IS NULL
 		%s
 		%s
 		LIMIT $%d OFFSET $%d`,
@@ -117,11 +134,15 @@ func (r *ProductRepository) List(ctx context.Context, req product.ListRequest) (
 		var p product.Product
 		var tagsArray, imagesArray pq.StringArray
 		var sourceMetadataJSON []byte
+		var delistedAt sql.NullTime
 
 		err := rows.Scan(
-			&p.ID, &p.Name, &p.Description, &p.Handle, &p.URL, &p.Brand, &p.Reseller,
+			&p.ID, &p.Name, &p.Description, &p.Handle, &p.URL, &p.Brand,
+			&p.BrandID, &p.RawBrand, &p.Reseller,
 			&p.ResellerID, &p.Category, &tagsArray, &imagesArray, &p.SourceType,
-			&p.SourceID, &sourceMetadataJSON, &p.VariantCount,
+			&p.SourceID, &sourceMetadataJSON, &p.CanonicalID,
+			&p.ConfigID, &delistedAt,
+			&p.FirstSeenAt, &p.LastSeenAt, &p.LastChangedAt, &p.VariantCount,
 		)
 		if err != nil {
 			return nil, 0, fmt.Errorf("failed to scan product: %w", err)
@@ -130,6 +151,9 @@ func (r *ProductRepository) List(ctx context.Context, req product.ListRequest) (
 		// Convert arrays
 		p.Tags = []string(tagsArray)
 		p.Images = []string(imagesArray)
+		if delistedAt.Valid {
+			p.DelistedAt = &delistedAt.Time
+		}
 
 		// Parse source metadata
 		if len(sourceMetadataJSON) > 0 {
@@ -144,38 +168,72 @@ func (r *ProductRepository) List(ctx context.Context, req product.ListRequest) (
 	return products, total, rows.Err()
 }
 
-func (r *ProductRepository) Save(ctx context.Context, p *product.Product) error {
+// Save inserts or updates a scraped product with its variants, writing
+// only what changed, and reports what that was
+func (r *ProductRepository) Save(ctx context.Context, p *product.Product) (*product.SaveOutcome, error) {
 	tx, err := r.db.DB.BeginTx(ctx, nil)
 	if err != nil {
-		return fmt.Errorf("failed to begin transaction: %w", err)
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
 	}
 	defer tx.Rollback()
 
 	// Check if product exists by source
 	existingID, err := r.findExistingProduct(ctx, tx, p.SourceType, p.SourceID, p.ResellerID)
 	if err != nil {
-		return fmt.Errorf("failed to check existing product: %w", err)
+		return nil, fmt.Errorf("failed to check existing product: %w", err)
 	}
 
+	outcome := &product.SaveOutcome{Status: product.SaveUnchanged}
+	var events []event.Event
 	if existingID != "" {
 		// Update existing product
 		p.ID = existingID
-		err = r.updateProduct(ctx, tx, p)
+		changed, err := r.updateProduct(ctx, tx, p)
+		if err != nil {
+			return nil, err
+		}
+		if changed {
+			outcome.Status = product.SaveUpdated
+		}
 	} else {
 		// Insert new product
-		err = r.insertProduct(ctx, tx, p)
+		if err := r.insertProduct(ctx, tx, p); err != nil {
+			return nil, err
+		}
+		outcome.Status = product.SaveCreated
+		events = append(events, event.ForProduct(event.ProductCreated, p))
 	}
 
+	// Save variants
+	variantEvents, err := r.saveVariants(ctx, tx, p, outcome)
 	if err != nil {
-		return err
+		return nil, fmt.Errorf("failed to save variants: %w", err)
+	}
+	events = append(events, variantEvents...)
+	if outcome.Status == product.SaveUnchanged && outcome.VariantsCreated+outcome.VariantsUpdated+outcome.VariantsGone > 0 {
+		outcome.Status = product.SaveUpdated
 	}
 
-	// Save variants
-	if err := r.saveVariants(ctx, tx, p.ID, p.Variants); err != nil {
-		return fmt.Errorf("failed to save variants: %w", err)
+	// Record that the product was seen, and by which scrape so a complete
+	// run can tell which of its config's products are no longer listed
+	if err := r.markSeen(ctx, tx, p, outcome.Status == product.SaveUpdated); err != nil {
+		return nil, fmt.Errorf("failed to mark product seen: %w", err)
+	}
+
+	// Group the product with its listings at other resellers
+	if err := r.assignCanonical(ctx, tx, p); err != nil {
+		return nil, fmt.Errorf("failed to match canonical product: %w", err)
+	}
+
+	// Downstream consumers learn of the changes only if they are committed
+	if err := insertEvents(ctx, tx, events); err != nil {
+		return nil, err
 	}
 
-	return tx.Commit()
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return outcome, nil
 }
 
 func (r *ProductRepository) insertProduct(ctx context.Context, tx *sql.Tx, p *product.Product) error {
@@ -183,78 +241,279 @@ func (r *ProductRepository) insertProduct(ctx context.Context, tx *sql.Tx, p *pr
 
 	query := `
 		INSERT INTO products (
-			name, description, handle, url, brand, reseller, reseller_id,
+			name, description, handle, url, brand, brand_id, raw_brand, reseller, reseller_id,
 			category, tags, images, source_type, source_id, source_metadata
-		) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13)
+		) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15)
 		RETURNING id
 	`
 
 	err := tx.QueryRowContext(ctx, query,
-		p.Name, p.Description, p.Handle, p.URL, p.Brand, p.Reseller, p.ResellerID,
-		p.Category, pq.Array(p.Tags), pq.Array(p.Images), p.SourceType, p.SourceID, sourceMetadataJSON,
+		p.Name, p.Description, p.Handle, p.URL, p.Brand, nullString(p.BrandID), p.RawBrand,
+		p.Reseller, p.ResellerID, p.Category, pq.Array(p.Tags), pq.Array(p.Images),
+		p.SourceType, p.SourceID, sourceMetadataJSON,
 	).Scan(&p.ID)
 
 	return err
 }
 
-func (r *ProductRepository) updateProduct(ctx context.Context, tx *sql.Tx, p *product.Product) error {
+// updateProduct saves the product's fields if any of them changed,
+// reporting whether they did. A delisted product is listed again.
+func (r *ProductRepository) updateProduct(ctx context.Context, tx *sql.Tx, p *product.Product) (bool, error) {
 	sourceMetadataJSON, _ := json.Marshal(p.SourceMetadata)
 
 	query := `
 		UPDATE products SET
 			name = $2, description = $3, handle = $4, url = $5, brand = $6, 
-			reseller = $7, category = $8, tags = $9, images = $10, 
-			source_metadata = $11
-		WHERE id = $1
+			brand_id = $7, raw_brand = $8, reseller = $9, category = $10,
+			tags = $11, images = $12, source_metadata = $13, delisted_at = NULL
+		WHERE id = $1 AND (
+			name, description, handle, url, brand, brand_id, raw_brand,
+			reseller, category, tags, images, source_metadata, delisted_at
+		) IS DISTINCT FROM (
+			$2, $3, $4, $5, $6, $7::uuid, $8,
+			$9, $10, $11::text[], $12::text[], $13::jsonb, NULL::timestamp
+		)
 	`
 
-	_, err := tx.ExecContext(ctx, query,
+	return rowsAffected(tx.ExecContext(ctx, query,
 		p.ID, p.Name, p.Description, p.Handle, p.URL, p.Brand,
-		p.Reseller, p.Category, pq.Array(p.Tags), pq.Array(p.Images), sourceMetadataJSON,
-	)
-
-	return err
+		nullString(p.BrandID), p.RawBrand, p.Reseller, p.Category,
+		pq.Array(p.Tags), pq.Array(p.Images), sourceMetadataJSON,
+	))
 }
 
-func (r *ProductRepository) saveVariants(ctx context.Context, tx *sql.Tx, productID string, variants []product.Variant) error {
-	// Delete existing variants
-	_, err := tx.ExecContext(ctx, "DELETE FROM product_variants WHERE product_id = $1", productID)
+// saveVariants upserts the product's variants by source ID, so their IDs
+// stay the same from one scrape to the next, writing only the ones that
+// changed. Stored variants the scrape did not list are marked gone. Every
+// change of price or availability is recorded, and the events the changes
+// make are returned.
+func (r *ProductRepository) saveVariants(ctx context.Context, tx *sql.Tx, p *product.Product, outcome *product.SaveOutcome) ([]event.Event, error) {
+	productID, variants := p.ID, p.Variants
+	existing, err := r.existingVariants(ctx, tx, productID)
 	if err != nil {
-		return fmt.Errorf("failed to delete existing variants: %w", err)
+		return nil, fmt.Errorf("failed to load existing variants: %w", err)
 	}
 
-	// Insert new variants
+	var events []event.Event
+	saved := make(map[string]string) // Source ID to variant ID
 	for i := range variants {
 		variant := &variants[i]
-		optionsJSON, _ := json.Marshal(variant.Options)
+		variant.SourceID = variantSourceID(*variant)
+		variant.ProductID = productID
+
+		// A source listing the same variant twice keeps the first
+		if id, seen := saved[variant.SourceID]; seen {
+			variant.ID = id
+			continue
+		}
+
+		previous, found := existing[variant.SourceID]
+		if found && !previous.changed(*variant) {
+			variant.ID = previous.ID
+			saved[variant.SourceID] = variant.ID
+			outcome.VariantsUnchanged++
+			continue
+		}
 
+		optionsJSON, _ := json.Marshal(variant.Options)
 		query := `
 			INSERT INTO product_variants (
-				product_id, name, sku, price, currency, available, 
+				product_id, name, sku, gtin, price, currency, available, 
 				url, images, options, source_id
-			) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)
+			) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11)
+			ON CONFLICT (product_id, source_id) DO UPDATE SET
+				name = EXCLUDED.name, sku = EXCLUDED.sku, gtin = EXCLUDED.gtin,
+				price = EXCLUDED.price, currency = EXCLUDED.currency, available = EXCLUDED.available,
+				url = EXCLUDED.url, images = EXCLUDED.images, options = EXCLUDED.options,
+				gone_at = NULL, last_changed_at = CURRENT_TIMESTAMP
 			RETURNING id
 		`
 
 		err := tx.QueryRowContext(ctx, query,
-			productID, variant.Name, variant.SKU, variant.Price,
-			variant.Currency, variant.Available, variant.URL, pq.Array(variant.Images),
+			productID, variant.Name, variant.SKU, nullString(product.NormalizeGTIN(variant.GTIN)),
+			variant.Price, variant.Currency, variant.Available, variant.URL, pq.Array(variant.Images),
 			optionsJSON, variant.SourceID,
 		).Scan(&variant.ID)
+		if err != nil {
+			return nil, fmt.Errorf("failed to upsert variant: %w", err)
+		}
+		saved[variant.SourceID] = variant.ID
 
+		if found {
+			outcome.VariantsUpdated++
+			events = append(events, event.VariantChanges(p, previous.Variant, *variant)...)
+		} else {
+			outcome.VariantsCreated++
+		}
+
+		if !found || previous.priceChanged(*variant) {
+			if err := r.recordPrice(ctx, tx, variant); err != nil {
+				return nil, fmt.Errorf("failed to record price history: %w", err)
+			}
+		}
+	}
+
+	// Variants the source no longer lists stay, unavailable, so their IDs
+	// and history survive if they come back
+	for sourceID, previous := range existing {
+		if _, seen := saved[sourceID]; seen || previous.Gone {
+			continue
+		}
+
+		_, err := tx.ExecContext(ctx,
+			`UPDATE product_variants SET gone_at = CURRENT_TIMESTAMP, available = false, last_changed_at = CURRENT_TIMESTAMP WHERE id = $1`, previous.ID)
 		if err != nil {
-			return fmt.Errorf("failed to insert variant: %w", err)
+			return nil, fmt.Errorf("failed to mark variant gone: %w", err)
+		}
+		outcome.VariantsGone++
+
+		if previous.Available {
+			gone := previous.Variant
+			gone.ProductID = productID
+			gone.Available = false
+			if err := r.recordPrice(ctx, tx, &gone); err != nil {
+				return nil, fmt.Errorf("failed to record price history: %w", err)
+			}
 		}
 	}
 
-	return nil
+	return events, nil
 }
 
-func (r *ProductRepository) getProductVariants(ctx context.Context, productID string) ([]product.Variant, error) {
+// markSeen records that the product and its current variants were listed,
+// by which scrape job and config, and whether saving changed the product
+func (r *ProductRepository) markSeen(ctx context.Context, tx *sql.Tx, p *product.Product, changed bool) error {
 	query := `
-		SELECT id, name, sku, price, currency, available, url, images, options, source_id
+		UPDATE products SET
+			last_seen_at = CURRENT_TIMESTAMP,
+			last_changed_at = CASE WHEN $2 THEN CURRENT_TIMESTAMP ELSE last_changed_at END,
+			last_seen_job_id = COALESCE($3, last_seen_job_id),
+			config_id = COALESCE($4, config_id)
+		WHERE id = $1
+	`
+	if _, err := tx.ExecContext(ctx, query, p.ID, changed, nullString(p.ScrapeJobID), nullString(p.ConfigID)); err != nil {
+		return err
+	}
+
+	_, err := tx.ExecContext(ctx, `
+		UPDATE product_variants SET last_seen_at = CURRENT_TIMESTAMP, last_seen_job_id = COALESCE($2, last_seen_job_id)
+		WHERE product_id = $1 AND gone_at IS NULL
+	`, p.ID, nullString(p.ScrapeJobID))
+	return err
+}
+
+// storedVariant is a variant as saved, which saveVariants compares the
+// scraped one with
+type storedVariant struct {
+	product.Variant
+	Gone bool
+}
+
+// changed reports whether saving v would change the stored variant
+func (s storedVariant) changed(v product.Variant) bool {
+	return s.Gone || s.priceChanged(v) ||
+		s.Name != v.Name || s.SKU != v.SKU || s.GTIN != product.NormalizeGTIN(v.GTIN) ||
+		s.URL != v.URL || !sameStrings(s.Images, v.Images) || !sameOptions(s.Options, v.Options)
+}
+
+// priceChanged reports whether a scraped variant's price, currency or
+// availability differs from the stored one
+func (s storedVariant) priceChanged(v product.Variant) bool {
+	return math.Round(s.Price*100) != math.Round(v.Price*100) ||
+		s.Currency != v.Currency || s.Available != v.Available
+}
+
+// existingVariants returns a product's stored variants, gone ones
+// included, keyed by source ID
+func (r *ProductRepository) existingVariants(ctx context.Context, tx *sql.Tx, productID string) (map[string]storedVariant, error) {
+	query := `
+		SELECT id, COALESCE(name, ''), COALESCE(sku, ''), COALESCE(gtin, ''), price, COALESCE(currency, ''),
+			COALESCE(available, false), COALESCE(url, ''), images, options, source_id, gone_at IS NOT NULL
 		FROM product_variants
 		WHERE product_id = $1
+	`
+
+	rows, err := tx.QueryContext(ctx, query, productID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	existing := make(map[string]storedVariant)
+	for rows.Next() {
+		var s storedVariant
+		var imagesArray pq.StringArray
+		var optionsJSON []byte
+		if err := rows.Scan(
+			&s.ID, &s.Name, &s.SKU, &s.GTIN, &s.Price, &s.Currency,
+			&s.Available, &s.URL, &imagesArray, &optionsJSON, &s.SourceID, &s.Gone,
+		); err != nil {
+			return nil, err
+		}
+		s.Images = []string(imagesArray)
+		if len(optionsJSON) > 0 {
+			if err := json.Unmarshal(optionsJSON, &s.Options); err != nil {
+				s.Options = nil
+			}
+		}
+		existing[s.SourceID] = s
+	}
+
+	return existing, rows.Err()
+}
+
+// variantSourceID identifies a variant within its product: by its ID at
+// the source, or by its name for sources that give variants no ID
+func variantSourceID(v product.Variant) string {
+	if v.SourceID != "" {
+		return v.SourceID
+	}
+	sum := md5.Sum([]byte(v.Name))
+	return "name-" + hex.EncodeToString(sum[:])
+}
+
+func (r *ProductRepository) recordPrice(ctx context.Context, tx *sql.Tx, v *product.Variant) error {
+	query := `
+		INSERT INTO variant_price_history (variant_id, product_id, price, currency, available)
+		VALUES ($1, $2, $3, $4, $5)
+	`
+
+	_, err := tx.ExecContext(ctx, query, v.ID, v.ProductID, v.Price, v.Currency, v.Available)
+	return err
+}
+
+// sameStrings compares slices, treating nil and empty as equal
+func sameStrings(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// sameOptions compares option maps, treating nil and empty as equal
+func sameOptions(a, b map[string]string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for key, value := range a {
+		if other, ok := b[key]; !ok || other != value {
+			return false
+		}
+	}
+	return true
+}
+
+func (r *ProductRepository) getProductVariants(ctx context.Context, productID string) ([]product.Variant, error) {
+	query := `
+		SELECT id, name, sku, COALESCE(gtin, ''), price, currency, available, url, images, options, source_id,
+			first_seen_at, last_seen_at, last_changed_at
+		FROM product_variants
+		WHERE product_id = $1 AND gone_at IS NULL
 		ORDER BY price ASC
 	`
 
@@ -271,8 +530,9 @@ func (r *ProductRepository) getProductVariants(ctx context.Context, productID st
 		var optionsJSON []byte
 
 		err := rows.Scan(
-			&v.ID, &v.Name, &v.SKU, &v.Price, &v.Currency, &v.Available,
+			&v.ID, &v.Name, &v.SKU, &v.GTIN, &v.Price, &v.Currency, &v.Available,
 			&v.URL, &imagesArray, &optionsJSON, &v.SourceID,
+			&v.FirstSeenAt, &v.LastSeenAt, &v.LastChangedAt,
 		)
 		if err != nil {
 			return nil, err
@@ -347,6 +607,16 @@ func (r *ProductRepository) buildWhereClause(req product.ListRequest) (string, [
 		argIndex++
 	}
 
+	if req.Since != nil {
+		conditions = append(conditions, fmt.Sprintf("p.first_seen_at >= $%d", argIndex))
+		args = append(args, *req.Since)
+		argIndex++
+	}
+
+	if !req.IncludeDelisted {
+		conditions = append(conditions, "p.delisted_at IS NULL")
+	}
+
 	whereClause := ""
 	if len(conditions) > 0 {
 		whereClause = "WHERE " + strings.Join(conditions, " AND ")
@@ -362,6 +632,9 @@ func (r *ProductRepository) buildOrderClause(sortBy, sortOrder string) string {
 		"brand":    "p.brand",
 		"reseller": "p.reseller",
 		"price":    "MIN(pv.price)",
+
+		"newest":           "p.first_seen_at",
+		"recently_updated": "p.last_changed_at",
 	}
 
 	field, ok := validSortFields[sortBy]
@@ -374,7 +647,7 @@ func (r *ProductRepository) buildOrderClause(sortBy, sortOrder string) string {
 	}
 
 	if sortBy == "price" {
-		return fmt.Sprintf("GROUP BY p.id, p.name, p.description, p.handle, p.url, p.brand, p.reseller, p.reseller_id, p.category, p.tags, p.images, p.source_type, p.source_id, p.source_metadata ORDER BY %s %s", field, sortOrder)
+		return fmt.Sprintf("GROUP BY p.id, p.name, p.description, p.handle, p.url, p.brand, p.brand_id, p.raw_brand, p.reseller, p.reseller_id, p.category, p.tags, p.images, p.source_type, p.source_id, p.source_metadata, p.canonical_id, p.config_id, p.delisted_at, p.first_seen_at, p.last_seen_at, p.last_changed_at ORDER BY %s %s", field, sortOrder)
 	}
 
 	return fmt.Sprintf("ORDER BY %s %s", field, sortOrder)
@@ -395,6 +668,39 @@ func (r *ProductRepository) findExistingProduct(ctx context.Context, tx *sql.Tx,
 	return id, nil
 }
 
+// ListBrandSources returns what every product's brand is worked out from
+func (r *ProductRepository) ListBrandSources(ctx context.Context) ([]product.BrandSource, error) {
+	query := `
+		SELECT id, name, COALESCE(raw_brand, brand, ''), COALESCE(brand, ''), COALESCE(brand_id::text, '')
+		FROM products
+		ORDER BY id
+	`
+
+	rows, err := r.db.QueryContext(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var sources []product.BrandSource
+	for rows.Next() {
+		var s product.BrandSource
+		if err := rows.Scan(&s.ID, &s.Name, &s.RawBrand, &s.Brand, &s.BrandID); err != nil {
+			return nil, err
+		}
+		sources = append(sources, s)
+	}
+
+	return sources, rows.Err()
+}
+
+// UpdateBrand files a product under another brand
+func (r *ProductRepository) UpdateBrand(ctx context.Context, id, brandID, brandName string) error {
+	_, err := r.db.ExecContext(ctx, `UPDATE products SET brand_id = $2, brand = $3 WHERE id = $1`,
+		id, nullString(brandID), brandName)
+	return err
+}
+
 // Filter helper methods
 func (r *ProductRepository) GetDistinctBrands(ctx context.Context) ([]string, error) {
 	query := `SELECT DISTINCT brand FROM products WHERE brand IS NOT NULL AND brand != '' ORDER BY brand`
@@ -0,0 +1,399 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/lib/pq"
+	"github.com/meta-boy/mech-alligator/internal/config"
+	"github.com/meta-boy/mech-alligator/internal/database"
+	"github.com/meta-boy/mech-alligator/internal/domain/job"
+)
+
+type ResellerRepository struct {
+	db *database.DB
+}
+
+func NewResellerRepository(db *database.DB) *ResellerRepository {
+	return &ResellerRepository{db: db}
+}
+
+const resellerColumns = `id, name, country, website, currency, active`
+
+const configColumns = `id, reseller_id, name, url, source_type, category, active, options, detected_source_type, detection_confidence, detected_at`
+
+func (r *ResellerRepository) GetByID(ctx context.Context, id string) (*config.Reseller, error) {
+	query := `SELECT ` + resellerColumns + ` FROM resellers WHERE id = $1`
+
+	return r.scanReseller(r.db.QueryRowContext(ctx, query, id))
+}
+
+// GetByWebsite finds the reseller whose website is the given origin,
+// ignoring case and a trailing slash
+func (r *ResellerRepository) GetByWebsite(ctx context.Context, website string) (*config.Reseller, error) {
+	query := `
+		SELECT ` + resellerColumns + `
+		FROM resellers
+		WHERE rtrim(lower(website), '/') = $1
+		LIMIT 1
+	`
+
+	website = strings.TrimSuffix(strings.ToLower(website), "/")
+	return r.scanReseller(r.db.QueryRowContext(ctx, query, website))
+}
+
+// List returns the resellers ordered by name, leaving out deactivated ones
+// unless includeInactive is set
+func (r *ResellerRepository) List(ctx context.Context, includeInactive bool) ([]config.Reseller, error) {
+	query := `
+		SELECT ` + resellerColumns + `
+		FROM resellers
+		WHERE $1 OR COALESCE(active, true)
+		ORDER BY name
+	`
+
+	rows, err := r.db.QueryContext(ctx, query, includeInactive)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var resellers []config.Reseller
+	for rows.Next() {
+		reseller, err := r.scanReseller(rows)
+		if err != nil {
+			return nil, err
+		}
+		resellers = append(resellers, *reseller)
+	}
+
+	return resellers, rows.Err()
+}
+
+func (r *ResellerRepository) Create(ctx context.Context, reseller *config.Reseller) error {
+	return r.insertReseller(ctx, r.db, reseller)
+}
+
+// Update saves the reseller's fields, returning false if it does not exist
+func (r *ResellerRepository) Update(ctx context.Context, reseller *config.Reseller) (bool, error) {
+	query := `
+		UPDATE resellers
+		SET name = $2, country = $3, website = $4, currency = $5, active = $6
+		WHERE id = $1
+	`
+
+	result, err := r.db.ExecContext(ctx, query,
+		reseller.ID, reseller.Name, reseller.Country, reseller.Website, reseller.Currency, reseller.Active,
+	)
+	return rowsAffected(result, err)
+}
+
+// SetActive activates or deactivates a reseller, returning false if it
+// does not exist. Its configs keep their own flag, so reactivating the
+// reseller brings back the configs that were active.
+func (r *ResellerRepository) SetActive(ctx context.Context, id string, active bool) (bool, error) {
+	result, err := r.db.ExecContext(ctx, `UPDATE resellers SET active = $2 WHERE id = $1`, id, active)
+	return rowsAffected(result, err)
+}
+
+func (r *ResellerRepository) GetConfig(ctx context.Context, id string) (*config.ResellerConfig, error) {
+	query := `SELECT ` + configColumns + ` FROM reseller_configs WHERE id = $1`
+
+	c, err := r.scanConfig(r.db.QueryRowContext(ctx, query, id))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return c, nil
+}
+
+func (r *ResellerRepository) ListConfigs(ctx context.Context, resellerID string) ([]config.ResellerConfig, error) {
+	query := `
+		SELECT ` + configColumns + `
+		FROM reseller_configs
+		WHERE reseller_id = $1
+		ORDER BY name
+	`
+
+	return r.queryConfigs(ctx, query, resellerID)
+}
+
+// ListActiveConfigs returns the active configs of active resellers
+func (r *ResellerRepository) ListActiveConfigs(ctx context.Context) ([]config.ResellerConfig, error) {
+	query := `
+		SELECT c.id, c.reseller_id, c.name, c.url, c.source_type, c.category, c.active, c.options,
+			c.detected_source_type, c.detection_confidence, c.detected_at
+		FROM reseller_configs c
+		JOIN resellers r ON r.id = c.reseller_id
+		WHERE c.active = true AND COALESCE(r.active, true)
+		ORDER BY c.name
+	`
+
+	return r.queryConfigs(ctx, query)
+}
+
+func (r *ResellerRepository) CreateConfig(ctx context.Context, c *config.ResellerConfig) error {
+	return r.insertConfig(ctx, r.db, c)
+}
+
+// UpdateConfig saves the config's fields, returning false if it does not
+// exist. A config cannot be moved to another reseller.
+func (r *ResellerRepository) UpdateConfig(ctx context.Context, c *config.ResellerConfig) (bool, error) {
+	optionsJSON, err := marshalOptions(c.Options)
+	if err != nil {
+		return false, err
+	}
+
+	query := `
+		UPDATE reseller_configs
+		SET name = $2, url = $3, source_type = $4, category = $5, active = $6, options = $7,
+			detected_source_type = $8, detection_confidence = $9, detected_at = $10
+		WHERE id = $1
+	`
+
+	result, err := r.db.ExecContext(ctx, query,
+		c.ID, c.Name, c.URL, c.SourceType, c.Category, c.Active, optionsJSON,
+		nullString(c.DetectedSourceType), c.DetectionConfidence, c.DetectedAt,
+	)
+	return rowsAffected(result, err)
+}
+
+// SetConfigDetection saves the platform detected for a config, returning
+// false if it does not exist
+func (r *ResellerRepository) SetConfigDetection(ctx context.Context, id, sourceType string, confidence float64, detectedAt time.Time) (bool, error) {
+	query := `
+		UPDATE reseller_configs
+		SET detected_source_type = $2, detection_confidence = $3, detected_at = $4
+		WHERE id = $1
+	`
+
+	result, err := r.db.ExecContext(ctx, query, id, sourceType, confidence, detectedAt)
+	return rowsAffected(result, err)
+}
+
+// SetConfigActive activates or deactivates a config, returning false if it
+// does not exist
+func (r *ResellerRepository) SetConfigActive(ctx context.Context, id string, active bool) (bool, error) {
+	result, err := r.db.ExecContext(ctx, `UPDATE reseller_configs SET active = $2 WHERE id = $1`, id, active)
+	return rowsAffected(result, err)
+}
+
+// CreateWithConfigs inserts the reseller, unless it already has an ID, and
+// its configs in one transaction, filling in the generated IDs
+func (r *ResellerRepository) CreateWithConfigs(ctx context.Context, reseller *config.Reseller, configs []config.ResellerConfig) error {
+	tx, err := r.db.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if reseller.ID == "" {
+		if err := r.insertReseller(ctx, tx, reseller); err != nil {
+			return fmt.Errorf("failed to insert reseller: %w", err)
+		}
+	}
+
+	for i := range configs {
+		c := &configs[i]
+		c.ResellerID = reseller.ID
+		if err := r.insertConfig(ctx, tx, c); err != nil {
+			return fmt.Errorf("failed to insert config %s: %w", c.Name, err)
+		}
+	}
+
+	return tx.Commit()
+}
+
+// LastScrapes returns the most recent scrape job of each config, keyed by
+// config ID. Configs that were never scraped are left out.
+func (r *ResellerRepository) LastScrapes(ctx context.Context, configIDs []string) (map[string]*config.ScrapeStatus, error) {
+	statuses := make(map[string]*config.ScrapeStatus)
+	if len(configIDs) == 0 {
+		return statuses, nil
+	}
+
+	query := `
+		SELECT DISTINCT ON (payload->>'config_id')
+			payload->>'config_id', id, status, COALESCE(error_message, ''),
+			COALESCE((result->>'products_created')::int, 0),
+			COALESCE((result->>'products_updated')::int, 0),
+			COALESCE((result->>'products_unchanged')::int, 0),
+			COALESCE((result->>'products_delisted')::int, 0),
+			COALESCE((result->>'total_errors')::int, 0),
+			COALESCE((result->>'partial')::boolean, false),
+			scheduled_at, started_at, completed_at
+		FROM jobs
+		WHERE type = $1 AND payload->>'config_id' = ANY($2)
+		ORDER BY payload->>'config_id', scheduled_at DESC
+	`
+
+	rows, err := r.db.QueryContext(ctx, query, job.JobTypeScrapeProducts, pq.Array(configIDs))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var configID string
+		var status config.ScrapeStatus
+		var startedAt, completedAt sql.NullTime
+		if err := rows.Scan(
+			&configID, &status.JobID, &status.Status, &status.Error,
+			&status.ProductsCreated, &status.ProductsUpdated, &status.ProductsUnchanged, &status.ProductsDelisted, &status.TotalErrors, &status.Partial,
+			&status.ScheduledAt, &startedAt, &completedAt,
+		); err != nil {
+			return nil, err
+		}
+		if startedAt.Valid {
+			status.StartedAt = &startedAt.Time
+		}
+		if completedAt.Valid {
+			status.CompletedAt = &completedAt.Time
+		}
+		statuses[configID] = &status
+	}
+
+	return statuses, rows.Err()
+}
+
+// queryRower is the database or a transaction
+type queryRower interface {
+	QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row
+}
+
+func (r *ResellerRepository) insertReseller(ctx context.Context, q queryRower, reseller *config.Reseller) error {
+	query := `
+		INSERT INTO resellers (name, country, website, currency, active)
+		VALUES ($1, $2, $3, $4, $5)
+		RETURNING id
+	`
+
+	return q.QueryRowContext(ctx, query,
+		reseller.Name, reseller.Country, reseller.Website, reseller.Currency, reseller.Active,
+	).Scan(&reseller.ID)
+}
+
+func (r *ResellerRepository) insertConfig(ctx context.Context, q queryRower, c *config.ResellerConfig) error {
+	optionsJSON, err := marshalOptions(c.Options)
+	if err != nil {
+		return err
+	}
+
+	query := `
+		INSERT INTO reseller_configs (reseller_id, name, url, source_type, category, active, options,
+			detected_source_type, detection_confidence, detected_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)
+		RETURNING id
+	`
+
+	return q.QueryRowContext(ctx, query,
+		c.ResellerID, c.Name, c.URL, c.SourceType, c.Category, c.Active, optionsJSON,
+		nullString(c.DetectedSourceType), c.DetectionConfidence, c.DetectedAt,
+	).Scan(&c.ID)
+}
+
+func (r *ResellerRepository) queryConfigs(ctx context.Context, query string, args ...interface{}) ([]config.ResellerConfig, error) {
+	rows, err := r.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var configs []config.ResellerConfig
+	for rows.Next() {
+		c, err := r.scanConfig(rows)
+		if err != nil {
+			return nil, err
+		}
+		configs = append(configs, *c)
+	}
+
+	return configs, rows.Err()
+}
+
+func (r *ResellerRepository) scanReseller(scanner interface {
+	Scan(dest ...interface{}) error
+}) (*config.Reseller, error) {
+	var reseller config.Reseller
+	var country, website, currency sql.NullString
+	var active sql.NullBool
+
+	err := scanner.Scan(&reseller.ID, &reseller.Name, &country, &website, &currency, &active)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	reseller.Country = country.String
+	reseller.Website = website.String
+	reseller.Currency = currency.String
+	// The column defaults to true; NULL means it was never set
+	reseller.Active = !active.Valid || active.Bool
+	return &reseller, nil
+}
+
+func (r *ResellerRepository) scanConfig(scanner interface {
+	Scan(dest ...interface{}) error
+}) (*config.ResellerConfig, error) {
+	var c config.ResellerConfig
+	var active sql.NullBool
+	var optionsJSON []byte
+	var detectedType sql.NullString
+	var confidence sql.NullFloat64
+	var detectedAt sql.NullTime
+
+	if err := scanner.Scan(
+		&c.ID, &c.ResellerID, &c.Name, &c.URL,
+		&c.SourceType, &c.Category, &active, &optionsJSON,
+		&detectedType, &confidence, &detectedAt,
+	); err != nil {
+		return nil, err
+	}
+
+	c.Active = !active.Valid || active.Bool
+	c.DetectedSourceType = detectedType.String
+	c.DetectionConfidence = confidence.Float64
+	if detectedAt.Valid {
+		c.DetectedAt = &detectedAt.Time
+	}
+	c.Options = make(map[string]string)
+	if len(optionsJSON) > 0 {
+		if err := json.Unmarshal(optionsJSON, &c.Options); err != nil {
+			// If JSON parsing fails, initialize empty map
+			c.Options = make(map[string]string)
+		}
+	}
+
+	return &c, nil
+}
+
+func marshalOptions(options map[string]string) ([]byte, error) {
+	if options == nil {
+		return []byte("{}"), nil
+	}
+	optionsJSON, err := json.Marshal(options)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal options: %w", err)
+	}
+	return optionsJSON, nil
+}
+
+// rowsAffected reports whether an update or delete matched a row
+func rowsAffected(result sql.Result, err error) (bool, error) {
+	if err != nil {
+		return false, err
+	}
+	n, err := result.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	return n > 0, nil
+}
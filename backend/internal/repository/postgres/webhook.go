@@ -0,0 +1,206 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+
+	"github.com/lib/pq"
+	"github.com/meta-boy/mech-alligator/internal/database"
+	"github.com/meta-boy/mech-alligator/internal/domain/event"
+	"github.com/meta-boy/mech-alligator/internal/domain/webhook"
+)
+
+type WebhookRepository struct {
+	db *database.DB
+}
+
+func NewWebhookRepository(db *database.DB) *WebhookRepository {
+	return &WebhookRepository{db: db}
+}
+
+const subscriptionColumns = `id, url, event_types, categories, brands, resellers, secret, created_at`
+
+const deliveryColumns = `
+	id, subscription_id, COALESCE(event_id, 0), event_type, payload, status, attempts,
+	COALESCE(response_status, 0), COALESCE(last_error, ''), COALESCE(job_id, ''), created_at, delivered_at`
+
+func (r *WebhookRepository) ListSubscriptions(ctx context.Context) ([]webhook.Subscription, error) {
+	rows, err := r.db.QueryContext(ctx, `SELECT `+subscriptionColumns+` FROM webhook_subscriptions ORDER BY created_at`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var subscriptions []webhook.Subscription
+	for rows.Next() {
+		s, err := r.scanSubscription(rows)
+		if err != nil {
+			return nil, err
+		}
+		subscriptions = append(subscriptions, *s)
+	}
+
+	return subscriptions, rows.Err()
+}
+
+func (r *WebhookRepository) GetSubscription(ctx context.Context, id string) (*webhook.Subscription, error) {
+	row := r.db.QueryRowContext(ctx, `SELECT `+subscriptionColumns+` FROM webhook_subscriptions WHERE id::text = $1`, id)
+	s, err := r.scanSubscription(row)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get webhook subscription: %w", err)
+	}
+	return s, nil
+}
+
+func (r *WebhookRepository) CreateSubscription(ctx context.Context, s *webhook.Subscription) error {
+	query := `
+		INSERT INTO webhook_subscriptions (url, event_types, categories, brands, resellers, secret)
+		VALUES ($1, $2, $3, $4, $5, $6)
+		RETURNING id, created_at
+	`
+
+	return r.db.QueryRowContext(ctx, query,
+		s.URL, pq.Array(eventTypeStrings(s.EventTypes)), pq.Array(s.Categories), pq.Array(s.Brands),
+		pq.Array(s.Resellers), s.Secret,
+	).Scan(&s.ID, &s.CreatedAt)
+}
+
+// DeleteSubscription deletes a subscription and its deliveries, returning
+// false if it does not exist
+func (r *WebhookRepository) DeleteSubscription(ctx context.Context, id string) (bool, error) {
+	return rowsAffected(r.db.ExecContext(ctx, `DELETE FROM webhook_subscriptions WHERE id::text = $1`, id))
+}
+
+// CreateDelivery saves a delivery. An event already delivered to the
+// subscription is not saved twice: d is filled in from the existing
+// delivery instead.
+func (r *WebhookRepository) CreateDelivery(ctx context.Context, d *webhook.Delivery) error {
+	var eventID sql.NullInt64
+	if d.EventID != 0 {
+		eventID = sql.NullInt64{Int64: d.EventID, Valid: true}
+	}
+
+	query := `
+		INSERT INTO webhook_deliveries (subscription_id, event_id, event_type, payload)
+		VALUES ($1, $2, $3, $4)
+		ON CONFLICT (subscription_id, event_id) DO UPDATE SET event_type = EXCLUDED.event_type
+		RETURNING ` + deliveryColumns
+
+	row := r.db.QueryRowContext(ctx, query, d.SubscriptionID, eventID, d.EventType, []byte(d.Payload))
+	saved, err := r.scanDelivery(row)
+	if err != nil {
+		return fmt.Errorf("failed to create webhook delivery: %w", err)
+	}
+	*d = *saved
+	return nil
+}
+
+func (r *WebhookRepository) GetDelivery(ctx context.Context, id string) (*webhook.Delivery, error) {
+	row := r.db.QueryRowContext(ctx, `SELECT `+deliveryColumns+` FROM webhook_deliveries WHERE id::text = $1`, id)
+	d, err := r.scanDelivery(row)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get webhook delivery: %w", err)
+	}
+	return d, nil
+}
+
+// ListDeliveries returns a subscription's latest deliveries, of one status
+// if status is set
+func (r *WebhookRepository) ListDeliveries(ctx context.Context, subscriptionID string, status webhook.DeliveryStatus, limit int) ([]webhook.Delivery, error) {
+	query := `
+		SELECT ` + deliveryColumns + `
+		FROM webhook_deliveries
+		WHERE subscription_id::text = $1 AND ($2 = '' OR status = $2)
+		ORDER BY created_at DESC
+		LIMIT $3
+	`
+
+	rows, err := r.db.QueryContext(ctx, query, subscriptionID, status, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var deliveries []webhook.Delivery
+	for rows.Next() {
+		d, err := r.scanDelivery(rows)
+		if err != nil {
+			return nil, err
+		}
+		deliveries = append(deliveries, *d)
+	}
+
+	return deliveries, rows.Err()
+}
+
+// UpdateDelivery saves the outcome of sending a delivery
+func (r *WebhookRepository) UpdateDelivery(ctx context.Context, d *webhook.Delivery) error {
+	query := `
+		UPDATE webhook_deliveries SET
+			status = $2, attempts = $3, response_status = $4, last_error = $5, job_id = $6, delivered_at = $7
+		WHERE id = $1
+	`
+
+	_, err := r.db.ExecContext(ctx, query,
+		d.ID, d.Status, d.Attempts, sql.NullInt64{Int64: int64(d.ResponseStatus), Valid: d.ResponseStatus != 0},
+		nullString(d.LastError), nullString(d.JobID), nullTime(d.DeliveredAt),
+	)
+	return err
+}
+
+func (r *WebhookRepository) scanSubscription(scanner interface {
+	Scan(dest ...interface{}) error
+}) (*webhook.Subscription, error) {
+	var s webhook.Subscription
+	var eventTypes, categories, brands, resellers pq.StringArray
+	if err := scanner.Scan(
+		&s.ID, &s.URL, &eventTypes, &categories, &brands, &resellers, &s.Secret, &s.CreatedAt,
+	); err != nil {
+		return nil, err
+	}
+
+	for _, t := range eventTypes {
+		s.EventTypes = append(s.EventTypes, event.Type(t))
+	}
+	s.Categories = []string(categories)
+	s.Brands = []string(brands)
+	s.Resellers = []string(resellers)
+
+	return &s, nil
+}
+
+func (r *WebhookRepository) scanDelivery(scanner interface {
+	Scan(dest ...interface{}) error
+}) (*webhook.Delivery, error) {
+	var d webhook.Delivery
+	var payload []byte
+	var deliveredAt sql.NullTime
+	if err := scanner.Scan(
+		&d.ID, &d.SubscriptionID, &d.EventID, &d.EventType, &payload, &d.Status, &d.Attempts,
+		&d.ResponseStatus, &d.LastError, &d.JobID, &d.CreatedAt, &deliveredAt,
+	); err != nil {
+		return nil, err
+	}
+
+	d.Payload = payload
+	if deliveredAt.Valid {
+		d.DeliveredAt = &deliveredAt.Time
+	}
+
+	return &d, nil
+}
+
+func eventTypeStrings(types []event.Type) []string {
+	strs := make([]string, len(types))
+	for i, t := range types {
+		strs[i] = string(t)
+	}
+	return strs
+}
@@ -0,0 +1,228 @@
+package scraper
+
+import (
+	"crypto/sha256"
+	"encoding/gob"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// ResponseCache keeps the last body and validators (ETag, Last-Modified) of
+// each fetched URL on disk, so later fetches can be made conditional and a
+// 304 Not Modified answered from the stored body. Entries not used for
+// maxAge are dropped, and once the cache grows past maxSize the least
+// recently used entries are pruned until it is back under 90% of it.
+type ResponseCache struct {
+	dir     string
+	maxSize int64         // Bytes, 0 for no limit
+	maxAge  time.Duration // 0 for no limit
+
+	size      atomic.Int64 // Bytes on disk, as of the last prune plus what was stored since
+	mu        sync.Mutex   // Held while pruning
+	lastPrune time.Time
+}
+
+// pruneInterval is how often stores check the cache for expired entries
+// when it is under its size limit
+const pruneInterval = time.Hour
+
+// NewResponseCache creates a cache rooted at dir, creating it if needed,
+// and prunes what an earlier run left over the limits
+func NewResponseCache(dir string, maxSize int64, maxAge time.Duration) (*ResponseCache, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, fmt.Errorf("failed to create cache directory: %w", err)
+	}
+
+	c := &ResponseCache{dir: dir, maxSize: max(maxSize, 0), maxAge: max(maxAge, 0)}
+	if err := c.prune(); err != nil {
+		return nil, fmt.Errorf("failed to prune cache: %w", err)
+	}
+	return c, nil
+}
+
+// cacheEntry is what is stored for one URL
+type cacheEntry struct {
+	URL          string // Final URL after redirects
+	ETag         string
+	LastModified string
+	Header       http.Header
+	Body         []byte
+	StoredAt     time.Time
+}
+
+// path spreads entries over 256 subdirectories keyed by the URL hash
+func (c *ResponseCache) path(rawURL string) string {
+	sum := sha256.Sum256([]byte(rawURL))
+	key := hex.EncodeToString(sum[:])
+	return filepath.Join(c.dir, key[:2], key)
+}
+
+// load returns the entry for rawURL, or nil when there is none, it has
+// expired or it cannot be read
+func (c *ResponseCache) load(rawURL string) *cacheEntry {
+	file, err := os.Open(c.path(rawURL))
+	if err != nil {
+		return nil
+	}
+	defer file.Close()
+
+	if info, err := file.Stat(); err != nil || c.expired(info.ModTime(), time.Now()) {
+		return nil
+	}
+
+	var entry cacheEntry
+	if err := gob.NewDecoder(file).Decode(&entry); err != nil {
+		return nil
+	}
+	return &entry
+}
+
+// store writes the entry through a temporary file so concurrent readers
+// never see a partial one
+func (c *ResponseCache) store(rawURL string, entry *cacheEntry) error {
+	path := c.path(rawURL)
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(path), ".tmp-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if err := gob.NewEncoder(tmp).Encode(entry); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	info, err := os.Stat(tmp.Name())
+	if err != nil {
+		return err
+	}
+
+	var replaced int64
+	if old, err := os.Stat(path); err == nil {
+		replaced = old.Size()
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return err
+	}
+
+	size := c.size.Add(info.Size() - replaced)
+	if (c.maxSize > 0 && size > c.maxSize) || c.pruneDue() {
+		if err := c.prune(); err != nil {
+			log.Printf("Warning: Failed to prune scraper cache: %v", err)
+		}
+	}
+	return nil
+}
+
+// touch marks the entry for rawURL as used, keeping it from being pruned
+func (c *ResponseCache) touch(rawURL string) {
+	now := time.Now()
+	os.Chtimes(c.path(rawURL), now, now)
+}
+
+// expired reports whether an entry last used at modTime is past maxAge
+func (c *ResponseCache) expired(modTime, now time.Time) bool {
+	return c.maxAge > 0 && now.Sub(modTime) > c.maxAge
+}
+
+func (c *ResponseCache) pruneDue() bool {
+	if c.maxAge == 0 || !c.mu.TryLock() {
+		return false
+	}
+	defer c.mu.Unlock()
+
+	return time.Since(c.lastPrune) > pruneInterval
+}
+
+// prune deletes expired entries and, when the cache is over maxSize, the
+// least recently used ones until it is under 90% of it. A prune already
+// running makes it return straight away.
+func (c *ResponseCache) prune() error {
+	if !c.mu.TryLock() {
+		return nil
+	}
+	defer c.mu.Unlock()
+
+	type file struct {
+		path    string
+		size    int64
+		modTime time.Time
+	}
+
+	now := time.Now()
+	var files []file
+	var total int64
+	err := filepath.WalkDir(c.dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			// Entries can be replaced or pruned while walking
+			if errors.Is(err, fs.ErrNotExist) {
+				return nil
+			}
+			return err
+		}
+		if d.IsDir() {
+			return nil
+		}
+		info, err := d.Info()
+		if err != nil {
+			return nil
+		}
+
+		// Temporary files are only left behind by a crash mid-store
+		stale := strings.HasPrefix(d.Name(), ".tmp-") && now.Sub(info.ModTime()) > pruneInterval
+		if stale || c.expired(info.ModTime(), now) {
+			os.Remove(path)
+			return nil
+		}
+
+		files = append(files, file{path: path, size: info.Size(), modTime: info.ModTime()})
+		total += info.Size()
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	if c.maxSize > 0 && total > c.maxSize {
+		sort.Slice(files, func(i, j int) bool { return files[i].modTime.Before(files[j].modTime) })
+		target := c.maxSize / 10 * 9
+		for _, f := range files {
+			if total <= target {
+				break
+			}
+			if err := os.Remove(f.path); err == nil || errors.Is(err, fs.ErrNotExist) {
+				total -= f.size
+			}
+		}
+	}
+
+	c.size.Store(total)
+	c.lastPrune = now
+	return nil
+}
+
+// cacheable reports whether a 200 response can be revalidated later and the
+// server does not forbid storing it
+func cacheable(header http.Header) bool {
+	if header.Get("ETag") == "" && header.Get("Last-Modified") == "" {
+		return false
+	}
+	return !strings.Contains(strings.ToLower(header.Get("Cache-Control")), "no-store")
+}
@@ -0,0 +1,291 @@
+package scraper
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestCacheable(t *testing.T) {
+	tests := []struct {
+		header http.Header
+		want   bool
+	}{
+		{http.Header{"Etag": {`"abc"`}}, true},
+		{http.Header{"Last-Modified": {"Wed, 21 Oct 2015 07:28:00 GMT"}}, true},
+		{http.Header{"Etag": {`"abc"`}, "Cache-Control": {"max-age=0, must-revalidate"}}, true},
+		{http.Header{"Etag": {`"abc"`}, "Cache-Control": {"private, No-Store"}}, false},
+		{http.Header{"Cache-Control": {"max-age=3600"}}, false}, // Nothing to revalidate with
+		{http.Header{}, false},
+	}
+
+	for _, tt := range tests {
+		if got := cacheable(tt.header); got != tt.want {
+			t.Errorf("cacheable(%v) = %v, want %v", tt.header, got, tt.want)
+		}
+	}
+}
+
+func TestResponseCacheExpired(t *testing.T) {
+	now := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		maxAge  time.Duration
+		modTime time.Time
+		want    bool
+	}{
+		{time.Hour, now.Add(-30 * time.Minute), false},
+		{time.Hour, now.Add(-time.Hour), false},
+		{time.Hour, now.Add(-time.Hour - time.Second), true},
+		{0, now.Add(-365 * 24 * time.Hour), false}, // No limit
+	}
+
+	for _, tt := range tests {
+		c := &ResponseCache{maxAge: tt.maxAge}
+		if got := c.expired(tt.modTime, now); got != tt.want {
+			t.Errorf("expired(%v) with maxAge %v = %v, want %v", now.Sub(tt.modTime), tt.maxAge, got, tt.want)
+		}
+	}
+}
+
+func TestResponseCacheStoreLoad(t *testing.T) {
+	c, err := NewResponseCache(t.TempDir(), 0, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	const u = "https://shop.example.com/products.json"
+	if entry := c.load(u); entry != nil {
+		t.Errorf("load() before store = %+v, want nil", entry)
+	}
+
+	stored := &cacheEntry{URL: u, ETag: `"v1"`, Header: http.Header{"Etag": {`"v1"`}}, Body: []byte("first"), StoredAt: time.Now()}
+	if err := c.store(u, stored); err != nil {
+		t.Fatalf("store() error = %v", err)
+	}
+	entry := c.load(u)
+	if entry == nil || entry.ETag != `"v1"` || string(entry.Body) != "first" {
+		t.Fatalf("load() = %+v, want the stored entry", entry)
+	}
+
+	// Replacing an entry does not count its old size twice
+	sizeBefore := c.size.Load()
+	if err := c.store(u, &cacheEntry{URL: u, ETag: `"v2"`, Body: []byte("second"), StoredAt: time.Now()}); err != nil {
+		t.Fatalf("store() error = %v", err)
+	}
+	if entry := c.load(u); entry == nil || string(entry.Body) != "second" {
+		t.Errorf("load() after replacing = %+v, want the new entry", entry)
+	}
+	if size := c.size.Load(); size-sizeBefore > 16 {
+		t.Errorf("size after replacing grew from %d to %d", sizeBefore, size)
+	}
+}
+
+// setAge backdates the entry for rawURL as if it was last used age ago
+func setAge(t *testing.T, c *ResponseCache, rawURL string, age time.Duration) {
+	t.Helper()
+	at := time.Now().Add(-age)
+	if err := os.Chtimes(c.path(rawURL), at, at); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func exists(path string) bool {
+	_, err := os.Stat(path)
+	return err == nil
+}
+
+func TestResponseCachePruneByAge(t *testing.T) {
+	dir := t.TempDir()
+	c, err := NewResponseCache(dir, 0, time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, u := range []string{"https://a.example.com/", "https://b.example.com/"} {
+		if err := c.store(u, &cacheEntry{URL: u, Body: []byte(u)}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	setAge(t, c, "https://a.example.com/", 2*time.Hour)
+
+	if entry := c.load("https://a.example.com/"); entry != nil {
+		t.Errorf("load() of an expired entry = %+v, want nil", entry)
+	}
+	if entry := c.load("https://b.example.com/"); entry == nil {
+		t.Error("load() of a fresh entry = nil")
+	}
+
+	// Temporary files are removed once a crashed store must have given up
+	stale := filepath.Join(dir, ".tmp-stale")
+	fresh := filepath.Join(dir, ".tmp-fresh")
+	for _, path := range []string{stale, fresh} {
+		if err := os.WriteFile(path, []byte("partial"), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	old := time.Now().Add(-2 * pruneInterval)
+	os.Chtimes(stale, old, old)
+
+	if err := c.prune(); err != nil {
+		t.Fatalf("prune() error = %v", err)
+	}
+	for path, want := range map[string]bool{
+		c.path("https://a.example.com/"): false,
+		c.path("https://b.example.com/"): true,
+		stale:                            false,
+		fresh:                            true,
+	} {
+		if got := exists(path); got != want {
+			t.Errorf("after prune() %s exists = %v, want %v", filepath.Base(path), got, want)
+		}
+	}
+
+	// A new cache prunes what the last run left expired
+	setAge(t, c, "https://b.example.com/", 2*time.Hour)
+	if _, err := NewResponseCache(dir, 0, time.Hour); err != nil {
+		t.Fatal(err)
+	}
+	if exists(c.path("https://b.example.com/")) {
+		t.Error("NewResponseCache() kept an expired entry")
+	}
+}
+
+func TestResponseCachePruneBySize(t *testing.T) {
+	body := bytes.Repeat([]byte("x"), 1000)
+	entry := func(u string) *cacheEntry { return &cacheEntry{URL: u, Body: body} }
+
+	// Measure an entry to size the cache for three of them but not four,
+	// keeping three under the 90% pruning target
+	probe, err := NewResponseCache(t.TempDir(), 0, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := probe.store("https://shop.example.com/a", entry("https://shop.example.com/a")); err != nil {
+		t.Fatal(err)
+	}
+	maxSize := probe.size.Load()*3*10/9 + 100
+
+	c, err := NewResponseCache(t.TempDir(), maxSize, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	urls := []string{"https://shop.example.com/a", "https://shop.example.com/b", "https://shop.example.com/c"}
+	for i, u := range urls {
+		if err := c.store(u, entry(u)); err != nil {
+			t.Fatal(err)
+		}
+		setAge(t, c, u, time.Duration(len(urls)-i)*time.Minute)
+	}
+	for _, u := range urls {
+		if !exists(c.path(u)) {
+			t.Fatalf("store() pruned %s while under the size limit", u)
+		}
+	}
+
+	// Using a is enough to keep it; b is now the least recently used
+	c.touch(urls[0])
+	if err := c.store("https://shop.example.com/d", entry("https://shop.example.com/d")); err != nil {
+		t.Fatal(err)
+	}
+
+	for u, want := range map[string]bool{urls[0]: true, urls[1]: false, urls[2]: true, "https://shop.example.com/d": true} {
+		if got := exists(c.path(u)); got != want {
+			t.Errorf("after store() over the limit %s cached = %v, want %v", u, got, want)
+		}
+	}
+	if size := c.size.Load(); size > maxSize/10*9 {
+		t.Errorf("size after pruning = %d, want at most 90%% of %d", size, maxSize)
+	}
+}
+
+func TestFetcherConditionalRequests(t *testing.T) {
+	const etag = `"v1"`
+	body := strings.Repeat("catalogue ", 10)
+
+	var mu sync.Mutex
+	conditional := make(map[string]int)
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/robots.txt" {
+			http.NotFound(w, r)
+			return
+		}
+		if r.Header.Get("If-None-Match") == etag {
+			mu.Lock()
+			conditional[r.URL.Path]++
+			mu.Unlock()
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		w.Header().Set("Content-Type", "text/html")
+		w.Header().Set("ETag", etag)
+		if r.URL.Path == "/private" {
+			w.Header().Set("Cache-Control", "no-store")
+		}
+		fmt.Fprint(w, body)
+	}))
+	defer srv.Close()
+
+	cache, err := NewResponseCache(t.TempDir(), 0, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f := NewFetcher(FetcherConfig{RequestInterval: -1, MaxRetries: -1, Cache: cache})
+
+	fetch := func(path string, options map[string]string) (*Response, ScrapeStats) {
+		t.Helper()
+		ctx, session, err := withFetchSession(context.Background(), options)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp, err := f.GetHTML(ctx, srv.URL+path)
+		if err != nil {
+			t.Fatalf("GetHTML(%s) error = %v", path, err)
+		}
+		result := &ScrapeResult{}
+		session.apply(result)
+		return resp, result.Stats
+	}
+
+	resp, stats := fetch("/catalogue", nil)
+	if resp.FromCache || stats.CacheMisses != 1 || stats.CacheHits != 0 {
+		t.Errorf("first fetch FromCache = %v, %d hits, %d misses; want a miss", resp.FromCache, stats.CacheHits, stats.CacheMisses)
+	}
+
+	// The 304 is answered from the stored body and counted as a hit
+	resp, stats = fetch("/catalogue", nil)
+	if !resp.FromCache || string(resp.Body) != body || resp.StatusCode != http.StatusOK {
+		t.Errorf("second fetch = %d %q, FromCache %v; want the cached body", resp.StatusCode, resp.Body, resp.FromCache)
+	}
+	if stats.CacheHits != 1 || stats.CacheMisses != 0 || stats.BytesFromCache != int64(len(body)) || stats.BytesFetched != 0 {
+		t.Errorf("second fetch stats = %d hits, %d misses, %d bytes from cache, %d fetched; want 1, 0, %d, 0",
+			stats.CacheHits, stats.CacheMisses, stats.BytesFromCache, stats.BytesFetched, len(body))
+	}
+
+	// no_cache downloads in full
+	if resp, _ := fetch("/catalogue", map[string]string{"no_cache": "true"}); resp.FromCache {
+		t.Error("fetch with no_cache came from the cache")
+	}
+
+	// no-store responses are never revalidated
+	fetch("/private", nil)
+	if resp, _ := fetch("/private", nil); resp.FromCache {
+		t.Error("fetch of a no-store response came from the cache")
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	if conditional["/catalogue"] != 1 || conditional["/private"] != 0 {
+		t.Errorf("conditional requests = %v, want one for /catalogue only", conditional)
+	}
+}
@@ -0,0 +1,225 @@
+package scraper
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"math"
+	"net/url"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/PuerkitoBio/goquery"
+)
+
+// MinDetectConfidence is the confidence below which a detection is not
+// trusted to pick a plugin on its own
+const MinDetectConfidence = 0.5
+
+// Detection is the platform a site most likely runs on
+type Detection struct {
+	SourceType string             `json:"source_type"`
+	Confidence float64            `json:"confidence"`       // 0 to 1
+	Signals    []string           `json:"signals"`          // What was found, for operators
+	Scores     map[string]float64 `json:"scores,omitempty"` // Confidence of every source type with a signal
+}
+
+// Detector probes a site for the signals each platform leaves, so configs
+// with source type AUTO get the plugin that fits rather than a guess from
+// the URL
+type Detector struct {
+	fetcher *Fetcher
+}
+
+// NewDetector creates a detector that probes through fetcher
+func NewDetector(fetcher *Fetcher) *Detector {
+	return &Detector{fetcher: fetcher}
+}
+
+// signal weights; a source type's confidence is the sum of its signals,
+// capped at 1
+const (
+	weightShopifyAPI      = 0.9
+	weightShopifyMarkup   = 0.4
+	weightWooCommerceAPI  = 0.9
+	weightWooGenerator    = 0.5
+	weightWooMarkup       = 0.3
+	weightWordPress       = 0.1
+	weightJSONLDProduct   = 0.6
+	weightJSONLDOffersBit = 0.1
+)
+
+// scoreboard collects signals from probes running in parallel
+type scoreboard struct {
+	mu      sync.Mutex
+	scores  map[string]float64
+	signals []string
+}
+
+func (b *scoreboard) add(sourceType string, weight float64, signal string) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.scores[sourceType] += weight
+	b.signals = append(b.signals, signal)
+}
+
+// Detect probes siteURL and the root of its host and returns the best
+// matching source type. A detection with no signals has an empty source
+// type and zero confidence; an error means the site could not be reached
+// at all.
+func (d *Detector) Detect(ctx context.Context, siteURL string) (*Detection, error) {
+	u, err := url.Parse(siteURL)
+	if err != nil || u.Scheme == "" || u.Host == "" {
+		return nil, fmt.Errorf("invalid URL %q", siteURL)
+	}
+	origin := u.Scheme + "://" + u.Host
+
+	board := &scoreboard{scores: make(map[string]float64)}
+	probes := []func(context.Context) error{
+		func(ctx context.Context) error { return d.probePage(ctx, siteURL, board) },
+		func(ctx context.Context) error { return d.probeShopify(ctx, origin, board) },
+		func(ctx context.Context) error { return d.probeWooCommerce(ctx, origin, board) },
+	}
+
+	errs := make([]error, len(probes))
+	var wg sync.WaitGroup
+	for i, probe := range probes {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			errs[i] = probe(ctx)
+		}()
+	}
+	wg.Wait()
+
+	// Every probe failing means the site is down, not that it is unknown
+	reached := false
+	for _, err := range errs {
+		if err == nil {
+			reached = true
+		}
+	}
+	if !reached {
+		return nil, fmt.Errorf("failed to probe %s: %w", siteURL, errs[0])
+	}
+
+	detection := &Detection{Signals: board.signals, Scores: make(map[string]float64)}
+	if detection.Signals == nil {
+		detection.Signals = []string{}
+	}
+	sort.Strings(detection.Signals)
+
+	for sourceType, score := range board.scores {
+		detection.Scores[sourceType] = math.Round(math.Min(score, 1)*100) / 100
+	}
+	detection.choose(nil)
+
+	return detection, nil
+}
+
+// choose sets the source type to the highest scoring one that supported
+// accepts, or any when supported is nil. Ties go to the alphabetically
+// first type so the result is stable.
+func (d *Detection) choose(supported func(sourceType string) bool) {
+	types := make([]string, 0, len(d.Scores))
+	for sourceType := range d.Scores {
+		types = append(types, sourceType)
+	}
+	sort.Strings(types)
+
+	d.SourceType = ""
+	d.Confidence = 0
+	for _, sourceType := range types {
+		if supported != nil && !supported(sourceType) {
+			continue
+		}
+		if confidence := d.Scores[sourceType]; confidence > d.Confidence {
+			d.SourceType = sourceType
+			d.Confidence = confidence
+		}
+	}
+}
+
+// probePage looks for generator meta tags, platform markup and JSON-LD
+// products in the HTML of the page itself
+func (d *Detector) probePage(ctx context.Context, pageURL string, board *scoreboard) error {
+	resp, err := d.fetcher.GetHTML(ctx, pageURL)
+	if err != nil {
+		return err
+	}
+
+	doc, err := goquery.NewDocumentFromReader(bytes.NewReader(resp.Body))
+	if err != nil {
+		return fmt.Errorf("failed to parse HTML: %w", err)
+	}
+
+	doc.Find(`meta[name="generator" i]`).Each(func(i int, s *goquery.Selection) {
+		generator := strings.ToLower(s.AttrOr("content", ""))
+		switch {
+		case strings.Contains(generator, "woocommerce"):
+			board.add("WOOCOMMERCE", weightWooGenerator, "generator meta tag names WooCommerce")
+		case strings.Contains(generator, "wordpress"):
+			board.add("WOOCOMMERCE", weightWordPress, "generator meta tag names WordPress")
+		case strings.Contains(generator, "shopify"):
+			board.add("SHOPIFY", weightShopifyMarkup, "generator meta tag names Shopify")
+		}
+	})
+
+	html := string(resp.Body)
+	if strings.Contains(html, "cdn.shopify.com") || strings.Contains(html, "Shopify.shop") {
+		board.add("SHOPIFY", weightShopifyMarkup, "page loads Shopify assets")
+	}
+	if doc.Find("body.woocommerce, body.woocommerce-page, .woocommerce").Length() > 0 ||
+		strings.Contains(html, "/wp-content/plugins/woocommerce/") {
+		board.add("WOOCOMMERCE", weightWooMarkup, "page has WooCommerce markup")
+	}
+
+	products, offers := 0, 0
+	doc.Find(`script[type="application/ld+json"]`).Each(func(i int, s *goquery.Selection) {
+		text := s.Text()
+		if strings.Contains(text, `"Product"`) || strings.Contains(text, `"ProductGroup"`) {
+			products++
+		}
+		if strings.Contains(text, `"Offer"`) || strings.Contains(text, `"AggregateOffer"`) {
+			offers++
+		}
+	})
+	if products > 0 {
+		board.add("JSONLD", weightJSONLDProduct, fmt.Sprintf("page has %d JSON-LD Product blocks", products))
+		if offers > 0 {
+			board.add("JSONLD", weightJSONLDOffersBit, "JSON-LD products carry offers")
+		}
+	}
+
+	return nil
+}
+
+// probeShopify checks for the public products.json endpoint every Shopify
+// store serves
+func (d *Detector) probeShopify(ctx context.Context, origin string, board *scoreboard) error {
+	var body struct {
+		Products *[]json.RawMessage `json:"products"`
+	}
+	if _, err := d.fetcher.GetJSON(ctx, origin+"/products.json?limit=1", &body); err != nil {
+		return err
+	}
+
+	if body.Products != nil {
+		board.add("SHOPIFY", weightShopifyAPI, "/products.json returns a product list")
+	}
+	return nil
+}
+
+// probeWooCommerce checks for the WooCommerce Store API
+func (d *Detector) probeWooCommerce(ctx context.Context, origin string, board *scoreboard) error {
+	var products []json.RawMessage
+	if _, err := d.fetcher.GetJSON(ctx, origin+"/wp-json/wc/store/v1/products?per_page=1", &products); err != nil {
+		return err
+	}
+
+	board.add("WOOCOMMERCE", weightWooCommerceAPI, "/wp-json/wc/store/v1/products returns a product list")
+	return nil
+}
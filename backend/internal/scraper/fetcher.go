@@ -0,0 +1,605 @@
+package scraper
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"math/rand"
+	"mime"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// DefaultUserAgent identifies the scraper unless a reseller overrides it
+const DefaultUserAgent = "Mozilla/5.0 (compatible; ProductScraper/1.0)"
+
+// Accept headers for the kinds of documents plugins request
+const (
+	AcceptHTML = "text/html,application/xhtml+xml,application/xml;q=0.9,*/*;q=0.8"
+	AcceptJSON = "application/json"
+	AcceptXML  = "application/xml,text/xml,text/plain;q=0.9,*/*;q=0.8"
+)
+
+// maxRetryAfter caps how long a Retry-After header can hold up a scrape
+const maxRetryAfter = 2 * time.Minute
+
+// FetcherConfig controls how the Fetcher treats the stores it talks to
+type FetcherConfig struct {
+	UserAgent            string
+	Timeout              time.Duration // Per attempt
+	RequestInterval      time.Duration // Minimum gap between requests to one host, negative for none
+	MaxConcurrentPerHost int
+	MaxRetries           int // Negative disables retries
+	RetryBaseDelay       time.Duration
+	RetryMaxDelay        time.Duration
+	MaxResponseSize      int64
+	Transport            http.RoundTripper // Defaults to http.DefaultTransport
+	Cache                *ResponseCache    // Enables conditional requests when set
+}
+
+// DefaultFetcherConfig returns the settings used for zero config values
+func DefaultFetcherConfig() FetcherConfig {
+	return FetcherConfig{
+		UserAgent:            DefaultUserAgent,
+		Timeout:              30 * time.Second,
+		RequestInterval:      500 * time.Millisecond,
+		MaxConcurrentPerHost: 2,
+		MaxRetries:           3,
+		RetryBaseDelay:       time.Second,
+		RetryMaxDelay:        30 * time.Second,
+		MaxResponseSize:      20 << 20,
+	}
+}
+
+// Fetcher is the HTTP client shared by all plugins. It obeys robots.txt,
+// rate limits and caps concurrency per host, retries 429 and 5xx responses
+// with jittered backoff and guards against oversized or unexpected
+// responses. Per-reseller headers and request counters are carried by the
+// context, see withFetchSession.
+type Fetcher struct {
+	client      *http.Client
+	config      FetcherConfig
+	robotsAgent string
+
+	mu     sync.Mutex
+	hosts  map[string]*hostLimiter
+	robots map[string]*robotsEntry
+}
+
+// NewFetcher creates a fetcher, filling unset config values with defaults
+func NewFetcher(config FetcherConfig) *Fetcher {
+	defaults := DefaultFetcherConfig()
+	if config.UserAgent == "" {
+		config.UserAgent = defaults.UserAgent
+	}
+	if config.Timeout <= 0 {
+		config.Timeout = defaults.Timeout
+	}
+	if config.RequestInterval == 0 {
+		config.RequestInterval = defaults.RequestInterval
+	} else if config.RequestInterval < 0 {
+		config.RequestInterval = 0
+	}
+	if config.MaxConcurrentPerHost <= 0 {
+		config.MaxConcurrentPerHost = defaults.MaxConcurrentPerHost
+	}
+	if config.MaxRetries == 0 {
+		config.MaxRetries = defaults.MaxRetries
+	} else if config.MaxRetries < 0 {
+		config.MaxRetries = 0
+	}
+	if config.RetryBaseDelay <= 0 {
+		config.RetryBaseDelay = defaults.RetryBaseDelay
+	}
+	if config.RetryMaxDelay <= 0 {
+		config.RetryMaxDelay = defaults.RetryMaxDelay
+	}
+	if config.MaxResponseSize <= 0 {
+		config.MaxResponseSize = defaults.MaxResponseSize
+	}
+
+	return &Fetcher{
+		client: &http.Client{
+			Timeout:   config.Timeout,
+			Transport: config.Transport,
+		},
+		config:      config,
+		robotsAgent: robotsAgent(config.UserAgent),
+		hosts:       make(map[string]*hostLimiter),
+		robots:      make(map[string]*robotsEntry),
+	}
+}
+
+// Response is a fully read HTTP response
+type Response struct {
+	URL        string // Final URL after redirects
+	StatusCode int
+	Header     http.Header
+	Body       []byte
+	FromCache  bool // The server answered 304 and Body is the cached copy
+}
+
+// HTTPError reports a response with a status other than 200
+type HTTPError struct {
+	URL        string
+	StatusCode int
+}
+
+func (e *HTTPError) Error() string {
+	return fmt.Sprintf("HTTP %d from %s", e.StatusCode, e.URL)
+}
+
+// IsHTTPStatus reports whether err is an HTTPError with the given status
+func IsHTTPStatus(err error, status int) bool {
+	var httpErr *HTTPError
+	return errors.As(err, &httpErr) && httpErr.StatusCode == status
+}
+
+// GetHTML fetches an HTML page
+func (f *Fetcher) GetHTML(ctx context.Context, rawURL string) (*Response, error) {
+	return f.Get(ctx, rawURL, AcceptHTML, "text/html", "application/xhtml+xml")
+}
+
+// GetJSON fetches a JSON document and decodes it into out
+func (f *Fetcher) GetJSON(ctx context.Context, rawURL string, out interface{}) (*Response, error) {
+	resp, err := f.Get(ctx, rawURL, AcceptJSON, "application/json", "text/json")
+	if err != nil {
+		return nil, err
+	}
+
+	if err := json.Unmarshal(resp.Body, out); err != nil {
+		return nil, fmt.Errorf("failed to decode JSON from %s: %w", rawURL, err)
+	}
+
+	return resp, nil
+}
+
+// Get fetches rawURL and returns the response once its status is 200. URLs
+// robots.txt disallows fail with a RobotsError. When allowedTypes is not
+// empty, responses declaring any other content type are rejected.
+func (f *Fetcher) Get(ctx context.Context, rawURL, accept string, allowedTypes ...string) (*Response, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, fmt.Errorf("invalid URL %s: %w", rawURL, err)
+	}
+
+	session := sessionFromContext(ctx)
+
+	// Also loads the host's Crawl-delay, so it runs even when rules are ignored
+	rules, err := f.robotsFor(ctx, u)
+	if err != nil {
+		return nil, err
+	}
+	if !session.ignoreRobots && !rules.allowed(f.agentFor(session), u) {
+		session.block(rawURL)
+		return nil, &RobotsError{URL: rawURL, Err: rules.err}
+	}
+
+	return f.get(ctx, u, accept, allowedTypes, session)
+}
+
+// get fetches u with retries, without consulting robots.txt
+func (f *Fetcher) get(ctx context.Context, u *url.URL, accept string, allowedTypes []string, session *fetchSession) (*Response, error) {
+	rawURL := u.String()
+	host := f.host(u.Host)
+
+	for attempt := 0; ; attempt++ {
+		resp, retryAfter, err := f.do(ctx, host, session, rawURL, accept, allowedTypes)
+		if err == nil {
+			return resp, nil
+		}
+
+		if attempt >= f.config.MaxRetries || !isRetryable(err) || ctx.Err() != nil {
+			session.countFailure()
+			return nil, err
+		}
+
+		delay := f.backoff(attempt)
+		if retryAfter > delay {
+			delay = retryAfter
+		}
+		if retryAfter > 0 {
+			// The server asked everyone to slow down, not just this request
+			host.delay(time.Now().Add(retryAfter))
+		}
+
+		session.countRetry()
+		if err := sleepContext(ctx, delay); err != nil {
+			session.countFailure()
+			return nil, err
+		}
+	}
+}
+
+// do performs a single attempt and returns the server's Retry-After delay
+// when it sent one
+func (f *Fetcher) do(ctx context.Context, host *hostLimiter, session *fetchSession, rawURL, accept string, allowedTypes []string) (*Response, time.Duration, error) {
+	if err := host.acquire(ctx, max(f.config.RequestInterval, session.interval), f.agentFor(session)); err != nil {
+		return nil, 0, err
+	}
+	defer host.release()
+
+	req, err := http.NewRequestWithContext(ctx, "GET", rawURL, nil)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	req.Header.Set("User-Agent", f.config.UserAgent)
+	req.Header.Set("Accept", accept)
+	for name, values := range session.headers {
+		req.Header[name] = values
+	}
+
+	var cached *cacheEntry
+	if f.config.Cache != nil && !session.noCache {
+		if cached = f.config.Cache.load(rawURL); cached != nil {
+			if cached.ETag != "" {
+				req.Header.Set("If-None-Match", cached.ETag)
+			}
+			if cached.LastModified != "" {
+				req.Header.Set("If-Modified-Since", cached.LastModified)
+			}
+		}
+	}
+
+	session.countRequest()
+	resp, err := f.client.Do(req)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusNotModified && cached != nil {
+		f.config.Cache.touch(rawURL)
+		session.countCacheHit(len(cached.Body))
+		return &Response{
+			URL:        cached.URL,
+			StatusCode: http.StatusOK,
+			Header:     cached.Header,
+			Body:       cached.Body,
+			FromCache:  true,
+		}, 0, nil
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		// Drain a little so the connection can be reused
+		io.CopyN(io.Discard, resp.Body, 4<<10)
+		return nil, parseRetryAfter(resp.Header.Get("Retry-After")), &HTTPError{URL: rawURL, StatusCode: resp.StatusCode}
+	}
+
+	if err := checkContentType(resp.Header.Get("Content-Type"), allowedTypes); err != nil {
+		return nil, 0, fmt.Errorf("%s: %w", rawURL, err)
+	}
+
+	if resp.ContentLength > f.config.MaxResponseSize {
+		return nil, 0, fmt.Errorf("response from %s is %d bytes, over the %d byte limit", rawURL, resp.ContentLength, f.config.MaxResponseSize)
+	}
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, f.config.MaxResponseSize+1))
+	if err != nil {
+		return nil, 0, err
+	}
+	if int64(len(body)) > f.config.MaxResponseSize {
+		return nil, 0, fmt.Errorf("response from %s exceeds the %d byte limit", rawURL, f.config.MaxResponseSize)
+	}
+	session.countBytes(len(body))
+
+	finalURL := resp.Request.URL.String()
+	if f.config.Cache != nil {
+		session.countCacheMiss()
+		if cacheable(resp.Header) {
+			entry := &cacheEntry{
+				URL:          finalURL,
+				ETag:         resp.Header.Get("ETag"),
+				LastModified: resp.Header.Get("Last-Modified"),
+				Header:       resp.Header,
+				Body:         body,
+				StoredAt:     time.Now(),
+			}
+			if err := f.config.Cache.store(rawURL, entry); err != nil {
+				log.Printf("Warning: Failed to cache response from %s: %v", rawURL, err)
+			}
+		}
+	}
+
+	return &Response{
+		URL:        finalURL,
+		StatusCode: resp.StatusCode,
+		Header:     resp.Header,
+		Body:       body,
+	}, 0, nil
+}
+
+// backoff returns the jittered delay before retry attempt+1
+func (f *Fetcher) backoff(attempt int) time.Duration {
+	delay := f.config.RetryBaseDelay << attempt
+	if delay <= 0 || delay > f.config.RetryMaxDelay {
+		delay = f.config.RetryMaxDelay
+	}
+
+	// Somewhere between half and the full delay
+	half := delay / 2
+	return half + time.Duration(rand.Int63n(int64(half)+1))
+}
+
+func (f *Fetcher) host(name string) *hostLimiter {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	name = strings.ToLower(name)
+	limiter, ok := f.hosts[name]
+	if !ok {
+		limiter = &hostLimiter{slots: make(chan struct{}, f.config.MaxConcurrentPerHost)}
+		f.hosts[name] = limiter
+	}
+	return limiter
+}
+
+// hostLimiter spaces out and caps the concurrent requests to one host
+type hostLimiter struct {
+	slots chan struct{}
+
+	mu     sync.Mutex
+	next   time.Time    // Earliest start of the next request
+	robots *robotsRules // For the Crawl-delay, nil until robots.txt is loaded
+}
+
+// acquire waits for a free slot and the host's next request time. The gap
+// between requests is the larger of interval and the Crawl-delay the
+// host's robots.txt asks of agent.
+func (h *hostLimiter) acquire(ctx context.Context, interval time.Duration, agent string) error {
+	select {
+	case h.slots <- struct{}{}:
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+
+	h.mu.Lock()
+	if h.robots != nil {
+		interval = max(interval, h.robots.crawlDelay(agent))
+	}
+	now := time.Now()
+	start := now
+	if h.next.After(now) {
+		start = h.next
+	}
+	h.next = start.Add(interval)
+	h.mu.Unlock()
+
+	if err := sleepContext(ctx, start.Sub(now)); err != nil {
+		h.release()
+		return err
+	}
+	return nil
+}
+
+func (h *hostLimiter) release() {
+	<-h.slots
+}
+
+func (h *hostLimiter) setRobots(rules *robotsRules) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	h.robots = rules
+}
+
+// delay holds back requests to the host until the given time
+func (h *hostLimiter) delay(until time.Time) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if until.After(h.next) {
+		h.next = until
+	}
+}
+
+func isRetryable(err error) bool {
+	if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return false
+	}
+
+	var httpErr *HTTPError
+	if errors.As(err, &httpErr) {
+		switch httpErr.StatusCode {
+		case http.StatusTooManyRequests, http.StatusInternalServerError, http.StatusBadGateway,
+			http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+			return true
+		}
+		return false
+	}
+
+	// Transport errors such as resets and timeouts
+	var urlErr *url.Error
+	return errors.As(err, &urlErr)
+}
+
+// parseRetryAfter reads a Retry-After header given in seconds or as a date
+func parseRetryAfter(value string) time.Duration {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return 0
+	}
+
+	var delay time.Duration
+	if seconds, err := strconv.Atoi(value); err == nil {
+		delay = time.Duration(seconds) * time.Second
+	} else if at, err := http.ParseTime(value); err == nil {
+		delay = time.Until(at)
+	}
+
+	if delay < 0 {
+		return 0
+	}
+	if delay > maxRetryAfter {
+		return maxRetryAfter
+	}
+	return delay
+}
+
+// checkContentType accepts responses without a Content-Type header, since
+// some stores omit it, and otherwise requires one of the allowed media types
+func checkContentType(contentType string, allowedTypes []string) error {
+	if len(allowedTypes) == 0 || contentType == "" {
+		return nil
+	}
+
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return fmt.Errorf("invalid content type %q", contentType)
+	}
+
+	for _, allowed := range allowedTypes {
+		if strings.EqualFold(mediaType, allowed) {
+			return nil
+		}
+	}
+	return fmt.Errorf("unexpected content type %q", mediaType)
+}
+
+func sleepContext(ctx context.Context, d time.Duration) error {
+	if d <= 0 {
+		return ctx.Err()
+	}
+
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// fetchSession holds the per-scrape overrides and request counters. The
+// manager attaches one to the context of every scrape.
+type fetchSession struct {
+	headers      http.Header
+	interval     time.Duration
+	ignoreRobots bool
+	noCache      bool
+
+	mu      sync.Mutex
+	blocked []string
+
+	requests    atomic.Int64
+	retries     atomic.Int64
+	failures    atomic.Int64
+	bytes       atomic.Int64
+	cacheHits   atomic.Int64
+	cacheMisses atomic.Int64
+	bytesSaved  atomic.Int64
+}
+
+type fetchSessionKey struct{}
+
+// withFetchSession reads the fetch options of a scrape request:
+//
+//	user_agent        replaces the User-Agent header
+//	header.<Name>     sets an extra request header
+//	request_interval  slows requests to each host down (Go duration, e.g. "2s")
+//	ignore_robots     skips robots.txt Allow/Disallow rules for stores that
+//	                  have allowed us in; Crawl-delay still applies
+//	no_cache          always downloads in full instead of sending conditional
+//	                  requests; responses still refresh the cache
+func withFetchSession(ctx context.Context, options map[string]string) (context.Context, *fetchSession, error) {
+	session := &fetchSession{headers: make(http.Header)}
+
+	for key, value := range options {
+		switch {
+		case key == "user_agent":
+			if value != "" {
+				session.headers.Set("User-Agent", value)
+			}
+		case strings.HasPrefix(key, "header."):
+			name := strings.TrimSpace(strings.TrimPrefix(key, "header."))
+			if name == "" {
+				return nil, nil, fmt.Errorf("option %q has no header name", key)
+			}
+			session.headers.Set(name, value)
+		case key == "request_interval":
+			interval, err := time.ParseDuration(value)
+			if err != nil || interval < 0 {
+				return nil, nil, fmt.Errorf("invalid request_interval %q", value)
+			}
+			session.interval = interval
+		case key == "ignore_robots":
+			ignore, err := strconv.ParseBool(value)
+			if err != nil {
+				return nil, nil, fmt.Errorf("invalid ignore_robots %q", value)
+			}
+			session.ignoreRobots = ignore
+		case key == "no_cache":
+			noCache, err := strconv.ParseBool(value)
+			if err != nil {
+				return nil, nil, fmt.Errorf("invalid no_cache %q", value)
+			}
+			session.noCache = noCache
+		}
+	}
+
+	return context.WithValue(ctx, fetchSessionKey{}, session), session, nil
+}
+
+// sessionFromContext returns the scrape's session, or a throwaway one for
+// requests made outside the manager
+func sessionFromContext(ctx context.Context) *fetchSession {
+	if session, ok := ctx.Value(fetchSessionKey{}).(*fetchSession); ok {
+		return session
+	}
+	return &fetchSession{}
+}
+
+// agentFor returns the robots.txt product token of the User-Agent the
+// session sends, which a reseller's user_agent option may have replaced
+func (f *Fetcher) agentFor(session *fetchSession) string {
+	if userAgent := session.headers.Get("User-Agent"); userAgent != "" {
+		return robotsAgent(userAgent)
+	}
+	return f.robotsAgent
+}
+
+func (s *fetchSession) countRequest()    { s.requests.Add(1) }
+func (s *fetchSession) countRetry()      { s.retries.Add(1) }
+func (s *fetchSession) countFailure()    { s.failures.Add(1) }
+func (s *fetchSession) countBytes(n int) { s.bytes.Add(int64(n)) }
+func (s *fetchSession) countCacheMiss()  { s.cacheMisses.Add(1) }
+
+func (s *fetchSession) countCacheHit(saved int) {
+	s.cacheHits.Add(1)
+	s.bytesSaved.Add(int64(saved))
+}
+
+func (s *fetchSession) block(rawURL string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.blocked = append(s.blocked, rawURL)
+}
+
+// apply copies the counters and blocked URLs into the scrape result
+func (s *fetchSession) apply(result *ScrapeResult) {
+	result.Stats.HTTPRequests = int(s.requests.Load())
+	result.Stats.HTTPRetries = int(s.retries.Load())
+	result.Stats.HTTPFailures = int(s.failures.Load())
+	result.Stats.BytesFetched = s.bytes.Load()
+	result.Stats.CacheHits = int(s.cacheHits.Load())
+	result.Stats.CacheMisses = int(s.cacheMisses.Load())
+	result.Stats.BytesFromCache = s.bytesSaved.Load()
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	result.BlockedURLs = append(result.BlockedURLs, s.blocked...)
+	result.Stats.URLsBlocked = len(result.BlockedURLs)
+}
@@ -0,0 +1,349 @@
+package scraper
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// countingHandler counts the requests to each path before handing them on
+type countingHandler struct {
+	mu     sync.Mutex
+	counts map[string]int
+	serve  func(w http.ResponseWriter, r *http.Request, n int)
+}
+
+func (h *countingHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	h.mu.Lock()
+	if h.counts == nil {
+		h.counts = make(map[string]int)
+	}
+	h.counts[r.URL.Path]++
+	n := h.counts[r.URL.Path]
+	h.mu.Unlock()
+
+	if r.URL.Path == "/robots.txt" {
+		http.NotFound(w, r)
+		return
+	}
+	h.serve(w, r, n)
+}
+
+func (h *countingHandler) count(path string) int {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return h.counts[path]
+}
+
+func TestFetcherRetries(t *testing.T) {
+	var mu sync.Mutex
+	var limitedAt []time.Time
+
+	h := &countingHandler{serve: func(w http.ResponseWriter, r *http.Request, n int) {
+		switch r.URL.Path {
+		case "/limited":
+			mu.Lock()
+			limitedAt = append(limitedAt, time.Now())
+			mu.Unlock()
+			if n == 1 {
+				w.Header().Set("Retry-After", "1")
+				w.WriteHeader(http.StatusTooManyRequests)
+				return
+			}
+		case "/unavailable":
+			if n == 1 {
+				w.WriteHeader(http.StatusServiceUnavailable)
+				return
+			}
+		case "/down":
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		case "/missing":
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		fmt.Fprint(w, "ok")
+	}}
+	srv := httptest.NewServer(h)
+	defer srv.Close()
+
+	f := NewFetcher(FetcherConfig{
+		RequestInterval: -1,
+		MaxRetries:      2,
+		RetryBaseDelay:  time.Millisecond,
+		RetryMaxDelay:   time.Millisecond,
+	})
+	ctx := context.Background()
+
+	// 429 waits for Retry-After rather than the much shorter backoff
+	if _, err := f.Get(ctx, srv.URL+"/limited", AcceptHTML); err != nil {
+		t.Fatalf("Get(/limited) error = %v", err)
+	}
+	if len(limitedAt) != 2 {
+		t.Fatalf("Get(/limited) made %d requests, want 2", len(limitedAt))
+	}
+	if gap := limitedAt[1].Sub(limitedAt[0]); gap < 900*time.Millisecond {
+		t.Errorf("Get(/limited) retried after %v, want the 1s Retry-After", gap)
+	}
+
+	if _, err := f.Get(ctx, srv.URL+"/unavailable", AcceptHTML); err != nil {
+		t.Errorf("Get(/unavailable) error = %v", err)
+	}
+	if n := h.count("/unavailable"); n != 2 {
+		t.Errorf("Get(/unavailable) made %d requests, want 2", n)
+	}
+
+	// Retries stop after MaxRetries
+	_, err := f.Get(ctx, srv.URL+"/down", AcceptHTML)
+	if !IsHTTPStatus(err, http.StatusServiceUnavailable) {
+		t.Errorf("Get(/down) error = %v, want HTTP 503", err)
+	}
+	if n := h.count("/down"); n != 3 {
+		t.Errorf("Get(/down) made %d requests, want 1 and 2 retries", n)
+	}
+
+	// Other client errors are not retried
+	if _, err := f.Get(ctx, srv.URL+"/missing", AcceptHTML); !IsHTTPStatus(err, http.StatusNotFound) {
+		t.Errorf("Get(/missing) error = %v, want HTTP 404", err)
+	}
+	if n := h.count("/missing"); n != 1 {
+		t.Errorf("Get(/missing) made %d requests, want 1", n)
+	}
+}
+
+func TestParseRetryAfter(t *testing.T) {
+	tests := []struct {
+		value string
+		want  time.Duration
+	}{
+		{"", 0},
+		{"0", 0},
+		{"5", 5 * time.Second},
+		{" 30 ", 30 * time.Second},
+		{"-5", 0},
+		{"86400", maxRetryAfter},
+		{"Wed, 21 Oct 2015 07:28:00 GMT", 0}, // In the past
+		{"soon", 0},
+	}
+
+	for _, tt := range tests {
+		if got := parseRetryAfter(tt.value); got != tt.want {
+			t.Errorf("parseRetryAfter(%q) = %v, want %v", tt.value, got, tt.want)
+		}
+	}
+
+	future := time.Now().Add(20 * time.Second).UTC().Format(http.TimeFormat)
+	if got := parseRetryAfter(future); got < 18*time.Second || got > 20*time.Second {
+		t.Errorf("parseRetryAfter(%q) = %v, want about 20s", future, got)
+	}
+}
+
+func TestFetcherConcurrencyPerHost(t *testing.T) {
+	var inFlight, peak atomic.Int32
+
+	srv := httptest.NewServer(&countingHandler{serve: func(w http.ResponseWriter, r *http.Request, n int) {
+		now := inFlight.Add(1)
+		defer inFlight.Add(-1)
+		for {
+			old := peak.Load()
+			if now <= old || peak.CompareAndSwap(old, now) {
+				break
+			}
+		}
+		time.Sleep(30 * time.Millisecond)
+		fmt.Fprint(w, "ok")
+	}})
+	defer srv.Close()
+
+	f := NewFetcher(FetcherConfig{RequestInterval: -1, MaxRetries: -1, MaxConcurrentPerHost: 2})
+
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			if _, err := f.Get(context.Background(), fmt.Sprintf("%s/page/%d", srv.URL, i), AcceptHTML); err != nil {
+				t.Errorf("Get() error = %v", err)
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	if got := peak.Load(); got != 2 {
+		t.Errorf("peak concurrent requests = %d, want 2", got)
+	}
+}
+
+func TestFetcherResponseGuards(t *testing.T) {
+	srv := httptest.NewServer(&countingHandler{serve: func(w http.ResponseWriter, r *http.Request, n int) {
+		switch r.URL.Path {
+		case "/big":
+			w.Header().Set("Content-Type", "text/html")
+			w.Header().Set("Content-Length", "128")
+			fmt.Fprint(w, strings.Repeat("x", 128))
+		case "/big-chunked":
+			// Without a Content-Length the limit applies while reading
+			w.Header().Set("Content-Type", "text/html")
+			for i := 0; i < 4; i++ {
+				fmt.Fprint(w, strings.Repeat("x", 32))
+				w.(http.Flusher).Flush()
+			}
+		case "/html":
+			w.Header().Set("Content-Type", "text/html; charset=utf-8")
+			fmt.Fprint(w, "<html></html>")
+		case "/json":
+			w.Header().Set("Content-Type", "application/json; charset=utf-8")
+			fmt.Fprint(w, `{"ok":true}`)
+		case "/untyped":
+			w.Header()["Content-Type"] = nil
+			fmt.Fprint(w, `{"ok":true}`)
+		}
+	}})
+	defer srv.Close()
+
+	f := NewFetcher(FetcherConfig{RequestInterval: -1, MaxRetries: -1, MaxResponseSize: 64})
+	ctx := context.Background()
+
+	for _, path := range []string{"/big", "/big-chunked"} {
+		if _, err := f.GetHTML(ctx, srv.URL+path); err == nil || !strings.Contains(err.Error(), "limit") {
+			t.Errorf("GetHTML(%s) error = %v, want the size limit", path, err)
+		}
+	}
+
+	var out map[string]bool
+	if _, err := f.GetJSON(ctx, srv.URL+"/html", &out); err == nil || !strings.Contains(err.Error(), "unexpected content type") {
+		t.Errorf("GetJSON(/html) error = %v, want an unexpected content type", err)
+	}
+	for _, path := range []string{"/json", "/untyped"} {
+		out = nil
+		if _, err := f.GetJSON(ctx, srv.URL+path, &out); err != nil || !out["ok"] {
+			t.Errorf("GetJSON(%s) = %v, %v, want ok", path, out, err)
+		}
+	}
+}
+
+func TestFetcherHeaderOverrides(t *testing.T) {
+	var mu sync.Mutex
+	headers := make(map[string]http.Header)
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		headers[r.URL.Path] = r.Header.Clone()
+		mu.Unlock()
+		if r.URL.Path == "/robots.txt" {
+			http.NotFound(w, r)
+			return
+		}
+		fmt.Fprint(w, "ok")
+	}))
+	defer srv.Close()
+
+	f := NewFetcher(FetcherConfig{RequestInterval: -1, MaxRetries: -1})
+
+	if _, err := f.Get(context.Background(), srv.URL+"/default", AcceptHTML); err != nil {
+		t.Fatalf("Get() error = %v", err)
+	}
+
+	ctx, _, err := withFetchSession(context.Background(), map[string]string{
+		"user_agent":       "ResellerBot/2.0",
+		"header.X-Api-Key": "s3cret",
+		"header.Accept":    "text/html",
+	})
+	if err != nil {
+		t.Fatalf("withFetchSession() error = %v", err)
+	}
+	if _, err := f.Get(ctx, srv.URL+"/override", AcceptJSON); err != nil {
+		t.Fatalf("Get() error = %v", err)
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+
+	if got := headers["/default"].Get("User-Agent"); got != DefaultUserAgent {
+		t.Errorf("default User-Agent = %q, want %q", got, DefaultUserAgent)
+	}
+	if got := headers["/default"].Get("Accept"); got != AcceptHTML {
+		t.Errorf("default Accept = %q, want %q", got, AcceptHTML)
+	}
+
+	override := headers["/override"]
+	for name, want := range map[string]string{"User-Agent": "ResellerBot/2.0", "X-Api-Key": "s3cret", "Accept": "text/html"} {
+		if got := override.Get(name); got != want {
+			t.Errorf("%s = %q, want %q", name, got, want)
+		}
+	}
+
+	// Invalid options are refused before any request
+	for _, options := range []map[string]string{
+		{"header.": "x"},
+		{"request_interval": "soon"},
+		{"ignore_robots": "maybe"},
+	} {
+		if _, _, err := withFetchSession(context.Background(), options); err == nil {
+			t.Errorf("withFetchSession(%v) succeeded, want an error", options)
+		}
+	}
+}
+
+// fetchPlugin fetches its URLs with the fetcher and returns one product
+type fetchPlugin struct {
+	fetcher *Fetcher
+	urls    []string
+}
+
+func (p *fetchPlugin) Name() string                             { return "fetch" }
+func (p *fetchPlugin) SupportedTypes() []string                 { return []string{"FETCH"} }
+func (p *fetchPlugin) Options() []OptionSpec                    { return nil }
+func (p *fetchPlugin) ValidateRequest(req *ScrapeRequest) error { return nil }
+
+func (p *fetchPlugin) Scrape(ctx context.Context, req *ScrapeRequest) (*ScrapeResult, error) {
+	result := &ScrapeResult{Products: []ScrapedProduct{{Name: "Product"}}}
+	for _, u := range p.urls {
+		if _, err := p.fetcher.GetHTML(ctx, u); err != nil {
+			result.Errors = append(result.Errors, err.Error())
+		}
+	}
+	return result, nil
+}
+
+func TestFetcherStats(t *testing.T) {
+	srv := httptest.NewServer(&countingHandler{serve: func(w http.ResponseWriter, r *http.Request, n int) {
+		switch r.URL.Path {
+		case "/flaky":
+			if n == 1 {
+				w.WriteHeader(http.StatusBadGateway)
+				return
+			}
+		case "/gone":
+			w.WriteHeader(http.StatusGone)
+			return
+		}
+		w.Header().Set("Content-Type", "text/html")
+		fmt.Fprint(w, "0123456789")
+	}})
+	defer srv.Close()
+
+	f := NewFetcher(FetcherConfig{RequestInterval: -1, RetryBaseDelay: time.Millisecond, RetryMaxDelay: time.Millisecond})
+	m := NewManager(f)
+	if err := m.RegisterPlugin(&fetchPlugin{fetcher: f, urls: []string{srv.URL + "/ok", srv.URL + "/flaky", srv.URL + "/gone"}}); err != nil {
+		t.Fatal(err)
+	}
+
+	result, err := m.ScrapeByType(context.Background(), &ScrapeRequest{URL: srv.URL, SourceType: "FETCH"})
+	if err != nil {
+		t.Fatalf("ScrapeByType() error = %v", err)
+	}
+
+	// robots.txt is kept out of the counters
+	stats := result.Stats
+	if stats.HTTPRequests != 4 || stats.HTTPRetries != 1 || stats.HTTPFailures != 1 || stats.BytesFetched != 20 {
+		t.Errorf("ScrapeByType() stats = %d requests, %d retries, %d failures, %d bytes; want 4, 1, 1, 20",
+			stats.HTTPRequests, stats.HTTPRetries, stats.HTTPFailures, stats.BytesFetched)
+	}
+}
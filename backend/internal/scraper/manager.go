@@ -3,51 +3,153 @@ package scraper
 import (
 	"context"
 	"fmt"
+	"net/url"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
 	"time"
 )
 
 // Manager orchestrates the scraping operations
 type Manager struct {
-	registry *Registry
+	registry    *Registry
+	sitemaps    *SitemapDiscoverer
+	detector    *Detector
+	concurrency int
 }
 
-// NewManager creates a new scraper manager
-func NewManager() *Manager {
+// NewManager creates a new scraper manager. The fetcher should be the one
+// the registered plugins were built with so that sitemap requests share
+// their per-host limits.
+func NewManager(fetcher *Fetcher) *Manager {
 	return &Manager{
-		registry: NewRegistry(),
+		registry:    NewRegistry(),
+		sitemaps:    NewSitemapDiscoverer(fetcher),
+		detector:    NewDetector(fetcher),
+		concurrency: DefaultConcurrency,
 	}
 }
 
-// RegisterPlugin registers a new plugin
+// SetConcurrency sets how many pages or product pages of one scrape are
+// fetched at once; requests can override it with the concurrency option
+func (m *Manager) SetConcurrency(n int) {
+	if n > 0 {
+		m.concurrency = n
+	}
+}
+
+// RegisterPlugin registers a new plugin with priority 0
 func (m *Manager) RegisterPlugin(plugin Plugin) error {
 	return m.registry.Register(plugin)
 }
 
-// ScrapeByType automatically selects the appropriate plugin based on source type
+// RegisterPluginWithPriority registers a plugin that is tried before lower
+// priority plugins supporting the same source type
+func (m *Manager) RegisterPluginWithPriority(plugin Plugin, priority int) error {
+	return m.registry.RegisterWithPriority(plugin, priority)
+}
+
+// ScrapeByType automatically selects the appropriate plugin based on source
+// type, or the chain named by the plugins option
 func (m *Manager) ScrapeByType(ctx context.Context, req *ScrapeRequest) (*ScrapeResult, error) {
-	plugin, err := m.registry.GetPluginForType(req.SourceType)
+	chain, err := m.pluginChain(req.SourceType, req.Options)
 	if err != nil {
-		return nil, fmt.Errorf("failed to find plugin for type %s: %w", req.SourceType, err)
+		return nil, err
 	}
 
-	return m.scrapeWithPlugin(ctx, plugin, req)
+	return m.scrapeWithChain(ctx, chain, req)
 }
 
 // ScrapeByPlugin uses a specific plugin by name
 func (m *Manager) ScrapeByPlugin(ctx context.Context, pluginName string, req *ScrapeRequest) (*ScrapeResult, error) {
-	plugins := m.registry.ListPlugins()
-	plugin, ok := plugins[pluginName]
-	if !ok {
-		return nil, fmt.Errorf("plugin %s not found", pluginName)
+	plugin, err := m.registry.GetPlugin(pluginName)
+	if err != nil {
+		return nil, err
 	}
 
-	return m.scrapeWithPlugin(ctx, plugin, req)
+	return m.scrapeWithChain(ctx, []Plugin{plugin}, req)
+}
+
+// StreamByType is ScrapeByType for large catalogues: products are handed to
+// emit as they are scraped and the returned result carries only the errors
+// and stats. If scraping fails part way, the result so far is returned
+// along with the error; products already emitted stay emitted.
+func (m *Manager) StreamByType(ctx context.Context, req *ScrapeRequest, emit Emitter) (*ScrapeResult, error) {
+	chain, err := m.pluginChain(req.SourceType, req.Options)
+	if err != nil {
+		return nil, err
+	}
+
+	return m.streamWithChain(ctx, chain, req, emit)
+}
+
+// pluginChain returns the plugins to try in order: those named by the
+// plugins option, or else every plugin supporting the source type by
+// priority
+func (m *Manager) pluginChain(sourceType string, options map[string]string) ([]Plugin, error) {
+	names := splitPluginNames(options["plugins"])
+	if len(names) == 0 {
+		chain := m.registry.PluginsForType(sourceType)
+		if len(chain) == 0 {
+			return nil, fmt.Errorf("failed to find plugin for type %s: no plugin found for source type %s", sourceType, sourceType)
+		}
+		return chain, nil
+	}
+
+	chain := make([]Plugin, 0, len(names))
+	for _, name := range names {
+		plugin, err := m.registry.GetPlugin(name)
+		if err != nil {
+			return nil, fmt.Errorf("invalid plugins option: %w", err)
+		}
+		chain = append(chain, plugin)
+	}
+	return chain, nil
+}
+
+// splitPluginNames parses the comma-separated plugins option
+func splitPluginNames(value string) []string {
+	var names []string
+	for _, name := range strings.Split(value, ",") {
+		if name = strings.TrimSpace(name); name != "" {
+			names = append(names, name)
+		}
+	}
+	return names
+}
+
+// scrapeWithChain performs the actual scraping, keeping every product in
+// the result
+func (m *Manager) scrapeWithChain(ctx context.Context, chain []Plugin, req *ScrapeRequest) (*ScrapeResult, error) {
+	c := &collector{}
+	result, err := m.streamWithChain(ctx, chain, req, c)
+	if err != nil {
+		return nil, err
+	}
+
+	result.Products = c.products
+	return result, nil
 }
 
-// scrapeWithPlugin performs the actual scraping with timing and error handling
-func (m *Manager) scrapeWithPlugin(ctx context.Context, plugin Plugin, req *ScrapeRequest) (*ScrapeResult, error) {
+// streamWithChain performs the actual scraping with timing and error
+// handling. Each plugin of the chain runs in turn until one produces
+// products; products already emitted cannot be taken back, so a plugin that
+// fails after emitting some does not fall back.
+func (m *Manager) streamWithChain(ctx context.Context, chain []Plugin, req *ScrapeRequest, emit Emitter) (*ScrapeResult, error) {
 	// Validate request
-	if err := plugin.ValidateRequest(req); err != nil {
+	for _, plugin := range chain {
+		if err := plugin.ValidateRequest(req); err != nil {
+			if len(chain) > 1 {
+				return nil, fmt.Errorf("validation failed: %s: %w", plugin.Name(), err)
+			}
+			return nil, fmt.Errorf("validation failed: %w", err)
+		}
+	}
+
+	// Attach per-reseller fetch overrides and request counters
+	ctx, session, err := withFetchSession(ctx, req.Options)
+	if err != nil {
 		return nil, fmt.Errorf("validation failed: %w", err)
 	}
 
@@ -62,105 +164,282 @@ func (m *Manager) scrapeWithPlugin(ctx context.Context, plugin Plugin, req *Scra
 	start := time.Now()
 
 	// Perform scraping
-	result, err := plugin.Scrape(ctx, req)
+	tracker := &trackingEmitter{next: emit}
+	var stats ScrapeStats
+	var plugin Plugin
+	var attempts []PluginAttempt
+	for i := range chain {
+		plugin = chain[i]
+		before := tracker.products
+		stats, err = m.runPlugin(ctx, plugin, req, tracker)
+
+		attempt := PluginAttempt{Plugin: plugin.Name(), Products: tracker.products - before}
+		if err != nil {
+			attempt.Error = err.Error()
+		}
+		attempts = append(attempts, attempt)
+
+		if tracker.products > 0 || ctx.Err() != nil {
+			break
+		}
+	}
+
+	result := &ScrapeResult{Errors: tracker.errors, Plugin: plugin.Name(), Stats: stats}
+	if len(attempts) > 1 {
+		result.Attempts = attempts
+	}
+	session.apply(result)
+	result.Stats.ProductsFound = tracker.products
+	result.Stats.VariantsFound = tracker.variants
+	result.Stats.ErrorCount = len(result.Errors)
+	result.Stats.Duration = time.Since(start).String()
+	result.Stats.Source = req.Reseller
+
 	if err != nil {
-		return nil, fmt.Errorf("scraping failed: %w", err)
+		return result, fmt.Errorf("scraping failed: %w", err)
 	}
+	return result, nil
+}
 
-	// Ensure result stats are populated
-	if result.Stats.Duration == "" {
-		result.Stats.Duration = time.Since(start).String()
+// runPlugin runs one plugin through the most capable interface it offers
+func (m *Manager) runPlugin(ctx context.Context, plugin Plugin, req *ScrapeRequest, emit Emitter) (ScrapeStats, error) {
+	if req.Options["discovery"] == "sitemap" {
+		return m.scrapeFromSitemap(ctx, plugin, req, emit)
 	}
-	if result.Stats.ProductsFound == 0 {
-		result.Stats.ProductsFound = len(result.Products)
+	if paged, ok := plugin.(PagedPlugin); ok {
+		return StreamPages(ctx, paged, req, emit, m.concurrency)
 	}
-	if result.Stats.ErrorCount == 0 {
-		result.Stats.ErrorCount = len(result.Errors)
+	if streamer, ok := plugin.(StreamPlugin); ok {
+		return streamer.ScrapeStream(ctx, req, emit)
 	}
-	if result.Stats.Source == "" {
-		result.Stats.Source = req.Reseller
+	return emitResult(ctx, plugin, req, emit)
+}
+
+// emitResult runs a plugin that does not stream and emits its result
+func emitResult(ctx context.Context, plugin Plugin, req *ScrapeRequest, emit Emitter) (ScrapeStats, error) {
+	result, err := plugin.Scrape(ctx, req)
+	if err != nil {
+		return ScrapeStats{}, err
 	}
 
-	// Count variants if not already counted
-	if result.Stats.VariantsFound == 0 {
-		for _, product := range result.Products {
-			result.Stats.VariantsFound += len(product.Variants)
+	for _, message := range result.Errors {
+		emit.Error(message)
+	}
+	for _, product := range result.Products {
+		if err := emit.Product(product); err != nil {
+			return result.Stats, err
 		}
 	}
-
-	return result, nil
+	return result.Stats, nil
 }
 
-// GetPluginInfo returns information about a plugin
-func (m *Manager) GetPluginInfo(pluginName string) (map[string]interface{}, error) {
-	plugins := m.registry.ListPlugins()
-	plugin, ok := plugins[pluginName]
+// scrapeFromSitemap discovers product URLs through the site's sitemaps and
+// scrapes each one with the plugin's detail page extractor. When the
+// modified_since option (RFC3339) is set, URLs whose lastmod is older are
+// skipped.
+func (m *Manager) scrapeFromSitemap(ctx context.Context, plugin Plugin, req *ScrapeRequest, emit Emitter) (ScrapeStats, error) {
+	var stats ScrapeStats
+
+	detailScraper, ok := plugin.(DetailScraper)
 	if !ok {
-		return nil, fmt.Errorf("plugin %s not found", pluginName)
+		return stats, fmt.Errorf("plugin %s does not support sitemap discovery", plugin.Name())
 	}
 
-	return map[string]interface{}{
-		"name":            plugin.Name(),
-		"supported_types": plugin.SupportedTypes(),
-	}, nil
-}
+	var pattern *regexp.Regexp
+	if p := req.Options["sitemap_pattern"]; p != "" {
+		compiled, err := regexp.Compile(p)
+		if err != nil {
+			return stats, fmt.Errorf("invalid sitemap_pattern: %w", err)
+		}
+		pattern = compiled
+	}
 
-// ListAvailablePlugins returns information about all registered plugins
-func (m *Manager) ListAvailablePlugins() map[string]map[string]interface{} {
-	plugins := m.registry.ListPlugins()
-	result := make(map[string]map[string]interface{})
+	var modifiedSince time.Time
+	if since := req.Options["modified_since"]; since != "" {
+		parsed, err := time.Parse(time.RFC3339, since)
+		if err != nil {
+			return stats, fmt.Errorf("invalid modified_since: %w", err)
+		}
+		modifiedSince = parsed
+	}
+
+	maxURLs := 0
+	if n, err := strconv.Atoi(req.Options["max_urls"]); err == nil && n > 0 {
+		maxURLs = n
+	}
+
+	entries, errors, err := m.sitemaps.Discover(ctx, req.URL, pattern)
+	if err != nil {
+		return stats, err
+	}
+	for _, message := range errors {
+		emit.Error(message)
+	}
+	stats.URLsFound = len(entries)
 
-	for name, plugin := range plugins {
-		result[name] = map[string]interface{}{
-			"name":            plugin.Name(),
-			"supported_types": plugin.SupportedTypes(),
+	var urls []string
+	var lastMods []time.Time
+	for _, entry := range entries {
+		// Pages without a lastmod are always scraped
+		if !modifiedSince.IsZero() && !entry.LastMod.IsZero() && entry.LastMod.Before(modifiedSince) {
+			stats.URLsUnchanged++
+			continue
+		}
+		if maxURLs > 0 && len(urls) >= maxURLs {
+			break
 		}
+		urls = append(urls, entry.URL)
+		lastMods = append(lastMods, entry.LastMod)
 	}
 
-	return result
+	workers := m.concurrency
+	if n, err := strconv.Atoi(req.Options["concurrency"]); err == nil && n > 0 {
+		workers = n
+	}
+
+	err = scrapeDetails(ctx, detailScraper, req, urls, emit, make(chan struct{}, workers),
+		func(i int, product *ScrapedProduct) {
+			if lastMods[i].IsZero() {
+				return
+			}
+			if product.Metadata == nil {
+				product.Metadata = make(map[string]string)
+			}
+			product.Metadata["sitemap_lastmod"] = lastMods[i].Format(time.RFC3339)
+		})
+	return stats, err
 }
 
-// ScrapeMultiplePages scrapes multiple pages by creating separate requests
-// This is a utility method for handling pagination at the manager level
-func (m *Manager) ScrapeMultiplePages(ctx context.Context, baseReq *ScrapeRequest, maxPages int) (*ScrapeResult, error) {
-	var allProducts []ScrapedProduct
-	var allErrors []string
-	totalVariants := 0
+// Detect probes a site for the platform it runs on. A plugin written for
+// the site's host wins outright. Only source types with a registered
+// plugin are considered, so the detection can always be scraped.
+func (m *Manager) Detect(ctx context.Context, siteURL string) (*Detection, error) {
+	detection, err := m.detector.Detect(ctx, siteURL)
+	if err != nil {
+		return nil, err
+	}
 
-	for page := 1; page <= maxPages; page++ {
-		// Clone request and add page parameter
-		req := *baseReq
-		if req.Options == nil {
-			req.Options = make(map[string]string)
-		}
-		req.Options["page"] = fmt.Sprintf("%d", page)
+	if plugin, host := m.sitePlugin(siteURL); plugin != nil {
+		detection.Scores[plugin.SupportedTypes()[0]] = 1
+		detection.Signals = append(detection.Signals, fmt.Sprintf("%s plugin is written for %s", plugin.Name(), host))
+	}
 
-		result, err := m.ScrapeByType(ctx, &req)
-		if err != nil {
-			allErrors = append(allErrors, fmt.Sprintf("Page %d: %v", page, err))
+	detection.choose(func(sourceType string) bool {
+		return len(m.registry.PluginsForType(sourceType)) > 0
+	})
+	return detection, nil
+}
+
+// sitePlugin returns the plugin written for siteURL's host and the host
+// it names, or nil when no plugin is
+func (m *Manager) sitePlugin(siteURL string) (Plugin, string) {
+	u, err := url.Parse(siteURL)
+	if err != nil {
+		return nil, ""
+	}
+	host := strings.ToLower(u.Hostname())
+
+	for _, plugin := range m.registry.ListPlugins() {
+		site, ok := plugin.(SitePlugin)
+		if !ok || len(plugin.SupportedTypes()) == 0 {
 			continue
 		}
+		for _, pluginHost := range site.Hosts() {
+			if host == pluginHost || strings.HasSuffix(host, "."+pluginHost) {
+				return plugin, pluginHost
+			}
+		}
+	}
+	return nil, ""
+}
 
-		// If no products found, we've reached the end
-		if len(result.Products) == 0 {
-			break
+// ListCollections lists a store's collections with the first plugin for
+// sourceType that can. It returns nil when no such plugin can.
+func (m *Manager) ListCollections(ctx context.Context, sourceType, siteURL string) ([]Collection, error) {
+	for _, plugin := range m.registry.PluginsForType(sourceType) {
+		if lister, ok := plugin.(CollectionLister); ok {
+			return lister.ListCollections(ctx, siteURL)
+		}
+	}
+	return nil, nil
+}
+
+// DescribeStore asks a store for its country and currency with the first
+// plugin for sourceType that can. It returns nil when no such plugin can.
+func (m *Manager) DescribeStore(ctx context.Context, sourceType, siteURL string) (*StoreInfo, error) {
+	for _, plugin := range m.registry.PluginsForType(sourceType) {
+		if describer, ok := plugin.(StoreDescriber); ok {
+			return describer.DescribeStore(ctx, siteURL)
 		}
+	}
+	return nil, nil
+}
+
+// PluginInfo describes a registered plugin and the options it understands
+type PluginInfo struct {
+	Name           string       `json:"name"`
+	SupportedTypes []string     `json:"supported_types"`
+	Options        []OptionSpec `json:"options"`
+}
+
+func pluginInfo(plugin Plugin) PluginInfo {
+	options := plugin.Options()
+	if options == nil {
+		options = []OptionSpec{}
+	}
+	return PluginInfo{
+		Name:           plugin.Name(),
+		SupportedTypes: plugin.SupportedTypes(),
+		Options:        options,
+	}
+}
 
-		allProducts = append(allProducts, result.Products...)
-		allErrors = append(allErrors, result.Errors...)
-		totalVariants += result.Stats.VariantsFound
+// GetPluginInfo returns information about a plugin
+func (m *Manager) GetPluginInfo(pluginName string) (*PluginInfo, error) {
+	plugin, err := m.registry.GetPlugin(pluginName)
+	if err != nil {
+		return nil, err
 	}
 
-	return &ScrapeResult{
-		Products: allProducts,
-		Errors:   allErrors,
-		Stats: ScrapeStats{
-			ProductsFound: len(allProducts),
-			VariantsFound: totalVariants,
-			ErrorCount:    len(allErrors),
-			Source:        baseReq.Reseller,
-		},
-	}, nil
+	info := pluginInfo(plugin)
+	return &info, nil
+}
+
+// ListAvailablePlugins returns information about all registered plugins,
+// ordered by name
+func (m *Manager) ListAvailablePlugins() []PluginInfo {
+	plugins := m.registry.ListPlugins()
+	result := make([]PluginInfo, 0, len(plugins))
+
+	for _, plugin := range plugins {
+		result = append(result, pluginInfo(plugin))
+	}
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].Name < result[j].Name
+	})
+
+	return result
+}
+
+// ValidateOptions checks options against the common options and those of
+// the plugins that would run for sourceType
+func (m *Manager) ValidateOptions(sourceType string, options map[string]string) error {
+	chain, err := m.pluginChain(sourceType, options)
+	if err != nil {
+		return &OptionsError{Problems: []string{err.Error()}}
+	}
+
+	return ValidateOptions(chainOptions(chain), options)
+}
+
+// chainOptions returns the common options plus those of every plugin in
+// the chain, since any of them may end up running
+func chainOptions(chain []Plugin) []OptionSpec {
+	specs := CommonOptions()
+	for _, plugin := range chain {
+		specs = append(specs, plugin.Options()...)
+	}
+	return specs
 }
 
 // ValidateRequest validates a scrape request across all plugins
@@ -178,11 +457,19 @@ func (m *Manager) ValidateRequest(req *ScrapeRequest) error {
 		return fmt.Errorf("category is required")
 	}
 
-	// Find plugin and validate with it
-	plugin, err := m.registry.GetPluginForType(req.SourceType)
+	// Find the plugins and validate with them
+	chain, err := m.pluginChain(req.SourceType, req.Options)
 	if err != nil {
-		return fmt.Errorf("no plugin available for source type %s", req.SourceType)
+		return err
+	}
+	if err := ValidateOptions(chainOptions(chain), req.Options); err != nil {
+		return err
 	}
 
-	return plugin.ValidateRequest(req)
+	for _, plugin := range chain {
+		if err := plugin.ValidateRequest(req); err != nil {
+			return err
+		}
+	}
+	return nil
 }
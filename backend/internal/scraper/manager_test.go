@@ -0,0 +1,150 @@
+package scraper
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestPluginChain(t *testing.T) {
+	m := NewManager(newTestFetcher())
+	m.RegisterPlugin(&stubPlugin{name: "generic", types: []string{"X"}})
+	m.RegisterPluginWithPriority(&stubPlugin{name: "specific", types: []string{"X"}}, 10)
+	m.RegisterPlugin(&stubPlugin{name: "other", types: []string{"Y"}})
+
+	tests := []struct {
+		sourceType string
+		plugins    string
+		want       []string
+		wantErr    bool
+	}{
+		{"X", "", []string{"specific", "generic"}, false},
+		{"Y", "", []string{"other"}, false},
+		{"Z", "", nil, true},
+		// The plugins option replaces the chain, even across source types
+		{"X", "generic", []string{"generic"}, false},
+		{"X", " other , generic ", []string{"other", "generic"}, false},
+		{"Z", "other", []string{"other"}, false},
+		{"X", " , ", []string{"specific", "generic"}, false},
+		{"X", "generic,missing", nil, true},
+	}
+
+	for _, tt := range tests {
+		chain, err := m.pluginChain(tt.sourceType, map[string]string{"plugins": tt.plugins})
+		if (err != nil) != tt.wantErr {
+			t.Errorf("pluginChain(%q, %q) error = %v, want error %v", tt.sourceType, tt.plugins, err, tt.wantErr)
+			continue
+		}
+		if got := pluginNames(chain); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("pluginChain(%q, %q) = %v, want %v", tt.sourceType, tt.plugins, got, tt.want)
+		}
+	}
+}
+
+func TestStreamWithChain(t *testing.T) {
+	errFirst := errors.New("first failed")
+	errSecond := errors.New("second failed")
+
+	tests := []struct {
+		name         string
+		first        *stubPlugin
+		second       *stubPlugin
+		wantPlugin   string
+		wantProducts int
+		wantAttempts []PluginAttempt
+		wantErr      error
+		wantCalls    [2]int
+	}{
+		{
+			name:         "first produces",
+			first:        &stubPlugin{products: 2},
+			second:       &stubPlugin{products: 3},
+			wantPlugin:   "first",
+			wantProducts: 2,
+			wantCalls:    [2]int{1, 0},
+		},
+		{
+			name:         "falls back on an error",
+			first:        &stubPlugin{err: errFirst},
+			second:       &stubPlugin{products: 3},
+			wantPlugin:   "second",
+			wantProducts: 3,
+			wantAttempts: []PluginAttempt{{Plugin: "first", Error: errFirst.Error()}, {Plugin: "second", Products: 3}},
+			wantCalls:    [2]int{1, 1},
+		},
+		{
+			name:         "falls back on no products",
+			first:        &stubPlugin{},
+			second:       &stubPlugin{products: 1},
+			wantPlugin:   "second",
+			wantProducts: 1,
+			wantAttempts: []PluginAttempt{{Plugin: "first"}, {Plugin: "second", Products: 1}},
+			wantCalls:    [2]int{1, 1},
+		},
+		{
+			name:         "no fallback after emitting",
+			first:        &stubPlugin{products: 2, err: errFirst},
+			second:       &stubPlugin{products: 3},
+			wantPlugin:   "first",
+			wantProducts: 2,
+			wantErr:      errFirst,
+			wantCalls:    [2]int{1, 0},
+		},
+		{
+			name:         "every plugin fails",
+			first:        &stubPlugin{err: errFirst},
+			second:       &stubPlugin{err: errSecond},
+			wantPlugin:   "second",
+			wantAttempts: []PluginAttempt{{Plugin: "first", Error: errFirst.Error()}, {Plugin: "second", Error: errSecond.Error()}},
+			wantErr:      errSecond,
+			wantCalls:    [2]int{1, 1},
+		},
+	}
+
+	for _, tt := range tests {
+		tt.first.name, tt.first.types = "first", []string{"X"}
+		tt.second.name, tt.second.types = "second", []string{"X"}
+
+		m := NewManager(newTestFetcher())
+		m.RegisterPlugin(tt.second)
+		m.RegisterPluginWithPriority(tt.first, 1)
+
+		c := &collector{}
+		result, err := m.StreamByType(context.Background(), &ScrapeRequest{SourceType: "X"}, c)
+		if !errors.Is(err, tt.wantErr) || (err == nil) != (tt.wantErr == nil) {
+			t.Errorf("StreamByType() %s error = %v, want %v", tt.name, err, tt.wantErr)
+		}
+		if result == nil {
+			t.Fatalf("StreamByType() %s result = nil", tt.name)
+		}
+
+		if result.Plugin != tt.wantPlugin {
+			t.Errorf("StreamByType() %s Plugin = %q, want %q", tt.name, result.Plugin, tt.wantPlugin)
+		}
+		if !reflect.DeepEqual(result.Attempts, tt.wantAttempts) {
+			t.Errorf("StreamByType() %s Attempts = %+v, want %+v", tt.name, result.Attempts, tt.wantAttempts)
+		}
+		if len(c.products) != tt.wantProducts || result.Stats.ProductsFound != tt.wantProducts {
+			t.Errorf("StreamByType() %s emitted %d products, ProductsFound %d; want %d",
+				tt.name, len(c.products), result.Stats.ProductsFound, tt.wantProducts)
+		}
+		if calls := [2]int{tt.first.calls, tt.second.calls}; calls != tt.wantCalls {
+			t.Errorf("StreamByType() %s plugin calls = %v, want %v", tt.name, calls, tt.wantCalls)
+		}
+	}
+
+	// A plugin that refuses the request stops the chain before anything runs
+	first := &stubPlugin{name: "first", types: []string{"X"}, products: 1}
+	second := &stubPlugin{name: "second", types: []string{"X"}, invalid: errors.New("missing store")}
+	m := NewManager(newTestFetcher())
+	m.RegisterPluginWithPriority(first, 1)
+	m.RegisterPlugin(second)
+
+	if _, err := m.StreamByType(context.Background(), &ScrapeRequest{SourceType: "X"}, &collector{}); err == nil {
+		t.Error("StreamByType() with an invalid request succeeded, want an error")
+	}
+	if first.calls != 0 {
+		t.Errorf("StreamByType() with an invalid request ran %d plugins", first.calls)
+	}
+}
@@ -0,0 +1,172 @@
+package scraper
+
+import (
+	"fmt"
+	"net/url"
+	"regexp"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// OptionType is the kind of value an option takes. Every option is stored
+// as a string; the type says how it is parsed.
+type OptionType string
+
+const (
+	OptionString   OptionType = "string"
+	OptionInt      OptionType = "int"
+	OptionBool     OptionType = "bool"
+	OptionDuration OptionType = "duration" // Go duration, e.g. "1.5s"
+	OptionTime     OptionType = "time"     // RFC3339 timestamp
+	OptionEnum     OptionType = "enum"
+	OptionRegexp   OptionType = "regexp"
+	OptionURL      OptionType = "url"
+	OptionSelector OptionType = "selector" // CSS selector
+)
+
+// OptionSpec describes one key a plugin reads from the request options
+type OptionSpec struct {
+	Key         string     `json:"key"`
+	Type        OptionType `json:"type"`
+	Default     string     `json:"default,omitempty"`
+	Description string     `json:"description"`
+	Required    bool       `json:"required,omitempty"`
+	Values      []string   `json:"values,omitempty"` // Allowed values of an enum
+	Min         int        `json:"min,omitempty"`    // Smallest allowed int
+	Max         int        `json:"max,omitempty"`    // Largest allowed int, 0 for no limit
+	Prefix      bool       `json:"prefix,omitempty"` // Key is a prefix completed by the operator, e.g. "header." + name
+}
+
+// OptionsError lists every problem found in a set of options
+type OptionsError struct {
+	Problems []string
+}
+
+func (e *OptionsError) Error() string {
+	return "invalid options: " + strings.Join(e.Problems, "; ")
+}
+
+// CommonOptions are understood for every plugin. They are handled by the
+// manager, the fetcher and the job service rather than the plugins.
+func CommonOptions() []OptionSpec {
+	return []OptionSpec{
+		{Key: "plugins", Type: OptionString,
+			Description: "Comma-separated plugin names to try in order, each running only if the ones before found no products; defaults to every plugin supporting the source type by priority"},
+		{Key: "discovery", Type: OptionEnum, Values: []string{"listing", "sitemap"}, Default: "listing",
+			Description: "Where product URLs come from: the plugin's own listing, or the site's sitemaps scraped with the plugin's product page extractor"},
+		{Key: "sitemap_pattern", Type: OptionRegexp,
+			Description: "Only scrape sitemap URLs matching this pattern; by default URLs that look like product pages are kept"},
+		{Key: "modified_since", Type: OptionTime,
+			Description: "Skip sitemap URLs whose lastmod is older than this"},
+		{Key: "incremental", Type: OptionBool, Default: "false",
+			Description: "Set modified_since to the start of the config's last completed scrape"},
+		{Key: "max_urls", Type: OptionInt, Default: "0",
+			Description: "Stop after scraping this many sitemap URLs; 0 means no limit"},
+		{Key: "concurrency", Type: OptionInt, Min: 1, Default: strconv.Itoa(DefaultConcurrency),
+			Description: "Pages or product pages fetched at once; requests to one host are still spaced out by the fetcher"},
+		{Key: "user_agent", Type: OptionString,
+			Description: "User-Agent sent instead of the worker's"},
+		{Key: "header.", Type: OptionString, Prefix: true,
+			Description: "Extra request header, e.g. header.Cookie"},
+		{Key: "request_interval", Type: OptionDuration,
+			Description: "Minimum time between requests to the site, when longer than the worker's"},
+		{Key: "ignore_robots", Type: OptionBool, Default: "false",
+			Description: "Fetch URLs that robots.txt disallows; only for sites that allow it by agreement"},
+		{Key: "no_cache", Type: OptionBool, Default: "false",
+			Description: "Fetch every page in full instead of revalidating cached copies"},
+	}
+}
+
+// ValidateOptions checks options against specs, reporting unknown keys,
+// missing required keys and values that do not parse as their type
+func ValidateOptions(specs []OptionSpec, options map[string]string) error {
+	var problems []string
+
+	for key, value := range options {
+		spec, ok := findOption(specs, key)
+		if !ok {
+			problems = append(problems, fmt.Sprintf("unknown option %q", key))
+			continue
+		}
+		if spec.Prefix && key == spec.Key {
+			problems = append(problems, fmt.Sprintf("option %q needs a name after the prefix", key))
+			continue
+		}
+		if value == "" {
+			continue
+		}
+		if err := spec.check(value); err != nil {
+			problems = append(problems, fmt.Sprintf("%s: %v", key, err))
+		}
+	}
+
+	for _, spec := range specs {
+		if spec.Required && options[spec.Key] == "" {
+			problems = append(problems, spec.Key+" is required")
+		}
+	}
+
+	if len(problems) > 0 {
+		sort.Strings(problems)
+		return &OptionsError{Problems: problems}
+	}
+	return nil
+}
+
+// findOption returns the spec for key, matching prefix specs by prefix
+func findOption(specs []OptionSpec, key string) (OptionSpec, bool) {
+	for _, spec := range specs {
+		if spec.Key == key || (spec.Prefix && strings.HasPrefix(key, spec.Key)) {
+			return spec, true
+		}
+	}
+	return OptionSpec{}, false
+}
+
+// check reports whether value parses as the spec's type
+func (s OptionSpec) check(value string) error {
+	switch s.Type {
+	case OptionInt:
+		n, err := strconv.Atoi(value)
+		if err != nil {
+			return fmt.Errorf("%q is not a number", value)
+		}
+		if n < s.Min {
+			return fmt.Errorf("must be at least %d", s.Min)
+		}
+		if s.Max > 0 && n > s.Max {
+			return fmt.Errorf("must be at most %d", s.Max)
+		}
+	case OptionBool:
+		if _, err := strconv.ParseBool(value); err != nil {
+			return fmt.Errorf("%q is not true or false", value)
+		}
+	case OptionDuration:
+		d, err := time.ParseDuration(value)
+		if err != nil || d < 0 {
+			return fmt.Errorf("%q is not a duration such as 1.5s", value)
+		}
+	case OptionTime:
+		if _, err := time.Parse(time.RFC3339, value); err != nil {
+			return fmt.Errorf("%q is not an RFC3339 time", value)
+		}
+	case OptionEnum:
+		if !slices.Contains(s.Values, value) {
+			return fmt.Errorf("must be one of %s", strings.Join(s.Values, ", "))
+		}
+	case OptionRegexp:
+		if _, err := regexp.Compile(value); err != nil {
+			return fmt.Errorf("invalid pattern: %v", err)
+		}
+	case OptionURL:
+		u, err := url.Parse(value)
+		if err != nil || u.Scheme == "" || u.Host == "" {
+			return fmt.Errorf("%q is not an absolute URL", value)
+		}
+	}
+	// Selectors are checked by the plugins, which know their syntax extensions
+	return nil
+}
@@ -0,0 +1,256 @@
+package scraper
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"sync"
+)
+
+// DefaultConcurrency is how many pages or product pages of one scrape are
+// fetched at once unless configured otherwise. Requests to one host are
+// still limited by the fetcher.
+const DefaultConcurrency = 4
+
+// PagedPlugin is implemented by plugins whose listings can be fetched one
+// numbered page at a time, which lets the manager fetch pages and the
+// product pages they link to in parallel
+type PagedPlugin interface {
+	Plugin
+	// ScrapePage scrapes listing page n, counting from 1. An empty page
+	// ends the listing when the number of pages is not known.
+	ScrapePage(ctx context.Context, req *ScrapeRequest, page int) (*Page, error)
+}
+
+// Page is what a PagedPlugin found on one listing page
+type Page struct {
+	Products   []ScrapedProduct
+	DetailURLs []string // Product pages to scrape with the plugin's ScrapeProduct
+	Errors     []string
+	TotalPages int // Number of listing pages when the page says, 0 when unknown
+	Fetched    int // Listing pages read to build this one, when the plugin followed next links itself
+}
+
+// empty reports whether the page had nothing to offer
+func (p *Page) empty() bool {
+	return len(p.Products) == 0 && len(p.DetailURLs) == 0
+}
+
+// StreamPages scrapes a paged plugin's listing with up to workers pages or
+// product pages in flight at once, overridden by the concurrency option.
+// Products are emitted in page order, each page's listing products before
+// its product pages, so the output does not depend on timing. Page 1 is
+// fetched first; the rest are fetched in parallel when page 1 gives the
+// number of pages and one after another otherwise.
+func StreamPages(ctx context.Context, plugin PagedPlugin, req *ScrapeRequest, emit Emitter, workers int) (ScrapeStats, error) {
+	var stats ScrapeStats
+
+	if n, err := strconv.Atoi(req.Options["concurrency"]); err == nil && n > 0 {
+		workers = n
+	}
+	if workers < 1 {
+		workers = 1
+	}
+	maxPages := 0
+	if n, err := strconv.Atoi(req.Options["max_pages"]); err == nil && n > 0 {
+		maxPages = n
+	}
+
+	slots := make(chan struct{}, workers)
+	emitPage := func(page *Page) error {
+		stats.PagesFetched += max(page.Fetched, 1)
+		return emitListingPage(ctx, plugin, req, emit, slots, page)
+	}
+
+	first, err := plugin.ScrapePage(ctx, req, 1)
+	if err != nil {
+		// Without the first page there is nothing to return
+		return stats, fmt.Errorf("failed to fetch first page: %w", err)
+	}
+	if err := emitPage(first); err != nil {
+		return stats, err
+	}
+
+	if first.TotalPages > 0 {
+		last := first.TotalPages
+		if maxPages > 0 && last > maxPages {
+			last = maxPages
+		}
+
+		// Pages 2..last are independent, so one failing does not end the listing
+		err := forEachOrdered(ctx, last-1, slots,
+			func(ctx context.Context, i int) pageResult {
+				page, err := plugin.ScrapePage(ctx, req, i+2)
+				return pageResult{page, err}
+			},
+			func(i int, r pageResult) (bool, error) {
+				if r.err != nil {
+					emit.Error(fmt.Sprintf("page %d: %v", i+2, r.err))
+					// Once cancelled, every remaining page would fail the same way
+					return ctx.Err() == nil, nil
+				}
+				return true, emitPage(r.page)
+			})
+		return stats, err
+	}
+
+	if first.empty() {
+		return stats, nil
+	}
+	for n := 2; maxPages == 0 || n <= maxPages; n++ {
+		if err := ctx.Err(); err != nil {
+			emit.Error(fmt.Sprintf("page %d: stopped before fetching: %v", n, err))
+			break
+		}
+
+		page, err := plugin.ScrapePage(ctx, req, n)
+		if err != nil {
+			emit.Error(fmt.Sprintf("page %d: %v", n, err))
+			break
+		}
+		if err := emitPage(page); err != nil {
+			return stats, err
+		}
+		if page.empty() {
+			break
+		}
+	}
+
+	return stats, nil
+}
+
+type pageResult struct {
+	page *Page
+	err  error
+}
+
+type detailResult struct {
+	page *Page
+	err  error
+}
+
+// emitListingPage emits a page's errors and products, then scrapes and
+// emits the product pages it links to
+func emitListingPage(ctx context.Context, plugin Plugin, req *ScrapeRequest, emit Emitter, slots chan struct{}, page *Page) error {
+	for _, message := range page.Errors {
+		emit.Error(message)
+	}
+	for _, product := range page.Products {
+		if err := emit.Product(product); err != nil {
+			return err
+		}
+	}
+	if len(page.DetailURLs) == 0 {
+		return nil
+	}
+
+	detailScraper, ok := plugin.(DetailScraper)
+	if !ok {
+		return fmt.Errorf("plugin %s does not support product pages", plugin.Name())
+	}
+	return scrapeDetails(ctx, detailScraper, req, page.DetailURLs, emit, slots, nil)
+}
+
+// scrapeDetails scrapes product pages in parallel and emits them in the
+// order of urls. prepare, when set, adjusts each product before it is
+// emitted.
+func scrapeDetails(ctx context.Context, detailScraper DetailScraper, req *ScrapeRequest, urls []string, emit Emitter, slots chan struct{}, prepare func(i int, product *ScrapedProduct)) error {
+	return forEachOrdered(ctx, len(urls), slots,
+		func(ctx context.Context, i int) detailResult {
+			page, err := scrapeDetail(ctx, detailScraper, req, urls[i])
+			return detailResult{page, err}
+		},
+		func(i int, r detailResult) (bool, error) {
+			if r.err != nil {
+				emit.Error(fmt.Sprintf("%s: %v", urls[i], r.err))
+				return true, nil
+			}
+			for _, message := range r.page.Errors {
+				emit.Error(fmt.Sprintf("%s: %s", urls[i], message))
+			}
+			for j := range r.page.Products {
+				if prepare != nil {
+					prepare(i, &r.page.Products[j])
+				}
+				if err := emit.Product(r.page.Products[j]); err != nil {
+					return false, err
+				}
+			}
+			return true, nil
+		})
+}
+
+// scrapeDetail scrapes every product on a product page, or the one
+// product when the plugin reads product pages one product at a time
+func scrapeDetail(ctx context.Context, detailScraper DetailScraper, req *ScrapeRequest, productURL string) (*Page, error) {
+	if pageScraper, ok := detailScraper.(ProductPageScraper); ok {
+		return pageScraper.ScrapeProductPage(ctx, req, productURL)
+	}
+
+	product, err := detailScraper.ScrapeProduct(ctx, req, productURL)
+	if err != nil {
+		return nil, err
+	}
+	return &Page{Products: []ScrapedProduct{*product}}, nil
+}
+
+// forEachOrdered runs work for 0..n-1, each call holding one of slots, and
+// hands the results to done in index order. Work runs at most two slots'
+// worth ahead of done, so a slow item holds back memory rather than
+// letting results pile up. It stops early when done returns false or an
+// error, cancelling the work still running and waiting for it to return.
+func forEachOrdered[T any](ctx context.Context, n int, slots chan struct{}, work func(ctx context.Context, i int) T, done func(i int, v T) (bool, error)) error {
+	if n <= 0 {
+		return nil
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	var wg sync.WaitGroup
+	defer func() {
+		cancel()
+		wg.Wait()
+	}()
+
+	type item struct {
+		i int
+		v T
+	}
+
+	window := 2 * cap(slots)
+	// Every started item sends once and at most window are outstanding,
+	// so sends never block even after an early return
+	results := make(chan item, window)
+	pending := make(map[int]T)
+	next, emitted := 0, 0
+
+	for emitted < n {
+		for next < n && next-emitted < window {
+			wg.Add(1)
+			go func(i int) {
+				defer wg.Done()
+				slots <- struct{}{}
+				defer func() { <-slots }()
+				results <- item{i, work(ctx, i)}
+			}(next)
+			next++
+		}
+
+		r := <-results
+		pending[r.i] = r.v
+
+		for {
+			v, ok := pending[emitted]
+			if !ok {
+				break
+			}
+			delete(pending, emitted)
+			more, err := done(emitted, v)
+			emitted++
+			if err != nil || !more {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
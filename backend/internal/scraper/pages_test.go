@@ -0,0 +1,346 @@
+package scraper
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// fakePaged serves listing pages from memory. Page n has one listing
+// product "pn" and detail URLs "pn-d1".."pn-dk"; later pages and earlier
+// details take longer so results arrive out of order.
+type fakePaged struct {
+	pages      int  // Pages with products
+	details    int  // Detail URLs per page
+	totalPages bool // Whether page 1 gives the number of pages
+	block      bool // Detail pages wait for cancellation
+
+	mu     sync.Mutex
+	calls  []string
+	active atomic.Int32
+	peak   atomic.Int32
+}
+
+func (f *fakePaged) Name() string                             { return "fake" }
+func (f *fakePaged) SupportedTypes() []string                 { return []string{"FAKE"} }
+func (f *fakePaged) Options() []OptionSpec                    { return nil }
+func (f *fakePaged) ValidateRequest(req *ScrapeRequest) error { return nil }
+
+func (f *fakePaged) Scrape(ctx context.Context, req *ScrapeRequest) (*ScrapeResult, error) {
+	return nil, fmt.Errorf("not streamed")
+}
+
+// enter records a call and the peak number of calls running at once
+func (f *fakePaged) enter(call string) {
+	f.mu.Lock()
+	f.calls = append(f.calls, call)
+	f.mu.Unlock()
+
+	now := f.active.Add(1)
+	for {
+		peak := f.peak.Load()
+		if now <= peak || f.peak.CompareAndSwap(peak, now) {
+			return
+		}
+	}
+}
+
+func (f *fakePaged) leave() {
+	f.active.Add(-1)
+}
+
+func (f *fakePaged) called() []string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return append([]string(nil), f.calls...)
+}
+
+func (f *fakePaged) ScrapePage(ctx context.Context, req *ScrapeRequest, n int) (*Page, error) {
+	f.enter("page " + strconv.Itoa(n))
+	defer f.leave()
+
+	if err := sleepContext(ctx, time.Duration(n)*2*time.Millisecond); err != nil {
+		return nil, err
+	}
+
+	page := &Page{}
+	if f.totalPages {
+		page.TotalPages = f.pages
+	}
+	if n > f.pages {
+		return page, nil
+	}
+
+	page.Products = []ScrapedProduct{{URL: fmt.Sprintf("p%d", n)}}
+	for d := 1; d <= f.details; d++ {
+		page.DetailURLs = append(page.DetailURLs, fmt.Sprintf("p%d-d%d", n, d))
+	}
+	return page, nil
+}
+
+func (f *fakePaged) ScrapeProduct(ctx context.Context, req *ScrapeRequest, productURL string) (*ScrapedProduct, error) {
+	f.enter(productURL)
+	defer f.leave()
+
+	if f.block {
+		<-ctx.Done()
+		return nil, ctx.Err()
+	}
+
+	d, _ := strconv.Atoi(productURL[strings.LastIndex(productURL, "d")+1:])
+	if err := sleepContext(ctx, time.Duration(f.details-d+1)*2*time.Millisecond); err != nil {
+		return nil, err
+	}
+	return &ScrapedProduct{URL: productURL}, nil
+}
+
+// recordingEmitter keeps the URLs of emitted products and fails once it
+// has taken stopAfter of them when stopAfter is set
+type recordingEmitter struct {
+	stopAfter int
+	onProduct func()
+
+	mu     sync.Mutex
+	urls   []string
+	errors []string
+}
+
+var errStopped = errors.New("consumer stopped")
+
+func (e *recordingEmitter) Product(product ScrapedProduct) error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	if e.stopAfter > 0 && len(e.urls) >= e.stopAfter {
+		return errStopped
+	}
+	e.urls = append(e.urls, product.URL)
+	if e.onProduct != nil {
+		e.onProduct()
+	}
+	return nil
+}
+
+func (e *recordingEmitter) Error(message string) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	e.errors = append(e.errors, message)
+}
+
+// wantOrder lists the products of pages 1..pages in page order, each
+// page's listing product before its detail pages
+func wantOrder(pages, details int) []string {
+	var urls []string
+	for n := 1; n <= pages; n++ {
+		urls = append(urls, fmt.Sprintf("p%d", n))
+		for d := 1; d <= details; d++ {
+			urls = append(urls, fmt.Sprintf("p%d-d%d", n, d))
+		}
+	}
+	return urls
+}
+
+func TestStreamPagesOrder(t *testing.T) {
+	for _, totalPages := range []bool{true, false} {
+		for _, workers := range []int{1, 3} {
+			f := &fakePaged{pages: 4, details: 3, totalPages: totalPages}
+			emit := &recordingEmitter{}
+
+			stats, err := StreamPages(context.Background(), f, &ScrapeRequest{}, emit, workers)
+			if err != nil {
+				t.Fatalf("StreamPages() error = %v", err)
+			}
+
+			name := fmt.Sprintf("total pages %v, %d workers", totalPages, workers)
+			if want := wantOrder(4, 3); !reflect.DeepEqual(emit.urls, want) {
+				t.Errorf("StreamPages() %s emitted\n%v\nwant\n%v", name, emit.urls, want)
+			}
+			if peak := f.peak.Load(); peak > int32(workers) {
+				t.Errorf("StreamPages() %s ran %d calls at once, want at most %d", name, peak, workers)
+			}
+			if wantPages := 4 + map[bool]int{true: 0, false: 1}[totalPages]; stats.PagesFetched != wantPages {
+				t.Errorf("StreamPages() %s PagesFetched = %d, want %d", name, stats.PagesFetched, wantPages)
+			}
+		}
+	}
+
+	// The concurrency option overrides the workers argument
+	f := &fakePaged{pages: 3, details: 4, totalPages: true}
+	if _, err := StreamPages(context.Background(), f, &ScrapeRequest{Options: map[string]string{"concurrency": "2"}}, &recordingEmitter{}, 8); err != nil {
+		t.Fatal(err)
+	}
+	if peak := f.peak.Load(); peak != 2 {
+		t.Errorf("StreamPages() with concurrency 2 ran %d calls at once, want 2", peak)
+	}
+}
+
+func TestStreamPagesStops(t *testing.T) {
+	tests := []struct {
+		name       string
+		f          *fakePaged
+		options    map[string]string
+		wantPages  []string
+		wantOutput []string
+	}{
+		{"empty page ends the listing", &fakePaged{pages: 2},
+			nil, []string{"page 1", "page 2", "page 3"}, wantOrder(2, 0)},
+		{"empty first page", &fakePaged{pages: 0},
+			nil, []string{"page 1"}, nil},
+		{"max_pages without a page count", &fakePaged{pages: 5},
+			map[string]string{"max_pages": "2"}, []string{"page 1", "page 2"}, wantOrder(2, 0)},
+		{"max_pages with a page count", &fakePaged{pages: 5, totalPages: true},
+			map[string]string{"max_pages": "3"}, []string{"page 1", "page 2", "page 3"}, wantOrder(3, 0)},
+	}
+
+	for _, tt := range tests {
+		emit := &recordingEmitter{}
+		if _, err := StreamPages(context.Background(), tt.f, &ScrapeRequest{Options: tt.options}, emit, 2); err != nil {
+			t.Fatalf("StreamPages() %s error = %v", tt.name, err)
+		}
+
+		var pages []string
+		for _, call := range tt.f.called() {
+			if strings.HasPrefix(call, "page ") {
+				pages = append(pages, call)
+			}
+		}
+		// Numbered pages may be requested in any order
+		if tt.f.totalPages {
+			sort.Strings(pages)
+		}
+		if !reflect.DeepEqual(pages, tt.wantPages) {
+			t.Errorf("StreamPages() %s fetched %v, want %v", tt.name, pages, tt.wantPages)
+		}
+		if !reflect.DeepEqual(emit.urls, tt.wantOutput) {
+			t.Errorf("StreamPages() %s emitted %v, want %v", tt.name, emit.urls, tt.wantOutput)
+		}
+	}
+}
+
+func TestStreamPagesEmitterError(t *testing.T) {
+	f := &fakePaged{pages: 4, details: 5, totalPages: true}
+	emit := &recordingEmitter{stopAfter: 3}
+
+	_, err := StreamPages(context.Background(), f, &ScrapeRequest{}, emit, 3)
+	if !errors.Is(err, errStopped) {
+		t.Fatalf("StreamPages() error = %v, want %v", err, errStopped)
+	}
+
+	// Everything started has returned, and nothing starts afterwards
+	if active := f.active.Load(); active != 0 {
+		t.Errorf("StreamPages() returned with %d calls still running", active)
+	}
+	calls := len(f.called())
+	time.Sleep(20 * time.Millisecond)
+	if after := len(f.called()); after != calls {
+		t.Errorf("StreamPages() made %d calls after returning", after-calls)
+	}
+	if want := wantOrder(4, 5)[:3]; !reflect.DeepEqual(emit.urls, want) {
+		t.Errorf("StreamPages() emitted %v, want %v", emit.urls, want)
+	}
+}
+
+func TestStreamPagesCancel(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	// Detail pages hang until the scrape is cancelled after the first product
+	f := &fakePaged{pages: 3, details: 4, totalPages: true, block: true}
+	emit := &recordingEmitter{onProduct: cancel}
+
+	done := make(chan error, 1)
+	go func() {
+		_, err := StreamPages(ctx, f, &ScrapeRequest{}, emit, 2)
+		done <- err
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("StreamPages() did not return after cancellation")
+	}
+
+	if active := f.active.Load(); active != 0 {
+		t.Errorf("StreamPages() returned with %d calls still running", active)
+	}
+	if len(emit.urls) != 1 {
+		t.Errorf("StreamPages() emitted %v after cancellation, want only the first product", emit.urls)
+	}
+	if len(emit.errors) == 0 {
+		t.Error("StreamPages() reported no errors for the cancelled pages")
+	}
+}
+
+func TestForEachOrdered(t *testing.T) {
+	const n = 20
+	slots := make(chan struct{}, 3)
+
+	var active, peak, ahead atomic.Int32
+	var emitted atomic.Int32
+
+	var got []int
+	err := forEachOrdered(context.Background(), n, slots,
+		func(ctx context.Context, i int) int {
+			now := active.Add(1)
+			defer active.Add(-1)
+			for {
+				p := peak.Load()
+				if now <= p || peak.CompareAndSwap(p, now) {
+					break
+				}
+			}
+			// Work never gets more than the window ahead of done
+			if lead := int32(i) - emitted.Load(); lead > ahead.Load() {
+				ahead.Store(lead)
+			}
+			time.Sleep(time.Duration(n-i) * 100 * time.Microsecond)
+			return i * i
+		},
+		func(i int, v int) (bool, error) {
+			if v != i*i {
+				t.Errorf("done(%d) got %d, want %d", i, v, i*i)
+			}
+			got = append(got, i)
+			emitted.Add(1)
+			return true, nil
+		})
+	if err != nil {
+		t.Fatalf("forEachOrdered() error = %v", err)
+	}
+
+	for i, v := range got {
+		if v != i {
+			t.Fatalf("forEachOrdered() order = %v, want 0..%d", got, n-1)
+		}
+	}
+	if len(got) != n {
+		t.Errorf("forEachOrdered() handled %d items, want %d", len(got), n)
+	}
+	if p := peak.Load(); p > 3 {
+		t.Errorf("forEachOrdered() ran %d items at once, want at most 3", p)
+	}
+	if a := ahead.Load(); a >= int32(2*cap(slots)) {
+		t.Errorf("forEachOrdered() started work %d items ahead, want under %d", a, 2*cap(slots))
+	}
+
+	// Returning false stops without an error
+	calls := 0
+	err = forEachOrdered(context.Background(), n, slots,
+		func(ctx context.Context, i int) int { return i },
+		func(i int, v int) (bool, error) {
+			calls++
+			return i < 4, nil
+		})
+	if err != nil || calls != 5 {
+		t.Errorf("forEachOrdered() stopping at 4 = %d calls, %v; want 5 calls, nil", calls, err)
+	}
+}
@@ -0,0 +1,309 @@
+package jsonld
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/PuerkitoBio/goquery"
+)
+
+// node is a decoded JSON-LD object. Schema.org properties are loosely typed
+// (a brand can be a string or a Brand object, offers can be one Offer or a
+// list), so nodes are kept as generic maps and read through helpers.
+type node map[string]interface{}
+
+var gtinKeys = []string{"gtin", "gtin13", "gtin12", "gtin14", "gtin8", "isbn"}
+
+// extractNodes returns every JSON-LD object in the document, flattening
+// top-level arrays and @graph containers.
+func extractNodes(doc *goquery.Document) []node {
+	var nodes []node
+
+	doc.Find(`script[type="application/ld+json"]`).Each(func(i int, s *goquery.Selection) {
+		raw := strings.TrimSpace(s.Text())
+		if raw == "" {
+			return
+		}
+
+		var data interface{}
+		if err := json.Unmarshal([]byte(raw), &data); err != nil {
+			// Hand-written blocks often contain raw newlines inside strings
+			cleaned := strings.NewReplacer("\n", " ", "\r", " ", "\t", " ").Replace(raw)
+			if err := json.Unmarshal([]byte(cleaned), &data); err != nil {
+				return
+			}
+		}
+
+		nodes = append(nodes, flatten(data)...)
+	})
+
+	return nodes
+}
+
+func flatten(data interface{}) []node {
+	var nodes []node
+
+	switch v := data.(type) {
+	case []interface{}:
+		for _, item := range v {
+			nodes = append(nodes, flatten(item)...)
+		}
+	case map[string]interface{}:
+		if graph, ok := v["@graph"]; ok {
+			nodes = append(nodes, flatten(graph)...)
+		}
+		nodes = append(nodes, node(v))
+	}
+
+	return nodes
+}
+
+// findProducts returns the Product and ProductGroup nodes, dropping products
+// that are already listed as a variant of a group on the same page.
+func findProducts(nodes []node) []node {
+	var groups, products []node
+	variantIDs := make(map[string]bool)
+
+	for _, n := range nodes {
+		switch {
+		case n.isType("ProductGroup"):
+			groups = append(groups, n)
+			for _, variant := range n.nodes("hasVariant") {
+				if id := variant.str("@id"); id != "" {
+					variantIDs[id] = true
+				}
+				if sku := variant.str("sku"); sku != "" {
+					variantIDs[sku] = true
+				}
+			}
+		case n.isType("Product"):
+			products = append(products, n)
+		}
+	}
+
+	result := groups
+	for _, p := range products {
+		if id := p.str("@id"); id != "" && variantIDs[id] {
+			continue
+		}
+		if sku := p.str("sku"); sku != "" && variantIDs[sku] {
+			continue
+		}
+		result = append(result, p)
+	}
+
+	return result
+}
+
+func (n node) isType(typeName string) bool {
+	for _, t := range n.strs("@type") {
+		if t == typeName || strings.HasSuffix(t, "/"+typeName) || strings.HasSuffix(t, ":"+typeName) {
+			return true
+		}
+	}
+	return false
+}
+
+// str reads a property as a string. Numbers are formatted, and objects
+// yield their name, @id or url so that {"@type": "Brand", "name": "GMK"}
+// reads as "GMK".
+func (n node) str(key string) string {
+	return stringValue(n[key])
+}
+
+func stringValue(v interface{}) string {
+	switch val := v.(type) {
+	case string:
+		return strings.TrimSpace(val)
+	case float64:
+		return strconv.FormatFloat(val, 'f', -1, 64)
+	case bool:
+		return strconv.FormatBool(val)
+	case map[string]interface{}:
+		for _, key := range []string{"name", "@value", "url", "contentUrl", "@id"} {
+			if s := stringValue(val[key]); s != "" {
+				return s
+			}
+		}
+	case []interface{}:
+		if len(val) > 0 {
+			return stringValue(val[0])
+		}
+	}
+	return ""
+}
+
+// strs reads a property that may be a single value or a list.
+func (n node) strs(key string) []string {
+	var values []string
+
+	switch val := n[key].(type) {
+	case []interface{}:
+		for _, item := range val {
+			if s := stringValue(item); s != "" {
+				values = append(values, s)
+			}
+		}
+	default:
+		if s := stringValue(val); s != "" {
+			values = append(values, s)
+		}
+	}
+
+	return values
+}
+
+// nodes reads a property that may be a single object or a list of objects.
+func (n node) nodes(key string) []node {
+	var nodes []node
+
+	switch val := n[key].(type) {
+	case map[string]interface{}:
+		nodes = append(nodes, node(val))
+	case []interface{}:
+		for _, item := range val {
+			if m, ok := item.(map[string]interface{}); ok {
+				nodes = append(nodes, node(m))
+			}
+		}
+	}
+
+	return nodes
+}
+
+func (n node) gtin() string {
+	for _, key := range gtinKeys {
+		if gtin := n.str(key); gtin != "" {
+			return gtin
+		}
+	}
+	return ""
+}
+
+// offer is the price information flattened out of an Offer, an
+// AggregateOffer or a priceSpecification.
+type offer struct {
+	Price        float64
+	Currency     string
+	Available    bool
+	SKU          string
+	GTIN         string
+	URL          string
+	Name         string
+	HasPrice     bool
+	RegularPrice float64
+}
+
+// offers expands a node's offers property into individual offers. An
+// AggregateOffer with nested offers yields those; without them it yields a
+// single offer at its lowPrice.
+func (n node) offers() []offer {
+	var result []offer
+
+	for _, o := range n.nodes("offers") {
+		if o.isType("AggregateOffer") {
+			nested := o.offers()
+			if len(nested) > 0 {
+				result = append(result, nested...)
+				continue
+			}
+
+			agg := parseOffer(o)
+			if low, err := parsePrice(o.str("lowPrice")); err == nil {
+				agg.Price = low
+				agg.HasPrice = true
+			}
+			result = append(result, agg)
+			continue
+		}
+
+		result = append(result, parseOffer(o))
+	}
+
+	return result
+}
+
+func parseOffer(o node) offer {
+	parsed := offer{
+		Currency:  o.str("priceCurrency"),
+		Available: parseAvailability(o.str("availability")),
+		SKU:       o.str("sku"),
+		GTIN:      o.gtin(),
+		URL:       o.str("url"),
+		Name:      o.str("name"),
+	}
+
+	if price, err := parsePrice(o.str("price")); err == nil {
+		parsed.Price = price
+		parsed.HasPrice = true
+	}
+
+	for _, spec := range o.nodes("priceSpecification") {
+		price, err := parsePrice(spec.str("price"))
+		if err != nil {
+			continue
+		}
+		if parsed.Currency == "" {
+			parsed.Currency = spec.str("priceCurrency")
+		}
+		// A list price specification is the pre-discount price
+		if strings.Contains(spec.str("priceType"), "ListPrice") || strings.Contains(spec.str("priceType"), "StrikethroughPrice") {
+			parsed.RegularPrice = price
+			continue
+		}
+		if !parsed.HasPrice {
+			parsed.Price = price
+			parsed.HasPrice = true
+		}
+	}
+
+	if parsed.RegularPrice <= parsed.Price {
+		parsed.RegularPrice = 0
+	}
+
+	return parsed
+}
+
+// parseAvailability treats anything that can still be ordered as available.
+// A missing availability is assumed to be in stock.
+func parseAvailability(availability string) bool {
+	if availability == "" {
+		return true
+	}
+
+	value := availability
+	if i := strings.LastIndexAny(value, "/:"); i >= 0 {
+		value = value[i+1:]
+	}
+
+	switch strings.ToLower(value) {
+	case "outofstock", "soldout", "discontinued":
+		return false
+	default:
+		return true
+	}
+}
+
+var priceCleanup = regexp.MustCompile(`[^\d.,]`)
+
+func parsePrice(raw string) (float64, error) {
+	cleaned := priceCleanup.ReplaceAllString(raw, "")
+	if cleaned == "" {
+		return 0, fmt.Errorf("empty price")
+	}
+
+	// "1.999,00" style prices use a comma as the decimal separator
+	lastComma := strings.LastIndex(cleaned, ",")
+	lastDot := strings.LastIndex(cleaned, ".")
+	if lastComma > lastDot && len(cleaned)-lastComma == 3 {
+		cleaned = strings.ReplaceAll(cleaned, ".", "")
+		cleaned = strings.Replace(cleaned, ",", ".", 1)
+	} else {
+		cleaned = strings.ReplaceAll(cleaned, ",", "")
+	}
+
+	return strconv.ParseFloat(cleaned, 64)
+}
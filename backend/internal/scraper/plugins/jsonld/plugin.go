@@ -0,0 +1,634 @@
+package jsonld
+
+import (
+	"bytes"
+	"context"
+	"crypto/md5"
+	"fmt"
+	"net/url"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/PuerkitoBio/goquery"
+	"github.com/andybalholm/cascadia"
+	"github.com/meta-boy/mech-alligator/internal/scraper"
+)
+
+const (
+	defaultLinkSelector = `a[href*="/product"]`
+	defaultNextSelector = `link[rel="next"], a[rel="next"], a.next`
+	defaultMaxPages     = 50
+)
+
+// Plugin scrapes any store that embeds schema.org Product data as JSON-LD.
+// A request URL that carries Product markup itself is scraped as a single
+// product; otherwise it is treated as a listing page whose product links
+// (and next pages) are followed.
+type Plugin struct {
+	fetcher *scraper.Fetcher
+}
+
+func NewJSONLDPlugin(fetcher *scraper.Fetcher) *Plugin {
+	return &Plugin{fetcher: fetcher}
+}
+
+func (p *Plugin) Name() string {
+	return "jsonld"
+}
+
+func (p *Plugin) SupportedTypes() []string {
+	return []string{"JSONLD"}
+}
+
+func (p *Plugin) Options() []scraper.OptionSpec {
+	return []scraper.OptionSpec{
+		{Key: "mode", Type: scraper.OptionEnum, Values: []string{"auto", "listing", "detail"}, Default: "auto",
+			Description: "Treat the request URL as a listing or a product page; auto decides by whether it carries Product markup"},
+		{Key: "product_link_selector", Type: scraper.OptionSelector, Default: defaultLinkSelector,
+			Description: "Links to product pages on listing pages"},
+		{Key: "next_page_selector", Type: scraper.OptionSelector, Default: defaultNextSelector,
+			Description: "Link to the next listing page"},
+		{Key: "link_pattern", Type: scraper.OptionRegexp,
+			Description: "Only follow product links matching this pattern"},
+		{Key: "max_pages", Type: scraper.OptionInt, Min: 1, Default: strconv.Itoa(defaultMaxPages),
+			Description: "Listing pages to follow through next links"},
+	}
+}
+
+func (p *Plugin) ValidateRequest(req *scraper.ScrapeRequest) error {
+	if req.URL == "" {
+		return fmt.Errorf("URL is required")
+	}
+	if !strings.HasPrefix(req.URL, "http") {
+		return fmt.Errorf("URL must include protocol (http/https)")
+	}
+
+	for _, key := range []string{"product_link_selector", "next_page_selector"} {
+		if selector := req.Options[key]; selector != "" {
+			if _, err := cascadia.ParseGroup(selector); err != nil {
+				return fmt.Errorf("invalid %s %q: %w", key, selector, err)
+			}
+		}
+	}
+
+	if pattern := req.Options["link_pattern"]; pattern != "" {
+		if _, err := regexp.Compile(pattern); err != nil {
+			return fmt.Errorf("invalid link_pattern %q: %w", pattern, err)
+		}
+	}
+
+	if maxPages, ok := req.Options["max_pages"]; ok {
+		if n, err := strconv.Atoi(maxPages); err != nil || n < 1 {
+			return fmt.Errorf("max_pages must be a positive number")
+		}
+	}
+
+	switch req.Options["mode"] {
+	case "", "auto", "listing", "detail":
+	default:
+		return fmt.Errorf("mode must be one of auto, listing or detail")
+	}
+
+	return nil
+}
+
+func (p *Plugin) Scrape(ctx context.Context, req *scraper.ScrapeRequest) (*scraper.ScrapeResult, error) {
+	return scraper.Collect(ctx, p, req)
+}
+
+// ScrapeStream emits the products of a product page, or of each linked
+// product page as soon as it is scraped
+func (p *Plugin) ScrapeStream(ctx context.Context, req *scraper.ScrapeRequest, emit scraper.Emitter) (scraper.ScrapeStats, error) {
+	return scraper.StreamPages(ctx, p, req, emit, scraper.DefaultConcurrency)
+}
+
+// ScrapePage scrapes the request URL. Listings are paginated through next
+// links rather than page numbers, so the whole listing counts as page 1 and
+// its product links are returned for the manager to fetch in parallel.
+func (p *Plugin) ScrapePage(ctx context.Context, req *scraper.ScrapeRequest, page int) (*scraper.Page, error) {
+	doc, pageURL, err := p.fetchDocument(ctx, req.URL)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch page: %w", err)
+	}
+
+	mode := req.Options["mode"]
+	nodes := findProducts(extractNodes(doc))
+
+	if mode == "detail" || (mode != "listing" && len(nodes) > 0) {
+		// The request URL is a product page
+		if len(nodes) == 0 {
+			return nil, fmt.Errorf("no JSON-LD Product found on %s", pageURL)
+		}
+		result := p.convertProducts(nodes, pageURL, req)
+		for i, message := range result.Errors {
+			result.Errors[i] = fmt.Sprintf("%s: %s", pageURL, message)
+		}
+		result.TotalPages = 1
+		return result, nil
+	}
+
+	links, pagesFetched, errors := p.collectProductLinks(ctx, doc, pageURL, req)
+	return &scraper.Page{
+		DetailURLs: links,
+		Errors:     errors,
+		TotalPages: 1,
+		Fetched:    pagesFetched,
+	}, nil
+}
+
+// ScrapeProduct scrapes the JSON-LD product a product page is about: the
+// one whose url or @id is the page's URL, or else the first one that can
+// be read
+func (p *Plugin) ScrapeProduct(ctx context.Context, req *scraper.ScrapeRequest, productURL string) (*scraper.ScrapedProduct, error) {
+	nodes, pageURL, err := p.fetchProducts(ctx, productURL)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, n := range nodes {
+		if sameURL(scraper.ResolveURL(pageURL, n.str("url")), pageURL) || sameURL(scraper.ResolveURL(pageURL, n.str("@id")), pageURL) {
+			if product, err := p.convertProduct(n, pageURL, req); err == nil {
+				return product, nil
+			}
+		}
+	}
+
+	page := p.convertProducts(nodes, pageURL, req)
+	if len(page.Products) == 0 {
+		return nil, fmt.Errorf("no usable JSON-LD Product found: %s", strings.Join(page.Errors, "; "))
+	}
+	return &page.Products[0], nil
+}
+
+// ScrapeProductPage scrapes every JSON-LD product on a product page. Nodes
+// that are not usable products, such as stubs of related products, are
+// reported as errors and skipped.
+func (p *Plugin) ScrapeProductPage(ctx context.Context, req *scraper.ScrapeRequest, productURL string) (*scraper.Page, error) {
+	nodes, pageURL, err := p.fetchProducts(ctx, productURL)
+	if err != nil {
+		return nil, err
+	}
+
+	page := p.convertProducts(nodes, pageURL, req)
+	if len(page.Products) == 0 {
+		return nil, fmt.Errorf("no usable JSON-LD Product found: %s", strings.Join(page.Errors, "; "))
+	}
+	return page, nil
+}
+
+// fetchProducts returns the product nodes of a page and its final URL
+func (p *Plugin) fetchProducts(ctx context.Context, productURL string) ([]node, string, error) {
+	doc, pageURL, err := p.fetchDocument(ctx, productURL)
+	if err != nil {
+		return nil, "", err
+	}
+
+	nodes := findProducts(extractNodes(doc))
+	if len(nodes) == 0 {
+		return nil, "", fmt.Errorf("no JSON-LD Product found")
+	}
+	return nodes, pageURL, nil
+}
+
+// convertProducts converts the product nodes of a page, collecting the
+// reasons for the ones that cannot be converted
+func (p *Plugin) convertProducts(nodes []node, pageURL string, req *scraper.ScrapeRequest) *scraper.Page {
+	page := &scraper.Page{}
+	for _, n := range nodes {
+		product, err := p.convertProduct(n, pageURL, req)
+		if err != nil {
+			page.Errors = append(page.Errors, err.Error())
+			continue
+		}
+		page.Products = append(page.Products, *product)
+	}
+	return page
+}
+
+// collectProductLinks gathers product URLs from the listing page and every
+// following page reachable through the next-page selector.
+func (p *Plugin) collectProductLinks(ctx context.Context, doc *goquery.Document, pageURL string, req *scraper.ScrapeRequest) ([]string, int, []string) {
+	var links []string
+	var errors []string
+	seenLinks := make(map[string]bool)
+	seenPages := map[string]bool{pageURL: true}
+
+	linkSelector := req.Options["product_link_selector"]
+	if linkSelector == "" {
+		linkSelector = defaultLinkSelector
+	}
+	nextSelector := req.Options["next_page_selector"]
+	if nextSelector == "" {
+		nextSelector = defaultNextSelector
+	}
+	var linkPattern *regexp.Regexp
+	if pattern := req.Options["link_pattern"]; pattern != "" {
+		linkPattern = regexp.MustCompile(pattern)
+	}
+	maxPages := defaultMaxPages
+	if n, err := strconv.Atoi(req.Options["max_pages"]); err == nil && n > 0 {
+		maxPages = n
+	}
+
+	pages := 1
+	for {
+		doc.Find(linkSelector).Each(func(i int, s *goquery.Selection) {
+			href, ok := s.Attr("href")
+			if !ok {
+				return
+			}
+			link := scraper.ResolveURL(pageURL, href)
+			if link == "" || !sameHost(link, pageURL) || seenLinks[link] {
+				return
+			}
+			if linkPattern != nil && !linkPattern.MatchString(link) {
+				return
+			}
+			seenLinks[link] = true
+			links = append(links, link)
+		})
+
+		if pages >= maxPages {
+			break
+		}
+
+		next := ""
+		doc.Find(nextSelector).EachWithBreak(func(i int, s *goquery.Selection) bool {
+			if href, ok := s.Attr("href"); ok {
+				next = scraper.ResolveURL(pageURL, href)
+			}
+			return next == ""
+		})
+		if next == "" || seenPages[next] || !sameHost(next, pageURL) {
+			break
+		}
+		seenPages[next] = true
+
+		if err := ctx.Err(); err != nil {
+			errors = append(errors, fmt.Sprintf("page %d: stopped before fetching: %v", pages+1, err))
+			break
+		}
+
+		nextDoc, nextURL, err := p.fetchDocument(ctx, next)
+		if err != nil {
+			errors = append(errors, fmt.Sprintf("page %d: %v", pages+1, err))
+			break
+		}
+		doc, pageURL = nextDoc, nextURL
+		pages++
+	}
+
+	return links, pages, errors
+}
+
+func (p *Plugin) fetchDocument(ctx context.Context, pageURL string) (*goquery.Document, string, error) {
+	resp, err := p.fetcher.GetHTML(ctx, pageURL)
+	if err != nil {
+		return nil, "", err
+	}
+
+	doc, err := goquery.NewDocumentFromReader(bytes.NewReader(resp.Body))
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to parse HTML: %w", err)
+	}
+
+	// Relative links resolve against the final URL after redirects
+	return doc, resp.URL, nil
+}
+
+func (p *Plugin) convertProduct(n node, pageURL string, req *scraper.ScrapeRequest) (*scraper.ScrapedProduct, error) {
+	name := n.str("name")
+	if name == "" {
+		return nil, fmt.Errorf("product has no name")
+	}
+
+	productURL := pageURL
+	if u := n.str("url"); u != "" {
+		productURL = scraper.ResolveURL(pageURL, u)
+	}
+
+	brand := n.str("brand")
+	if brand == "" {
+		brand = n.str("manufacturer")
+	}
+	if brand == "" {
+		brand = "Unknown"
+	}
+
+	images := imageURLs(n, pageURL)
+
+	var variants []scraper.ScrapedVariant
+	if n.isType("ProductGroup") {
+		variants = p.convertGroupVariants(n, productURL, pageURL, images)
+	}
+	if len(variants) == 0 {
+		variants = p.convertOffers(n, name, productURL, pageURL, images)
+	}
+	if len(variants) == 0 {
+		return nil, fmt.Errorf("product %q has no offers with a price", name)
+	}
+
+	// Offers without a priceCurrency are in the reseller's currency
+	for i := range variants {
+		if variants[i].Currency == "" {
+			variants[i].Currency = strings.ToUpper(req.Currency)
+		}
+	}
+
+	handle := scraper.HandleFromURL(productURL)
+
+	sourceID := n.str("productGroupID")
+	if sourceID == "" {
+		sourceID = n.str("sku")
+	}
+	if sourceID == "" {
+		sourceID = n.gtin()
+	}
+	if sourceID == "" {
+		sourceID = handle
+	}
+
+	metadata := map[string]string{
+		"schema_type": strings.Join(n.strs("@type"), ","),
+	}
+	if sku := n.str("sku"); sku != "" {
+		metadata["sku"] = sku
+	}
+	if gtin := n.gtin(); gtin != "" {
+		metadata["gtin"] = gtin
+	}
+	if mpn := n.str("mpn"); mpn != "" {
+		metadata["mpn"] = mpn
+	}
+	if category := n.str("category"); category != "" {
+		metadata["categories"] = category
+	}
+
+	return &scraper.ScrapedProduct{
+		Name:        name,
+		Description: n.str("description"),
+		Handle:      handle,
+		URL:         productURL,
+		Brand:       brand,
+		Category:    req.Category,
+		Images:      images,
+		Variants:    variants,
+		SourceType:  "JSONLD",
+		SourceID:    scraper.EnsureMaxLength(sourceID, scraper.MaxSourceIDLength),
+		Metadata:    metadata,
+	}, nil
+}
+
+// convertGroupVariants builds one variant per hasVariant entry of a
+// ProductGroup, naming options after the group's variesBy properties.
+func (p *Plugin) convertGroupVariants(group node, productURL, pageURL string, baseImages []string) []scraper.ScrapedVariant {
+	var variants []scraper.ScrapedVariant
+
+	var variesBy []string
+	for _, property := range group.strs("variesBy") {
+		if i := strings.LastIndexAny(property, "/:"); i >= 0 {
+			property = property[i+1:]
+		}
+		variesBy = append(variesBy, property)
+	}
+
+	for _, v := range group.nodes("hasVariant") {
+		offers := v.offers()
+		if len(offers) == 0 {
+			continue
+		}
+		o := offers[0]
+		if !o.HasPrice {
+			continue
+		}
+
+		options := make(map[string]string)
+		for _, property := range variesBy {
+			if value := v.str(property); value != "" {
+				options[optionName(property)] = value
+			}
+		}
+		for _, prop := range v.nodes("additionalProperty") {
+			if name, value := prop.str("name"), prop.str("value"); name != "" && value != "" {
+				options[name] = value
+			}
+		}
+
+		variantURL := productURL
+		if u := v.str("url"); u != "" {
+			variantURL = scraper.ResolveURL(pageURL, u)
+		} else if o.URL != "" {
+			variantURL = scraper.ResolveURL(pageURL, o.URL)
+		}
+
+		images := imageURLs(v, pageURL)
+		if len(images) == 0 {
+			images = baseImages
+		}
+
+		sku := firstNonEmpty(v.str("sku"), o.SKU)
+		gtin := firstNonEmpty(v.gtin(), o.GTIN)
+
+		variants = append(variants, scraper.ScrapedVariant{
+			Name:         variantName(options, v.str("name"), group.str("name")),
+			SKU:          sku,
+			GTIN:         gtin,
+			Price:        o.Price,
+			RegularPrice: o.RegularPrice,
+			Currency:     strings.ToUpper(o.Currency),
+			Available:    o.Available,
+			URL:          variantURL,
+			Images:       images,
+			Options:      options,
+			SourceID:     scraper.EnsureMaxLength(firstNonEmpty(sku, gtin, variantURLKey(variantURL, productURL), v.str("@id"), optionsKey(options, v.str("name")), "default"), scraper.MaxSourceIDLength),
+		})
+	}
+
+	return variants
+}
+
+// convertOffers builds one variant per priced offer of a plain Product.
+func (p *Plugin) convertOffers(n node, productName, productURL, pageURL string, images []string) []scraper.ScrapedVariant {
+	var variants []scraper.ScrapedVariant
+
+	offers := n.offers()
+	for i, o := range offers {
+		if !o.HasPrice {
+			continue
+		}
+
+		variantURL := productURL
+		if o.URL != "" {
+			variantURL = scraper.ResolveURL(pageURL, o.URL)
+		}
+
+		name := "Default"
+		if len(offers) > 1 {
+			name = firstNonEmpty(strings.TrimSpace(strings.TrimPrefix(o.Name, productName)), o.Name, o.SKU, fmt.Sprintf("Offer %d", i+1))
+		}
+
+		sku := firstNonEmpty(o.SKU, n.str("sku"))
+		gtin := firstNonEmpty(o.GTIN, n.gtin())
+
+		sourceID := "default"
+		if len(offers) > 1 {
+			sourceID = firstNonEmpty(o.SKU, o.GTIN, variantURLKey(variantURL, productURL), optionsKey(nil, o.Name), "default")
+		}
+
+		variants = append(variants, scraper.ScrapedVariant{
+			Name:         name,
+			SKU:          sku,
+			GTIN:         gtin,
+			Price:        o.Price,
+			RegularPrice: o.RegularPrice,
+			Currency:     strings.ToUpper(o.Currency),
+			Available:    o.Available,
+			URL:          variantURL,
+			Images:       images,
+			Options:      make(map[string]string),
+			SourceID:     scraper.EnsureMaxLength(sourceID, scraper.MaxSourceIDLength),
+		})
+	}
+
+	return variants
+}
+
+// variantURLKey identifies a variant by its own URL: the Shopify-style
+// variant query parameter when there is one, otherwise the whole URL. It is
+// empty when the variant only has the product's URL.
+func variantURLKey(variantURL, productURL string) string {
+	if variantURL == "" || variantURL == productURL {
+		return ""
+	}
+	if u, err := url.Parse(variantURL); err == nil {
+		if id := u.Query().Get("variant"); id != "" {
+			return id
+		}
+	}
+	return variantURL
+}
+
+// optionsKey hashes a variant's options, sorted by name, or its name when
+// it has none. Either stays the same between scrapes, unlike the variant's
+// position on the page.
+func optionsKey(options map[string]string, name string) string {
+	var parts []string
+	for option, value := range options {
+		parts = append(parts, option+"="+value)
+	}
+	sort.Strings(parts)
+
+	key := strings.Join(parts, ";")
+	if key == "" {
+		key = strings.TrimSpace(name)
+	}
+	if key == "" {
+		return ""
+	}
+	return fmt.Sprintf("%x", md5.Sum([]byte(key)))[:12]
+}
+
+// imageURLs reads the image property, which may be a URL, a list of URLs or
+// ImageObjects.
+func imageURLs(n node, pageURL string) []string {
+	var images []string
+	seen := make(map[string]bool)
+
+	var values []interface{}
+	switch v := n["image"].(type) {
+	case []interface{}:
+		values = v
+	case nil:
+	default:
+		values = []interface{}{v}
+	}
+
+	for _, value := range values {
+		var src string
+		switch v := value.(type) {
+		case string:
+			src = v
+		case map[string]interface{}:
+			src = firstNonEmpty(stringValue(v["url"]), stringValue(v["contentUrl"]))
+		}
+		src = scraper.ResolveURL(pageURL, strings.TrimSpace(src))
+		if src != "" && !seen[src] {
+			seen[src] = true
+			images = append(images, src)
+		}
+	}
+
+	return images
+}
+
+// variantName joins option values in a stable order, falling back to the
+// variant's own name without the group name prefix.
+func variantName(options map[string]string, name, groupName string) string {
+	if len(options) > 0 {
+		keys := make([]string, 0, len(options))
+		for key := range options {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+
+		parts := make([]string, 0, len(keys))
+		for _, key := range keys {
+			parts = append(parts, options[key])
+		}
+		return strings.Join(parts, " - ")
+	}
+
+	if trimmed := strings.Trim(strings.TrimPrefix(name, groupName), " -–|/"); trimmed != "" {
+		return trimmed
+	}
+	if name != "" {
+		return name
+	}
+	return "Default"
+}
+
+func optionName(property string) string {
+	if property == "" {
+		return property
+	}
+	return strings.ToUpper(property[:1]) + property[1:]
+}
+
+func firstNonEmpty(values ...string) string {
+	for _, v := range values {
+		if v != "" {
+			return v
+		}
+	}
+	return ""
+}
+
+// sameURL compares URLs ignoring a trailing slash and the query
+func sameURL(a, b string) bool {
+	if a == "" || b == "" {
+		return false
+	}
+	ua, err := url.Parse(a)
+	if err != nil {
+		return false
+	}
+	ub, err := url.Parse(b)
+	if err != nil {
+		return false
+	}
+	return sameHost(a, b) && strings.TrimSuffix(ua.Path, "/") == strings.TrimSuffix(ub.Path, "/")
+}
+
+func sameHost(a, b string) bool {
+	ua, err := url.Parse(a)
+	if err != nil {
+		return false
+	}
+	ub, err := url.Parse(b)
+	if err != nil {
+		return false
+	}
+	return strings.TrimPrefix(ua.Host, "www.") == strings.TrimPrefix(ub.Host, "www.")
+}
@@ -0,0 +1,125 @@
+package jsonld
+
+import (
+	"context"
+	"encoding/json"
+	"reflect"
+	"testing"
+
+	"github.com/meta-boy/mech-alligator/internal/scraper"
+	"github.com/meta-boy/mech-alligator/internal/scraper/scrapertest"
+)
+
+func TestScrapeGolden(t *testing.T) {
+	tests := []struct {
+		name string
+		url  string
+	}{
+		{"listing", "https://jsonld.example.com/collections/keycaps"},
+		{"product_group", "https://jsonld.example.com/products/gmk-olivia"},
+		{"aggregate_offer", "https://jsonld.example.com/products/epbt-kuro-shiro"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			plugin := NewJSONLDPlugin(scrapertest.NewFetcher(t, "testdata/fixtures"))
+
+			result, err := plugin.Scrape(context.Background(), &scraper.ScrapeRequest{
+				URL:        tt.url,
+				SourceType: "JSONLD",
+				Reseller:   "JSON-LD Example",
+				Category:   "KEYCAPS",
+			})
+			if err != nil {
+				t.Fatalf("Scrape() error = %v", err)
+			}
+
+			scrapertest.AssertGolden(t, "testdata/golden/"+tt.name+".json", result)
+		})
+	}
+}
+
+func TestConvertProductCurrency(t *testing.T) {
+	const product = `{
+		"@type": "Product",
+		"name": "Switch Puller",
+		"offers": [
+			{"name": "Steel", "sku": "SP-1", "price": "300", "priceCurrency": "usd"},
+			{"name": "Brass", "sku": "SP-2", "price": "450"}
+		]
+	}`
+
+	var n node
+	if err := json.Unmarshal([]byte(product), &n); err != nil {
+		t.Fatal(err)
+	}
+
+	p := &Plugin{}
+	got, err := p.convertProduct(n, "https://jsonld.example.com/products/switch-puller", &scraper.ScrapeRequest{Currency: "eur"})
+	if err != nil {
+		t.Fatalf("convertProduct() error = %v", err)
+	}
+
+	// Offers without a priceCurrency take the reseller's
+	var currencies []string
+	for _, v := range got.Variants {
+		currencies = append(currencies, v.Currency)
+	}
+	if want := []string{"USD", "EUR"}; !reflect.DeepEqual(currencies, want) {
+		t.Errorf("convertProduct() variant currencies = %v, want %v", currencies, want)
+	}
+}
+
+func TestGroupVariantSourceIDs(t *testing.T) {
+	const group = `{
+		"@type": "ProductGroup",
+		"name": "GMK Olivia",
+		"variesBy": ["https://schema.org/color"],
+		"hasVariant": [
+			{"name": "Base", "color": "Base", "url": "/products/gmk-olivia?variant=42", "offers": {"price": "11000"}},
+			{"name": "Novelties", "color": "Novelties", "@id": "#novelties", "offers": {"price": "4500"}},
+			{"name": "Dark", "color": "Dark", "offers": {"price": "11000"}},
+			{"name": "Light", "color": "Light", "offers": {"price": "11000"}},
+			{"name": "Spacebars", "offers": {"price": "3000"}}
+		]
+	}`
+
+	var n node
+	if err := json.Unmarshal([]byte(group), &n); err != nil {
+		t.Fatal(err)
+	}
+
+	p := &Plugin{}
+	const productURL = "https://jsonld.example.com/products/gmk-olivia"
+	ids := func(n node) map[string]string {
+		byName := make(map[string]string)
+		for _, v := range p.convertGroupVariants(n, productURL, productURL, nil) {
+			if _, dup := byName[v.SourceID]; dup {
+				t.Errorf("convertGroupVariants() gave %q to more than one variant", v.SourceID)
+			}
+			byName[v.SourceID] = v.Name
+		}
+		got := make(map[string]string)
+		for id, name := range byName {
+			got[name] = id
+		}
+		return got
+	}
+
+	got := ids(n)
+	if got["Base"] != "42" {
+		t.Errorf("variant with a variant URL got source ID %q, want %q", got["Base"], "42")
+	}
+	if got["Novelties"] != "#novelties" {
+		t.Errorf("variant with an @id got source ID %q, want %q", got["Novelties"], "#novelties")
+	}
+
+	// Reordering the variants on the page keeps every ID
+	variants := n["hasVariant"].([]interface{})
+	for i, j := 0, len(variants)-1; i < j; i, j = i+1, j-1 {
+		variants[i], variants[j] = variants[j], variants[i]
+	}
+	if reordered := ids(n); !reflect.DeepEqual(reordered, got) {
+		t.Errorf("convertGroupVariants() source IDs changed with the order of the variants:\n%v\nwant\n%v", reordered, got)
+	}
+}
@@ -0,0 +1,40 @@
+// Package plugins registers the scraper plugins built into the service, so
+// the worker that runs them and the API that describes them agree.
+package plugins
+
+import (
+	"log"
+
+	"github.com/meta-boy/mech-alligator/internal/scraper"
+	"github.com/meta-boy/mech-alligator/internal/scraper/plugins/jsonld"
+	"github.com/meta-boy/mech-alligator/internal/scraper/plugins/selector"
+	"github.com/meta-boy/mech-alligator/internal/scraper/plugins/shopify"
+	"github.com/meta-boy/mech-alligator/internal/scraper/plugins/stackskb"
+	"github.com/meta-boy/mech-alligator/internal/scraper/plugins/woocommerce"
+)
+
+// All returns one of each built-in plugin, all sharing fetcher
+func All(fetcher *scraper.Fetcher) []scraper.Plugin {
+	return []scraper.Plugin{
+		shopify.NewShopifyPlugin(fetcher),
+		stackskb.NewStacksKBPlugin(fetcher),
+		woocommerce.NewWooCommercePlugin(fetcher),
+		jsonld.NewJSONLDPlugin(fetcher),
+		selector.NewSelectorPlugin(fetcher),
+	}
+}
+
+// NewManager creates a scraper manager with every built-in plugin
+// registered. The fetcher is shared so per-host limits hold across
+// concurrent jobs.
+func NewManager(fetcher *scraper.Fetcher) *scraper.Manager {
+	manager := scraper.NewManager(fetcher)
+
+	for _, plugin := range All(fetcher) {
+		if err := manager.RegisterPlugin(plugin); err != nil {
+			log.Printf("Warning: Failed to register %s plugin: %v", plugin.Name(), err)
+		}
+	}
+
+	return manager
+}
@@ -0,0 +1,310 @@
+package selector
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/andybalholm/cascadia"
+	"github.com/meta-boy/mech-alligator/internal/scraper"
+)
+
+// Option keys read from reseller_configs.options. Field selectors accept an
+// optional "@attr" suffix to read an attribute instead of the element text,
+// e.g. "img.wp-post-image@data-src".
+const (
+	keyListingItem      = "listing.item"
+	keyListingLink      = "listing.link"
+	keyListingName      = "listing.name"
+	keyListingPrice     = "listing.price"
+	keyListingImage     = "listing.image"
+	keyListingSoldOut   = "listing.sold_out"
+	keyPaginationNext   = "pagination.next"
+	keyPaginationURL    = "pagination.url"
+	keyPaginationMax    = "pagination.max_pages"
+	keyDetailName       = "detail.name"
+	keyDetailPrice      = "detail.price"
+	keyDetailRegular    = "detail.regular_price"
+	keyDetailDesc       = "detail.description"
+	keyDetailImages     = "detail.images"
+	keyDetailSKU        = "detail.sku"
+	keyDetailBrand      = "detail.brand"
+	keyDetailSoldOut    = "detail.sold_out"
+	keyAttributesRow    = "attributes.row"
+	keyAttributesLabel  = "attributes.label"
+	keyAttributesValue  = "attributes.value"
+	keyPriceDecimal     = "price.decimal_separator"
+	keyPriceCurrency    = "price.currency"
+	keyBrandDefault     = "brand.default"
+	attributeMapPrefix  = "attributes.map."
+	defaultMaxPages     = 20
+	defaultLinkSelector = "a@href"
+)
+
+// Attribute mapping targets for attributes.map.<Label>
+const (
+	targetBrand  = "brand"
+	targetSKU    = "sku"
+	targetTag    = "tag"
+	targetOption = "option"
+)
+
+// field is a CSS selector plus the attribute to read; an empty attr means
+// the element's text
+type field struct {
+	selector string
+	attr     string
+}
+
+func (f field) isSet() bool {
+	return f.selector != ""
+}
+
+// config is the parsed and validated form of the plugin options
+type config struct {
+	listingItem    field
+	listingLink    field
+	listingName    field
+	listingPrice   field
+	listingImage   field
+	listingSoldOut field
+
+	paginationNext field
+	paginationURL  string
+	maxPages       int
+
+	detailName    field
+	detailPrice   field
+	detailRegular field
+	detailDesc    field
+	detailImages  field
+	detailSKU     field
+	detailBrand   field
+	detailSoldOut field
+
+	attributesRow   field
+	attributesLabel field
+	attributesValue field
+	attributeMap    map[string]string // lower-cased label -> target
+
+	decimalSeparator string
+	currency         string
+	defaultBrand     string
+}
+
+// hasDetail reports whether product pages should be fetched
+func (c *config) hasDetail() bool {
+	return c.detailName.isSet()
+}
+
+// optionSpecs describes every key parseConfig reads
+var optionSpecs = []scraper.OptionSpec{
+	{Key: keyListingItem, Type: scraper.OptionSelector, Required: true,
+		Description: "One element per product on listing pages; the other listing selectors are relative to it"},
+	{Key: keyListingLink, Type: scraper.OptionSelector, Default: defaultLinkSelector,
+		Description: "Link to the product page, reading href unless an @attr is given"},
+	{Key: keyListingName, Type: scraper.OptionSelector,
+		Description: "Product name; required when detail.name is not set"},
+	{Key: keyListingPrice, Type: scraper.OptionSelector,
+		Description: "Product price; required when neither detail.name nor detail.price is set"},
+	{Key: keyListingImage, Type: scraper.OptionSelector,
+		Description: "Product image, reading src unless an @attr is given"},
+	{Key: keyListingSoldOut, Type: scraper.OptionSelector,
+		Description: "Element present only on sold-out products"},
+	{Key: keyPaginationNext, Type: scraper.OptionSelector,
+		Description: "Link to the next listing page; set this or pagination.url"},
+	{Key: keyPaginationURL, Type: scraper.OptionString,
+		Description: "Listing page URL template with a {page} placeholder; set this or pagination.next"},
+	{Key: keyPaginationMax, Type: scraper.OptionInt, Min: 1, Default: strconv.Itoa(defaultMaxPages),
+		Description: "Listing pages to scrape at most"},
+	{Key: keyDetailName, Type: scraper.OptionSelector,
+		Description: "Product name on the product page; setting it makes the plugin fetch every product page"},
+	{Key: keyDetailPrice, Type: scraper.OptionSelector,
+		Description: "Price on the product page"},
+	{Key: keyDetailRegular, Type: scraper.OptionSelector,
+		Description: "Pre-discount price on the product page"},
+	{Key: keyDetailDesc, Type: scraper.OptionSelector,
+		Description: "Description on the product page"},
+	{Key: keyDetailImages, Type: scraper.OptionSelector,
+		Description: "Gallery images on the product page, reading src unless an @attr is given"},
+	{Key: keyDetailSKU, Type: scraper.OptionSelector,
+		Description: "SKU on the product page"},
+	{Key: keyDetailBrand, Type: scraper.OptionSelector,
+		Description: "Brand on the product page"},
+	{Key: keyDetailSoldOut, Type: scraper.OptionSelector,
+		Description: "Element present only when the product is sold out"},
+	{Key: keyAttributesRow, Type: scraper.OptionSelector,
+		Description: "One element per row of the product attributes table"},
+	{Key: keyAttributesLabel, Type: scraper.OptionSelector,
+		Description: "Attribute label within a row"},
+	{Key: keyAttributesValue, Type: scraper.OptionSelector,
+		Description: "Attribute value within a row"},
+	{Key: attributeMapPrefix, Type: scraper.OptionEnum, Prefix: true,
+		Values:      []string{targetBrand, targetSKU, targetTag, targetOption},
+		Description: "Where the attribute with this label goes, e.g. attributes.map.Brand=brand"},
+	{Key: keyPriceDecimal, Type: scraper.OptionEnum, Values: []string{".", ","}, Default: ".",
+		Description: "Decimal separator used in prices"},
+	{Key: keyPriceCurrency, Type: scraper.OptionString,
+		Description: "3-letter currency code of the prices; defaults to the reseller's currency"},
+	{Key: keyBrandDefault, Type: scraper.OptionString,
+		Description: "Brand for products whose page does not name one"},
+}
+
+var knownKeys = func() map[string]bool {
+	keys := make(map[string]bool)
+	for _, spec := range optionSpecs {
+		if !spec.Prefix {
+			keys[spec.Key] = true
+		}
+	}
+	return keys
+}()
+
+// parseConfig reads the plugin configuration from the request options and
+// reports every problem found rather than stopping at the first one.
+func parseConfig(options map[string]string) (*config, error) {
+	var problems []string
+
+	parseField := func(key, defaultValue string) field {
+		raw := strings.TrimSpace(options[key])
+		if raw == "" {
+			raw = defaultValue
+		}
+		if raw == "" {
+			return field{}
+		}
+
+		f := field{selector: raw}
+		if i := strings.LastIndex(raw, "@"); i > 0 && !strings.ContainsAny(raw[i:], " ]") {
+			f.selector = strings.TrimSpace(raw[:i])
+			f.attr = strings.TrimSpace(raw[i+1:])
+		}
+
+		if _, err := cascadia.ParseGroup(f.selector); err != nil {
+			problems = append(problems, fmt.Sprintf("%s: invalid selector %q: %v", key, f.selector, err))
+			return field{}
+		}
+		return f
+	}
+
+	cfg := &config{
+		listingItem:    parseField(keyListingItem, ""),
+		listingLink:    parseField(keyListingLink, defaultLinkSelector),
+		listingName:    parseField(keyListingName, ""),
+		listingPrice:   parseField(keyListingPrice, ""),
+		listingImage:   parseField(keyListingImage, ""),
+		listingSoldOut: parseField(keyListingSoldOut, ""),
+
+		paginationNext: parseField(keyPaginationNext, ""),
+		paginationURL:  strings.TrimSpace(options[keyPaginationURL]),
+		maxPages:       defaultMaxPages,
+
+		detailName:    parseField(keyDetailName, ""),
+		detailPrice:   parseField(keyDetailPrice, ""),
+		detailRegular: parseField(keyDetailRegular, ""),
+		detailDesc:    parseField(keyDetailDesc, ""),
+		detailImages:  parseField(keyDetailImages, ""),
+		detailSKU:     parseField(keyDetailSKU, ""),
+		detailBrand:   parseField(keyDetailBrand, ""),
+		detailSoldOut: parseField(keyDetailSoldOut, ""),
+
+		attributesRow:   parseField(keyAttributesRow, ""),
+		attributesLabel: parseField(keyAttributesLabel, ""),
+		attributesValue: parseField(keyAttributesValue, ""),
+		attributeMap:    make(map[string]string),
+
+		decimalSeparator: ".",
+		defaultBrand:     strings.TrimSpace(options[keyBrandDefault]),
+	}
+
+	if !cfg.listingItem.isSet() && options[keyListingItem] == "" {
+		problems = append(problems, keyListingItem+" is required")
+	}
+
+	// Products need a name and price from either the listing or the detail page
+	if !cfg.hasDetail() {
+		if !cfg.listingName.isSet() {
+			problems = append(problems, keyListingName+" is required when "+keyDetailName+" is not set")
+		}
+		if !cfg.listingPrice.isSet() {
+			problems = append(problems, keyListingPrice+" is required when "+keyDetailName+" is not set")
+		}
+	} else if !cfg.detailPrice.isSet() && !cfg.listingPrice.isSet() {
+		problems = append(problems, keyDetailPrice+" or "+keyListingPrice+" is required")
+	}
+
+	if cfg.paginationNext.isSet() && cfg.paginationURL != "" {
+		problems = append(problems, "set only one of "+keyPaginationNext+" and "+keyPaginationURL)
+	}
+	if cfg.paginationURL != "" && !strings.Contains(cfg.paginationURL, "{page}") {
+		problems = append(problems, keyPaginationURL+" must contain a {page} placeholder")
+	}
+	if raw := options[keyPaginationMax]; raw != "" {
+		n, err := strconv.Atoi(raw)
+		if err != nil || n < 1 {
+			problems = append(problems, keyPaginationMax+" must be a positive number")
+		} else {
+			cfg.maxPages = n
+		}
+	}
+
+	if sep := options[keyPriceDecimal]; sep != "" {
+		if sep != "." && sep != "," {
+			problems = append(problems, keyPriceDecimal+` must be "." or ","`)
+		} else {
+			cfg.decimalSeparator = sep
+		}
+	}
+	if currency := strings.TrimSpace(options[keyPriceCurrency]); currency != "" {
+		if len(currency) != 3 {
+			problems = append(problems, keyPriceCurrency+" must be a 3-letter currency code")
+		} else {
+			cfg.currency = strings.ToUpper(currency)
+		}
+	}
+
+	hasAttributeMap := false
+	for key, target := range options {
+		if !strings.HasPrefix(key, attributeMapPrefix) {
+			continue
+		}
+		hasAttributeMap = true
+
+		label := strings.ToLower(strings.TrimSpace(strings.TrimPrefix(key, attributeMapPrefix)))
+		if label == "" {
+			problems = append(problems, key+": attribute label is empty")
+			continue
+		}
+		target = strings.ToLower(strings.TrimSpace(target))
+		switch target {
+		case targetBrand, targetSKU, targetTag, targetOption:
+			cfg.attributeMap[label] = target
+		default:
+			problems = append(problems, fmt.Sprintf("%s: unknown target %q (use brand, sku, tag or option)", key, target))
+		}
+	}
+	if hasAttributeMap && (!cfg.attributesRow.isSet() || !cfg.attributesLabel.isSet() || !cfg.attributesValue.isSet()) {
+		problems = append(problems, "attribute mappings need "+keyAttributesRow+", "+keyAttributesLabel+" and "+keyAttributesValue)
+	}
+
+	// Catch typos in keys that belong to this plugin's namespaces
+	for key := range options {
+		if knownKeys[key] || strings.HasPrefix(key, attributeMapPrefix) {
+			continue
+		}
+		for _, prefix := range []string{"listing.", "detail.", "pagination.", "attributes.", "price.", "brand."} {
+			if strings.HasPrefix(key, prefix) {
+				problems = append(problems, fmt.Sprintf("unknown option %q", key))
+				break
+			}
+		}
+	}
+
+	if len(problems) > 0 {
+		sort.Strings(problems)
+		return nil, fmt.Errorf("invalid selector config: %s", strings.Join(problems, "; "))
+	}
+
+	return cfg, nil
+}
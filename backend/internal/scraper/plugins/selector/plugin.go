@@ -0,0 +1,491 @@
+package selector
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/PuerkitoBio/goquery"
+	"github.com/meta-boy/mech-alligator/internal/scraper"
+)
+
+// Plugin scrapes plain HTML stores using CSS selectors taken entirely from
+// the reseller config options, so a new store needs a config row rather than
+// a new plugin. See config.go for the supported keys.
+type Plugin struct {
+	fetcher *scraper.Fetcher
+
+	mu       sync.Mutex
+	listings map[string]map[string]*listingData // Listing URL -> product URL -> card
+}
+
+func NewSelectorPlugin(fetcher *scraper.Fetcher) *Plugin {
+	return &Plugin{
+		fetcher:  fetcher,
+		listings: make(map[string]map[string]*listingData),
+	}
+}
+
+func (p *Plugin) Name() string {
+	return "selector"
+}
+
+func (p *Plugin) SupportedTypes() []string {
+	return []string{"SELECTOR"}
+}
+
+func (p *Plugin) Options() []scraper.OptionSpec {
+	return optionSpecs
+}
+
+func (p *Plugin) ValidateRequest(req *scraper.ScrapeRequest) error {
+	if req.URL == "" {
+		return fmt.Errorf("URL is required")
+	}
+	if !strings.HasPrefix(req.URL, "http") {
+		return fmt.Errorf("URL must include protocol (http/https)")
+	}
+
+	_, err := parseConfig(req.Options)
+	return err
+}
+
+func (p *Plugin) Scrape(ctx context.Context, req *scraper.ScrapeRequest) (*scraper.ScrapeResult, error) {
+	return scraper.Collect(ctx, p, req)
+}
+
+// ScrapeStream emits each listing item as soon as it is scraped, or each
+// product page when detail selectors are set
+func (p *Plugin) ScrapeStream(ctx context.Context, req *scraper.ScrapeRequest, emit scraper.Emitter) (scraper.ScrapeStats, error) {
+	return scraper.StreamPages(ctx, p, req, emit, scraper.DefaultConcurrency)
+}
+
+// ScrapePage scrapes the listing. Pages are found through the next link or
+// the URL template and must be read in order to spot a store repeating its
+// last page, so the whole listing counts as page 1. With detail selectors
+// the product links are returned for the manager to fetch in parallel.
+func (p *Plugin) ScrapePage(ctx context.Context, req *scraper.ScrapeRequest, page int) (*scraper.Page, error) {
+	result := &scraper.Page{TotalPages: 1}
+	if page > 1 {
+		return result, nil
+	}
+
+	cfg, err := parseConfig(req.Options)
+	if err != nil {
+		return nil, err
+	}
+
+	seen := make(map[string]bool)
+	listings := make(map[string]*listingData)
+
+	pageURL := req.URL
+	for n := 1; n <= cfg.maxPages && pageURL != ""; n++ {
+		if err := ctx.Err(); err != nil {
+			result.Errors = append(result.Errors, fmt.Sprintf("page %d: stopped before fetching: %v", n, err))
+			break
+		}
+
+		doc, finalURL, err := p.fetchDocument(ctx, pageURL)
+		if err != nil {
+			if n == 1 {
+				return nil, fmt.Errorf("failed to fetch listing page: %w", err)
+			}
+			// Templated pagination ends with a 404 past the last page
+			if cfg.paginationURL == "" || !scraper.IsHTTPStatus(err, http.StatusNotFound) {
+				result.Errors = append(result.Errors, fmt.Sprintf("page %d: %v", n, err))
+			}
+			break
+		}
+		result.Fetched++
+
+		items := doc.Find(cfg.listingItem.selector)
+		if items.Length() == 0 {
+			if n == 1 {
+				result.Errors = append(result.Errors, fmt.Sprintf("page 1: no elements match %s %q", keyListingItem, cfg.listingItem.selector))
+			}
+			break
+		}
+
+		newItems := 0
+		items.Each(func(i int, s *goquery.Selection) {
+			productURL, listing, err := p.scrapeListingItem(cfg, s, finalURL)
+			var product *scraper.ScrapedProduct
+			if err == nil && !cfg.hasDetail() {
+				product, err = p.listingProduct(cfg, productURL, listing, req)
+			}
+			if err != nil {
+				result.Errors = append(result.Errors, fmt.Sprintf("page %d item %d: %v", n, i, err))
+				return
+			}
+			if seen[productURL] {
+				return
+			}
+			seen[productURL] = true
+			newItems++
+
+			if cfg.hasDetail() {
+				result.DetailURLs = append(result.DetailURLs, productURL)
+				listings[productURL] = listing
+			} else {
+				result.Products = append(result.Products, *product)
+			}
+		})
+
+		// Stores that ignore out-of-range page numbers repeat the last page
+		if newItems == 0 {
+			break
+		}
+
+		pageURL = p.nextPageURL(cfg, doc, finalURL, n+1)
+	}
+
+	if cfg.hasDetail() {
+		p.setListings(req.URL, listings)
+	}
+	return result, nil
+}
+
+// ScrapeProduct scrapes a single product page with the detail selectors.
+// Fields the page lacks are taken from the product's listing card when the
+// page was found through the request's listing.
+func (p *Plugin) ScrapeProduct(ctx context.Context, req *scraper.ScrapeRequest, productURL string) (*scraper.ScrapedProduct, error) {
+	cfg, err := parseConfig(req.Options)
+	if err != nil {
+		return nil, err
+	}
+	if !cfg.hasDetail() {
+		return nil, fmt.Errorf("%s is required to scrape product pages", keyDetailName)
+	}
+
+	return p.scrapeDetail(ctx, cfg, productURL, p.takeListing(req.URL, productURL), req)
+}
+
+// setListings keeps the listing cards of a listing's product pages until
+// ScrapeProduct takes them. Cards left over by an earlier scrape of the
+// same listing that stopped early are dropped.
+func (p *Plugin) setListings(listingURL string, listings map[string]*listingData) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if len(listings) == 0 {
+		delete(p.listings, listingURL)
+		return
+	}
+	p.listings[listingURL] = listings
+}
+
+// takeListing returns and forgets the listing card of a product page
+func (p *Plugin) takeListing(listingURL, productURL string) *listingData {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	listings := p.listings[listingURL]
+	listing := listings[productURL]
+	delete(listings, productURL)
+	if len(listings) == 0 {
+		delete(p.listings, listingURL)
+	}
+	return listing
+}
+
+func (p *Plugin) nextPageURL(cfg *config, doc *goquery.Document, pageURL string, nextPage int) string {
+	if cfg.paginationURL != "" {
+		return strings.ReplaceAll(cfg.paginationURL, "{page}", strconv.Itoa(nextPage))
+	}
+	if cfg.paginationNext.isSet() {
+		next := scraper.ResolveURL(pageURL, extract(doc.Selection, field{selector: cfg.paginationNext.selector, attr: attrOrDefault(cfg.paginationNext, "href")}))
+		if next != pageURL {
+			return next
+		}
+	}
+	return ""
+}
+
+// listingData is what a listing card yields, used as a fallback for fields
+// the detail page does not provide
+type listingData struct {
+	name      string
+	price     float64
+	hasPrice  bool
+	image     string
+	available bool
+}
+
+// scrapeListingItem reads a listing card's product link and the fields the
+// listing selectors pick out
+func (p *Plugin) scrapeListingItem(cfg *config, s *goquery.Selection, pageURL string) (string, *listingData, error) {
+	productURL := scraper.ResolveURL(pageURL, extract(s, field{selector: cfg.listingLink.selector, attr: attrOrDefault(cfg.listingLink, "href")}))
+	if productURL == "" {
+		return "", nil, fmt.Errorf("no product link found")
+	}
+
+	listing := &listingData{available: true}
+	if cfg.listingName.isSet() {
+		listing.name = extract(s, cfg.listingName)
+	}
+	if cfg.listingPrice.isSet() {
+		listing.price, listing.hasPrice = parsePrice(extract(s, cfg.listingPrice), cfg.decimalSeparator)
+	}
+	if cfg.listingImage.isSet() {
+		listing.image = scraper.ResolveURL(pageURL, extract(s, field{selector: cfg.listingImage.selector, attr: attrOrDefault(cfg.listingImage, "src")}))
+	}
+	if cfg.listingSoldOut.isSet() && s.Find(cfg.listingSoldOut.selector).Length() > 0 {
+		listing.available = false
+	}
+
+	return productURL, listing, nil
+}
+
+// listingProduct builds a product from its listing card alone
+func (p *Plugin) listingProduct(cfg *config, productURL string, listing *listingData, req *scraper.ScrapeRequest) (*scraper.ScrapedProduct, error) {
+	if listing.name == "" {
+		return nil, fmt.Errorf("no product name found")
+	}
+	if !listing.hasPrice {
+		return nil, fmt.Errorf("no price found for %q", listing.name)
+	}
+
+	var images []string
+	if listing.image != "" {
+		images = append(images, listing.image)
+	}
+
+	return p.buildProduct(cfg, productURL, listing.name, "", p.brandOrDefault(cfg, ""), "", listing.price, 0, listing.available, images, nil, nil, req), nil
+}
+
+func (p *Plugin) scrapeDetail(ctx context.Context, cfg *config, productURL string, listing *listingData, req *scraper.ScrapeRequest) (*scraper.ScrapedProduct, error) {
+	doc, finalURL, err := p.fetchDocument(ctx, productURL)
+	if err != nil {
+		return nil, err
+	}
+	root := doc.Selection
+
+	name := extract(root, cfg.detailName)
+	if name == "" && listing != nil {
+		name = listing.name
+	}
+	if name == "" {
+		return nil, fmt.Errorf("no product name found on %s", productURL)
+	}
+
+	var price float64
+	hasPrice := false
+	if cfg.detailPrice.isSet() {
+		price, hasPrice = parsePrice(extract(root, cfg.detailPrice), cfg.decimalSeparator)
+	}
+	if !hasPrice && listing != nil && listing.hasPrice {
+		price, hasPrice = listing.price, true
+	}
+	if !hasPrice {
+		return nil, fmt.Errorf("no price found for %q", name)
+	}
+
+	var regularPrice float64
+	if cfg.detailRegular.isSet() {
+		if regular, ok := parsePrice(extract(root, cfg.detailRegular), cfg.decimalSeparator); ok && regular > price {
+			regularPrice = regular
+		}
+	}
+
+	available := listing == nil || listing.available
+	if cfg.detailSoldOut.isSet() {
+		available = root.Find(cfg.detailSoldOut.selector).Length() == 0
+	}
+
+	var description string
+	if cfg.detailDesc.isSet() {
+		if cfg.detailDesc.attr == "" {
+			description, _ = root.Find(cfg.detailDesc.selector).First().Html()
+			description = strings.TrimSpace(description)
+		} else {
+			description = extract(root, cfg.detailDesc)
+		}
+	}
+
+	var images []string
+	if cfg.detailImages.isSet() {
+		seen := make(map[string]bool)
+		attr := attrOrDefault(cfg.detailImages, "src")
+		root.Find(cfg.detailImages.selector).Each(func(i int, s *goquery.Selection) {
+			src := scraper.ResolveURL(finalURL, strings.TrimSpace(s.AttrOr(attr, "")))
+			if src != "" && !seen[src] {
+				seen[src] = true
+				images = append(images, src)
+			}
+		})
+	}
+	if len(images) == 0 && listing != nil && listing.image != "" {
+		images = append(images, listing.image)
+	}
+
+	var sku, brand string
+	if cfg.detailSKU.isSet() {
+		sku = extract(root, cfg.detailSKU)
+	}
+	if cfg.detailBrand.isSet() {
+		brand = extract(root, cfg.detailBrand)
+	}
+
+	// Apply attribute table mappings
+	var tags []string
+	options := make(map[string]string)
+	if cfg.attributesRow.isSet() {
+		root.Find(cfg.attributesRow.selector).Each(func(i int, row *goquery.Selection) {
+			label := strings.TrimSuffix(extract(row, cfg.attributesLabel), ":")
+			value := extract(row, cfg.attributesValue)
+			if label == "" || value == "" {
+				return
+			}
+
+			switch cfg.attributeMap[strings.ToLower(label)] {
+			case targetBrand:
+				if brand == "" {
+					brand = value
+				}
+			case targetSKU:
+				if sku == "" {
+					sku = value
+				}
+			case targetTag:
+				tags = append(tags, strings.ToLower(value))
+			case targetOption:
+				options[label] = value
+			}
+		})
+	}
+
+	return p.buildProduct(cfg, productURL, name, description, p.brandOrDefault(cfg, brand), sku, price, regularPrice, available, images, tags, options, req), nil
+}
+
+func (p *Plugin) buildProduct(cfg *config, productURL, name, description, brand, sku string, price, regularPrice float64, available bool, images, tags []string, options map[string]string, req *scraper.ScrapeRequest) *scraper.ScrapedProduct {
+	handle := scraper.HandleFromURL(productURL)
+
+	sourceID := handle
+	if sku != "" {
+		sourceID = sku
+	}
+	if sourceID == "" {
+		sourceID = generateHandle(name)
+	}
+
+	if options == nil {
+		options = make(map[string]string)
+	}
+
+	currency := cfg.currency
+	if currency == "" {
+		currency = strings.ToUpper(req.Currency)
+	}
+
+	return &scraper.ScrapedProduct{
+		Name:        name,
+		Description: description,
+		Handle:      generateHandle(name),
+		URL:         productURL,
+		Brand:       brand,
+		Category:    req.Category,
+		Tags:        tags,
+		Images:      images,
+		Variants: []scraper.ScrapedVariant{
+			{
+				Name:         "Default",
+				SKU:          sku,
+				Price:        price,
+				RegularPrice: regularPrice,
+				Currency:     currency,
+				Available:    available,
+				URL:          productURL,
+				Images:       images,
+				Options:      options,
+				SourceID:     "default",
+			},
+		},
+		SourceType: "SELECTOR",
+		SourceID:   scraper.EnsureMaxLength(sourceID, scraper.MaxSourceIDLength),
+		Metadata: map[string]string{
+			"detail_page": strconv.FormatBool(cfg.hasDetail()),
+		},
+	}
+}
+
+func (p *Plugin) brandOrDefault(cfg *config, brand string) string {
+	if brand != "" {
+		return brand
+	}
+	if cfg.defaultBrand != "" {
+		return cfg.defaultBrand
+	}
+	return "Unknown"
+}
+
+func (p *Plugin) fetchDocument(ctx context.Context, pageURL string) (*goquery.Document, string, error) {
+	resp, err := p.fetcher.GetHTML(ctx, pageURL)
+	if err != nil {
+		return nil, "", err
+	}
+
+	doc, err := goquery.NewDocumentFromReader(bytes.NewReader(resp.Body))
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to parse HTML: %w", err)
+	}
+
+	return doc, resp.URL, nil
+}
+
+// extract returns the trimmed text or attribute of the first match
+func extract(s *goquery.Selection, f field) string {
+	match := s.Find(f.selector).First()
+	if match.Length() == 0 {
+		return ""
+	}
+	if f.attr != "" {
+		return strings.TrimSpace(match.AttrOr(f.attr, ""))
+	}
+	return strings.Join(strings.Fields(match.Text()), " ")
+}
+
+func attrOrDefault(f field, attr string) string {
+	if f.attr != "" {
+		return f.attr
+	}
+	return attr
+}
+
+var priceNumber = regexp.MustCompile(`\d[\d.,\s]*`)
+
+// parsePrice reads the first number in the text. When a range such as
+// "₹1,999 – ₹2,499" is shown, the lower bound is used.
+func parsePrice(text, decimalSeparator string) (float64, bool) {
+	match := strings.TrimSpace(priceNumber.FindString(text))
+	if match == "" {
+		return 0, false
+	}
+
+	thousands := ","
+	if decimalSeparator == "," {
+		thousands = "."
+	}
+	match = strings.ReplaceAll(match, thousands, "")
+	match = strings.ReplaceAll(match, " ", "")
+	match = strings.ReplaceAll(match, decimalSeparator, ".")
+
+	price, err := strconv.ParseFloat(match, 64)
+	if err != nil {
+		return 0, false
+	}
+	return price, true
+}
+
+func generateHandle(name string) string {
+	handle := regexp.MustCompile(`[^\p{L}\p{N}]+`).ReplaceAllString(strings.ToLower(name), "-")
+	handle = strings.Trim(handle, "-")
+	if handle == "" {
+		return "unnamed-product"
+	}
+	return handle
+}
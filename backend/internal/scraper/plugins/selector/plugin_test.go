@@ -0,0 +1,121 @@
+package selector
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	"github.com/meta-boy/mech-alligator/internal/scraper"
+	"github.com/meta-boy/mech-alligator/internal/scraper/scrapertest"
+)
+
+// listingOptions read everything from the listing cards
+var listingOptions = map[string]string{
+	"listing.item":            "li.product-card",
+	"listing.link":            "a.title",
+	"listing.name":            "a.title",
+	"listing.price":           ".price.now",
+	"listing.image":           "img.thumb@data-src",
+	"listing.sold_out":        ".badge-soldout",
+	"pagination.next":         "a.next",
+	"price.decimal_separator": ",",
+	"brand.default":           "HTML Example",
+}
+
+func TestScrapeGolden(t *testing.T) {
+	detailOptions := map[string]string{
+		"detail.name":           "h1.product-title",
+		"detail.price":          ".price ins",
+		"detail.regular_price":  ".price del",
+		"detail.description":    ".description",
+		"detail.images":         ".gallery img",
+		"detail.sold_out":       ".stock.out-of-stock",
+		"attributes.row":        "table.attributes tr",
+		"attributes.label":      "th",
+		"attributes.value":      "td",
+		"attributes.map.Brand":  "brand",
+		"attributes.map.Model":  "sku",
+		"attributes.map.Colour": "option",
+		"attributes.map.Mount":  "tag",
+	}
+	for key, value := range listingOptions {
+		if _, ok := detailOptions[key]; !ok {
+			detailOptions[key] = value
+		}
+	}
+
+	tests := []struct {
+		name    string
+		options map[string]string
+	}{
+		{"listing", listingOptions},
+		{"detail", detailOptions},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			plugin := NewSelectorPlugin(scrapertest.NewFetcher(t, "testdata/fixtures"))
+
+			req := &scraper.ScrapeRequest{
+				URL:        "https://html.example.com/shop/",
+				SourceType: "SELECTOR",
+				Reseller:   "HTML Example",
+				Category:   "ACCESSORIES",
+				Currency:   "INR",
+				Options:    tt.options,
+			}
+			if err := plugin.ValidateRequest(req); err != nil {
+				t.Fatalf("ValidateRequest() error = %v", err)
+			}
+
+			result, err := plugin.Scrape(context.Background(), req)
+			if err != nil {
+				t.Fatalf("Scrape() error = %v", err)
+			}
+
+			scrapertest.AssertGolden(t, "testdata/golden/"+tt.name+".json", result)
+		})
+	}
+}
+
+// Product pages found through the listing fall back to the listing card
+// for the fields their selectors do not cover
+func TestScrapeDetailListingFallback(t *testing.T) {
+	options := map[string]string{"detail.name": "h1.product-title"}
+	for key, value := range listingOptions {
+		options[key] = value
+	}
+
+	plugin := NewSelectorPlugin(scrapertest.NewFetcher(t, "testdata/fixtures"))
+	result, err := plugin.Scrape(context.Background(), &scraper.ScrapeRequest{
+		URL:        "https://html.example.com/shop/",
+		SourceType: "SELECTOR",
+		Options:    options,
+	})
+	if err != nil {
+		t.Fatalf("Scrape() error = %v", err)
+	}
+
+	want := map[string]float64{
+		"https://html.example.com/product/durock-stabilizers/": 1299,
+		"https://html.example.com/product/switch-puller/":      249,
+		"https://html.example.com/product/lube-station/":       899,
+	}
+	got := make(map[string]float64)
+	for _, product := range result.Products {
+		got[product.URL] = product.Variants[0].Price
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Scrape() prices = %v, want the listing prices %v", got, want)
+	}
+
+	if len(plugin.listings) != 0 {
+		t.Errorf("Scrape() left %d listings behind", len(plugin.listings))
+	}
+
+	// Without the listing there is nothing to fall back to
+	_, err = plugin.ScrapeProduct(context.Background(), &scraper.ScrapeRequest{URL: "https://html.example.com/", Options: options}, "https://html.example.com/product/lube-station/")
+	if err == nil {
+		t.Error("ScrapeProduct() without a listing price succeeded, want an error")
+	}
+}
@@ -4,21 +4,32 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
-	"net/http"
+	"net/url"
 	"strconv"
 	"strings"
-	"time"
+	"sync"
 
 	"github.com/meta-boy/mech-alligator/internal/scraper"
 )
 
+const (
+	// pageLimit is the largest page size products.json accepts
+	pageLimit = 250
+	// maxCollectionPages bounds the walk of collections.json
+	maxCollectionPages = 20
+)
+
 type Plugin struct {
-	client *http.Client
+	fetcher *scraper.Fetcher
+
+	mu         sync.Mutex
+	currencies map[string]string // Store currencies from meta.json by base URL
 }
 
-func NewShopifyPlugin() *Plugin {
+func NewShopifyPlugin(fetcher *scraper.Fetcher) *Plugin {
 	return &Plugin{
-		client: &http.Client{Timeout: 30 * time.Second},
+		fetcher:    fetcher,
+		currencies: make(map[string]string),
 	}
 }
 
@@ -30,6 +41,13 @@ func (p *Plugin) SupportedTypes() []string {
 	return []string{"SHOPIFY"}
 }
 
+func (p *Plugin) Options() []scraper.OptionSpec {
+	return []scraper.OptionSpec{
+		{Key: "max_pages", Type: scraper.OptionInt, Default: "0",
+			Description: "Stop after this many pages of products.json; 0 walks until an empty page"},
+	}
+}
+
 func (p *Plugin) ValidateRequest(req *scraper.ScrapeRequest) error {
 	if req.URL == "" {
 		return fmt.Errorf("URL is required")
@@ -37,102 +55,286 @@ func (p *Plugin) ValidateRequest(req *scraper.ScrapeRequest) error {
 	if !strings.HasPrefix(req.URL, "http") {
 		return fmt.Errorf("URL must include protocol (http/https)")
 	}
+	if maxPages, ok := req.Options["max_pages"]; ok {
+		if n, err := strconv.Atoi(maxPages); err != nil || n < 0 {
+			return fmt.Errorf("max_pages must be a non-negative number")
+		}
+	}
 	return nil
 }
 
 func (p *Plugin) Scrape(ctx context.Context, req *scraper.ScrapeRequest) (*scraper.ScrapeResult, error) {
-	start := time.Now()
+	return scraper.Collect(ctx, p, req)
+}
+
+// ScrapeStream emits each page of products as soon as it is converted
+func (p *Plugin) ScrapeStream(ctx context.Context, req *scraper.ScrapeRequest, emit scraper.Emitter) (scraper.ScrapeStats, error) {
+	return scraper.StreamPages(ctx, p, req, emit, scraper.DefaultConcurrency)
+}
+
+// ScrapePage converts one page of products.json. The store does not say
+// how many pages there are; the listing ends with an empty page.
+func (p *Plugin) ScrapePage(ctx context.Context, req *scraper.ScrapeRequest, page int) (*scraper.Page, error) {
+	baseURL, collection, err := p.parseStoreURL(req.URL)
+	if err != nil {
+		return nil, err
+	}
 
-	// Build API URL
-	apiURL := p.buildAPIURL(req)
+	currency, err := p.currency(ctx, req, baseURL)
+	if err != nil {
+		return nil, err
+	}
 
-	// Fetch products
-	shopifyProducts, err := p.fetchProducts(ctx, apiURL)
+	shopifyProducts, err := p.fetchProducts(ctx, p.buildAPIURL(baseURL, collection, page))
 	if err != nil {
 		return nil, fmt.Errorf("failed to fetch products: %w", err)
 	}
 
 	// Convert to our format
-	var products []scraper.ScrapedProduct
-	var errors []string
-	variantCount := 0
-
+	result := &scraper.Page{}
 	for _, sp := range shopifyProducts {
-		product, productErrors := p.convertProduct(sp, req)
+		product, productErrors := p.convertProduct(sp, baseURL, currency, req)
 		if product != nil {
-			products = append(products, *product)
-			variantCount += len(product.Variants)
+			result.Products = append(result.Products, *product)
 		}
-		errors = append(errors, productErrors...)
-	}
-
-	return &scraper.ScrapeResult{
-		Products: products,
-		Errors:   errors,
-		Stats: scraper.ScrapeStats{
-			ProductsFound: len(products),
-			VariantsFound: variantCount,
-			ErrorCount:    len(errors),
-			Duration:      time.Since(start).String(),
-			Source:        req.Reseller,
-		},
-	}, nil
+		result.Errors = append(result.Errors, productErrors...)
+	}
+
+	return result, nil
 }
 
-func (p *Plugin) buildAPIURL(req *scraper.ScrapeRequest) string {
-	baseURL := strings.TrimSuffix(req.URL, "/")
+// ScrapeProduct scrapes one product from its page's JSON, which lets
+// sitemap discovery hand the plugin product URLs
+func (p *Plugin) ScrapeProduct(ctx context.Context, req *scraper.ScrapeRequest, productURL string) (*scraper.ScrapedProduct, error) {
+	page, err := p.ScrapeProductPage(ctx, req, productURL)
+	if err != nil {
+		return nil, err
+	}
+	return &page.Products[0], nil
+}
 
-	// Handle collection URLs
-	if strings.Contains(baseURL, "/collections/") {
-		return baseURL + "/products.json?limit=250"
+// ScrapeProductPage is ScrapeProduct keeping the errors of variants that
+// could not be converted
+func (p *Plugin) ScrapeProductPage(ctx context.Context, req *scraper.ScrapeRequest, productURL string) (*scraper.Page, error) {
+	baseURL, handle, err := p.parseProductURL(productURL)
+	if err != nil {
+		return nil, err
 	}
+	pageURL := baseURL + "/products/" + handle
 
-	// Default to all products
-	return baseURL + "/products.json?limit=250"
+	currency, err := p.currency(ctx, req, baseURL)
+	if err != nil {
+		return nil, err
+	}
+
+	var resp ProductResponse
+	if _, err := p.fetcher.GetJSON(ctx, pageURL+".json", &resp); err != nil {
+		return nil, fmt.Errorf("failed to fetch product: %w", err)
+	}
+	if resp.Product.ID == 0 {
+		return nil, fmt.Errorf("no product at %s.json", pageURL)
+	}
+
+	// Product page JSON has no variant availability; the .js version does
+	if err := p.fillAvailability(ctx, pageURL+".js", resp.Product.Variants); err != nil {
+		return nil, fmt.Errorf("failed to fetch availability: %w", err)
+	}
+
+	product, productErrors := p.convertProduct(resp.Product, baseURL, currency, req)
+	if product == nil {
+		return nil, fmt.Errorf("%s", strings.Join(productErrors, "; "))
+	}
+	return &scraper.Page{Products: []scraper.ScrapedProduct{*product}, Errors: productErrors}, nil
 }
 
-func (p *Plugin) fetchProducts(ctx context.Context, url string) ([]Product, error) {
-	request, err := http.NewRequestWithContext(ctx, "GET", url, nil)
+// fillAvailability sets the availability of variants that lack it from
+// the product's .js endpoint. Variants it does not list are unavailable.
+func (p *Plugin) fillAvailability(ctx context.Context, jsURL string, variants []Variant) error {
+	missing := false
+	for _, v := range variants {
+		missing = missing || v.Available == nil
+	}
+	if !missing {
+		return nil
+	}
+
+	resp, err := p.fetcher.Get(ctx, jsURL, scraper.AcceptJSON, "application/json", "application/javascript", "text/javascript")
+	if err != nil {
+		return err
+	}
+	var productJS ProductJS
+	if err := json.Unmarshal(resp.Body, &productJS); err != nil {
+		return fmt.Errorf("failed to decode JSON from %s: %w", jsURL, err)
+	}
+
+	available := make(map[int64]bool)
+	for _, v := range productJS.Variants {
+		available[v.ID] = v.Available
+	}
+	for i := range variants {
+		if variants[i].Available == nil {
+			a := available[variants[i].ID]
+			variants[i].Available = &a
+		}
+	}
+	return nil
+}
+
+// ListCollections lists the store's collections from /collections.json.
+// The frontpage collection is left out since it only repeats featured
+// products.
+func (p *Plugin) ListCollections(ctx context.Context, siteURL string) ([]scraper.Collection, error) {
+	baseURL, _, err := p.parseStoreURL(siteURL)
 	if err != nil {
 		return nil, err
 	}
 
-	request.Header.Set("User-Agent", "Mozilla/5.0 (compatible; ProductScraper/1.0)")
-	request.Header.Set("Accept", "application/json")
+	var collections []scraper.Collection
+	for page := 1; page <= maxCollectionPages; page++ {
+		var resp CollectionsResponse
+		url := fmt.Sprintf("%s/collections.json?limit=%d&page=%d", baseURL, pageLimit, page)
+		if _, err := p.fetcher.GetJSON(ctx, url, &resp); err != nil {
+			return nil, fmt.Errorf("failed to fetch collections: %w", err)
+		}
+		if len(resp.Collections) == 0 {
+			break
+		}
 
-	resp, err := p.client.Do(request)
+		for _, c := range resp.Collections {
+			if c.Handle == "frontpage" {
+				continue
+			}
+			collections = append(collections, scraper.Collection{
+				Name:         c.Title,
+				Handle:       c.Handle,
+				URL:          baseURL + "/collections/" + c.Handle,
+				ProductCount: c.ProductsCount,
+			})
+		}
+	}
+
+	return collections, nil
+}
+
+// DescribeStore reads the store's country and currency from /meta.json
+func (p *Plugin) DescribeStore(ctx context.Context, siteURL string) (*scraper.StoreInfo, error) {
+	baseURL, _, err := p.parseStoreURL(siteURL)
 	if err != nil {
 		return nil, err
 	}
-	defer resp.Body.Close()
 
-	if resp.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("HTTP %d from %s", resp.StatusCode, url)
+	var meta Meta
+	if _, err := p.fetcher.GetJSON(ctx, baseURL+"/meta.json", &meta); err != nil {
+		return nil, fmt.Errorf("failed to fetch store meta: %w", err)
+	}
+
+	return &scraper.StoreInfo{
+		Country:  strings.ToUpper(meta.Country),
+		Currency: strings.ToUpper(meta.Currency),
+	}, nil
+}
+
+// currency returns the currency of the store's prices, which products.json
+// does not give: the reseller's currency from the request, or else the one
+// the store's /meta.json names
+func (p *Plugin) currency(ctx context.Context, req *scraper.ScrapeRequest, baseURL string) (string, error) {
+	if req.Currency != "" {
+		return strings.ToUpper(req.Currency), nil
+	}
+
+	p.mu.Lock()
+	currency, ok := p.currencies[baseURL]
+	p.mu.Unlock()
+	if ok {
+		return currency, nil
+	}
+
+	info, err := p.DescribeStore(ctx, baseURL)
+	if err != nil {
+		return "", fmt.Errorf("failed to find the store currency: %w", err)
+	}
+	if info.Currency == "" {
+		return "", fmt.Errorf("failed to find the store currency: meta.json names none")
+	}
+
+	p.mu.Lock()
+	p.currencies[baseURL] = info.Currency
+	p.mu.Unlock()
+	return info.Currency, nil
+}
+
+// parseStoreURL splits a store or collection URL into the store's base URL
+// and the collection handle, if any. Anything after the handle (product
+// paths, query strings, pagination) is dropped.
+func (p *Plugin) parseStoreURL(rawURL string) (string, string, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return "", "", fmt.Errorf("invalid URL: %w", err)
+	}
+
+	baseURL := u.Scheme + "://" + u.Host
+
+	parts := strings.Split(strings.Trim(u.Path, "/"), "/")
+	for i, part := range parts {
+		if part == "collections" && i+1 < len(parts) {
+			handle := strings.TrimSuffix(parts[i+1], ".json")
+			// "all" is Shopify's built-in collection of every product
+			if handle != "" && handle != "all" && handle != "products" {
+				return baseURL, handle, nil
+			}
+		}
 	}
 
+	return baseURL, "", nil
+}
+
+// parseProductURL splits a product URL, which may be nested in a
+// collection, into the store's base URL and the product handle
+func (p *Plugin) parseProductURL(rawURL string) (string, string, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return "", "", fmt.Errorf("invalid URL: %w", err)
+	}
+
+	parts := strings.Split(strings.Trim(u.Path, "/"), "/")
+	for i, part := range parts {
+		if part == "products" && i+1 < len(parts) {
+			handle := strings.TrimSuffix(strings.TrimSuffix(parts[i+1], ".json"), ".js")
+			if handle != "" {
+				return u.Scheme + "://" + u.Host, handle, nil
+			}
+		}
+	}
+
+	return "", "", fmt.Errorf("%s is not a product URL", rawURL)
+}
+
+func (p *Plugin) buildAPIURL(baseURL, collection string, page int) string {
+	query := fmt.Sprintf("?limit=%d&page=%d", pageLimit, page)
+
+	// Handle collection URLs
+	if collection != "" {
+		return baseURL + "/collections/" + collection + "/products.json" + query
+	}
+
+	// Default to all products
+	return baseURL + "/products.json" + query
+}
+
+func (p *Plugin) fetchProducts(ctx context.Context, url string) ([]Product, error) {
 	var shopifyResp Response
-	if err := json.NewDecoder(resp.Body).Decode(&shopifyResp); err != nil {
+	if _, err := p.fetcher.GetJSON(ctx, url, &shopifyResp); err != nil {
 		return nil, err
 	}
 
 	return shopifyResp.Products, nil
 }
 
-func (p *Plugin) convertProduct(sp Product, req *scraper.ScrapeRequest) (*scraper.ScrapedProduct, []string) {
+func (p *Plugin) convertProduct(sp Product, baseURL, currency string, req *scraper.ScrapeRequest) (*scraper.ScrapedProduct, []string) {
 	var errors []string
 
 	// Extract brand from vendor field or product title
-	brand := p.extractBrand(sp.Vendor, sp.Title)
-
-	// Build base URL for product links
-	baseURL := strings.TrimSuffix(req.URL, "/")
-	if strings.Contains(baseURL, "/collections/") {
-		// Extract domain from collection URL
-		parts := strings.Split(baseURL, "/collections/")
-		if len(parts) > 0 {
-			baseURL = parts[0]
-		}
-	}
+	brand := p.extractBrand(sp.Vendor)
 
 	productURL := baseURL + "/products/" + sp.Handle
 
@@ -143,9 +345,10 @@ func (p *Plugin) convertProduct(sp Product, req *scraper.ScrapeRequest) (*scrape
 	}
 
 	// Convert variants
+	optionNames := p.optionNames(sp.Options)
 	var variants []scraper.ScrapedVariant
 	for _, sv := range sp.Variants {
-		variant, err := p.convertVariant(sv, productURL, baseURL)
+		variant, err := p.convertVariant(sv, productURL, currency, optionNames)
 		if err != nil {
 			errors = append(errors, fmt.Sprintf("variant %d: %s", sv.ID, err.Error()))
 			continue
@@ -184,7 +387,7 @@ func (p *Plugin) convertProduct(sp Product, req *scraper.ScrapeRequest) (*scrape
 	return product, errors
 }
 
-func (p *Plugin) convertVariant(sv Variant, productURL, baseURL string) (scraper.ScrapedVariant, error) {
+func (p *Plugin) convertVariant(sv Variant, productURL, currency string, optionNames [3]string) (scraper.ScrapedVariant, error) {
 	price, err := strconv.ParseFloat(sv.Price, 64)
 	if err != nil {
 		return scraper.ScrapedVariant{}, fmt.Errorf("invalid price: %s", sv.Price)
@@ -196,16 +399,21 @@ func (p *Plugin) convertVariant(sv Variant, productURL, baseURL string) (scraper
 		variantURL += "?variant=" + strconv.FormatInt(sv.ID, 10)
 	}
 
-	// Build variant options
+	// Build variant options keyed by the product's option names
 	options := make(map[string]string)
-	if sv.Option1 != "" {
-		options["option1"] = sv.Option1
+	values := [3]string{sv.Option1}
+	if sv.Option2 != nil {
+		values[1] = *sv.Option2
 	}
-	if sv.Option2 != nil && *sv.Option2 != "" {
-		options["option2"] = *sv.Option2
+	if sv.Option3 != nil {
+		values[2] = *sv.Option3
 	}
-	if sv.Option3 != nil && *sv.Option3 != "" {
-		options["option3"] = *sv.Option3
+	for i, value := range values {
+		// Single-variant products carry a placeholder "Title: Default Title" option
+		if value == "" || value == "Default Title" {
+			continue
+		}
+		options[optionNames[i]] = value
 	}
 
 	// Variant-specific images
@@ -218,8 +426,8 @@ func (p *Plugin) convertVariant(sv Variant, productURL, baseURL string) (scraper
 		Name:      sv.Title,
 		SKU:       sv.SKU,
 		Price:     price,
-		Currency:  "INR", // Default, should be configurable per reseller
-		Available: sv.Available,
+		Currency:  currency,
+		Available: sv.Available != nil && *sv.Available,
 		URL:       variantURL,
 		Images:    images,
 		Options:   options,
@@ -229,62 +437,35 @@ func (p *Plugin) convertVariant(sv Variant, productURL, baseURL string) (scraper
 	return variant, nil
 }
 
-// extractBrand tries to determine the actual brand from Shopify vendor field or product title
-func (p *Plugin) extractBrand(vendor, title string) string {
-	// If vendor is meaningful, use it
-	if vendor != "" && !p.isGenericVendor(vendor) {
-		return vendor
-	}
-
-	// Try to extract brand from title using common patterns
-	title = strings.ToLower(title)
-
-	// Known brand patterns
-	brands := []string{
-		"wuque studio", "gmk", "cherry", "keychron", "akko", "ducky",
-		"leopold", "varmilo", "filco", "topre", "hhkb", "realforce",
-		"drop", "novelkeys", "gateron", "kailh", "holy panda", "zealios",
-		"artisan", "sa", "oem", "cherry profile", "xda", "dsa",
-	}
+// optionNames resolves the names of option1..option3 from the product's
+// options array, falling back to the positional key when a name is missing.
+func (p *Plugin) optionNames(productOptions []Option) [3]string {
+	names := [3]string{"option1", "option2", "option3"}
 
-	for _, brand := range brands {
-		if strings.Contains(title, brand) {
-			return p.capitalizeWords(brand)
+	for i, opt := range productOptions {
+		index := opt.Position - 1
+		if opt.Position == 0 {
+			index = i
 		}
-	}
-
-	// Fallback to vendor even if generic
-	if vendor != "" {
-		return vendor
-	}
-
-	return "Unknown"
-}
-
-func (p *Plugin) isGenericVendor(vendor string) bool {
-	genericVendors := []string{
-		"default", "shop", "store", "keyboards", "keycaps", "switches",
-		"mechanical", "gaming", "tech", "electronics",
-	}
-
-	vendorLower := strings.ToLower(vendor)
-	for _, generic := range genericVendors {
-		if strings.Contains(vendorLower, generic) {
-			return true
+		if index < 0 || index >= len(names) || strings.TrimSpace(opt.Name) == "" {
+			continue
 		}
+		names[index] = strings.TrimSpace(opt.Name)
 	}
 
-	return false
+	return names
 }
 
-func (p *Plugin) capitalizeWords(s string) string {
-	words := strings.Fields(s)
-	for i, word := range words {
-		if len(word) > 0 {
-			words[i] = strings.ToUpper(word[:1]) + strings.ToLower(word[1:])
-		}
+// extractBrand returns the Shopify vendor as it is. Brands are matched
+// against the registry, which strips corporate suffixes and looks for them
+// in the title, when the product is saved; a vendor that is really the
+// store's own name only becomes a provisional brand.
+func (p *Plugin) extractBrand(vendor string) string {
+	vendor = strings.TrimSpace(vendor)
+	if vendor == "" {
+		return "Unknown"
 	}
-	return strings.Join(words, " ")
+	return vendor
 }
 
 // ShopifyResponse represents the JSON response from Shopify products API
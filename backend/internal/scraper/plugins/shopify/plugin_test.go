@@ -0,0 +1,101 @@
+package shopify
+
+import (
+	"context"
+	"testing"
+
+	"github.com/meta-boy/mech-alligator/internal/scraper"
+	"github.com/meta-boy/mech-alligator/internal/scraper/scrapertest"
+)
+
+func TestScrapeCollectionGolden(t *testing.T) {
+	plugin := NewShopifyPlugin(scrapertest.NewFetcher(t, "testdata/fixtures"))
+
+	result, err := plugin.Scrape(context.Background(), &scraper.ScrapeRequest{
+		URL:        "https://keys.example.com/collections/switches",
+		SourceType: "SHOPIFY",
+		Reseller:   "Keys Example",
+		Category:   "SWITCHES",
+	})
+	if err != nil {
+		t.Fatalf("Scrape() error = %v", err)
+	}
+
+	scrapertest.AssertGolden(t, "testdata/golden/collection.json", result)
+}
+
+func TestScrapeSitemapGolden(t *testing.T) {
+	fetcher := scrapertest.NewFetcher(t, "testdata/fixtures")
+	manager := scraper.NewManager(fetcher)
+	if err := manager.RegisterPlugin(NewShopifyPlugin(fetcher)); err != nil {
+		t.Fatalf("RegisterPlugin() error = %v", err)
+	}
+
+	result, err := manager.ScrapeByType(context.Background(), &scraper.ScrapeRequest{
+		URL:        "https://keys.example.com",
+		SourceType: "SHOPIFY",
+		Reseller:   "Keys Example",
+		Category:   "SWITCHES",
+		Currency:   "INR",
+		Options:    map[string]string{"discovery": "sitemap"},
+	})
+	if err != nil {
+		t.Fatalf("ScrapeByType() error = %v", err)
+	}
+
+	scrapertest.AssertGolden(t, "testdata/golden/sitemap.json", result)
+}
+
+func TestDescribeStore(t *testing.T) {
+	plugin := NewShopifyPlugin(scrapertest.NewFetcher(t, "testdata/fixtures"))
+
+	info, err := plugin.DescribeStore(context.Background(), "https://keys.example.com/collections/switches")
+	if err != nil {
+		t.Fatalf("DescribeStore() error = %v", err)
+	}
+
+	want := scraper.StoreInfo{Country: "IN", Currency: "INR"}
+	if *info != want {
+		t.Errorf("DescribeStore() = %+v, want %+v", *info, want)
+	}
+}
+
+func TestCurrency(t *testing.T) {
+	plugin := NewShopifyPlugin(scrapertest.NewFetcher(t, "testdata/fixtures"))
+
+	tests := []struct {
+		currency string
+		want     string
+	}{
+		{"usd", "USD"}, // The reseller's currency needs no request
+		{"", "INR"},    // Else meta.json names it
+	}
+
+	for _, tt := range tests {
+		got, err := plugin.currency(context.Background(), &scraper.ScrapeRequest{Currency: tt.currency}, "https://keys.example.com")
+		if err != nil || got != tt.want {
+			t.Errorf("currency() with request currency %q = %q, %v; want %q", tt.currency, got, err, tt.want)
+		}
+	}
+}
+
+func TestExtractBrand(t *testing.T) {
+	tests := []struct {
+		vendor string
+		want   string
+	}{
+		{"GMK Electronics", "GMK Electronics"},
+		{"  Keychron ", "Keychron"},
+		{"TechKeys", "TechKeys"},
+		{"Keycaps Shop", "Keycaps Shop"},
+		{"", "Unknown"},
+		{"   ", "Unknown"},
+	}
+
+	plugin := &Plugin{}
+	for _, tt := range tests {
+		if got := plugin.extractBrand(tt.vendor); got != tt.want {
+			t.Errorf("extractBrand(%q) = %q, want %q", tt.vendor, got, tt.want)
+		}
+	}
+}
@@ -1,9 +1,47 @@
 package shopify
 
+import (
+	"encoding/json"
+	"strings"
+)
+
 type Response struct {
 	Products []Product `json:"products"`
 }
 
+// ProductResponse is a product page's JSON, /products/{handle}.json
+type ProductResponse struct {
+	Product Product `json:"product"`
+}
+
+// ProductJS is the part of /products/{handle}.js the product page JSON
+// leaves out
+type ProductJS struct {
+	Variants []struct {
+		ID        int64 `json:"id"`
+		Available bool  `json:"available"`
+	} `json:"variants"`
+}
+
+// Meta is the store's /meta.json
+type Meta struct {
+	Name     string `json:"name"`
+	Country  string `json:"country"`
+	Currency string `json:"currency"`
+}
+
+// CollectionsResponse is a page of /collections.json
+type CollectionsResponse struct {
+	Collections []Collection `json:"collections"`
+}
+
+type Collection struct {
+	ID            int64  `json:"id"`
+	Title         string `json:"title"`
+	Handle        string `json:"handle"`
+	ProductsCount int    `json:"products_count"`
+}
+
 type Product struct {
 	ID          int64     `json:"id"`
 	Title       string    `json:"title"`
@@ -14,9 +52,18 @@ type Product struct {
 	UpdatedAt   string    `json:"updated_at"`
 	Vendor      string    `json:"vendor"`
 	ProductType string    `json:"product_type"`
-	Tags        []string  `json:"tags"`
+	Tags        Tags      `json:"tags"`
 	Variants    []Variant `json:"variants"`
 	Images      []Image   `json:"images"`
+	Options     []Option  `json:"options"`
+}
+
+// Option names the variant option stored at the same position in
+// option1..option3, e.g. {"name": "Switch", "position": 1}
+type Option struct {
+	Name     string   `json:"name"`
+	Position int      `json:"position"`
+	Values   []string `json:"values"`
 }
 
 type Variant struct {
@@ -26,7 +73,7 @@ type Variant struct {
 	Option2       *string       `json:"option2"`
 	Option3       *string       `json:"option3"`
 	SKU           string        `json:"sku"`
-	Available     bool          `json:"available"`
+	Available     *bool         `json:"available"` // Missing from product page JSON
 	Price         string        `json:"price"`
 	FeaturedImage *VariantImage `json:"featured_image"`
 	ProductID     int64         `json:"product_id"`
@@ -46,3 +93,27 @@ type Image struct {
 	Src       string `json:"src"`
 	Alt       string `json:"alt"`
 }
+
+// Tags are a list in products.json but a comma-separated string in product
+// page JSON
+type Tags []string
+
+func (t *Tags) UnmarshalJSON(data []byte) error {
+	var list []string
+	if err := json.Unmarshal(data, &list); err == nil {
+		*t = list
+		return nil
+	}
+
+	var joined string
+	if err := json.Unmarshal(data, &joined); err != nil {
+		return err
+	}
+	*t = nil
+	for _, tag := range strings.Split(joined, ",") {
+		if tag = strings.TrimSpace(tag); tag != "" {
+			*t = append(*t, tag)
+		}
+	}
+	return nil
+}
@@ -1,32 +1,28 @@
 package stackskb
 
 import (
+	"bytes"
 	"context"
 	"crypto/md5"
 	"encoding/json"
 	"fmt"
 	"html"
-	"net/http"
 	"net/url"
 	"regexp"
+	"sort"
 	"strconv"
 	"strings"
-	"time"
 
 	"github.com/PuerkitoBio/goquery"
 	"github.com/meta-boy/mech-alligator/internal/scraper"
 )
 
 type Plugin struct {
-	client *http.Client
+	fetcher *scraper.Fetcher
 }
 
-func NewStacksKBPlugin() *Plugin {
-	return &Plugin{
-		client: &http.Client{
-			Timeout: 30 * time.Second,
-		},
-	}
+func NewStacksKBPlugin(fetcher *scraper.Fetcher) *Plugin {
+	return &Plugin{fetcher: fetcher}
 }
 
 func (p *Plugin) Name() string {
@@ -37,6 +33,18 @@ func (p *Plugin) SupportedTypes() []string {
 	return []string{"STACKS", "STACKSKB"}
 }
 
+// Hosts makes detection pick this plugin for StacksKB's custom storefront
+func (p *Plugin) Hosts() []string {
+	return []string{"stackskb.com"}
+}
+
+func (p *Plugin) Options() []scraper.OptionSpec {
+	return []scraper.OptionSpec{
+		{Key: "max_pages", Type: scraper.OptionInt, Default: "0",
+			Description: "Stop after this many listing pages; 0 means every page"},
+	}
+}
+
 func (p *Plugin) ValidateRequest(req *scraper.ScrapeRequest) error {
 	if req.URL == "" {
 		return fmt.Errorf("URL is required")
@@ -48,86 +56,59 @@ func (p *Plugin) ValidateRequest(req *scraper.ScrapeRequest) error {
 }
 
 func (p *Plugin) Scrape(ctx context.Context, req *scraper.ScrapeRequest) (*scraper.ScrapeResult, error) {
-	start := time.Now()
+	return scraper.Collect(ctx, p, req)
+}
 
+// ScrapeStream emits the products of each listing page as soon as it is
+// parsed, or the single product of a detail page
+func (p *Plugin) ScrapeStream(ctx context.Context, req *scraper.ScrapeRequest, emit scraper.Emitter) (scraper.ScrapeStats, error) {
+	return scraper.StreamPages(ctx, p, req, emit, scraper.DefaultConcurrency)
+}
+
+// ScrapePage scrapes one page of a category listing. A product detail page
+// is treated as a listing of one.
+func (p *Plugin) ScrapePage(ctx context.Context, req *scraper.ScrapeRequest, page int) (*scraper.Page, error) {
 	// Determine if this is a product listing or product detail page
 	if p.isProductDetailPage(req.URL) {
-		return p.scrapeProductDetail(ctx, req, start)
-	} else {
-		return p.scrapeProductListing(ctx, req, start)
+		product, err := p.ScrapeProduct(ctx, req, req.URL)
+		if err != nil {
+			return nil, err
+		}
+		return &scraper.Page{Products: []scraper.ScrapedProduct{*product}, TotalPages: 1}, nil
 	}
-}
 
-func (p *Plugin) isProductDetailPage(url string) bool {
-	// Listing pages contain /product-category/
-	// Everything else is a product detail page
-	return !strings.Contains(url, "/product-category/")
-}
-
-func (p *Plugin) scrapeProductListing(ctx context.Context, req *scraper.ScrapeRequest, start time.Time) (*scraper.ScrapeResult, error) {
-	baseURL := p.getBaseCategoryURL(req.URL)
-	allProducts := []scraper.ScrapedProduct{}
-	var allErrors []string
+	pageURL := p.getBaseCategoryURL(req.URL)
+	if page > 1 {
+		pageURL = fmt.Sprintf("%s/page/%d/", strings.TrimSuffix(pageURL, "/"), page)
+	}
 
-	// Fetch first page to determine total pages
-	firstPageHTML, err := p.fetchPage(ctx, baseURL)
+	pageHTML, err := p.fetchPage(ctx, pageURL)
 	if err != nil {
-		return nil, fmt.Errorf("failed to fetch first page: %w", err)
+		return nil, err
 	}
 
-	doc, err := goquery.NewDocumentFromReader(strings.NewReader(firstPageHTML))
+	doc, err := goquery.NewDocumentFromReader(strings.NewReader(pageHTML))
 	if err != nil {
 		return nil, fmt.Errorf("failed to parse HTML: %w", err)
 	}
 
-	// Extract products from first page
-	firstPageProducts, pageErrors := p.extractProductsFromListing(doc, req)
-	allProducts = append(allProducts, firstPageProducts...)
-	allErrors = append(allErrors, pageErrors...)
-
-	// Determine total pages
-	totalPages := p.extractTotalPages(doc)
-
-	// If there are more pages, scrape them
-	if totalPages > 1 {
-		for page := 2; page <= totalPages; page++ {
-			// Add delay to be respectful
-			time.Sleep(2 * time.Second)
-
-			pageURL := fmt.Sprintf("%s/page/%d/", strings.TrimSuffix(baseURL, "/"), page)
-			pageHTML, err := p.fetchPage(ctx, pageURL)
-			if err != nil {
-				allErrors = append(allErrors, fmt.Sprintf("page %d: %v", page, err))
-				continue
-			}
-
-			pageDoc, err := goquery.NewDocumentFromReader(strings.NewReader(pageHTML))
-			if err != nil {
-				allErrors = append(allErrors, fmt.Sprintf("page %d: failed to parse HTML: %v", page, err))
-				continue
-			}
-
-			pageProducts, pagePageErrors := p.extractProductsFromListing(pageDoc, req)
-			allProducts = append(allProducts, pageProducts...)
-			allErrors = append(allErrors, pagePageErrors...)
-		}
-	}
-
-	return &scraper.ScrapeResult{
-		Products: allProducts,
-		Errors:   allErrors,
-		Stats: scraper.ScrapeStats{
-			ProductsFound: len(allProducts),
-			VariantsFound: p.countVariants(allProducts),
-			ErrorCount:    len(allErrors),
-			Duration:      time.Since(start).String(),
-			Source:        req.Reseller,
-		},
+	products, errors := p.extractProductsFromListing(doc, req)
+	return &scraper.Page{
+		Products:   products,
+		Errors:     errors,
+		TotalPages: p.extractTotalPages(doc),
 	}, nil
 }
 
-func (p *Plugin) scrapeProductDetail(ctx context.Context, req *scraper.ScrapeRequest, start time.Time) (*scraper.ScrapeResult, error) {
-	html, err := p.fetchPage(ctx, req.URL)
+func (p *Plugin) isProductDetailPage(url string) bool {
+	// Listing pages contain /product-category/
+	// Everything else is a product detail page
+	return !strings.Contains(url, "/product-category/")
+}
+
+// ScrapeProduct scrapes a single product detail page
+func (p *Plugin) ScrapeProduct(ctx context.Context, req *scraper.ScrapeRequest, productURL string) (*scraper.ScrapedProduct, error) {
+	html, err := p.fetchPage(ctx, productURL)
 	if err != nil {
 		return nil, fmt.Errorf("failed to fetch product page: %w", err)
 	}
@@ -137,44 +118,24 @@ func (p *Plugin) scrapeProductDetail(ctx context.Context, req *scraper.ScrapeReq
 		return nil, fmt.Errorf("failed to parse HTML: %w", err)
 	}
 
-	product, err := p.extractProductDetail(doc, req)
+	detailReq := *req
+	detailReq.URL = productURL
+
+	product, err := p.extractProductDetail(doc, &detailReq)
 	if err != nil {
 		return nil, fmt.Errorf("failed to extract product: %w", err)
 	}
 
-	return &scraper.ScrapeResult{
-		Products: []scraper.ScrapedProduct{*product},
-		Errors:   []string{},
-		Stats: scraper.ScrapeStats{
-			ProductsFound: 1,
-			VariantsFound: len(product.Variants),
-			ErrorCount:    0,
-			Duration:      time.Since(start).String(),
-			Source:        req.Reseller,
-		},
-	}, nil
+	return product, nil
 }
 
 func (p *Plugin) fetchPage(ctx context.Context, url string) (string, error) {
-	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
+	resp, err := p.fetcher.GetHTML(ctx, url)
 	if err != nil {
 		return "", err
 	}
 
-	req.Header.Set("User-Agent", "Mozilla/5.0 (compatible; ProductScraper/1.0)")
-	req.Header.Set("Accept", "text/html,application/xhtml+xml,application/xml;q=0.9,*/*;q=0.8")
-
-	resp, err := p.client.Do(req)
-	if err != nil {
-		return "", err
-	}
-	defer resp.Body.Close()
-
-	if resp.StatusCode != http.StatusOK {
-		return "", fmt.Errorf("HTTP %d from %s", resp.StatusCode, url)
-	}
-
-	doc, err := goquery.NewDocumentFromReader(resp.Body)
+	doc, err := goquery.NewDocumentFromReader(bytes.NewReader(resp.Body))
 	if err != nil {
 		return "", err
 	}
@@ -261,8 +222,8 @@ func (p *Plugin) extractProductFromListingItem(s *goquery.Selection, req *scrape
 	// Extract categories from classes
 	categories := p.extractCategoriesFromClasses(s)
 
-	// Determine brand
-	brand := p.determineBrand(name, categories)
+	// The listing has no brand; the registry finds it in the name
+	brand := "Unknown"
 
 	// Generate tags
 	tags := p.generateTags(name, categories)
@@ -323,7 +284,7 @@ func (p *Plugin) extractProductDetail(doc *goquery.Document, req *scraper.Scrape
 	basePrice, currency := p.extractPrice(priceElement)
 
 	// Extract brand
-	brand := p.extractBrandFromAttributes(doc, title)
+	brand := p.extractBrandFromAttributes(doc)
 
 	// Extract categories and tags
 	categories, tags := p.extractCategoriesAndTags(doc, title)
@@ -444,7 +405,7 @@ func (p *Plugin) extractImages(doc *goquery.Document) []string {
 	return images
 }
 
-func (p *Plugin) extractBrandFromAttributes(doc *goquery.Document, title string) string {
+func (p *Plugin) extractBrandFromAttributes(doc *goquery.Document) string {
 	// Check product attributes table
 	foundBrand := ""
 	doc.Find("table.woocommerce-product-attributes tr").Each(func(i int, s *goquery.Selection) {
@@ -461,8 +422,8 @@ func (p *Plugin) extractBrandFromAttributes(doc *goquery.Document, title string)
 		return foundBrand
 	}
 
-	// Fallback to brand detection from title
-	return p.determineBrand(title, []string{})
+	// The registry finds the brand in the title when the product is saved
+	return "Unknown"
 }
 
 func (p *Plugin) extractCategoriesAndTags(doc *goquery.Document, title string) ([]string, []string) {
@@ -570,8 +531,16 @@ func (p *Plugin) createVariantFromJSON(varData map[string]interface{}, currency
 
 	// Extract name from attributes
 	if attributes, ok := varData["attributes"].(map[string]interface{}); ok {
+		// Sorted so the variant name does not depend on map order
+		keys := make([]string, 0, len(attributes))
+		for key := range attributes {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+
 		var nameParts []string
-		for key, value := range attributes {
+		for _, key := range keys {
+			value := attributes[key]
 			// Clean up attribute key (remove attribute_ and pa_ prefixes)
 			cleanKey := strings.ReplaceAll(key, "attribute_", "")
 			cleanKey = strings.ReplaceAll(cleanKey, "pa_", "")
@@ -684,9 +653,12 @@ func (p *Plugin) generateVariantCombinations(variationOptions map[string][]map[s
 	var attributes []string
 	var optionLists [][]map[string]string
 
-	for attrName, options := range variationOptions {
+	for attrName := range variationOptions {
 		attributes = append(attributes, attrName)
-		optionLists = append(optionLists, options)
+	}
+	sort.Strings(attributes)
+	for _, attrName := range attributes {
+		optionLists = append(optionLists, variationOptions[attrName])
 	}
 
 	if len(attributes) == 0 {
@@ -843,22 +815,6 @@ func (p *Plugin) extractCategoriesFromClasses(s *goquery.Selection) []string {
 	return categories
 }
 
-func (p *Plugin) determineBrand(title string, categories []string) string {
-	titleLower := strings.ToLower(title)
-	knownBrands := []string{
-		"epbt", "gmk", "sa", "cherry", "gateron", "kailh",
-		"akko", "keychron", "drop", "wuque studio",
-	}
-
-	for _, brand := range knownBrands {
-		if strings.Contains(titleLower, brand) {
-			return strings.Title(brand)
-		}
-	}
-
-	return "StacksKB"
-}
-
 func (p *Plugin) generateTags(title string, categories []string) []string {
 	var tags []string
 	seenTags := make(map[string]bool)
@@ -956,11 +912,3 @@ func (p *Plugin) extractSourceID(doc *goquery.Document, productURL, title string
 	// Fallback to URL-based ID
 	return p.generateSourceID(productURL, title)
 }
-
-func (p *Plugin) countVariants(products []scraper.ScrapedProduct) int {
-	count := 0
-	for _, product := range products {
-		count += len(product.Variants)
-	}
-	return count
-}
@@ -0,0 +1,40 @@
+package stackskb
+
+import (
+	"context"
+	"testing"
+
+	"github.com/meta-boy/mech-alligator/internal/scraper"
+	"github.com/meta-boy/mech-alligator/internal/scraper/scrapertest"
+)
+
+func TestScrapeGolden(t *testing.T) {
+	tests := []struct {
+		name     string
+		url      string
+		category string
+	}{
+		{"listing", "https://stackskb.com/product-category/keyboards/", "KEYBOARD"},
+		{"variations_json", "https://stackskb.com/product/gmk-olivia-keycaps/", "KEYCAPS"},
+		{"variations_form", "https://stackskb.com/product/gateron-milky-yellow-switches/", "SWITCHES"},
+		{"simple_sale", "https://stackskb.com/product/keychron-q1-pro/", "KEYBOARD"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			plugin := NewStacksKBPlugin(scrapertest.NewFetcher(t, "testdata/fixtures"))
+
+			result, err := plugin.Scrape(context.Background(), &scraper.ScrapeRequest{
+				URL:        tt.url,
+				SourceType: "STACKS",
+				Reseller:   "StacksKB",
+				Category:   tt.category,
+			})
+			if err != nil {
+				t.Fatalf("Scrape() error = %v", err)
+			}
+
+			scrapertest.AssertGolden(t, "testdata/golden/"+tt.name+".json", result)
+		})
+	}
+}
@@ -0,0 +1,561 @@
+package woocommerce
+
+import (
+	"context"
+	"fmt"
+	"html"
+	"math"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/meta-boy/mech-alligator/internal/scraper"
+)
+
+const (
+	storeAPIPath   = "/wp-json/wc/store/v1"
+	defaultPerPage = 100
+	maxPerPage     = 100 // The Store API's per_page limit
+)
+
+type Plugin struct {
+	fetcher *scraper.Fetcher
+}
+
+func NewWooCommercePlugin(fetcher *scraper.Fetcher) *Plugin {
+	return &Plugin{fetcher: fetcher}
+}
+
+func (p *Plugin) Name() string {
+	return "woocommerce"
+}
+
+func (p *Plugin) SupportedTypes() []string {
+	return []string{"WOOCOMMERCE"}
+}
+
+func (p *Plugin) Options() []scraper.OptionSpec {
+	return []scraper.OptionSpec{
+		{Key: "api_base", Type: scraper.OptionURL,
+			Description: "Store API root for stores installed in a sub-directory; defaults to " + storeAPIPath + " on the request URL's host"},
+		{Key: "category", Type: scraper.OptionString,
+			Description: "Category slug to restrict the listing to; defaults to the slug of a /product-category/ request URL"},
+		{Key: "per_page", Type: scraper.OptionInt, Min: 1, Max: maxPerPage, Default: strconv.Itoa(defaultPerPage),
+			Description: "Products requested per page"},
+		{Key: "max_pages", Type: scraper.OptionInt, Default: "0",
+			Description: "Stop after this many pages; 0 means every page"},
+		{Key: "fetch_variations", Type: scraper.OptionBool, Default: "false",
+			Description: "Fetch the variations of each variable product, in one request per product, for their own price, SKU and stock"},
+	}
+}
+
+func (p *Plugin) ValidateRequest(req *scraper.ScrapeRequest) error {
+	if req.URL == "" {
+		return fmt.Errorf("URL is required")
+	}
+	if !strings.HasPrefix(req.URL, "http") {
+		return fmt.Errorf("URL must include protocol (http/https)")
+	}
+	if _, err := url.Parse(req.URL); err != nil {
+		return fmt.Errorf("invalid URL: %w", err)
+	}
+	if perPage, ok := req.Options["per_page"]; ok {
+		n, err := strconv.Atoi(perPage)
+		if err != nil || n < 1 || n > maxPerPage {
+			return fmt.Errorf("per_page must be a number between 1 and 100")
+		}
+	}
+	return nil
+}
+
+func (p *Plugin) Scrape(ctx context.Context, req *scraper.ScrapeRequest) (*scraper.ScrapeResult, error) {
+	return scraper.Collect(ctx, p, req)
+}
+
+// ScrapeStream emits each page of products as soon as it is converted
+func (p *Plugin) ScrapeStream(ctx context.Context, req *scraper.ScrapeRequest, emit scraper.Emitter) (scraper.ScrapeStats, error) {
+	return scraper.StreamPages(ctx, p, req, emit, scraper.DefaultConcurrency)
+}
+
+// ScrapePage converts one page of the Store API listing. The number of
+// pages comes from the X-WP-TotalPages header, so the rest can be fetched
+// in parallel once page 1 is in.
+func (p *Plugin) ScrapePage(ctx context.Context, req *scraper.ScrapeRequest, page int) (*scraper.Page, error) {
+	apiBase, err := p.buildAPIBase(req)
+	if err != nil {
+		return nil, err
+	}
+
+	perPage := defaultPerPage
+	if n, err := strconv.Atoi(req.Options["per_page"]); err == nil && n > 0 {
+		perPage = n
+	}
+
+	wooProducts, totalPages, err := p.fetchProductPage(ctx, apiBase, p.categoryFilter(req), page, perPage)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch products: %w", err)
+	}
+
+	result := &scraper.Page{TotalPages: totalPages}
+	for _, wp := range wooProducts {
+		product, productErrors := p.convertProduct(ctx, wp, apiBase, req)
+		if product != nil {
+			result.Products = append(result.Products, *product)
+		}
+		result.Errors = append(result.Errors, productErrors...)
+	}
+
+	return result, nil
+}
+
+// ScrapeProduct scrapes a single product by looking up the slug of its
+// permalink (/product/<slug>/) through the Store API.
+func (p *Plugin) ScrapeProduct(ctx context.Context, req *scraper.ScrapeRequest, productURL string) (*scraper.ScrapedProduct, error) {
+	apiBase, err := p.buildAPIBase(req)
+	if err != nil {
+		return nil, err
+	}
+
+	u, err := url.Parse(productURL)
+	if err != nil {
+		return nil, fmt.Errorf("invalid product URL: %w", err)
+	}
+	parts := strings.Split(strings.Trim(u.Path, "/"), "/")
+	slug := parts[len(parts)-1]
+	if slug == "" {
+		return nil, fmt.Errorf("no product slug in %s", productURL)
+	}
+
+	var products []Product
+	if _, err := p.getJSON(ctx, apiBase+"/products?slug="+url.QueryEscape(slug), &products); err != nil {
+		return nil, fmt.Errorf("failed to fetch product: %w", err)
+	}
+	if len(products) == 0 {
+		return nil, fmt.Errorf("no product with slug %q", slug)
+	}
+
+	product, errors := p.convertProduct(ctx, products[0], apiBase, req)
+	if product == nil {
+		return nil, fmt.Errorf("failed to convert product: %s", strings.Join(errors, "; "))
+	}
+
+	return product, nil
+}
+
+// ListCollections lists the store's product categories that have
+// products. Their URLs are /product-category/ pages, which the plugin
+// scrapes as a category filter.
+func (p *Plugin) ListCollections(ctx context.Context, siteURL string) ([]scraper.Collection, error) {
+	req := &scraper.ScrapeRequest{URL: siteURL}
+	apiBase, err := p.buildAPIBase(req)
+	if err != nil {
+		return nil, err
+	}
+	u, err := url.Parse(siteURL)
+	if err != nil {
+		return nil, fmt.Errorf("invalid URL: %w", err)
+	}
+
+	var categories []Category
+	if _, err := p.getJSON(ctx, apiBase+"/products/categories", &categories); err != nil {
+		return nil, fmt.Errorf("failed to fetch categories: %w", err)
+	}
+
+	var collections []scraper.Collection
+	for _, c := range categories {
+		if c.Count == 0 || c.Slug == "uncategorized" {
+			continue
+		}
+		categoryURL := c.Permalink
+		if categoryURL == "" {
+			categoryURL = u.Scheme + "://" + u.Host + "/product-category/" + c.Slug + "/"
+		}
+		collections = append(collections, scraper.Collection{
+			Name:         html.UnescapeString(c.Name),
+			Handle:       c.Slug,
+			URL:          categoryURL,
+			ProductCount: c.Count,
+		})
+	}
+
+	return collections, nil
+}
+
+// DescribeStore reads the store's currency from the prices of a product.
+// The Store API does not say which country the store is in.
+func (p *Plugin) DescribeStore(ctx context.Context, siteURL string) (*scraper.StoreInfo, error) {
+	apiBase, err := p.buildAPIBase(&scraper.ScrapeRequest{URL: siteURL})
+	if err != nil {
+		return nil, err
+	}
+
+	products, _, err := p.fetchProductPage(ctx, apiBase, "", 1, 1)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch products: %w", err)
+	}
+
+	info := &scraper.StoreInfo{}
+	if len(products) > 0 {
+		info.Currency = strings.ToUpper(products[0].Prices.CurrencyCode)
+	}
+	return info, nil
+}
+
+// buildAPIBase returns the Store API root for the site the request URL
+// belongs to. The api_base option overrides it for stores installed in a
+// sub-directory.
+func (p *Plugin) buildAPIBase(req *scraper.ScrapeRequest) (string, error) {
+	if apiBase := req.Options["api_base"]; apiBase != "" {
+		return strings.TrimSuffix(apiBase, "/"), nil
+	}
+
+	u, err := url.Parse(req.URL)
+	if err != nil {
+		return "", fmt.Errorf("invalid URL: %w", err)
+	}
+
+	return u.Scheme + "://" + u.Host + storeAPIPath, nil
+}
+
+// categoryFilter returns the category slug to restrict the listing to, taken
+// from the category option or a /product-category/<slug>/ URL.
+func (p *Plugin) categoryFilter(req *scraper.ScrapeRequest) string {
+	if category := req.Options["category"]; category != "" {
+		return category
+	}
+
+	u, err := url.Parse(req.URL)
+	if err != nil {
+		return ""
+	}
+
+	parts := strings.Split(strings.Trim(u.Path, "/"), "/")
+	slug := ""
+	for i := 0; i < len(parts); i++ {
+		if parts[i] != "product-category" {
+			continue
+		}
+		// Nested categories list the leaf slug last, before any /page/N/
+		for _, part := range parts[i+1:] {
+			if part == "page" {
+				break
+			}
+			slug = part
+		}
+		break
+	}
+
+	return slug
+}
+
+func (p *Plugin) fetchProductPage(ctx context.Context, apiBase, category string, page, perPage int) ([]Product, int, error) {
+	query := url.Values{}
+	query.Set("page", strconv.Itoa(page))
+	query.Set("per_page", strconv.Itoa(perPage))
+	if category != "" {
+		query.Set("category", category)
+	}
+
+	var products []Product
+	header, err := p.getJSON(ctx, apiBase+"/products?"+query.Encode(), &products)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	totalPages, _ := strconv.Atoi(header.Get("X-WP-TotalPages"))
+
+	return products, totalPages, nil
+}
+
+// fetchVariations fetches the given variations with as few requests as the
+// per_page limit allows, keyed by ID
+func (p *Plugin) fetchVariations(ctx context.Context, apiBase string, refs []VariationRef) (map[int64]Product, error) {
+	variations := make(map[int64]Product, len(refs))
+
+	for start := 0; start < len(refs); start += maxPerPage {
+		end := start + maxPerPage
+		if end > len(refs) {
+			end = len(refs)
+		}
+
+		ids := make([]string, 0, end-start)
+		for _, ref := range refs[start:end] {
+			ids = append(ids, strconv.FormatInt(ref.ID, 10))
+		}
+
+		query := url.Values{}
+		query.Set("type", "variation")
+		query.Set("include", strings.Join(ids, ","))
+		query.Set("per_page", strconv.Itoa(len(ids)))
+
+		var batch []Product
+		if _, err := p.getJSON(ctx, apiBase+"/products?"+query.Encode(), &batch); err != nil {
+			return nil, err
+		}
+		for _, variation := range batch {
+			variations[variation.ID] = variation
+		}
+	}
+
+	return variations, nil
+}
+
+func (p *Plugin) getJSON(ctx context.Context, url string, out interface{}) (http.Header, error) {
+	resp, err := p.fetcher.GetJSON(ctx, url, out)
+	if err != nil {
+		return nil, err
+	}
+
+	return resp.Header, nil
+}
+
+func (p *Plugin) convertProduct(ctx context.Context, wp Product, apiBase string, req *scraper.ScrapeRequest) (*scraper.ScrapedProduct, []string) {
+	var errors []string
+
+	var images []string
+	for _, img := range wp.Images {
+		images = append(images, img.Src)
+	}
+
+	var categories []string
+	for _, cat := range wp.Categories {
+		categories = append(categories, cat.Name)
+	}
+
+	var tags []string
+	for _, tag := range wp.Tags {
+		tags = append(tags, tag.Slug)
+	}
+
+	var variants []scraper.ScrapedVariant
+	if wp.Type == "variable" && len(wp.Variations) > 0 {
+		variants, errors = p.convertVariations(ctx, wp, apiBase, req)
+	} else {
+		variant, err := p.convertVariant(wp, wp.Permalink, nil)
+		if err != nil {
+			errors = append(errors, fmt.Sprintf("product %d: %s", wp.ID, err.Error()))
+		} else {
+			variant.Name = "Default"
+			variants = append(variants, variant)
+		}
+	}
+
+	// If no variants, skip this product
+	if len(variants) == 0 {
+		errors = append(errors, fmt.Sprintf("product %d has no valid variants", wp.ID))
+		return nil, errors
+	}
+
+	// Prices without a currency code are in the reseller's currency
+	for i := range variants {
+		if variants[i].Currency == "" {
+			variants[i].Currency = strings.ToUpper(req.Currency)
+		}
+	}
+
+	description := wp.Description
+	if description == "" {
+		description = wp.ShortDescription
+	}
+
+	product := &scraper.ScrapedProduct{
+		Name:        wp.Name,
+		Description: description,
+		Handle:      wp.Slug,
+		URL:         wp.Permalink,
+		Brand:       p.extractBrand(wp),
+		Category:    req.Category,
+		Tags:        tags,
+		Images:      images,
+		Variants:    variants,
+		SourceType:  "WOOCOMMERCE",
+		SourceID:    strconv.FormatInt(wp.ID, 10),
+		Metadata: map[string]string{
+			"woocommerce_type": wp.Type,
+			"woocommerce_slug": wp.Slug,
+			"categories":       strings.Join(categories, ","),
+			"on_sale":          strconv.FormatBool(wp.OnSale),
+		},
+	}
+
+	return product, errors
+}
+
+// convertVariations expands a variable product into one variant per
+// variation. The listing only carries variation IDs and attributes, so by
+// default every variation takes the parent's price and stock. Setting the
+// fetch_variations option to "true" fetches the product's variations in a
+// single request for their own price, SKU, stock and images.
+func (p *Plugin) convertVariations(ctx context.Context, wp Product, apiBase string, req *scraper.ScrapeRequest) ([]scraper.ScrapedVariant, []string) {
+	var variants []scraper.ScrapedVariant
+	var errors []string
+
+	fetchVariations := req.Options["fetch_variations"] == "true"
+
+	var fetched map[int64]Product
+	if fetchVariations {
+		var err error
+		fetched, err = p.fetchVariations(ctx, apiBase, wp.Variations)
+		if err != nil {
+			return nil, []string{fmt.Sprintf("variations: %v", err)}
+		}
+	}
+
+	for _, ref := range wp.Variations {
+		options := p.variationOptions(wp, ref)
+
+		source := wp
+		if fetchVariations {
+			variation, ok := fetched[ref.ID]
+			if !ok {
+				errors = append(errors, fmt.Sprintf("variation %d: not returned by the store", ref.ID))
+				continue
+			}
+			source = variation
+		}
+
+		variantURL := wp.Permalink
+		if source.Permalink != "" && source.ID != wp.ID {
+			variantURL = source.Permalink
+		}
+
+		variant, err := p.convertVariant(source, variantURL, options)
+		if err != nil {
+			errors = append(errors, fmt.Sprintf("variation %d: %s", ref.ID, err.Error()))
+			continue
+		}
+
+		variant.SourceID = strconv.FormatInt(ref.ID, 10)
+		variant.Name = p.variantName(options, wp)
+		if !fetchVariations {
+			// Without the variation itself only the parent's data is known
+			variant.SKU = ""
+			variant.Images = nil
+		}
+
+		variants = append(variants, variant)
+	}
+
+	return variants, errors
+}
+
+func (p *Plugin) convertVariant(wp Product, variantURL string, options map[string]string) (scraper.ScrapedVariant, error) {
+	price, err := p.parsePrice(wp.Prices.Price, wp.Prices.CurrencyMinorUnit)
+	if err != nil {
+		return scraper.ScrapedVariant{}, fmt.Errorf("invalid price: %s", wp.Prices.Price)
+	}
+
+	variant := scraper.ScrapedVariant{
+		SKU:       wp.SKU,
+		Price:     price,
+		Currency:  wp.Prices.CurrencyCode,
+		Available: wp.IsInStock || wp.IsOnBackorder,
+		URL:       variantURL,
+		Options:   options,
+		SourceID:  strconv.FormatInt(wp.ID, 10),
+	}
+
+	// Keep the pre-discount price when the item is on sale
+	if regular, err := p.parsePrice(wp.Prices.RegularPrice, wp.Prices.CurrencyMinorUnit); err == nil && regular > price {
+		variant.RegularPrice = regular
+	}
+
+	for _, img := range wp.Images {
+		variant.Images = append(variant.Images, img.Src)
+	}
+
+	return variant, nil
+}
+
+// variationOptions maps a variation's attributes to attribute name -> term
+// name. Taxonomy attributes reference terms by slug, so those are resolved
+// through the parent product's attribute terms.
+func (p *Plugin) variationOptions(wp Product, ref VariationRef) map[string]string {
+	options := make(map[string]string)
+
+	for _, attr := range ref.Attributes {
+		if attr.Value == "" {
+			options[attr.Name] = "Any"
+			continue
+		}
+
+		value := attr.Value
+		for _, productAttr := range wp.Attributes {
+			if !strings.EqualFold(productAttr.Name, attr.Name) {
+				continue
+			}
+			for _, term := range productAttr.Terms {
+				if term.Slug == attr.Value {
+					value = term.Name
+					break
+				}
+			}
+		}
+
+		options[attr.Name] = value
+	}
+
+	return options
+}
+
+// variantName joins option values in the order the product declares its
+// attributes so names are stable between scrapes.
+func (p *Plugin) variantName(options map[string]string, wp Product) string {
+	var parts []string
+	seen := make(map[string]bool)
+
+	for _, attr := range wp.Attributes {
+		if value, ok := options[attr.Name]; ok {
+			parts = append(parts, value)
+			seen[attr.Name] = true
+		}
+	}
+	var remaining []string
+	for name := range options {
+		if !seen[name] {
+			remaining = append(remaining, name)
+		}
+	}
+	sort.Strings(remaining)
+	for _, name := range remaining {
+		parts = append(parts, options[name])
+	}
+
+	if len(parts) == 0 {
+		return "Default"
+	}
+
+	return strings.Join(parts, " - ")
+}
+
+func (p *Plugin) parsePrice(amount string, minorUnit int) (float64, error) {
+	if amount == "" {
+		return 0, fmt.Errorf("empty price")
+	}
+
+	value, err := strconv.ParseInt(amount, 10, 64)
+	if err != nil {
+		return 0, err
+	}
+
+	return float64(value) / math.Pow10(minorUnit), nil
+}
+
+// extractBrand prefers the brands taxonomy (WooCommerce 9.6+ and most brand
+// plugins), then a "brand" or "manufacturer" attribute.
+func (p *Plugin) extractBrand(wp Product) string {
+	if len(wp.Brands) > 0 && wp.Brands[0].Name != "" {
+		return wp.Brands[0].Name
+	}
+
+	for _, attr := range wp.Attributes {
+		name := strings.ToLower(attr.Name)
+		if (strings.Contains(name, "brand") || strings.Contains(name, "manufacturer")) && len(attr.Terms) > 0 {
+			return attr.Terms[0].Name
+		}
+	}
+
+	return "Unknown"
+}
@@ -0,0 +1,59 @@
+package woocommerce
+
+import (
+	"context"
+	"testing"
+
+	"github.com/meta-boy/mech-alligator/internal/scraper"
+	"github.com/meta-boy/mech-alligator/internal/scraper/scrapertest"
+)
+
+func TestScrapeCategoryGolden(t *testing.T) {
+	plugin := NewWooCommercePlugin(scrapertest.NewFetcher(t, "testdata/fixtures"))
+
+	result, err := plugin.Scrape(context.Background(), &scraper.ScrapeRequest{
+		URL:        "https://woo.example.com/product-category/switches/",
+		SourceType: "WOOCOMMERCE",
+		Reseller:   "Woo Example",
+		Category:   "SWITCHES",
+		Options:    map[string]string{"per_page": "2", "fetch_variations": "true"},
+	})
+	if err != nil {
+		t.Fatalf("Scrape() error = %v", err)
+	}
+
+	scrapertest.AssertGolden(t, "testdata/golden/category.json", result)
+}
+
+// Without fetch_variations variations come from the listing alone, with
+// the parent's price and stock
+func TestScrapeCategoryListingOnlyGolden(t *testing.T) {
+	plugin := NewWooCommercePlugin(scrapertest.NewFetcher(t, "testdata/fixtures"))
+
+	result, err := plugin.Scrape(context.Background(), &scraper.ScrapeRequest{
+		URL:        "https://woo.example.com/product-category/switches/",
+		SourceType: "WOOCOMMERCE",
+		Reseller:   "Woo Example",
+		Category:   "SWITCHES",
+		Options:    map[string]string{"per_page": "2"},
+	})
+	if err != nil {
+		t.Fatalf("Scrape() error = %v", err)
+	}
+
+	scrapertest.AssertGolden(t, "testdata/golden/category_listing_only.json", result)
+}
+
+func TestDescribeStore(t *testing.T) {
+	plugin := NewWooCommercePlugin(scrapertest.NewFetcher(t, "testdata/fixtures"))
+
+	info, err := plugin.DescribeStore(context.Background(), "https://woo.example.com/product-category/switches/")
+	if err != nil {
+		t.Fatalf("DescribeStore() error = %v", err)
+	}
+
+	want := scraper.StoreInfo{Currency: "INR"}
+	if *info != want {
+		t.Errorf("DescribeStore() = %+v, want %+v", *info, want)
+	}
+}
@@ -0,0 +1,95 @@
+package woocommerce
+
+// Product represents a product from the WooCommerce Store API
+// (/wp-json/wc/store/v1/products). Variations fetched individually share the
+// same shape with Type set to "variation".
+type Product struct {
+	ID               int64          `json:"id"`
+	Name             string         `json:"name"`
+	Slug             string         `json:"slug"`
+	Parent           int64          `json:"parent"`
+	Type             string         `json:"type"`
+	Permalink        string         `json:"permalink"`
+	SKU              string         `json:"sku"`
+	ShortDescription string         `json:"short_description"`
+	Description      string         `json:"description"`
+	OnSale           bool           `json:"on_sale"`
+	Prices           Prices         `json:"prices"`
+	Images           []Image        `json:"images"`
+	Categories       []Term         `json:"categories"`
+	Tags             []Term         `json:"tags"`
+	Brands           []Term         `json:"brands"`
+	Attributes       []Attribute    `json:"attributes"`
+	Variations       []VariationRef `json:"variations"`
+	IsPurchasable    bool           `json:"is_purchasable"`
+	IsInStock        bool           `json:"is_in_stock"`
+	IsOnBackorder    bool           `json:"is_on_backorder"`
+}
+
+// Prices are returned as strings in the currency's minor unit, e.g. "199900"
+// with a minor unit of 2 means 1999.00.
+type Prices struct {
+	Price             string      `json:"price"`
+	RegularPrice      string      `json:"regular_price"`
+	SalePrice         string      `json:"sale_price"`
+	PriceRange        *PriceRange `json:"price_range"`
+	CurrencyCode      string      `json:"currency_code"`
+	CurrencyMinorUnit int         `json:"currency_minor_unit"`
+}
+
+type PriceRange struct {
+	MinAmount string `json:"min_amount"`
+	MaxAmount string `json:"max_amount"`
+}
+
+type Image struct {
+	ID        int64  `json:"id"`
+	Src       string `json:"src"`
+	Thumbnail string `json:"thumbnail"`
+	Name      string `json:"name"`
+	Alt       string `json:"alt"`
+}
+
+type Term struct {
+	ID   int64  `json:"id"`
+	Name string `json:"name"`
+	Slug string `json:"slug"`
+	Link string `json:"link"`
+}
+
+// Category is a product category from /products/categories
+type Category struct {
+	ID        int64  `json:"id"`
+	Name      string `json:"name"`
+	Slug      string `json:"slug"`
+	Parent    int64  `json:"parent"`
+	Count     int    `json:"count"`
+	Permalink string `json:"permalink"`
+}
+
+type Attribute struct {
+	ID            int64           `json:"id"`
+	Name          string          `json:"name"`
+	Taxonomy      string          `json:"taxonomy"`
+	HasVariations bool            `json:"has_variations"`
+	Terms         []AttributeTerm `json:"terms"`
+}
+
+type AttributeTerm struct {
+	ID   int64  `json:"id"`
+	Name string `json:"name"`
+	Slug string `json:"slug"`
+}
+
+type VariationRef struct {
+	ID         int64                `json:"id"`
+	Attributes []VariationAttribute `json:"attributes"`
+}
+
+// VariationAttribute holds the attribute label and the selected value. For
+// taxonomy attributes the value is the term slug; an empty value means the
+// variation applies to any value of that attribute.
+type VariationAttribute struct {
+	Name  string `json:"name"`
+	Value string `json:"value"`
+}
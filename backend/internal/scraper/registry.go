@@ -2,15 +2,23 @@ package scraper
 
 import (
 	"fmt"
+	"slices"
+	"sort"
 	"sync"
 )
 
 // Registry manages all registered plugins
 type Registry struct {
 	plugins map[string]Plugin
+	entries []registryEntry // Highest priority first, then in registration order
 	mu      sync.RWMutex
 }
 
+type registryEntry struct {
+	plugin   Plugin
+	priority int
+}
+
 // NewRegistry creates a new plugin registry
 func NewRegistry() *Registry {
 	return &Registry{
@@ -18,8 +26,15 @@ func NewRegistry() *Registry {
 	}
 }
 
-// Register adds a plugin to the registry
+// Register adds a plugin to the registry with priority 0
 func (r *Registry) Register(plugin Plugin) error {
+	return r.RegisterWithPriority(plugin, 0)
+}
+
+// RegisterWithPriority adds a plugin to the registry. When several plugins
+// support a source type, higher priorities are tried first and equal
+// priorities in the order they were registered.
+func (r *Registry) RegisterWithPriority(plugin Plugin, priority int) error {
 	r.mu.Lock()
 	defer r.mu.Unlock()
 
@@ -29,23 +44,38 @@ func (r *Registry) Register(plugin Plugin) error {
 	}
 
 	r.plugins[name] = plugin
+	r.entries = append(r.entries, registryEntry{plugin: plugin, priority: priority})
+	sort.SliceStable(r.entries, func(i, j int) bool {
+		return r.entries[i].priority > r.entries[j].priority
+	})
 	return nil
 }
 
-// GetPluginForType finds the first plugin that supports the given source type
+// GetPluginForType finds the highest priority plugin that supports the
+// given source type
 func (r *Registry) GetPluginForType(sourceType string) (Plugin, error) {
+	plugins := r.PluginsForType(sourceType)
+	if len(plugins) == 0 {
+		return nil, fmt.Errorf("no plugin found for source type %s", sourceType)
+	}
+
+	return plugins[0], nil
+}
+
+// PluginsForType returns every plugin that supports the given source type
+// in the order they should be tried
+func (r *Registry) PluginsForType(sourceType string) []Plugin {
 	r.mu.RLock()
 	defer r.mu.RUnlock()
 
-	for _, plugin := range r.plugins {
-		for _, supportedType := range plugin.SupportedTypes() {
-			if supportedType == sourceType {
-				return plugin, nil
-			}
+	var plugins []Plugin
+	for _, entry := range r.entries {
+		if slices.Contains(entry.plugin.SupportedTypes(), sourceType) {
+			plugins = append(plugins, entry.plugin)
 		}
 	}
 
-	return nil, fmt.Errorf("no plugin found for source type %s", sourceType)
+	return plugins
 }
 
 // GetPlugin returns a specific plugin by name
@@ -90,6 +120,7 @@ func (r *Registry) ListSupportedTypes() []string {
 	for sourceType := range typeSet {
 		types = append(types, sourceType)
 	}
+	sort.Strings(types)
 
 	return types
 }
@@ -0,0 +1,106 @@
+package scraper
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"testing"
+)
+
+// stubPlugin streams a fixed number of products and then returns err
+type stubPlugin struct {
+	name     string
+	types    []string
+	products int
+	err      error
+	invalid  error // Returned by ValidateRequest
+
+	calls int
+}
+
+func (p *stubPlugin) Name() string                             { return p.name }
+func (p *stubPlugin) SupportedTypes() []string                 { return p.types }
+func (p *stubPlugin) Options() []OptionSpec                    { return nil }
+func (p *stubPlugin) ValidateRequest(req *ScrapeRequest) error { return p.invalid }
+
+func (p *stubPlugin) Scrape(ctx context.Context, req *ScrapeRequest) (*ScrapeResult, error) {
+	return nil, fmt.Errorf("not streamed")
+}
+
+func (p *stubPlugin) ScrapeStream(ctx context.Context, req *ScrapeRequest, emit Emitter) (ScrapeStats, error) {
+	p.calls++
+	stats := ScrapeStats{PagesFetched: 1}
+	for i := 0; i < p.products; i++ {
+		if err := emit.Product(ScrapedProduct{Name: fmt.Sprintf("%s-%d", p.name, i)}); err != nil {
+			return stats, err
+		}
+	}
+	return stats, p.err
+}
+
+func pluginNames(plugins []Plugin) []string {
+	var names []string
+	for _, plugin := range plugins {
+		names = append(names, plugin.Name())
+	}
+	return names
+}
+
+func TestRegistryPriority(t *testing.T) {
+	r := NewRegistry()
+	for _, reg := range []struct {
+		name     string
+		types    []string
+		priority int
+	}{
+		{"a", []string{"X"}, 0},
+		{"b", []string{"X"}, 5},
+		{"c", []string{"X", "Y"}, 0},
+		{"d", []string{"Y"}, 5},
+		{"e", []string{"X"}, -1},
+		{"f", []string{"X"}, 5},
+	} {
+		if err := r.RegisterWithPriority(&stubPlugin{name: reg.name, types: reg.types}, reg.priority); err != nil {
+			t.Fatalf("RegisterWithPriority(%s) error = %v", reg.name, err)
+		}
+	}
+
+	tests := []struct {
+		sourceType string
+		want       []string
+	}{
+		// Higher priorities first, ties in registration order
+		{"X", []string{"b", "f", "a", "c", "e"}},
+		{"Y", []string{"d", "c"}},
+		{"Z", nil},
+	}
+
+	for _, tt := range tests {
+		if got := pluginNames(r.PluginsForType(tt.sourceType)); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("PluginsForType(%q) = %v, want %v", tt.sourceType, got, tt.want)
+		}
+	}
+
+	if plugin, err := r.GetPluginForType("X"); err != nil || plugin.Name() != "b" {
+		t.Errorf("GetPluginForType(\"X\") = %v, %v; want b", plugin, err)
+	}
+	if _, err := r.GetPluginForType("Z"); err == nil {
+		t.Error("GetPluginForType(\"Z\") succeeded, want an error")
+	}
+
+	// Names are unique whatever the priority
+	if err := r.RegisterWithPriority(&stubPlugin{name: "a", types: []string{"Z"}}, 10); err == nil {
+		t.Error("RegisterWithPriority() of a registered name succeeded, want an error")
+	}
+	if got := pluginNames(r.PluginsForType("Z")); got != nil {
+		t.Errorf("PluginsForType(\"Z\") after a refused registration = %v, want none", got)
+	}
+
+	// Register uses priority 0, after earlier plugins of the same priority
+	if err := r.Register(&stubPlugin{name: "g", types: []string{"X"}}); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := pluginNames(r.PluginsForType("X")), []string{"b", "f", "a", "c", "g", "e"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("PluginsForType(\"X\") after Register = %v, want %v", got, want)
+	}
+}
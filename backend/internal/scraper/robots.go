@@ -0,0 +1,324 @@
+package scraper
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	// robotsTTL is how long a fetched robots.txt is trusted
+	robotsTTL = 24 * time.Hour
+	// robotsErrorTTL is how long a failed robots.txt fetch blocks a host
+	// before it is retried
+	robotsErrorTTL = 10 * time.Minute
+	// maxRobotsSize is the most of a robots.txt that is parsed
+	maxRobotsSize = 500 << 10
+	// maxCrawlDelay caps Crawl-delay so one store cannot stall a scrape
+	maxCrawlDelay = time.Minute
+)
+
+// RobotsError is returned for URLs that robots.txt does not let us fetch
+type RobotsError struct {
+	URL string
+	Err error // Set when robots.txt itself could not be fetched
+}
+
+func (e *RobotsError) Error() string {
+	if e.Err != nil {
+		return fmt.Sprintf("skipped %s: robots.txt could not be fetched: %v", e.URL, e.Err)
+	}
+	return fmt.Sprintf("skipped %s: disallowed by robots.txt", e.URL)
+}
+
+func (e *RobotsError) Unwrap() error {
+	return e.Err
+}
+
+// robotsRules is a parsed robots.txt
+type robotsRules struct {
+	groups   []robotsGroup
+	sitemaps []string
+	err      error // Fetch failure; every URL is refused while set
+}
+
+type robotsGroup struct {
+	agents     []string
+	rules      []robotsRule
+	crawlDelay time.Duration
+}
+
+type robotsRule struct {
+	allow   bool
+	length  int // Length of the original pattern, longer rules win
+	pattern *regexp.Regexp
+}
+
+// parseRobots parses a robots.txt body. Consecutive User-agent lines share
+// the rules that follow them; Sitemap lines apply to the whole file.
+func parseRobots(body []byte) *robotsRules {
+	rules := &robotsRules{}
+	var current *robotsGroup
+	inAgents := false
+
+	scanner := bufio.NewScanner(bytes.NewReader(body))
+	for scanner.Scan() {
+		line := scanner.Text()
+		if i := strings.Index(line, "#"); i >= 0 {
+			line = line[:i]
+		}
+
+		key, value, found := strings.Cut(line, ":")
+		if !found {
+			continue
+		}
+		key = strings.ToLower(strings.TrimSpace(key))
+		value = strings.TrimSpace(value)
+
+		switch key {
+		case "user-agent":
+			if !inAgents {
+				rules.groups = append(rules.groups, robotsGroup{})
+				current = &rules.groups[len(rules.groups)-1]
+				inAgents = true
+			}
+			current.agents = append(current.agents, strings.ToLower(value))
+		case "allow", "disallow":
+			inAgents = false
+			// An empty Disallow allows everything, which is the default
+			if current == nil || value == "" {
+				continue
+			}
+			current.rules = append(current.rules, robotsRule{
+				allow:   key == "allow",
+				length:  len(value),
+				pattern: compileRobotsPattern(value),
+			})
+		case "crawl-delay":
+			inAgents = false
+			if current == nil {
+				continue
+			}
+			if seconds, err := strconv.ParseFloat(value, 64); err == nil && seconds > 0 {
+				current.crawlDelay = time.Duration(seconds * float64(time.Second))
+			}
+		case "sitemap":
+			if value != "" {
+				rules.sitemaps = append(rules.sitemaps, value)
+			}
+		}
+	}
+
+	return rules
+}
+
+// compileRobotsPattern turns a path pattern into a prefix match, where *
+// matches any run of characters and a trailing $ anchors the end
+func compileRobotsPattern(pattern string) *regexp.Regexp {
+	anchored := strings.HasSuffix(pattern, "$")
+	pattern = strings.TrimSuffix(pattern, "$")
+
+	parts := strings.Split(pattern, "*")
+	for i, part := range parts {
+		parts[i] = regexp.QuoteMeta(part)
+	}
+
+	expr := "^" + strings.Join(parts, ".*")
+	if anchored {
+		expr += "$"
+	}
+	return regexp.MustCompile(expr)
+}
+
+// group returns the rules for agent: the groups naming the longest token
+// that agent starts with, merged, or the * groups when none name it
+func (r *robotsRules) group(agent string) robotsGroup {
+	agent = strings.ToLower(agent)
+
+	var matched, wildcard robotsGroup
+	best := 0
+	for _, g := range r.groups {
+		named, wild := 0, false
+		for _, a := range g.agents {
+			if a == "*" {
+				wild = true
+			} else if a != "" && strings.HasPrefix(agent, a) && len(a) > named {
+				named = len(a)
+			}
+		}
+
+		switch {
+		case named > best:
+			best = named
+			matched = robotsGroup{}
+			matched.merge(g)
+		case named > 0 && named == best:
+			matched.merge(g)
+		case wild:
+			wildcard.merge(g)
+		}
+	}
+
+	if best > 0 {
+		return matched
+	}
+	return wildcard
+}
+
+// merge adds the rules of another group for the same agent
+func (g *robotsGroup) merge(other robotsGroup) {
+	g.rules = append(g.rules, other.rules...)
+	if other.crawlDelay > g.crawlDelay {
+		g.crawlDelay = other.crawlDelay
+	}
+}
+
+// allowed reports whether agent may fetch u. The longest matching rule
+// wins and Allow wins a tie.
+func (r *robotsRules) allowed(agent string, u *url.URL) bool {
+	if r.err != nil {
+		return false
+	}
+
+	path := u.EscapedPath()
+	if path == "" {
+		path = "/"
+	}
+	if path == "/robots.txt" {
+		return true
+	}
+	if u.RawQuery != "" {
+		path += "?" + u.RawQuery
+	}
+
+	allow, length := true, -1
+	for _, rule := range r.group(agent).rules {
+		if !rule.pattern.MatchString(path) {
+			continue
+		}
+		if rule.length > length || (rule.length == length && rule.allow) {
+			allow, length = rule.allow, rule.length
+		}
+	}
+	return allow
+}
+
+// crawlDelay returns the Crawl-delay asked of agent, capped at maxCrawlDelay
+func (r *robotsRules) crawlDelay(agent string) time.Duration {
+	delay := r.group(agent).crawlDelay
+	if delay > maxCrawlDelay {
+		return maxCrawlDelay
+	}
+	return delay
+}
+
+// robotsEntry is a cached robots.txt; ready is closed once rules is set
+type robotsEntry struct {
+	ready   chan struct{}
+	rules   *robotsRules
+	expires time.Time
+}
+
+// robotsFor returns the robots.txt rules for the origin of u, fetching them
+// at most once per robotsTTL. A missing robots.txt (4xx) allows everything;
+// one that cannot be fetched refuses everything until it is retried.
+func (f *Fetcher) robotsFor(ctx context.Context, u *url.URL) (*robotsRules, error) {
+	origin := strings.ToLower(u.Scheme + "://" + u.Host)
+
+	for {
+		f.mu.Lock()
+		entry, ok := f.robots[origin]
+		if ok {
+			select {
+			case <-entry.ready:
+				if time.Now().After(entry.expires) {
+					ok = false
+				}
+			default:
+				// Another request is fetching it
+			}
+		}
+		if !ok {
+			entry = &robotsEntry{ready: make(chan struct{})}
+			f.robots[origin] = entry
+			f.mu.Unlock()
+
+			f.loadRobots(ctx, origin, entry)
+		} else {
+			f.mu.Unlock()
+		}
+
+		select {
+		case <-entry.ready:
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+
+		if entry.rules != nil {
+			return entry.rules, nil
+		}
+		// Whoever was fetching it gave up; try again unless we have too
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+	}
+}
+
+func (f *Fetcher) loadRobots(ctx context.Context, origin string, entry *robotsEntry) {
+	defer close(entry.ready)
+
+	u, _ := url.Parse(origin + "/robots.txt")
+	// Sent with the scrape's headers but kept out of its counters, where a
+	// missing robots.txt would show up as a failed fetch
+	session := &fetchSession{headers: sessionFromContext(ctx).headers}
+	resp, err := f.get(ctx, u, "text/plain,*/*;q=0.8", nil, session)
+
+	var httpErr *HTTPError
+	switch {
+	case err == nil:
+		body := resp.Body
+		if len(body) > maxRobotsSize {
+			body = body[:maxRobotsSize]
+		}
+		entry.rules = parseRobots(body)
+		entry.expires = time.Now().Add(robotsTTL)
+	case errors.As(err, &httpErr) && httpErr.StatusCode >= 400 && httpErr.StatusCode < 500 && httpErr.StatusCode != http.StatusTooManyRequests:
+		entry.rules = &robotsRules{}
+		entry.expires = time.Now().Add(robotsTTL)
+	case ctx.Err() != nil:
+		// Leave rules unset and drop the entry so the next scrape refetches
+		f.mu.Lock()
+		if f.robots[origin] == entry {
+			delete(f.robots, origin)
+		}
+		f.mu.Unlock()
+		return
+	default:
+		entry.rules = &robotsRules{err: err}
+		entry.expires = time.Now().Add(robotsErrorTTL)
+	}
+
+	f.host(u.Host).setRobots(entry.rules)
+}
+
+// robotsAgent returns the product token matched against User-agent lines,
+// e.g. "productscraper" for "Mozilla/5.0 (compatible; ProductScraper/1.0)"
+func robotsAgent(userAgent string) string {
+	token := userAgent
+	if _, after, found := strings.Cut(userAgent, "compatible;"); found {
+		token = after
+	}
+	token = strings.TrimSpace(token)
+	if i := strings.IndexAny(token, "/ ;)"); i >= 0 {
+		token = token[:i]
+	}
+	return strings.ToLower(token)
+}
@@ -0,0 +1,235 @@
+package scraper
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"reflect"
+	"testing"
+	"time"
+)
+
+const testRobots = `# Shop robots.txt
+User-agent: *
+Disallow: /checkout
+Disallow: /search
+Allow: /search/keycaps
+Crawl-delay: 1
+
+User-agent: ProductScraper
+User-agent: OtherBot # Shares the group
+Disallow: /cart
+Crawl-delay: 2.5
+
+User-agent: product
+Disallow: /
+
+User-agent: productscraper
+Disallow: /*.json$
+Allow: /products/*.json$
+Crawl-delay: 4
+
+Sitemap: https://shop.example.com/sitemap.xml
+Sitemap: https://shop.example.com/sitemap_products.xml.gz
+`
+
+func TestParseRobots(t *testing.T) {
+	rules := parseRobots([]byte(testRobots))
+
+	wantSitemaps := []string{"https://shop.example.com/sitemap.xml", "https://shop.example.com/sitemap_products.xml.gz"}
+	if !reflect.DeepEqual(rules.sitemaps, wantSitemaps) {
+		t.Errorf("parseRobots() sitemaps = %v, want %v", rules.sitemaps, wantSitemaps)
+	}
+
+	var agents [][]string
+	for _, g := range rules.groups {
+		agents = append(agents, g.agents)
+	}
+	wantAgents := [][]string{{"*"}, {"productscraper", "otherbot"}, {"product"}, {"productscraper"}}
+	if !reflect.DeepEqual(agents, wantAgents) {
+		t.Errorf("parseRobots() agents = %v, want %v", agents, wantAgents)
+	}
+
+	// Rules before any User-agent and lines without a colon are ignored
+	rules = parseRobots([]byte("Disallow: /\nnonsense\nUser-agent: *\nDisallow:\n"))
+	if len(rules.groups) != 1 || len(rules.groups[0].rules) != 0 {
+		t.Errorf("parseRobots() groups = %+v, want one group without rules", rules.groups)
+	}
+}
+
+func TestRobotsGroup(t *testing.T) {
+	rules := parseRobots([]byte(testRobots))
+
+	tests := []struct {
+		agent      string
+		rules      int
+		crawlDelay time.Duration
+	}{
+		// Both productscraper groups merge; the shorter "product" loses
+		{"productscraper", 3, 4 * time.Second},
+		{"ProductScraper", 3, 4 * time.Second},
+		{"otherbot", 1, 2500 * time.Millisecond},
+		{"productbot", 1, 0},
+		{"googlebot", 3, time.Second},
+		{"", 3, time.Second},
+	}
+
+	for _, tt := range tests {
+		g := rules.group(tt.agent)
+		if len(g.rules) != tt.rules || g.crawlDelay != tt.crawlDelay {
+			t.Errorf("group(%q) = %d rules with Crawl-delay %v, want %d rules with %v",
+				tt.agent, len(g.rules), g.crawlDelay, tt.rules, tt.crawlDelay)
+		}
+	}
+}
+
+func TestRobotsAllowed(t *testing.T) {
+	rules := parseRobots([]byte(testRobots + `
+User-agent: patterns
+Disallow: /private
+Allow: /private/open
+Disallow: /*?sort=
+Disallow: /*.pdf$
+Allow: /p
+Disallow: /p
+Disallow: /collections/*/sale
+`))
+
+	tests := []struct {
+		agent string
+		path  string
+		want  bool
+	}{
+		{"googlebot", "/", true},
+		{"googlebot", "/checkout", false},
+		{"googlebot", "/checkout/step-2", false},
+		{"googlebot", "/search?q=gmk", false},
+		{"googlebot", "/search/keycaps?page=2", true}, // Longer Allow wins
+		{"googlebot", "/robots.txt", true},
+		{"googlebot", "/cart", true},
+		{"otherbot", "/cart", false},
+		{"otherbot", "/checkout", true}, // Named groups replace the * group
+		{"productscraper", "/collections.json", false},
+		{"productscraper", "/products/olivia.json", true},
+		{"productscraper", "/collections.json?page=2", true}, // $ anchors the end
+		{"productscraper", "/cart", false},
+		{"productbot", "/products", false},
+		{"patterns", "/private", false},
+		{"patterns", "/private/open/page", true},
+		{"patterns", "/privateer", false}, // Rules are prefixes
+		{"patterns", "/keycaps?sort=price", false},
+		{"patterns", "/keycaps?page=2&sort=price", true}, // "?sort=" does not follow the path
+		{"patterns", "/manual.pdf", false},
+		{"patterns", "/manual.pdf?download=1", true},
+		{"patterns", "/p", true}, // Allow wins a tie
+		{"patterns", "/collections/keycaps/sale", false},
+		{"patterns", "/collections/sale", true},
+	}
+
+	for _, tt := range tests {
+		u, _ := url.Parse("https://shop.example.com" + tt.path)
+		if got := rules.allowed(tt.agent, u); got != tt.want {
+			t.Errorf("allowed(%q, %q) = %v, want %v", tt.agent, tt.path, got, tt.want)
+		}
+	}
+
+	// robots.txt that failed to load refuses everything
+	failed := &robotsRules{err: errors.New("timeout")}
+	if u, _ := url.Parse("https://shop.example.com/"); failed.allowed("googlebot", u) {
+		t.Error("allowed() with a failed robots.txt = true, want false")
+	}
+}
+
+func TestRobotsCrawlDelay(t *testing.T) {
+	rules := parseRobots([]byte(`
+User-agent: *
+Crawl-delay: 0.5
+
+User-agent: slowbot
+Crawl-delay: 3600
+
+User-agent: badbot
+Crawl-delay: soon
+`))
+
+	tests := []struct {
+		agent string
+		want  time.Duration
+	}{
+		{"googlebot", 500 * time.Millisecond},
+		{"slowbot", maxCrawlDelay},
+		{"badbot", 0},
+	}
+
+	for _, tt := range tests {
+		if got := rules.crawlDelay(tt.agent); got != tt.want {
+			t.Errorf("crawlDelay(%q) = %v, want %v", tt.agent, got, tt.want)
+		}
+	}
+}
+
+func TestRobotsAgent(t *testing.T) {
+	tests := []struct {
+		userAgent string
+		want      string
+	}{
+		{DefaultUserAgent, "productscraper"},
+		{"Mozilla/5.0 (compatible; Googlebot/2.1; +http://www.google.com/bot.html)", "googlebot"},
+		{"ResellerBot/2.0", "resellerbot"},
+		{"curl", "curl"},
+		{"Mozilla/5.0 (X11; Linux x86_64)", "mozilla"},
+	}
+
+	for _, tt := range tests {
+		if got := robotsAgent(tt.userAgent); got != tt.want {
+			t.Errorf("robotsAgent(%q) = %q, want %q", tt.userAgent, got, tt.want)
+		}
+	}
+}
+
+func TestFetcherRobotsOverrides(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/robots.txt" {
+			fmt.Fprint(w, "User-agent: *\nDisallow: /private\n\nUser-agent: resellerbot\nAllow: /\n")
+			return
+		}
+		w.Header().Set("Content-Type", "text/html")
+		fmt.Fprint(w, "ok")
+	}))
+	defer srv.Close()
+
+	tests := []struct {
+		name    string
+		options map[string]string
+		allowed bool
+	}{
+		{"default agent", nil, false},
+		{"ignore_robots", map[string]string{"ignore_robots": "true"}, true},
+		{"ignore_robots off", map[string]string{"ignore_robots": "false"}, false},
+		{"allowed user_agent", map[string]string{"user_agent": "ResellerBot/2.0"}, true},
+		{"other user_agent", map[string]string{"user_agent": "OtherBot/1.0"}, false},
+	}
+
+	f := NewFetcher(FetcherConfig{RequestInterval: -1, MaxRetries: -1})
+	for _, tt := range tests {
+		ctx, session, err := withFetchSession(context.Background(), tt.options)
+		if err != nil {
+			t.Fatalf("withFetchSession() %s error = %v", tt.name, err)
+		}
+
+		_, err = f.GetHTML(ctx, srv.URL+"/private/page")
+		var robotsErr *RobotsError
+		if blocked := errors.As(err, &robotsErr); blocked == tt.allowed {
+			t.Errorf("GetHTML() %s error = %v, want allowed %v", tt.name, err, tt.allowed)
+		}
+
+		result := &ScrapeResult{}
+		session.apply(result)
+		if wantBlocked := !tt.allowed; (result.Stats.URLsBlocked == 1) != wantBlocked {
+			t.Errorf("GetHTML() %s blocked URLs = %v, want blocked %v", tt.name, result.BlockedURLs, wantBlocked)
+		}
+	}
+}
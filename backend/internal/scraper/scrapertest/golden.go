@@ -0,0 +1,97 @@
+package scrapertest
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/meta-boy/mech-alligator/internal/scraper"
+)
+
+var update = flag.Bool("update", false, "rewrite golden files with the current results")
+
+// maxDiffLines bounds how much of a mismatch is printed
+const maxDiffLines = 60
+
+// AssertGolden compares result, as indented JSON, with the golden file at
+// path. Values that change from run to run, such as the duration, are
+// cleared first.
+func AssertGolden(t testing.TB, path string, result *scraper.ScrapeResult) {
+	t.Helper()
+
+	normalized := *result
+	normalized.Stats.Duration = ""
+
+	got, err := json.MarshalIndent(normalized, "", "  ")
+	if err != nil {
+		t.Fatalf("failed to marshal result: %v", err)
+	}
+	got = append(got, '\n')
+
+	if *update {
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatalf("failed to create golden directory: %v", err)
+		}
+		if err := os.WriteFile(path, got, 0o644); err != nil {
+			t.Fatalf("failed to write golden file: %v", err)
+		}
+		return
+	}
+
+	want, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("failed to read golden file, create it with -update: %v", err)
+	}
+
+	if !bytes.Equal(got, want) {
+		t.Errorf("result differs from %s (-want +got):\n%s\nrun the test with -update if the change is intended",
+			path, diff(string(want), string(got)))
+	}
+}
+
+// diff returns a line diff of want and got, showing only changed lines
+func diff(want, got string) string {
+	a := strings.Split(want, "\n")
+	b := strings.Split(got, "\n")
+
+	// lcs[i][j] is the longest common subsequence of a[i:] and b[j:]
+	lcs := make([][]int, len(a)+1)
+	for i := range lcs {
+		lcs[i] = make([]int, len(b)+1)
+	}
+	for i := len(a) - 1; i >= 0; i-- {
+		for j := len(b) - 1; j >= 0; j-- {
+			if a[i] == b[j] {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else {
+				lcs[i][j] = max(lcs[i+1][j], lcs[i][j+1])
+			}
+		}
+	}
+
+	var out []string
+	i, j := 0, 0
+	for i < len(a) || j < len(b) {
+		switch {
+		case i < len(a) && j < len(b) && a[i] == b[j]:
+			i++
+			j++
+		case i < len(a) && (j == len(b) || lcs[i+1][j] >= lcs[i][j+1]):
+			out = append(out, fmt.Sprintf("-%4d %s", i+1, a[i]))
+			i++
+		default:
+			out = append(out, fmt.Sprintf("+%4d %s", j+1, b[j]))
+			j++
+		}
+	}
+
+	if len(out) > maxDiffLines {
+		out = append(out[:maxDiffLines], fmt.Sprintf("... %d more lines", len(out)-maxDiffLines))
+	}
+	return strings.Join(out, "\n")
+}
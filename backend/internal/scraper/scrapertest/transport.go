@@ -0,0 +1,162 @@
+// Package scrapertest runs scraper plugins offline against recorded HTTP
+// responses and compares their results with golden files.
+//
+// Fixtures are raw HTTP responses, one file per URL, named after the host,
+// path and query with unsafe characters replaced by "_", e.g.
+// "stackskb.com_product-category_keyboards_.http". They can be written by
+// hand or recorded from the live site by running the tests with
+// SCRAPER_RECORD=1. Golden files are rewritten with "go test -update".
+package scrapertest
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httputil"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/meta-boy/mech-alligator/internal/scraper"
+)
+
+// RecordEnv is the environment variable that switches to record mode
+const RecordEnv = "SCRAPER_RECORD"
+
+// maxFixtureName keeps fixture file names well within file system limits
+const maxFixtureName = 150
+
+var unsafeFixtureChars = regexp.MustCompile(`[^A-Za-z0-9._-]+`)
+
+// Transport is an http.RoundTripper that answers requests from fixture
+// files. In record mode it fetches from the network and saves each response
+// before returning it.
+type Transport struct {
+	t      testing.TB
+	dir    string
+	record bool
+	live   http.RoundTripper
+}
+
+// NewTransport creates a transport reading and recording fixtures in dir
+func NewTransport(t testing.TB, dir string) *Transport {
+	return &Transport{
+		t:      t,
+		dir:    dir,
+		record: os.Getenv(RecordEnv) != "",
+		live:   http.DefaultTransport,
+	}
+}
+
+// NewFetcher returns a fetcher backed by a Transport for dir. Replays skip
+// the politeness delays and retries; recordings keep them since they hit
+// the real site.
+func NewFetcher(t testing.TB, dir string) *scraper.Fetcher {
+	transport := NewTransport(t, dir)
+
+	config := scraper.FetcherConfig{Transport: transport}
+	if !transport.record {
+		config.RequestInterval = -1
+		config.MaxRetries = -1
+	}
+	return scraper.NewFetcher(config)
+}
+
+func (tr *Transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	path := filepath.Join(tr.dir, fixtureName(req.URL))
+	if tr.record {
+		return tr.recordResponse(req, path)
+	}
+
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		// Sites without robots.txt are common, anything else is a gap in the fixtures
+		if req.URL.Path != "/robots.txt" {
+			tr.t.Errorf("no fixture for %s at %s; record it with %s=1", req.URL, path, RecordEnv)
+		}
+		return notFound(req), nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := http.ReadResponse(bufio.NewReader(bytes.NewReader(data)), req)
+	if err != nil {
+		return nil, fmt.Errorf("invalid fixture %s: %w", path, err)
+	}
+	return resp, nil
+}
+
+// recordResponse fetches req from the network and saves the decoded
+// response, so fixtures stay readable and independent of compression
+func (tr *Transport) recordResponse(req *http.Request, path string) (*http.Response, error) {
+	resp, err := tr.live.RoundTrip(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	resp.Body = io.NopCloser(bytes.NewReader(body))
+	resp.ContentLength = int64(len(body))
+	resp.TransferEncoding = nil
+	resp.Uncompressed = false
+	resp.Header.Del("Content-Encoding")
+	resp.Header.Del("Transfer-Encoding")
+	resp.Header.Set("Content-Length", fmt.Sprint(len(body)))
+
+	dump, err := httputil.DumpResponse(resp, true)
+	if err != nil {
+		return nil, err
+	}
+	if err := os.MkdirAll(tr.dir, 0o755); err != nil {
+		return nil, err
+	}
+	if err := os.WriteFile(path, dump, 0o644); err != nil {
+		return nil, err
+	}
+	tr.t.Logf("recorded %s to %s", req.URL, path)
+
+	resp.Body = io.NopCloser(bytes.NewReader(body))
+	return resp, nil
+}
+
+// fixtureName maps a URL to its fixture file name
+func fixtureName(u *url.URL) string {
+	name := u.Host + u.EscapedPath()
+	if u.RawQuery != "" {
+		name += "?" + u.RawQuery
+	}
+	name = unsafeFixtureChars.ReplaceAllString(name, "_")
+
+	if len(name) > maxFixtureName {
+		sum := sha256.Sum256([]byte(u.String()))
+		name = name[:maxFixtureName-9] + "-" + hex.EncodeToString(sum[:])[:8]
+	}
+	return name + ".http"
+}
+
+func notFound(req *http.Request) *http.Response {
+	return &http.Response{
+		Status:        "404 Not Found",
+		StatusCode:    http.StatusNotFound,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        http.Header{"Content-Type": {"text/plain"}},
+		Body:          io.NopCloser(strings.NewReader("no fixture")),
+		ContentLength: int64(len("no fixture")),
+		Request:       req,
+	}
+}
@@ -0,0 +1,212 @@
+package scraper
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/url"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// defaultProductURLPattern matches the product URL layouts of Shopify
+// (/products/handle) and WooCommerce (/product/slug).
+var defaultProductURLPattern = regexp.MustCompile(`/products?/[^/?#]+`)
+
+// maxSitemapDepth bounds how many levels of nested sitemap indexes are followed
+const maxSitemapDepth = 3
+
+// SitemapEntry is a page URL listed in a sitemap
+type SitemapEntry struct {
+	URL     string    `json:"url"`
+	LastMod time.Time `json:"lastmod,omitempty"`
+}
+
+// SitemapDiscoverer finds product URLs through robots.txt and sitemaps
+type SitemapDiscoverer struct {
+	fetcher *Fetcher
+}
+
+// NewSitemapDiscoverer creates a new sitemap discoverer
+func NewSitemapDiscoverer(fetcher *Fetcher) *SitemapDiscoverer {
+	return &SitemapDiscoverer{fetcher: fetcher}
+}
+
+type sitemapIndex struct {
+	Sitemaps []sitemapLoc `xml:"sitemap"`
+}
+
+type urlSet struct {
+	URLs []sitemapLoc `xml:"url"`
+}
+
+type sitemapLoc struct {
+	Loc     string `xml:"loc"`
+	LastMod string `xml:"lastmod"`
+}
+
+// Discover returns every product URL listed in the site's sitemaps. Sitemaps
+// are located through the robots.txt Sitemap directives, falling back to
+// /sitemap.xml. Within a sitemap index only product sitemaps
+// (sitemap_products_1.xml, product-sitemap.xml, ...) are followed when the
+// index has any. URLs are filtered with pattern, or the default product URL
+// pattern when pattern is nil. Errors on individual sitemaps are returned
+// alongside whatever was found.
+func (d *SitemapDiscoverer) Discover(ctx context.Context, siteURL string, pattern *regexp.Regexp) ([]SitemapEntry, []string, error) {
+	u, err := url.Parse(siteURL)
+	if err != nil {
+		return nil, nil, fmt.Errorf("invalid URL: %w", err)
+	}
+	origin := u.Scheme + "://" + u.Host
+
+	if pattern == nil {
+		pattern = defaultProductURLPattern
+	}
+
+	var errors []string
+
+	sitemaps, err := d.sitemapsFromRobots(ctx, origin)
+	if err != nil {
+		errors = append(errors, fmt.Sprintf("robots.txt: %v", err))
+	}
+	if len(sitemaps) == 0 {
+		sitemaps = []string{origin + "/sitemap.xml"}
+	}
+
+	var entries []SitemapEntry
+	seen := make(map[string]bool)
+	visited := make(map[string]bool)
+
+	for _, sitemapURL := range sitemaps {
+		found, sitemapErrors := d.walk(ctx, sitemapURL, pattern, visited, 0)
+		errors = append(errors, sitemapErrors...)
+
+		for _, entry := range found {
+			if !seen[entry.URL] {
+				seen[entry.URL] = true
+				entries = append(entries, entry)
+			}
+		}
+	}
+
+	if len(entries) == 0 && len(errors) > 0 {
+		return nil, errors, fmt.Errorf("no product URLs found in sitemaps of %s", origin)
+	}
+
+	return entries, errors, nil
+}
+
+// sitemapsFromRobots returns the Sitemap directives of the cached robots.txt
+func (d *SitemapDiscoverer) sitemapsFromRobots(ctx context.Context, origin string) ([]string, error) {
+	u, err := url.Parse(origin)
+	if err != nil {
+		return nil, err
+	}
+
+	rules, err := d.fetcher.robotsFor(ctx, u)
+	if err != nil {
+		return nil, err
+	}
+	return rules.sitemaps, rules.err
+}
+
+func (d *SitemapDiscoverer) walk(ctx context.Context, sitemapURL string, pattern *regexp.Regexp, visited map[string]bool, depth int) ([]SitemapEntry, []string) {
+	if visited[sitemapURL] || depth > maxSitemapDepth {
+		return nil, nil
+	}
+	visited[sitemapURL] = true
+
+	if err := ctx.Err(); err != nil {
+		return nil, []string{fmt.Sprintf("%s: %v", sitemapURL, err)}
+	}
+
+	body, err := d.fetch(ctx, sitemapURL)
+	if err != nil {
+		return nil, []string{fmt.Sprintf("%s: %v", sitemapURL, err)}
+	}
+
+	// A sitemap index lists other sitemaps
+	var index sitemapIndex
+	if err := xml.Unmarshal(body, &index); err == nil && len(index.Sitemaps) > 0 {
+		var entries []SitemapEntry
+		var errors []string
+
+		for _, child := range selectProductSitemaps(index.Sitemaps) {
+			found, childErrors := d.walk(ctx, strings.TrimSpace(child.Loc), pattern, visited, depth+1)
+			entries = append(entries, found...)
+			errors = append(errors, childErrors...)
+		}
+
+		return entries, errors
+	}
+
+	var set urlSet
+	if err := xml.Unmarshal(body, &set); err != nil {
+		return nil, []string{fmt.Sprintf("%s: failed to parse sitemap: %v", sitemapURL, err)}
+	}
+
+	var entries []SitemapEntry
+	for _, loc := range set.URLs {
+		pageURL := strings.TrimSpace(loc.Loc)
+		if pageURL == "" || !pattern.MatchString(pageURL) {
+			continue
+		}
+		entries = append(entries, SitemapEntry{
+			URL:     pageURL,
+			LastMod: parseLastMod(loc.LastMod),
+		})
+	}
+
+	return entries, nil
+}
+
+// selectProductSitemaps keeps the product sitemaps of an index. Indexes
+// without recognisable product sitemaps are followed in full.
+func selectProductSitemaps(sitemaps []sitemapLoc) []sitemapLoc {
+	var products []sitemapLoc
+	for _, s := range sitemaps {
+		if strings.Contains(strings.ToLower(s.Loc), "product") {
+			products = append(products, s)
+		}
+	}
+
+	if len(products) == 0 {
+		return sitemaps
+	}
+	return products
+}
+
+func (d *SitemapDiscoverer) fetch(ctx context.Context, rawURL string) ([]byte, error) {
+	// Sitemaps come as XML, plain text or gzip, so any content type is accepted
+	resp, err := d.fetcher.Get(ctx, rawURL, AcceptXML)
+	if err != nil {
+		return nil, err
+	}
+	body := resp.Body
+
+	// Some stores serve compressed .xml.gz sitemaps
+	if len(body) > 2 && body[0] == 0x1f && body[1] == 0x8b {
+		reader, err := gzip.NewReader(bytes.NewReader(body))
+		if err != nil {
+			return nil, err
+		}
+		defer reader.Close()
+		return io.ReadAll(io.LimitReader(reader, d.fetcher.config.MaxResponseSize))
+	}
+
+	return body, nil
+}
+
+func parseLastMod(value string) time.Time {
+	value = strings.TrimSpace(value)
+	for _, layout := range []string{time.RFC3339, "2006-01-02T15:04:05Z0700", "2006-01-02T15:04Z07:00", "2006-01-02"} {
+		if t, err := time.Parse(layout, value); err == nil {
+			return t
+		}
+	}
+	return time.Time{}
+}
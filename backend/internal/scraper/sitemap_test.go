@@ -0,0 +1,315 @@
+package scraper
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"regexp"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestParseLastMod(t *testing.T) {
+	tests := []struct {
+		value string
+		want  time.Time
+	}{
+		{"2024-03-01T10:00:00+05:30", time.Date(2024, 3, 1, 4, 30, 0, 0, time.UTC)},
+		{"2024-03-01T10:00:00Z", time.Date(2024, 3, 1, 10, 0, 0, 0, time.UTC)},
+		{"2024-03-01T10:00:00.250Z", time.Date(2024, 3, 1, 10, 0, 0, 250e6, time.UTC)},
+		{"2024-03-01T10:00:00+0530", time.Date(2024, 3, 1, 4, 30, 0, 0, time.UTC)},
+		{"2024-03-01T10:00+05:30", time.Date(2024, 3, 1, 4, 30, 0, 0, time.UTC)},
+		{"2024-03-01", time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC)},
+		{"  2024-03-01\n", time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC)},
+		{"", time.Time{}},
+		{"March 1, 2024", time.Time{}},
+		{"2024-03", time.Time{}},
+	}
+
+	for _, tt := range tests {
+		if got := parseLastMod(tt.value); !got.Equal(tt.want) {
+			t.Errorf("parseLastMod(%q) = %v, want %v", tt.value, got, tt.want)
+		}
+	}
+}
+
+func TestSelectProductSitemaps(t *testing.T) {
+	locs := func(urls ...string) []sitemapLoc {
+		var locs []sitemapLoc
+		for _, u := range urls {
+			locs = append(locs, sitemapLoc{Loc: u})
+		}
+		return locs
+	}
+
+	tests := []struct {
+		name     string
+		sitemaps []sitemapLoc
+		want     []sitemapLoc
+	}{
+		{"shopify",
+			locs("/sitemap_products_1.xml", "/sitemap_pages_1.xml", "/sitemap_products_2.xml", "/sitemap_blogs_1.xml"),
+			locs("/sitemap_products_1.xml", "/sitemap_products_2.xml")},
+		{"woocommerce", locs("/post-sitemap.xml", "/Product-Sitemap.xml"), locs("/Product-Sitemap.xml")},
+		{"no product sitemaps", locs("/sitemap-1.xml", "/sitemap-2.xml"), locs("/sitemap-1.xml", "/sitemap-2.xml")},
+		{"empty", nil, nil},
+	}
+
+	for _, tt := range tests {
+		if got := selectProductSitemaps(tt.sitemaps); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("selectProductSitemaps() %s = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+// sitemapServer serves a Shopify-like sitemap tree:
+//
+//	sitemap_index.xml
+//	├── sitemap_products_1.xml.gz    /products/a, /products/b, /pages/about
+//	├── sitemap_pages_1.xml          skipped, not a product sitemap
+//	└── sitemap_products_nested.xml
+//	    ├── sitemap_products_2.xml   /products/c
+//	    └── sitemap_products_deep.xml
+//	        └── sitemap_products_deeper.xml
+//	            └── sitemap_products_deepest.xml  past maxSitemapDepth
+//
+// It records the paths requested.
+func sitemapServer(t *testing.T) (*httptest.Server, func() []string) {
+	t.Helper()
+
+	var mu sync.Mutex
+	var requested []string
+
+	var srv *httptest.Server
+	srv = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		requested = append(requested, r.URL.Path)
+		mu.Unlock()
+
+		index := func(paths ...string) string {
+			var b strings.Builder
+			b.WriteString(`<?xml version="1.0" encoding="UTF-8"?><sitemapindex xmlns="http://www.sitemaps.org/schemas/sitemap/0.9">`)
+			for _, path := range paths {
+				fmt.Fprintf(&b, "<sitemap><loc>%s%s</loc></sitemap>", srv.URL, path)
+			}
+			b.WriteString("</sitemapindex>")
+			return b.String()
+		}
+		urlset := func(urls ...[2]string) string {
+			var b strings.Builder
+			b.WriteString(`<?xml version="1.0" encoding="UTF-8"?><urlset xmlns="http://www.sitemaps.org/schemas/sitemap/0.9">`)
+			for _, u := range urls {
+				fmt.Fprintf(&b, "<url><loc>\n  %s%s\n</loc><lastmod>%s</lastmod></url>", srv.URL, u[0], u[1])
+			}
+			b.WriteString("</urlset>")
+			return b.String()
+		}
+
+		w.Header().Set("Content-Type", "application/xml")
+		switch r.URL.Path {
+		case "/robots.txt":
+			w.Header().Set("Content-Type", "text/plain")
+			fmt.Fprintf(w, "User-agent: *\nDisallow: /checkout\nSitemap: %s/sitemap_index.xml\n", srv.URL)
+		case "/sitemap_index.xml":
+			fmt.Fprint(w, index("/sitemap_products_1.xml.gz", "/sitemap_pages_1.xml", "/sitemap_products_nested.xml"))
+		case "/sitemap_products_1.xml.gz":
+			var buf bytes.Buffer
+			gz := gzip.NewWriter(&buf)
+			fmt.Fprint(gz, urlset(
+				[2]string{"/products/a", "2024-01-01"},
+				[2]string{"/products/b", "2024-03-01T10:00:00+05:30"},
+				[2]string{"/pages/about", "2024-03-01"}))
+			gz.Close()
+			w.Header().Set("Content-Type", "application/x-gzip")
+			w.Write(buf.Bytes())
+		case "/sitemap_pages_1.xml":
+			fmt.Fprint(w, urlset([2]string{"/pages/contact", ""}))
+		case "/sitemap_products_nested.xml":
+			fmt.Fprint(w, index("/sitemap_products_2.xml", "/sitemap_products_deep.xml"))
+		case "/sitemap_products_2.xml":
+			fmt.Fprint(w, urlset([2]string{"/products/c", ""}, [2]string{"/products/a", "2024-01-01"}))
+		case "/sitemap_products_deep.xml":
+			fmt.Fprint(w, index("/sitemap_products_deeper.xml"))
+		case "/sitemap_products_deeper.xml":
+			fmt.Fprint(w, index("/sitemap_products_deepest.xml"))
+		case "/sitemap_products_deepest.xml":
+			fmt.Fprint(w, urlset([2]string{"/products/too-deep", ""}))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	t.Cleanup(srv.Close)
+
+	return srv, func() []string {
+		mu.Lock()
+		defer mu.Unlock()
+		return append([]string(nil), requested...)
+	}
+}
+
+// newTestFetcher returns a fetcher without politeness delays or retries
+func newTestFetcher() *Fetcher {
+	return NewFetcher(FetcherConfig{RequestInterval: -1, MaxRetries: -1})
+}
+
+func TestSitemapDiscover(t *testing.T) {
+	srv, requested := sitemapServer(t)
+	d := NewSitemapDiscoverer(newTestFetcher())
+
+	entries, errors, err := d.Discover(context.Background(), srv.URL+"/collections/all", nil)
+	if err != nil {
+		t.Fatalf("Discover() error = %v", err)
+	}
+	if len(errors) > 0 {
+		t.Errorf("Discover() errors = %v", errors)
+	}
+
+	want := []SitemapEntry{
+		{URL: srv.URL + "/products/a", LastMod: time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)},
+		{URL: srv.URL + "/products/b", LastMod: time.Date(2024, 3, 1, 4, 30, 0, 0, time.UTC)},
+		{URL: srv.URL + "/products/c"},
+	}
+	if len(entries) != len(want) {
+		t.Fatalf("Discover() = %v, want %v", entries, want)
+	}
+	for i := range want {
+		if entries[i].URL != want[i].URL || !entries[i].LastMod.Equal(want[i].LastMod) {
+			t.Errorf("Discover()[%d] = %v, want %v", i, entries[i], want[i])
+		}
+	}
+
+	followed := make(map[string]bool)
+	for _, path := range requested() {
+		followed[path] = true
+		switch path {
+		case "/sitemap_pages_1.xml":
+			t.Errorf("Discover() followed %s, which is not a product sitemap", path)
+		case "/sitemap_products_deepest.xml":
+			t.Errorf("Discover() followed %s, past the depth limit", path)
+		}
+	}
+	if !followed["/sitemap_products_deeper.xml"] {
+		t.Errorf("Discover() stopped before the depth limit, requested %v", requested())
+	}
+
+	// A pattern replaces the default product URL pattern
+	entries, _, err = d.Discover(context.Background(), srv.URL, regexp.MustCompile(`/products/[bc]$`))
+	if err != nil {
+		t.Fatalf("Discover() with a pattern error = %v", err)
+	}
+	var urls []string
+	for _, entry := range entries {
+		urls = append(urls, entry.URL)
+	}
+	if want := []string{srv.URL + "/products/b", srv.URL + "/products/c"}; !reflect.DeepEqual(urls, want) {
+		t.Errorf("Discover() with a pattern = %v, want %v", urls, want)
+	}
+}
+
+func TestSitemapDiscoverWithoutRobots(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/sitemap.xml" {
+			http.NotFound(w, r)
+			return
+		}
+		fmt.Fprint(w, `<urlset><url><loc>https://shop.example.com/product/widget/</loc></url></urlset>`)
+	}))
+	defer srv.Close()
+
+	entries, _, err := NewSitemapDiscoverer(newTestFetcher()).Discover(context.Background(), srv.URL, nil)
+	if err != nil {
+		t.Fatalf("Discover() error = %v", err)
+	}
+	if len(entries) != 1 || entries[0].URL != "https://shop.example.com/product/widget/" {
+		t.Errorf("Discover() = %v, want the /sitemap.xml entry", entries)
+	}
+}
+
+// detailPlugin scrapes product pages into products named after their URL
+type detailPlugin struct{}
+
+func (detailPlugin) Name() string             { return "detail" }
+func (detailPlugin) SupportedTypes() []string { return []string{"DETAIL"} }
+func (detailPlugin) Options() []OptionSpec    { return nil }
+
+func (detailPlugin) ValidateRequest(req *ScrapeRequest) error { return nil }
+
+func (detailPlugin) Scrape(ctx context.Context, req *ScrapeRequest) (*ScrapeResult, error) {
+	return nil, fmt.Errorf("listing not supported")
+}
+
+func (detailPlugin) ScrapeProduct(ctx context.Context, req *ScrapeRequest, productURL string) (*ScrapedProduct, error) {
+	return &ScrapedProduct{Name: productURL, URL: productURL}, nil
+}
+
+func TestScrapeFromSitemap(t *testing.T) {
+	srv, _ := sitemapServer(t)
+
+	tests := []struct {
+		name          string
+		options       map[string]string
+		want          []string
+		wantUnchanged int
+	}{
+		{"every URL", nil, []string{"/products/a", "/products/b", "/products/c"}, 0},
+		{"modified since", map[string]string{"modified_since": "2024-02-01T00:00:00Z"}, []string{"/products/b", "/products/c"}, 1},
+		{"max URLs", map[string]string{"max_urls": "2"}, []string{"/products/a", "/products/b"}, 0},
+		{"modified since and max URLs", map[string]string{"modified_since": "2024-02-01T00:00:00Z", "max_urls": "1"}, []string{"/products/b"}, 1},
+		{"pattern", map[string]string{"sitemap_pattern": "/products/c"}, []string{"/products/c"}, 0},
+	}
+
+	for _, tt := range tests {
+		m := NewManager(newTestFetcher())
+		c := &collector{}
+
+		stats, err := m.scrapeFromSitemap(context.Background(), detailPlugin{}, &ScrapeRequest{URL: srv.URL, Options: tt.options}, c)
+		if err != nil {
+			t.Fatalf("scrapeFromSitemap() %s error = %v", tt.name, err)
+		}
+
+		var got []string
+		for _, product := range c.products {
+			got = append(got, strings.TrimPrefix(product.URL, srv.URL))
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("scrapeFromSitemap() %s scraped %v, want %v", tt.name, got, tt.want)
+		}
+		if stats.URLsUnchanged != tt.wantUnchanged {
+			t.Errorf("scrapeFromSitemap() %s URLsUnchanged = %d, want %d", tt.name, stats.URLsUnchanged, tt.wantUnchanged)
+		}
+		if wantFound := len(tt.want) + tt.wantUnchanged; tt.options["max_urls"] == "" && stats.URLsFound != wantFound {
+			t.Errorf("scrapeFromSitemap() %s URLsFound = %d, want %d", tt.name, stats.URLsFound, wantFound)
+		}
+
+		// The lastmod is recorded on products that had one
+		for _, product := range c.products {
+			lastMod, ok := product.Metadata["sitemap_lastmod"]
+			if hasLastMod := !strings.HasSuffix(product.URL, "/products/c"); ok != hasLastMod {
+				t.Errorf("scrapeFromSitemap() %s %s sitemap_lastmod = %q", tt.name, product.URL, lastMod)
+			}
+		}
+	}
+
+	// Plugins that cannot scrape a single product page are refused
+	m := NewManager(newTestFetcher())
+	if _, err := m.scrapeFromSitemap(context.Background(), listingOnlyPlugin{}, &ScrapeRequest{URL: srv.URL}, &collector{}); err == nil {
+		t.Error("scrapeFromSitemap() with a plugin without ScrapeProduct succeeded, want an error")
+	}
+}
+
+// listingOnlyPlugin has no product page extractor
+type listingOnlyPlugin struct{}
+
+func (listingOnlyPlugin) Name() string                             { return "listing" }
+func (listingOnlyPlugin) SupportedTypes() []string                 { return []string{"LISTING"} }
+func (listingOnlyPlugin) Options() []OptionSpec                    { return nil }
+func (listingOnlyPlugin) ValidateRequest(req *ScrapeRequest) error { return nil }
+func (listingOnlyPlugin) Scrape(ctx context.Context, req *ScrapeRequest) (*ScrapeResult, error) {
+	return &ScrapeResult{}, nil
+}
@@ -0,0 +1,145 @@
+package scraper
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// Emitter receives the output of a streaming scrape as it is produced
+type Emitter interface {
+	// Product hands a product to the consumer, blocking while the consumer
+	// is busy. An error means the consumer has stopped and the plugin
+	// should return it.
+	Product(product ScrapedProduct) error
+	// Error reports a problem that did not stop the scrape
+	Error(message string)
+}
+
+// StreamPlugin is implemented by plugins that emit products as each page
+// is scraped rather than returning the whole catalogue at the end
+type StreamPlugin interface {
+	Plugin
+	// ScrapeStream returns the stats only the plugin knows, such as pages
+	// fetched; product, variant and error counts are filled in by the caller
+	ScrapeStream(ctx context.Context, req *ScrapeRequest, emit Emitter) (ScrapeStats, error)
+}
+
+// Collect runs a streaming scrape and gathers its output into a
+// ScrapeResult. Streaming plugins implement Scrape with it.
+func Collect(ctx context.Context, plugin StreamPlugin, req *ScrapeRequest) (*ScrapeResult, error) {
+	start := time.Now()
+
+	c := &collector{}
+	stats, err := plugin.ScrapeStream(ctx, req, c)
+	if err != nil {
+		return nil, err
+	}
+
+	result := c.result()
+	result.Stats.PagesFetched = stats.PagesFetched
+	result.Stats.URLsFound = stats.URLsFound
+	result.Stats.URLsUnchanged = stats.URLsUnchanged
+	result.Stats.Duration = time.Since(start).String()
+	result.Stats.Source = req.Reseller
+	return result, nil
+}
+
+// collector is an Emitter that keeps everything in memory
+type collector struct {
+	mu       sync.Mutex
+	products []ScrapedProduct
+	errors   []string
+}
+
+func (c *collector) Product(product ScrapedProduct) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.products = append(c.products, product)
+	return nil
+}
+
+func (c *collector) Error(message string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.errors = append(c.errors, message)
+}
+
+func (c *collector) result() *ScrapeResult {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	variants := 0
+	for _, product := range c.products {
+		variants += len(product.Variants)
+	}
+
+	return &ScrapeResult{
+		Products: c.products,
+		Errors:   c.errors,
+		Stats: ScrapeStats{
+			ProductsFound: len(c.products),
+			VariantsFound: variants,
+			ErrorCount:    len(c.errors),
+		},
+	}
+}
+
+// ChannelEmitter sends products on a channel, so a bounded channel holds
+// the scrape back while its consumer catches up. Errors are not sent; they
+// are reported in the scrape result.
+type ChannelEmitter struct {
+	ctx      context.Context
+	products chan<- ScrapedProduct
+}
+
+// NewChannelEmitter creates an emitter that stops blocking once ctx is done
+func NewChannelEmitter(ctx context.Context, products chan<- ScrapedProduct) *ChannelEmitter {
+	return &ChannelEmitter{ctx: ctx, products: products}
+}
+
+func (e *ChannelEmitter) Product(product ScrapedProduct) error {
+	select {
+	case e.products <- product:
+		return nil
+	case <-e.ctx.Done():
+		return e.ctx.Err()
+	}
+}
+
+func (e *ChannelEmitter) Error(message string) {}
+
+// trackingEmitter forwards to the consumer while counting what passes
+// through and keeping the errors for the scrape result
+type trackingEmitter struct {
+	next Emitter
+
+	mu       sync.Mutex
+	products int
+	variants int
+	errors   []string
+}
+
+func (t *trackingEmitter) Product(product ScrapedProduct) error {
+	if err := t.next.Product(product); err != nil {
+		return err
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.products++
+	t.variants += len(product.Variants)
+	return nil
+}
+
+func (t *trackingEmitter) Error(message string) {
+	t.next.Error(message)
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.errors = append(t.errors, message)
+}
@@ -19,44 +19,122 @@ type ScrapedProduct struct {
 }
 
 type ScrapedVariant struct {
-	Name      string            `json:"name,omitempty"`
-	SKU       string            `json:"sku,omitempty"`
-	Price     float64           `json:"price"`
-	Currency  string            `json:"currency"`
-	Available bool              `json:"available"`
-	URL       string            `json:"url,omitempty"`
-	Images    []string          `json:"images,omitempty"`
-	Options   map[string]string `json:"options,omitempty"`
-	SourceID  string            `json:"source_id"`
+	Name         string            `json:"name,omitempty"`
+	SKU          string            `json:"sku,omitempty"`
+	GTIN         string            `json:"gtin,omitempty"`          // EAN/UPC barcode when the source exposes one
+	Price        float64           `json:"price"`                   // Current selling price
+	RegularPrice float64           `json:"regular_price,omitempty"` // Pre-discount price, set only when on sale
+	Currency     string            `json:"currency"`
+	Available    bool              `json:"available"`
+	URL          string            `json:"url,omitempty"`
+	Images       []string          `json:"images,omitempty"`
+	Options      map[string]string `json:"options,omitempty"`
+	SourceID     string            `json:"source_id"`
 }
 
 type ScrapeRequest struct {
 	URL        string            `json:"url"`
-	SourceType string            `json:"source_type"`       // SHOPIFY, WORDPRESS, etc.
+	SourceType string            `json:"source_type"`       // SHOPIFY, WOOCOMMERCE, etc.
 	Reseller   string            `json:"reseller"`          // Name of the reseller website
 	ResellerID string            `json:"reseller_id"`       // Config ID for the reseller
 	Category   string            `json:"category"`          // Category to assign to scraped products
+	Currency   string            `json:"currency"`          // Reseller's currency, for prices the store does not label
 	Options    map[string]string `json:"options,omitempty"` // Plugin-specific options
 }
 
 type ScrapeResult struct {
-	Products []ScrapedProduct `json:"products"`
-	Errors   []string         `json:"errors,omitempty"`
-	Stats    ScrapeStats      `json:"stats"`
+	Products    []ScrapedProduct `json:"products"`
+	Errors      []string         `json:"errors,omitempty"`
+	BlockedURLs []string         `json:"blocked_urls,omitempty"` // URLs skipped because robots.txt disallows them
+	Plugin      string           `json:"plugin,omitempty"`       // Plugin that produced the products
+	Attempts    []PluginAttempt  `json:"attempts,omitempty"`     // Every plugin run, when a chain fell back
+	Stats       ScrapeStats      `json:"stats"`
+}
+
+// PluginAttempt records one plugin's run in a fallback chain
+type PluginAttempt struct {
+	Plugin   string `json:"plugin"`
+	Products int    `json:"products"`
+	Error    string `json:"error,omitempty"`
 }
 
 type ScrapeStats struct {
-	ProductsFound int    `json:"products_found"`
-	VariantsFound int    `json:"variants_found"`
-	ErrorCount    int    `json:"error_count"`
-	Duration      string `json:"duration"`
-	Source        string `json:"source"`
+	ProductsFound  int    `json:"products_found"`
+	VariantsFound  int    `json:"variants_found"`
+	ErrorCount     int    `json:"error_count"`
+	PagesFetched   int    `json:"pages_fetched,omitempty"`
+	URLsFound      int    `json:"urls_found,omitempty"`     // Product URLs found by sitemap discovery
+	URLsUnchanged  int    `json:"urls_unchanged,omitempty"` // Discovered URLs skipped because lastmod predates modified_since
+	URLsBlocked    int    `json:"urls_blocked,omitempty"`   // URLs skipped because of robots.txt
+	HTTPRequests   int    `json:"http_requests,omitempty"`  // Requests sent, retries included
+	HTTPRetries    int    `json:"http_retries,omitempty"`
+	HTTPFailures   int    `json:"http_failures,omitempty"` // Fetches that failed after all retries
+	BytesFetched   int64  `json:"bytes_fetched,omitempty"`
+	CacheHits      int    `json:"cache_hits,omitempty"`   // 304 responses answered from the response cache
+	CacheMisses    int    `json:"cache_misses,omitempty"` // Full downloads while the cache was enabled
+	BytesFromCache int64  `json:"bytes_from_cache,omitempty"`
+	Duration       string `json:"duration"`
+	Source         string `json:"source"`
 }
 
 // Plugin interface
 type Plugin interface {
 	Name() string
 	SupportedTypes() []string
+	// Options describes the plugin's own option keys; CommonOptions are
+	// understood by every plugin and not repeated here
+	Options() []OptionSpec
 	Scrape(ctx context.Context, req *ScrapeRequest) (*ScrapeResult, error)
 	ValidateRequest(req *ScrapeRequest) error
 }
+
+// DetailScraper is implemented by plugins that can scrape a single product
+// page, which lets discovery modes hand them product URLs found elsewhere
+type DetailScraper interface {
+	ScrapeProduct(ctx context.Context, req *ScrapeRequest, productURL string) (*ScrapedProduct, error)
+}
+
+// ProductPageScraper is implemented by detail scrapers whose product pages
+// can carry several products, such as a page listing a product alongside
+// its bundles. Product pages are scraped with it instead of ScrapeProduct,
+// so every product on the page is emitted and the ones that cannot be read
+// are reported as errors without losing the rest.
+type ProductPageScraper interface {
+	ScrapeProductPage(ctx context.Context, req *ScrapeRequest, productURL string) (*Page, error)
+}
+
+// Collection is a group of products a store lists together, such as a
+// Shopify collection or a WooCommerce product category
+type Collection struct {
+	Name         string `json:"name"`
+	Handle       string `json:"handle"`
+	URL          string `json:"url"`                     // Scraping this URL scrapes only the collection
+	ProductCount int    `json:"product_count,omitempty"` // 0 when the store does not say
+}
+
+// CollectionLister is implemented by plugins that can list a store's
+// collections, which lets onboarding propose one config per collection
+type CollectionLister interface {
+	ListCollections(ctx context.Context, siteURL string) ([]Collection, error)
+}
+
+// SitePlugin is implemented by plugins written for particular sites rather
+// than a platform. Detection trusts a site's own plugin over any probe,
+// since its storefront need not look like anything a probe knows.
+type SitePlugin interface {
+	// Hosts lists the hosts the plugin scrapes; their subdomains match too
+	Hosts() []string
+}
+
+// StoreInfo is what a store says about itself. Fields it does not give
+// are left empty.
+type StoreInfo struct {
+	Country  string `json:"country,omitempty"`  // ISO 3166 code, such as IN
+	Currency string `json:"currency,omitempty"` // ISO 4217 code, such as INR
+}
+
+// StoreDescriber is implemented by plugins that can ask a store for its
+// country and currency, which lets onboarding fill them in
+type StoreDescriber interface {
+	DescribeStore(ctx context.Context, siteURL string) (*StoreInfo, error)
+}
@@ -0,0 +1,56 @@
+package scraper
+
+import (
+	"crypto/md5"
+	"fmt"
+	"net/url"
+	"strings"
+)
+
+// MaxSourceIDLength keeps source IDs within the source_id column, with a
+// few characters to spare
+const MaxSourceIDLength = 45
+
+// ResolveURL resolves a link found on the page at base, dropping the
+// fragment. Empty, fragment-only and javascript: links resolve to "".
+func ResolveURL(base, ref string) string {
+	if ref == "" || strings.HasPrefix(ref, "#") || strings.HasPrefix(ref, "javascript:") {
+		return ""
+	}
+
+	baseURL, err := url.Parse(base)
+	if err != nil {
+		return ""
+	}
+	refURL, err := url.Parse(ref)
+	if err != nil {
+		return ""
+	}
+
+	resolved := baseURL.ResolveReference(refURL)
+	resolved.Fragment = ""
+	return resolved.String()
+}
+
+// HandleFromURL returns the last path segment of a product URL without an
+// .html suffix, e.g. "gmk-olivia" for /products/gmk-olivia.html
+func HandleFromURL(productURL string) string {
+	u, err := url.Parse(productURL)
+	if err != nil {
+		return ""
+	}
+
+	parts := strings.Split(strings.Trim(u.Path, "/"), "/")
+	return strings.TrimSuffix(parts[len(parts)-1], ".html")
+}
+
+// EnsureMaxLength keeps source IDs within the source_id column width by
+// replacing the tail of long values with a short hash.
+func EnsureMaxLength(s string, maxLen int) string {
+	if len(s) <= maxLen {
+		return s
+	}
+
+	hash := fmt.Sprintf("%x", md5.Sum([]byte(s)))[:8]
+	return s[:maxLen-len(hash)-1] + "-" + hash
+}
@@ -0,0 +1,65 @@
+package scraper
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestResolveURL(t *testing.T) {
+	const page = "https://shop.example.com/collections/keycaps?page=2"
+
+	tests := []struct {
+		ref  string
+		want string
+	}{
+		{"/products/gmk-olivia", "https://shop.example.com/products/gmk-olivia"},
+		{"gmk-olivia", "https://shop.example.com/collections/gmk-olivia"},
+		{"?page=3", "https://shop.example.com/collections/keycaps?page=3"},
+		{"//cdn.example.com/olivia.jpg", "https://cdn.example.com/olivia.jpg"},
+		{"https://other.example.com/p#reviews", "https://other.example.com/p"},
+		{"#reviews", ""},
+		{"javascript:void(0)", ""},
+		{"", ""},
+	}
+
+	for _, tt := range tests {
+		if got := ResolveURL(page, tt.ref); got != tt.want {
+			t.Errorf("ResolveURL(%q) = %q, want %q", tt.ref, got, tt.want)
+		}
+	}
+}
+
+func TestHandleFromURL(t *testing.T) {
+	tests := []struct {
+		url  string
+		want string
+	}{
+		{"https://shop.example.com/products/gmk-olivia", "gmk-olivia"},
+		{"https://shop.example.com/product/gmk-olivia/", "gmk-olivia"},
+		{"https://shop.example.com/keycaps/gmk-olivia.html?variant=1", "gmk-olivia"},
+		{"https://shop.example.com/", ""},
+	}
+
+	for _, tt := range tests {
+		if got := HandleFromURL(tt.url); got != tt.want {
+			t.Errorf("HandleFromURL(%q) = %q, want %q", tt.url, got, tt.want)
+		}
+	}
+}
+
+func TestEnsureMaxLength(t *testing.T) {
+	short := "gmk-olivia"
+	if got := EnsureMaxLength(short, MaxSourceIDLength); got != short {
+		t.Errorf("EnsureMaxLength(%q) = %q, want it unchanged", short, got)
+	}
+
+	long := strings.Repeat("gmk-olivia-", 10)
+	got := EnsureMaxLength(long, MaxSourceIDLength)
+	if len(got) != MaxSourceIDLength || !strings.HasPrefix(long, got[:MaxSourceIDLength-9]) {
+		t.Errorf("EnsureMaxLength(%q) = %q, want the first %d bytes and a hash", long, got, MaxSourceIDLength-9)
+	}
+	// Values sharing a prefix keep distinct IDs
+	if other := EnsureMaxLength(long+"x", MaxSourceIDLength); other == got {
+		t.Errorf("EnsureMaxLength() gave %q to two different values", got)
+	}
+}
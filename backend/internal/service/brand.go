@@ -0,0 +1,270 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/meta-boy/mech-alligator/internal/domain/brand"
+	"github.com/meta-boy/mech-alligator/internal/repository/postgres"
+)
+
+type BrandService struct {
+	brands   *postgres.BrandRepository
+	products *postgres.ProductRepository
+}
+
+func NewBrandService(brands *postgres.BrandRepository, products *postgres.ProductRepository) *BrandService {
+	return &BrandService{
+		brands:   brands,
+		products: products,
+	}
+}
+
+func (s *BrandService) ListBrands(ctx context.Context) ([]brand.Brand, error) {
+	brands, err := s.brands.List(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list brands: %w", err)
+	}
+	if brands == nil {
+		brands = []brand.Brand{}
+	}
+	return brands, nil
+}
+
+func (s *BrandService) GetBrand(ctx context.Context, id string) (*brand.Brand, error) {
+	b, err := s.brands.GetByID(ctx, id)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get brand: %w", err)
+	}
+	if b == nil {
+		return nil, ErrNotFound
+	}
+	return b, nil
+}
+
+func (s *BrandService) CreateBrand(ctx context.Context, b *brand.Brand) error {
+	b.ID = ""
+	b.ProductCount = 0
+	if err := s.prepareBrand(ctx, b); err != nil {
+		return err
+	}
+
+	if err := s.brands.Create(ctx, b); err != nil {
+		return fmt.Errorf("failed to create brand: %w", err)
+	}
+	return nil
+}
+
+// BrandUpdate changes the fields that are set and leaves the rest. Aliases
+// and rules replace the brand's as a whole.
+type BrandUpdate struct {
+	Name        *string       `json:"name"`
+	Country     *string       `json:"country"`
+	Website     *string       `json:"website"`
+	Description *string       `json:"description"`
+	Aliases     *[]string     `json:"aliases"`
+	Rules       *[]brand.Rule `json:"rules"`
+}
+
+// UpdateBrand changes a brand. Products already filed under it take a new
+// name straight away; new aliases and rules apply to products scraped from
+// then on, or to all of them after a renormalize.
+func (s *BrandService) UpdateBrand(ctx context.Context, id string, update BrandUpdate) (*brand.Brand, error) {
+	b, err := s.GetBrand(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	setString(&b.Name, update.Name)
+	setString(&b.Country, update.Country)
+	setString(&b.Website, update.Website)
+	setString(&b.Description, update.Description)
+	if update.Aliases != nil {
+		b.Aliases = *update.Aliases
+	}
+	if update.Rules != nil {
+		b.Rules = *update.Rules
+	}
+
+	if err := s.prepareBrand(ctx, b); err != nil {
+		return nil, err
+	}
+
+	found, err := s.brands.Update(ctx, b)
+	if err != nil {
+		return nil, fmt.Errorf("failed to update brand: %w", err)
+	}
+	if !found {
+		return nil, ErrNotFound
+	}
+	return b, nil
+}
+
+// MergeBrands folds duplicate brands into the one with targetID: their
+// products, aliases and rules move to it and their names become its
+// aliases
+func (s *BrandService) MergeBrands(ctx context.Context, targetID string, req brand.MergeRequest) (*brand.Brand, error) {
+	target, err := s.GetBrand(ctx, targetID)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(req.BrandIDs) == 0 {
+		return nil, &ValidationError{Problems: []string{"brand_ids is required"}}
+	}
+
+	var problems []string
+	var sources []brand.Brand
+	seen := make(map[string]bool)
+	for _, id := range req.BrandIDs {
+		if id == target.ID {
+			problems = append(problems, "a brand cannot be merged into itself")
+			continue
+		}
+		if seen[id] {
+			continue
+		}
+		seen[id] = true
+
+		source, err := s.brands.GetByID(ctx, id)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get brand %s: %w", id, err)
+		}
+		if source == nil {
+			problems = append(problems, fmt.Sprintf("brand %s does not exist", id))
+			continue
+		}
+		sources = append(sources, *source)
+	}
+	if len(problems) > 0 {
+		return nil, &ValidationError{Problems: problems}
+	}
+
+	if _, err := s.brands.Merge(ctx, target, sources); err != nil {
+		return nil, fmt.Errorf("failed to merge brands: %w", err)
+	}
+
+	return s.GetBrand(ctx, target.ID)
+}
+
+// Renormalize works out the brand of every product again from the brand
+// its source gave, for when aliases or rules changed after it was saved
+func (s *BrandService) Renormalize(ctx context.Context) (*brand.RenormalizeResult, error) {
+	normalizer, err := s.Normalizer(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	sources, err := s.products.ListBrandSources(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list products: %w", err)
+	}
+
+	result := &brand.RenormalizeResult{Scanned: len(sources)}
+	for _, source := range sources {
+		match, err := normalizer.Resolve(ctx, s.brands, source.RawBrand, source.Name)
+		if err != nil {
+			return result, err
+		}
+
+		name := match.Name
+		if name == "" {
+			name = "Unknown"
+		}
+		if match.BrandID == source.BrandID && name == source.Brand {
+			continue
+		}
+
+		if err := s.products.UpdateBrand(ctx, source.ID, match.BrandID, name); err != nil {
+			return result, fmt.Errorf("failed to update product %s: %w", source.ID, err)
+		}
+		result.Updated++
+	}
+
+	return result, nil
+}
+
+// Normalizer loads the registry for filing products under brands
+func (s *BrandService) Normalizer(ctx context.Context) (*brand.Normalizer, error) {
+	brands, err := s.brands.List(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load brands: %w", err)
+	}
+	return brand.NewNormalizer(brands), nil
+}
+
+// prepareBrand normalises a brand's fields and rejects it if they are
+// invalid or its names would collide with another brand's
+func (s *BrandService) prepareBrand(ctx context.Context, b *brand.Brand) error {
+	var problems []string
+
+	b.Name = strings.TrimSpace(b.Name)
+	if brand.CleanName(b.Name) == "" || brand.Key(b.Name) == "" {
+		problems = append(problems, "name is required")
+	}
+	b.Country = strings.ToUpper(strings.TrimSpace(b.Country))
+	if len(b.Country) > 3 {
+		problems = append(problems, "country must be a country code such as US")
+	}
+	b.Website = strings.TrimSpace(b.Website)
+	b.Description = strings.TrimSpace(b.Description)
+
+	// Aliases that only repeat the name or each other are dropped
+	keys := map[string]bool{brand.Key(b.Name): true}
+	aliases := []string{}
+	for _, alias := range b.Aliases {
+		alias = strings.TrimSpace(alias)
+		key := brand.Key(alias)
+		if key == "" {
+			problems = append(problems, fmt.Sprintf("alias %q has no letters or digits", alias))
+			continue
+		}
+		if keys[key] {
+			continue
+		}
+		keys[key] = true
+		aliases = append(aliases, alias)
+	}
+	b.Aliases = aliases
+
+	if b.Rules == nil {
+		b.Rules = []brand.Rule{}
+	}
+	for i := range b.Rules {
+		rule := &b.Rules[i]
+		rule.ID = ""
+		rule.Pattern = strings.TrimSpace(rule.Pattern)
+		if rule.Pattern == "" {
+			problems = append(problems, "rule pattern is required")
+			continue
+		}
+		if _, err := brand.CompileRule(rule.Pattern); err != nil {
+			problems = append(problems, fmt.Sprintf("rule %q: %v", rule.Pattern, err))
+		}
+	}
+
+	if len(problems) > 0 {
+		return &ValidationError{Problems: problems}
+	}
+
+	others, err := s.brands.List(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to list brands: %w", err)
+	}
+	for _, other := range others {
+		if other.ID == b.ID {
+			continue
+		}
+		for _, name := range append([]string{other.Name}, other.Aliases...) {
+			if keys[brand.Key(name)] {
+				problems = append(problems, fmt.Sprintf("%q is already a name of brand %s (%s); merge the brands instead", name, other.ID, other.Name))
+			}
+		}
+	}
+	if len(problems) > 0 {
+		return &ValidationError{Problems: problems}
+	}
+
+	return nil
+}
@@ -2,47 +2,92 @@ package service
 
 import (
 	"context"
-	"encoding/json"
+	"database/sql"
 	"fmt"
-	"strings"
 	"time"
 
 	"github.com/meta-boy/mech-alligator/internal/config"
 	"github.com/meta-boy/mech-alligator/internal/database"
 	"github.com/meta-boy/mech-alligator/internal/domain/job"
+	"github.com/meta-boy/mech-alligator/internal/repository/postgres"
+	"github.com/meta-boy/mech-alligator/internal/scraper"
 )
 
 type JobService struct {
-	db    *database.DB
-	queue job.Queue
+	db        *database.DB
+	queue     job.Queue
+	resellers *postgres.ResellerRepository
+	plugins   *scraper.Manager
 }
 
-func NewJobService(db *database.DB, queue job.Queue) *JobService {
+// NewJobService creates the job service. plugins is used to check job
+// options against the plugin that will run the job.
+func NewJobService(db *database.DB, queue job.Queue, resellers *postgres.ResellerRepository, plugins *scraper.Manager) *JobService {
 	return &JobService{
-		db:    db,
-		queue: queue,
+		db:        db,
+		queue:     queue,
+		resellers: resellers,
+		plugins:   plugins,
 	}
 }
 
 func (s *JobService) CreateScrapeJob(ctx context.Context, configID string, options map[string]string) (*job.Job, error) {
 	// Get reseller config
-	resellerConfig, err := s.getResellerConfig(ctx, configID)
+	resellerConfig, err := s.resellers.GetConfig(ctx, configID)
 	if err != nil {
 		return nil, fmt.Errorf("failed to get reseller config: %w", err)
 	}
+	if resellerConfig == nil {
+		return nil, fmt.Errorf("reseller config %s not found", configID)
+	}
 
 	if !resellerConfig.Active {
 		return nil, fmt.Errorf("reseller config %s is not active", configID)
 	}
 
 	// Get reseller info
-	reseller, err := s.getReseller(ctx, resellerConfig.ResellerID)
+	reseller, err := s.resellers.GetByID(ctx, resellerConfig.ResellerID)
 	if err != nil {
 		return nil, fmt.Errorf("failed to get reseller: %w", err)
 	}
+	if reseller == nil {
+		return nil, fmt.Errorf("reseller %s not found", resellerConfig.ResellerID)
+	}
+
+	if !reseller.Active {
+		return nil, fmt.Errorf("reseller %s is not active", reseller.ID)
+	}
 
 	// Determine the appropriate source type
-	sourceType := s.determineSourceType(resellerConfig.URL, reseller.Name, resellerConfig.SourceType)
+	sourceType, detection, err := s.determineSourceType(ctx, resellerConfig)
+	if err != nil {
+		return nil, fmt.Errorf("config %s: %w", configID, err)
+	}
+
+	// Config options apply to every run; options passed with the job override them
+	mergedOptions := make(map[string]string)
+	for key, value := range resellerConfig.Options {
+		mergedOptions[key] = value
+	}
+	for key, value := range options {
+		mergedOptions[key] = value
+	}
+
+	// Incremental sitemap runs only re-scrape pages modified since the last completed run
+	if mergedOptions["discovery"] == "sitemap" && mergedOptions["incremental"] == "true" && mergedOptions["modified_since"] == "" {
+		lastScrapedAt, err := s.getLastScrapedAt(ctx, resellerConfig.ID)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get last scrape time: %w", err)
+		}
+		if lastScrapedAt != "" {
+			mergedOptions["modified_since"] = lastScrapedAt
+		}
+	}
+
+	// Reject options the plugin would not understand before a worker picks the job up
+	if err := s.plugins.ValidateOptions(sourceType, mergedOptions); err != nil {
+		return nil, fmt.Errorf("config %s: %w", configID, err)
+	}
 
 	// Create job payload
 	payload := job.ScrapeJobPayload{
@@ -52,7 +97,8 @@ func (s *JobService) CreateScrapeJob(ctx context.Context, configID string, optio
 		URL:          resellerConfig.URL,
 		SourceType:   sourceType,
 		Category:     resellerConfig.Category,
-		Options:      options,
+		Currency:     reseller.Currency,
+		Options:      mergedOptions,
 	}
 
 	// Convert to map for storage
@@ -63,8 +109,12 @@ func (s *JobService) CreateScrapeJob(ctx context.Context, configID string, optio
 		"url":           payload.URL,
 		"source_type":   payload.SourceType,
 		"category":      payload.Category,
+		"currency":      payload.Currency,
 		"options":       payload.Options,
 	}
+	if detection != nil {
+		payloadMap["detection"] = detection
+	}
 
 	// Create job
 	j := &job.Job{
@@ -87,38 +137,35 @@ func (s *JobService) CreateScrapeJob(ctx context.Context, configID string, optio
 	return j, nil
 }
 
-// Determine source type based on URL and reseller
-func (s *JobService) determineSourceType(url, resellerName, configuredType string) string {
+// determineSourceType returns the configured source type, or for AUTO
+// configs the platform detected for them. A config that was never
+// detected is probed now and the detection saved, so later jobs do not
+// probe again; the detection is returned so it can be recorded with the
+// job.
+func (s *JobService) determineSourceType(ctx context.Context, c *config.ResellerConfig) (string, *scraper.Detection, error) {
 	// If explicitly configured, use that
-	if configuredType != "" && configuredType != "AUTO" {
-		return configuredType
-	}
-
-	// Auto-detect based on URL and reseller
-	urlLower := strings.ToLower(url)
-	resellerLower := strings.ToLower(resellerName)
-
-	switch {
-	case strings.Contains(urlLower, "stackskb.com"):
-		return "STACKS"
-	case strings.Contains(resellerLower, "stackskb"):
-		return "STACKS"
-	case strings.Contains(urlLower, ".myshopify.com") || strings.Contains(urlLower, "/products.json"):
-		return "SHOPIFY"
-	case strings.Contains(urlLower, "/products.json"):
-		return "SHOPIFY"
-	case strings.Contains(urlLower, "/store/") || strings.Contains(urlLower, "/shop/"):
-		return "STACKS"
-	default:
-		// Default fallback
-		return "SHOPIFY"
+	if c.SourceType != "" && c.SourceType != "AUTO" {
+		return c.SourceType, nil, nil
 	}
+	if c.DetectedSourceType != "" {
+		return c.DetectedSourceType, nil, nil
+	}
+
+	detection, err := detectPlatform(ctx, s.plugins, c.URL)
+	if err != nil {
+		return "", detection, err
+	}
+	if _, err := s.resellers.SetConfigDetection(ctx, c.ID, detection.SourceType, detection.Confidence, time.Now().UTC()); err != nil {
+		return "", detection, fmt.Errorf("failed to save detected platform: %w", err)
+	}
+
+	return detection.SourceType, detection, nil
 }
 
 // CreateScrapeAllSitesJob creates individual scrape jobs for all active reseller configs
 func (s *JobService) CreateScrapeAllSitesJob(ctx context.Context) (*job.Job, error) {
 	// Get all active reseller configs
-	configs, err := s.getActiveResellerConfigs(ctx)
+	configs, err := s.resellers.ListActiveConfigs(ctx)
 	if err != nil {
 		return nil, fmt.Errorf("failed to get active configs: %w", err)
 	}
@@ -249,85 +296,24 @@ func (s *JobService) RetryJob(ctx context.Context, id string) (*job.Job, error)
 	return newJob, nil
 }
 
-func (s *JobService) getResellerConfig(ctx context.Context, configID string) (*config.ResellerConfig, error) {
+// getLastScrapedAt returns the start time of the config's most recent
+// completed scrape job, or an empty string if it has never completed
+func (s *JobService) getLastScrapedAt(ctx context.Context, configID string) (string, error) {
 	query := `
-		SELECT id, reseller_id, name, url, source_type, category, active, options
-		FROM reseller_configs
-		WHERE id = $1
+		SELECT COALESCE(result->>'scraped_at', '')
+		FROM jobs
+		WHERE type = $1 AND status = $2 AND payload->>'config_id' = $3
+		ORDER BY completed_at DESC NULLS LAST
+		LIMIT 1
 	`
 
-	var config config.ResellerConfig
-	var optionsJSON []byte
-
-	err := s.db.QueryRowContext(ctx, query, configID).Scan(
-		&config.ID, &config.ResellerID, &config.Name, &config.URL,
-		&config.SourceType, &config.Category, &config.Active, &optionsJSON,
-	)
-
-	if err != nil {
-		return nil, err
-	}
-
-	// Parse options JSON if not empty
-	if len(optionsJSON) > 0 {
-		config.Options = make(map[string]string)
-		if err := json.Unmarshal(optionsJSON, &config.Options); err != nil {
-			// If JSON parsing fails, initialize empty map
-			config.Options = make(map[string]string)
-		}
-	} else {
-		config.Options = make(map[string]string)
-	}
-
-	return &config, nil
-}
-
-func (s *JobService) getReseller(ctx context.Context, resellerID string) (*config.Reseller, error) {
-	query := `
-		SELECT id, name, country, website, currency, active
-		FROM resellers
-		WHERE id = $1
-	`
-
-	var reseller config.Reseller
-	err := s.db.QueryRowContext(ctx, query, resellerID).Scan(
-		&reseller.ID, &reseller.Name, &reseller.Country,
-		&reseller.Website, &reseller.Currency, &reseller.Active,
-	)
-
-	return &reseller, err
-}
-
-func (s *JobService) getActiveResellerConfigs(ctx context.Context) ([]config.ResellerConfig, error) {
-	query := `
-		SELECT id, reseller_id, name, url, source_type, category, active
-		FROM reseller_configs
-		WHERE active = true
-		ORDER BY name
-	`
-
-	rows, err := s.db.QueryContext(ctx, query)
-	if err != nil {
-		return nil, err
-	}
-	defer rows.Close()
-
-	var configs []config.ResellerConfig
-	for rows.Next() {
-		var config config.ResellerConfig
-		err := rows.Scan(
-			&config.ID, &config.ResellerID, &config.Name,
-			&config.URL, &config.SourceType, &config.Category, &config.Active,
-		)
-		if err != nil {
-			return nil, err
-		}
-		// Initialize empty options map for configs retrieved without options
-		config.Options = make(map[string]string)
-		configs = append(configs, config)
+	var scrapedAt string
+	err := s.db.QueryRowContext(ctx, query, job.JobTypeScrapeProducts, job.StatusCompleted, configID).Scan(&scrapedAt)
+	if err == sql.ErrNoRows {
+		return "", nil
 	}
 
-	return configs, rows.Err()
+	return scrapedAt, err
 }
 
 // GetJobStats returns statistics about jobs
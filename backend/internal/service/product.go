@@ -5,11 +5,16 @@ import (
 	"fmt"
 	"github.com/meta-boy/mech-alligator/internal/scraper"
 	"math"
+	"time"
 
 	"github.com/meta-boy/mech-alligator/internal/domain/product"
 	"github.com/meta-boy/mech-alligator/internal/repository/postgres"
 )
 
+// NewArrivalsWindow is how far back the new arrivals feed looks unless
+// asked otherwise
+const NewArrivalsWindow = 7 * 24 * time.Hour
+
 type ProductService struct {
 	productRepo *postgres.ProductRepository
 }
@@ -25,6 +30,152 @@ func (s *ProductService) GetProduct(ctx context.Context, id string) (*product.Pr
 	return s.productRepo.GetByID(ctx, id)
 }
 
+// GetOffers returns every reseller's variants of the product a listing is,
+// cheapest first
+func (s *ProductService) GetOffers(ctx context.Context, id string) (*product.OffersResponse, error) {
+	p, err := s.getProduct(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	offers, err := s.productRepo.ListOffers(ctx, p.CanonicalID, p.ID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list offers: %w", err)
+	}
+
+	resellers := make(map[string]bool)
+	for _, o := range offers {
+		resellers[o.ResellerID] = true
+	}
+
+	return &product.OffersResponse{
+		CanonicalID: p.CanonicalID,
+		Name:        p.Name,
+		Brand:       p.Brand,
+		Offers:      offers,
+		Count:       len(offers),
+		Resellers:   len(resellers),
+	}, nil
+}
+
+// OverrideCanonical files a product under a canonical product by hand.
+// Matching leaves it there until the override is reset.
+func (s *ProductService) OverrideCanonical(ctx context.Context, id string, override product.CanonicalOverride) (*product.Product, error) {
+	set := 0
+	for _, given := range []bool{override.CanonicalID != "", override.ProductID != "", override.Separate} {
+		if given {
+			set++
+		}
+	}
+	if set != 1 {
+		return nil, &ValidationError{Problems: []string{"exactly one of canonical_id, product_id and separate is required"}}
+	}
+
+	p, err := s.getProduct(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	switch {
+	case override.Separate:
+		if err := s.productRepo.SeparateCanonical(ctx, p); err != nil {
+			return nil, fmt.Errorf("failed to separate product: %w", err)
+		}
+		return s.getProduct(ctx, id)
+
+	case override.ProductID != "":
+		other, err := s.productRepo.GetByID(ctx, override.ProductID)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get product: %w", err)
+		}
+		if other == nil || other.ID == p.ID {
+			return nil, &ValidationError{Problems: []string{fmt.Sprintf("product %s is not another product", override.ProductID)}}
+		}
+		if other.CanonicalID == "" {
+			return nil, &ValidationError{Problems: []string{fmt.Sprintf("product %s has not been matched yet", other.ID)}}
+		}
+		override.CanonicalID = other.CanonicalID
+
+	default:
+		exists, err := s.productRepo.CanonicalExists(ctx, override.CanonicalID)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get canonical product: %w", err)
+		}
+		if !exists {
+			return nil, &ValidationError{Problems: []string{fmt.Sprintf("canonical product %s does not exist", override.CanonicalID)}}
+		}
+	}
+
+	found, err := s.productRepo.SetCanonical(ctx, p.ID, override.CanonicalID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to set canonical product: %w", err)
+	}
+	if !found {
+		return nil, ErrNotFound
+	}
+	return s.getProduct(ctx, id)
+}
+
+// ResetCanonical removes a manual override and matches the product again
+func (s *ProductService) ResetCanonical(ctx context.Context, id string) (*product.Product, error) {
+	p, err := s.getProduct(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.productRepo.ResetCanonical(ctx, p); err != nil {
+		return nil, fmt.Errorf("failed to match product: %w", err)
+	}
+	return p, nil
+}
+
+// GetPriceHistory returns how the prices and availability of a product's
+// variants changed, downsampled to req.Interval
+func (s *ProductService) GetPriceHistory(ctx context.Context, id string, req product.PriceHistoryRequest) (*product.PriceHistoryResponse, error) {
+	if req.Interval == "" {
+		req.Interval = product.IntervalRaw
+	}
+	var problems []string
+	if !product.ValidInterval(req.Interval) {
+		problems = append(problems, "interval must be raw, hour, day or week")
+	}
+	if req.From != nil && req.To != nil && req.From.After(*req.To) {
+		problems = append(problems, "from must be before to")
+	}
+	if len(problems) > 0 {
+		return nil, &ValidationError{Problems: problems}
+	}
+
+	p, err := s.getProduct(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	changes, err := s.productRepo.ListPriceChanges(ctx, p.ID, req.VariantID, req.From, req.To)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get price history: %w", err)
+	}
+
+	return &product.PriceHistoryResponse{
+		ProductID: p.ID,
+		From:      req.From,
+		To:        req.To,
+		Interval:  req.Interval,
+		Variants:  product.BuildPriceHistory(changes, req.From, req.Interval),
+	}, nil
+}
+
+func (s *ProductService) getProduct(ctx context.Context, id string) (*product.Product, error) {
+	p, err := s.productRepo.GetByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if p == nil {
+		return nil, ErrNotFound
+	}
+	return p, nil
+}
+
 func (s *ProductService) ListProducts(ctx context.Context, req product.ListRequest) (*product.ProductListResponse, error) {
 	// Validate request
 	req.Validate()
@@ -53,6 +204,19 @@ func (s *ProductService) ListProducts(ctx context.Context, req product.ListReque
 	}, nil
 }
 
+// ListNewArrivals lists the products first seen since req.Since, or within
+// NewArrivalsWindow, newest first
+func (s *ProductService) ListNewArrivals(ctx context.Context, req product.ListRequest) (*product.ProductListResponse, error) {
+	if req.Since == nil {
+		since := time.Now().Add(-NewArrivalsWindow)
+		req.Since = &since
+	}
+	req.SortBy = "newest"
+	req.SortOrder = "desc"
+
+	return s.ListProducts(ctx, req)
+}
+
 func (s *ProductService) GetFilterOptions(ctx context.Context) (*FilterOptions, error) {
 	brands, err := s.productRepo.GetDistinctBrands(ctx)
 	if err != nil {
@@ -73,7 +237,7 @@ func (s *ProductService) GetFilterOptions(ctx context.Context) (*FilterOptions,
 		Brands:     brands,
 		Resellers:  resellers,
 		Categories: categories,
-		SortFields: []string{"name", "price", "brand", "reseller"},
+		SortFields: []string{"name", "price", "brand", "reseller", "newest", "recently_updated"},
 		SortOrders: []string{"asc", "desc"},
 	}, nil
 }
@@ -88,28 +252,29 @@ type FilterOptions struct {
 
 // SaveScrapedProducts saves products from scraping
 func (s *ProductService) SaveScrapedProducts(ctx context.Context, scrapedProducts []scraper.ScrapedProduct, resellerID, resellerName string) (*SaveResult, error) {
-	var created, updated, errors int
+	result := &SaveResult{}
 
 	for _, sp := range scrapedProducts {
-		err := s.saveScrapedProduct(ctx, sp, resellerID, resellerName)
+		outcome, err := s.saveScrapedProduct(ctx, sp, resellerID, resellerName)
 		if err != nil {
-			errors++
+			result.Errors++
 			continue
 		}
 
-		// For simplicity, counting all as created
-		// In real implementation, you'd check if product exists
-		created++
+		switch outcome.Status {
+		case product.SaveCreated:
+			result.Created++
+		case product.SaveUpdated:
+			result.Updated++
+		default:
+			result.Unchanged++
+		}
 	}
 
-	return &SaveResult{
-		Created: created,
-		Updated: updated,
-		Errors:  errors,
-	}, nil
+	return result, nil
 }
 
-func (s *ProductService) saveScrapedProduct(ctx context.Context, sp scraper.ScrapedProduct, resellerID, resellerName string) error {
+func (s *ProductService) saveScrapedProduct(ctx context.Context, sp scraper.ScrapedProduct, resellerID, resellerName string) (*product.SaveOutcome, error) {
 	// Convert scraped product to our domain model
 	p := &product.Product{
 		Name:           sp.Name,
@@ -132,6 +297,7 @@ func (s *ProductService) saveScrapedProduct(ctx context.Context, sp scraper.Scra
 		variant := product.Variant{
 			Name:      sv.Name,
 			SKU:       sv.SKU,
+			GTIN:      sv.GTIN,
 			Price:     sv.Price,
 			Currency:  sv.Currency,
 			Available: sv.Available,
@@ -149,7 +315,8 @@ func (s *ProductService) saveScrapedProduct(ctx context.Context, sp scraper.Scra
 }
 
 type SaveResult struct {
-	Created int `json:"created"`
-	Updated int `json:"updated"`
-	Errors  int `json:"errors"`
+	Created   int `json:"created"`
+	Updated   int `json:"updated"`
+	Unchanged int `json:"unchanged"`
+	Errors    int `json:"errors"`
 }
@@ -0,0 +1,787 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net/url"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/meta-boy/mech-alligator/internal/config"
+	"github.com/meta-boy/mech-alligator/internal/repository/postgres"
+	"github.com/meta-boy/mech-alligator/internal/scraper"
+)
+
+type ResellerService struct {
+	repo    *postgres.ResellerRepository
+	plugins *scraper.Manager
+}
+
+// NewResellerService creates the reseller service. plugins detects the
+// platform of new resellers and checks the options of their configs.
+func NewResellerService(repo *postgres.ResellerRepository, plugins *scraper.Manager) *ResellerService {
+	return &ResellerService{
+		repo:    repo,
+		plugins: plugins,
+	}
+}
+
+// ErrNotFound is returned for a record that does not exist
+var ErrNotFound = errors.New("not found")
+
+// ValidationError lists every problem with a request the caller has to fix
+type ValidationError struct {
+	Problems []string
+}
+
+func (e *ValidationError) Error() string {
+	return "invalid request: " + strings.Join(e.Problems, "; ")
+}
+
+// Discovery is what probing a reseller's homepage found: the platform, the
+// reseller to create or the one that already exists, and a config for each
+// collection
+type Discovery struct {
+	URL       string             `json:"url"`
+	Detection *scraper.Detection `json:"detection"`
+	Reseller  config.Reseller    `json:"reseller"` // Has an ID when the reseller already exists
+	Configs   []ProposedConfig   `json:"configs"`
+	Message   string             `json:"message,omitempty"`
+}
+
+// ProposedConfig is a reseller config suggested by discovery. Configs
+// without a suggested category are left for the admin to categorise.
+type ProposedConfig struct {
+	config.ResellerConfig
+	ProductCount int  `json:"product_count,omitempty"`
+	Exists       bool `json:"exists,omitempty"` // The reseller already has a config for this URL
+}
+
+// AcceptDiscoveryRequest creates a reseller and its configs; a Discovery,
+// edited or not, can be posted back as one
+type AcceptDiscoveryRequest struct {
+	Reseller config.Reseller         `json:"reseller"`
+	Configs  []config.ResellerConfig `json:"configs"`
+}
+
+// AcceptedDiscovery is the reseller and the configs created for it
+type AcceptedDiscovery struct {
+	Reseller config.Reseller         `json:"reseller"`
+	Configs  []config.ResellerConfig `json:"configs"`
+	Skipped  []SkippedConfig         `json:"skipped,omitempty"`
+}
+
+type SkippedConfig struct {
+	Name   string `json:"name"`
+	URL    string `json:"url"`
+	Reason string `json:"reason"`
+}
+
+// ConfigDetection is what probing a config's URL found. The detection is
+// only saved on the config when it is confident.
+type ConfigDetection struct {
+	Config    *config.ResellerConfig `json:"config"`
+	Detection *scraper.Detection     `json:"detection"`
+	Message   string                 `json:"message,omitempty"`
+}
+
+// categoryKeywords maps words in collection names to product categories.
+// More specific categories come first, so "Keyboard Switches" is SWITCHES
+// rather than KEYBOARD.
+var categoryKeywords = []struct {
+	category string
+	words    []string
+}{
+	{"KEYCAPS", []string{"keycap", "keycaps", "caps", "artisan", "artisans"}},
+	{"SWITCHES", []string{"switch", "switches"}},
+	{"DESKMATS", []string{"deskmat", "deskmats", "mousepad", "mousepads"}},
+	{"ACCESSORIES", []string{"accessory", "accessories", "cable", "cables", "stabilizer", "stabilizers", "stabs", "lube", "lubes", "tools", "plate", "plates", "pcb", "pcbs", "case", "cases"}},
+	{"KEYBOARD", []string{"keyboard", "keyboards", "kit", "kits", "barebone", "barebones", "prebuilt", "prebuilts"}},
+}
+
+// Discover probes a reseller's homepage for its platform and collections
+// and proposes a config for each collection
+func (s *ResellerService) Discover(ctx context.Context, siteURL string) (*Discovery, error) {
+	origin, err := siteOrigin(siteURL)
+	if err != nil {
+		return nil, &ValidationError{Problems: []string{err.Error()}}
+	}
+
+	detection, err := s.plugins.Detect(ctx, siteURL)
+	if err != nil {
+		return nil, fmt.Errorf("failed to detect platform: %w", err)
+	}
+
+	discovery := &Discovery{
+		URL:       siteURL,
+		Detection: detection,
+		Reseller: config.Reseller{
+			Name:    nameFromHost(origin),
+			Website: origin,
+			Active:  true,
+		},
+		Configs: []ProposedConfig{},
+	}
+
+	existing, err := s.repo.GetByWebsite(ctx, origin)
+	if err != nil {
+		return nil, fmt.Errorf("failed to look up reseller: %w", err)
+	}
+	existingURLs := make(map[string]bool)
+	if existing != nil {
+		discovery.Reseller = *existing
+		configs, err := s.repo.ListConfigs(ctx, existing.ID)
+		if err != nil {
+			return nil, fmt.Errorf("failed to list configs: %w", err)
+		}
+		for _, c := range configs {
+			existingURLs[normalizeConfigURL(c.URL)] = true
+		}
+	}
+
+	if detection.Confidence < scraper.MinDetectConfidence {
+		discovery.Message = "could not detect the platform; add configs with an explicit source_type"
+		return discovery, nil
+	}
+
+	if existing == nil {
+		info, err := s.plugins.DescribeStore(ctx, detection.SourceType, siteURL)
+		if err != nil {
+			log.Printf("Warning: failed to describe %s: %v", siteURL, err)
+		}
+		if info != nil {
+			discovery.Reseller.Country = info.Country
+			discovery.Reseller.Currency = info.Currency
+		}
+		if discovery.Reseller.Country == "" || discovery.Reseller.Currency == "" {
+			discovery.Message = "the store did not say its country or currency; fill them in before accepting"
+		}
+	}
+
+	collections, err := s.plugins.ListCollections(ctx, detection.SourceType, siteURL)
+	if err != nil {
+		// The site can still be scraped as a whole
+		log.Printf("Warning: failed to list collections of %s: %v", siteURL, err)
+	}
+	if len(collections) == 0 {
+		collections = []scraper.Collection{{Name: "All Products", URL: siteURL}}
+	}
+
+	for _, collection := range collections {
+		discovery.Configs = append(discovery.Configs, ProposedConfig{
+			ResellerConfig: config.ResellerConfig{
+				Name:       discovery.Reseller.Name + " " + collection.Name,
+				URL:        collection.URL,
+				SourceType: detection.SourceType,
+				Category:   suggestCategory(collection.Name + " " + collection.Handle),
+				Active:     true,
+				Options:    map[string]string{},
+			},
+			ProductCount: collection.ProductCount,
+			Exists:       existingURLs[normalizeConfigURL(collection.URL)],
+		})
+	}
+
+	return discovery, nil
+}
+
+// AcceptDiscovery creates the reseller, unless it has an ID, and its
+// configs. Configs without a category or whose URL the reseller already
+// has are skipped; any other problem rejects the whole request.
+func (s *ResellerService) AcceptDiscovery(ctx context.Context, req AcceptDiscoveryRequest) (*AcceptedDiscovery, error) {
+	var problems []string
+
+	reseller := req.Reseller
+	existingURLs := make(map[string]bool)
+	if reseller.ID != "" {
+		existing, err := s.repo.GetByID(ctx, reseller.ID)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get reseller: %w", err)
+		}
+		if existing == nil {
+			return nil, &ValidationError{Problems: []string{fmt.Sprintf("reseller %s not found", reseller.ID)}}
+		}
+		reseller = *existing
+
+		configs, err := s.repo.ListConfigs(ctx, reseller.ID)
+		if err != nil {
+			return nil, fmt.Errorf("failed to list configs: %w", err)
+		}
+		for _, c := range configs {
+			existingURLs[normalizeConfigURL(c.URL)] = true
+		}
+	} else {
+		reseller.Active = true
+		reseller.Configs = nil
+		// Discovery leaves out what the store did not say rather than guess
+		if strings.TrimSpace(reseller.Country) == "" {
+			problems = append(problems, "reseller.country is required")
+		}
+		if strings.TrimSpace(reseller.Currency) == "" {
+			problems = append(problems, "reseller.currency is required")
+		}
+		for _, problem := range s.prepareReseller(&reseller) {
+			problems = append(problems, "reseller."+problem)
+		}
+		if len(problems) == 0 {
+			if err := s.checkWebsiteFree(ctx, &reseller); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	result := &AcceptedDiscovery{Configs: []config.ResellerConfig{}}
+	var configs []config.ResellerConfig
+	for i, c := range req.Configs {
+		switch {
+		case c.Category == "":
+			result.Skipped = append(result.Skipped, SkippedConfig{Name: c.Name, URL: c.URL, Reason: "no category"})
+			continue
+		case existingURLs[normalizeConfigURL(c.URL)]:
+			result.Skipped = append(result.Skipped, SkippedConfig{Name: c.Name, URL: c.URL, Reason: "already exists"})
+			continue
+		}
+		existingURLs[normalizeConfigURL(c.URL)] = true
+
+		c.ID = ""
+		c.LastScrape = nil
+		for _, problem := range s.prepareConfig(&c) {
+			problems = append(problems, fmt.Sprintf("configs[%d].%s", i, problem))
+		}
+		configs = append(configs, c)
+	}
+
+	if len(problems) > 0 {
+		return nil, &ValidationError{Problems: problems}
+	}
+	for i := range configs {
+		s.detectConfig(ctx, &configs[i])
+	}
+
+	if len(configs) == 0 && reseller.ID != "" {
+		result.Reseller = reseller
+		return result, nil
+	}
+
+	if err := s.repo.CreateWithConfigs(ctx, &reseller, configs); err != nil {
+		return nil, fmt.Errorf("failed to create reseller: %w", err)
+	}
+
+	result.Reseller = reseller
+	if configs != nil {
+		result.Configs = configs
+	}
+	return result, nil
+}
+
+// ListResellers returns the resellers with their configs and each
+// config's last scrape
+func (s *ResellerService) ListResellers(ctx context.Context, includeInactive bool) ([]config.Reseller, error) {
+	resellers, err := s.repo.List(ctx, includeInactive)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list resellers: %w", err)
+	}
+
+	for i := range resellers {
+		configs, err := s.listConfigs(ctx, resellers[i].ID)
+		if err != nil {
+			return nil, err
+		}
+		resellers[i].Configs = configs
+	}
+
+	return resellers, nil
+}
+
+func (s *ResellerService) GetReseller(ctx context.Context, id string) (*config.Reseller, error) {
+	reseller, err := s.getReseller(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	configs, err := s.listConfigs(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	reseller.Configs = configs
+
+	return reseller, nil
+}
+
+func (s *ResellerService) CreateReseller(ctx context.Context, reseller *config.Reseller) error {
+	reseller.ID = ""
+	reseller.Configs = nil
+	if problems := s.prepareReseller(reseller); len(problems) > 0 {
+		return &ValidationError{Problems: problems}
+	}
+	if err := s.checkWebsiteFree(ctx, reseller); err != nil {
+		return err
+	}
+
+	if err := s.repo.Create(ctx, reseller); err != nil {
+		return fmt.Errorf("failed to create reseller: %w", err)
+	}
+	return nil
+}
+
+// ResellerUpdate changes the fields that are set and leaves the rest
+type ResellerUpdate struct {
+	Name     *string `json:"name"`
+	Country  *string `json:"country"`
+	Website  *string `json:"website"`
+	Currency *string `json:"currency"`
+	Active   *bool   `json:"active"`
+}
+
+func (s *ResellerService) UpdateReseller(ctx context.Context, id string, update ResellerUpdate) (*config.Reseller, error) {
+	reseller, err := s.getReseller(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	setString(&reseller.Name, update.Name)
+	setString(&reseller.Country, update.Country)
+	setString(&reseller.Website, update.Website)
+	setString(&reseller.Currency, update.Currency)
+	if update.Active != nil {
+		reseller.Active = *update.Active
+	}
+
+	if problems := s.prepareReseller(reseller); len(problems) > 0 {
+		return nil, &ValidationError{Problems: problems}
+	}
+	if err := s.checkWebsiteFree(ctx, reseller); err != nil {
+		return nil, err
+	}
+
+	found, err := s.repo.Update(ctx, reseller)
+	if err != nil {
+		return nil, fmt.Errorf("failed to update reseller: %w", err)
+	}
+	if !found {
+		return nil, ErrNotFound
+	}
+	return reseller, nil
+}
+
+// DeactivateReseller stops the reseller's configs from being scraped
+// without deleting it or the products scraped from it
+func (s *ResellerService) DeactivateReseller(ctx context.Context, id string) error {
+	found, err := s.repo.SetActive(ctx, id, false)
+	if err != nil {
+		return fmt.Errorf("failed to deactivate reseller: %w", err)
+	}
+	if !found {
+		return ErrNotFound
+	}
+	return nil
+}
+
+func (s *ResellerService) ListConfigs(ctx context.Context, resellerID string) ([]config.ResellerConfig, error) {
+	if _, err := s.getReseller(ctx, resellerID); err != nil {
+		return nil, err
+	}
+	return s.listConfigs(ctx, resellerID)
+}
+
+func (s *ResellerService) GetConfig(ctx context.Context, id string) (*config.ResellerConfig, error) {
+	c, err := s.getConfig(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	statuses, err := s.repo.LastScrapes(ctx, []string{c.ID})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get last scrape: %w", err)
+	}
+	c.LastScrape = statuses[c.ID]
+
+	return c, nil
+}
+
+func (s *ResellerService) CreateConfig(ctx context.Context, resellerID string, c *config.ResellerConfig) error {
+	if _, err := s.getReseller(ctx, resellerID); err != nil {
+		return err
+	}
+
+	c.ID = ""
+	c.ResellerID = resellerID
+	c.LastScrape = nil
+	if problems := s.prepareConfig(c); len(problems) > 0 {
+		return &ValidationError{Problems: problems}
+	}
+	if err := s.checkURLFree(ctx, c); err != nil {
+		return err
+	}
+	s.detectConfig(ctx, c)
+
+	if err := s.repo.CreateConfig(ctx, c); err != nil {
+		return fmt.Errorf("failed to create config: %w", err)
+	}
+	return nil
+}
+
+// ConfigUpdate changes the fields that are set and leaves the rest.
+// Options replace the config's options as a whole.
+type ConfigUpdate struct {
+	Name       *string           `json:"name"`
+	URL        *string           `json:"url"`
+	SourceType *string           `json:"source_type"`
+	Category   *string           `json:"category"`
+	Active     *bool             `json:"active"`
+	Options    map[string]string `json:"options"`
+}
+
+func (s *ResellerService) UpdateConfig(ctx context.Context, id string, update ConfigUpdate) (*config.ResellerConfig, error) {
+	c, err := s.getConfig(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	previousURL, previousType := c.URL, c.SourceType
+	setString(&c.Name, update.Name)
+	setString(&c.URL, update.URL)
+	setString(&c.SourceType, update.SourceType)
+	setString(&c.Category, update.Category)
+	if update.Active != nil {
+		c.Active = *update.Active
+	}
+	if update.Options != nil {
+		c.Options = update.Options
+	}
+
+	if problems := s.prepareConfig(c); len(problems) > 0 {
+		return nil, &ValidationError{Problems: problems}
+	}
+	if err := s.checkURLFree(ctx, c); err != nil {
+		return nil, err
+	}
+	// A detection made for another URL says nothing about the new one
+	if c.URL != previousURL || c.SourceType != previousType {
+		s.detectConfig(ctx, c)
+	}
+
+	found, err := s.repo.UpdateConfig(ctx, c)
+	if err != nil {
+		return nil, fmt.Errorf("failed to update config: %w", err)
+	}
+	if !found {
+		return nil, ErrNotFound
+	}
+	return c, nil
+}
+
+// DeactivateConfig stops the config from being scraped
+func (s *ResellerService) DeactivateConfig(ctx context.Context, id string) error {
+	found, err := s.repo.SetConfigActive(ctx, id, false)
+	if err != nil {
+		return fmt.Errorf("failed to deactivate config: %w", err)
+	}
+	if !found {
+		return ErrNotFound
+	}
+	return nil
+}
+
+// DetectConfig probes the config's URL for its platform again and saves
+// the detection when it is confident. AUTO configs are scraped with the
+// saved detection, so this is how one is moved to another platform.
+func (s *ResellerService) DetectConfig(ctx context.Context, id string) (*ConfigDetection, error) {
+	c, err := s.getConfig(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	result := &ConfigDetection{Config: c}
+	result.Detection, err = detectPlatform(ctx, s.plugins, c.URL)
+	if err != nil {
+		if result.Detection == nil {
+			return nil, err
+		}
+		result.Message = err.Error()
+		return result, nil
+	}
+
+	now := time.Now().UTC()
+	found, err := s.repo.SetConfigDetection(ctx, c.ID, result.Detection.SourceType, result.Detection.Confidence, now)
+	if err != nil {
+		return nil, fmt.Errorf("failed to save detected platform: %w", err)
+	}
+	if !found {
+		return nil, ErrNotFound
+	}
+	c.DetectedSourceType = result.Detection.SourceType
+	c.DetectionConfidence = result.Detection.Confidence
+	c.DetectedAt = &now
+	return result, nil
+}
+
+func (s *ResellerService) getReseller(ctx context.Context, id string) (*config.Reseller, error) {
+	reseller, err := s.repo.GetByID(ctx, id)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get reseller: %w", err)
+	}
+	if reseller == nil {
+		return nil, ErrNotFound
+	}
+	return reseller, nil
+}
+
+func (s *ResellerService) getConfig(ctx context.Context, id string) (*config.ResellerConfig, error) {
+	c, err := s.repo.GetConfig(ctx, id)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get config: %w", err)
+	}
+	if c == nil {
+		return nil, ErrNotFound
+	}
+	return c, nil
+}
+
+// listConfigs returns a reseller's configs with their last scrape
+func (s *ResellerService) listConfigs(ctx context.Context, resellerID string) ([]config.ResellerConfig, error) {
+	configs, err := s.repo.ListConfigs(ctx, resellerID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list configs: %w", err)
+	}
+
+	ids := make([]string, len(configs))
+	for i, c := range configs {
+		ids[i] = c.ID
+	}
+	statuses, err := s.repo.LastScrapes(ctx, ids)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get last scrapes: %w", err)
+	}
+	for i := range configs {
+		configs[i].LastScrape = statuses[configs[i].ID]
+	}
+
+	if configs == nil {
+		configs = []config.ResellerConfig{}
+	}
+	return configs, nil
+}
+
+// prepareReseller normalises a reseller's fields and returns what is wrong
+// with them
+func (s *ResellerService) prepareReseller(reseller *config.Reseller) []string {
+	var problems []string
+
+	reseller.Name = strings.TrimSpace(reseller.Name)
+	if reseller.Name == "" {
+		problems = append(problems, "name is required")
+	}
+	origin, err := siteOrigin(reseller.Website)
+	if err != nil {
+		problems = append(problems, "website: "+err.Error())
+	}
+	reseller.Website = origin
+
+	reseller.Country = strings.ToUpper(strings.TrimSpace(reseller.Country))
+	if reseller.Country == "" {
+		reseller.Country = "IN"
+	}
+	reseller.Currency = strings.ToUpper(strings.TrimSpace(reseller.Currency))
+	if reseller.Currency == "" {
+		reseller.Currency = "INR"
+	}
+	if len(reseller.Country) > 3 {
+		problems = append(problems, "country must be a country code such as IN")
+	}
+	if len(reseller.Currency) != 3 {
+		problems = append(problems, "currency must be a currency code such as INR")
+	}
+
+	return problems
+}
+
+// prepareConfig normalises a config's fields and returns what is wrong
+// with them, including options the plugins would not understand
+func (s *ResellerService) prepareConfig(c *config.ResellerConfig) []string {
+	var problems []string
+
+	c.Name = strings.TrimSpace(c.Name)
+	if c.Name == "" {
+		problems = append(problems, "name is required")
+	}
+	c.URL = strings.TrimSpace(c.URL)
+	if _, err := siteOrigin(c.URL); err != nil {
+		problems = append(problems, "url: "+err.Error())
+	}
+	c.Category = strings.ToUpper(strings.TrimSpace(c.Category))
+	if c.Category == "" {
+		problems = append(problems, "category is required")
+	}
+	c.SourceType = strings.ToUpper(strings.TrimSpace(c.SourceType))
+	if c.SourceType == "" {
+		c.SourceType = "AUTO"
+	}
+	if c.Options == nil {
+		c.Options = make(map[string]string)
+	}
+
+	var optionsErr *scraper.OptionsError
+	if err := s.validateOptions(c.SourceType, c.Options); errors.As(err, &optionsErr) {
+		for _, problem := range optionsErr.Problems {
+			problems = append(problems, "options: "+problem)
+		}
+	}
+
+	return problems
+}
+
+// validateOptions checks options against the plugins for sourceType. AUTO
+// configs get their plugin when a job is created, so any plugin's options
+// are accepted for them.
+func (s *ResellerService) validateOptions(sourceType string, options map[string]string) error {
+	if sourceType != "AUTO" {
+		return s.plugins.ValidateOptions(sourceType, options)
+	}
+
+	specs := scraper.CommonOptions()
+	for _, plugin := range s.plugins.ListAvailablePlugins() {
+		specs = append(specs, plugin.Options...)
+	}
+	return scraper.ValidateOptions(specs, options)
+}
+
+// checkWebsiteFree rejects a website another reseller already has
+func (s *ResellerService) checkWebsiteFree(ctx context.Context, reseller *config.Reseller) error {
+	existing, err := s.repo.GetByWebsite(ctx, reseller.Website)
+	if err != nil {
+		return fmt.Errorf("failed to look up reseller: %w", err)
+	}
+	if existing != nil && existing.ID != reseller.ID {
+		return &ValidationError{Problems: []string{
+			fmt.Sprintf("reseller %s already has website %s", existing.ID, reseller.Website),
+		}}
+	}
+	return nil
+}
+
+// checkURLFree rejects a URL another config of the same reseller already
+// scrapes
+func (s *ResellerService) checkURLFree(ctx context.Context, c *config.ResellerConfig) error {
+	configs, err := s.repo.ListConfigs(ctx, c.ResellerID)
+	if err != nil {
+		return fmt.Errorf("failed to list configs: %w", err)
+	}
+	for _, other := range configs {
+		if other.ID != c.ID && normalizeConfigURL(other.URL) == normalizeConfigURL(c.URL) {
+			return &ValidationError{Problems: []string{
+				fmt.Sprintf("config %s already scrapes %s", other.ID, c.URL),
+			}}
+		}
+	}
+	return nil
+}
+
+// detectConfig replaces the config's detection with a fresh one when it
+// is AUTO. A site that cannot be detected yet is left for the config's
+// next scrape job to detect.
+func (s *ResellerService) detectConfig(ctx context.Context, c *config.ResellerConfig) {
+	c.DetectedSourceType, c.DetectionConfidence, c.DetectedAt = "", 0, nil
+	if c.SourceType != "AUTO" {
+		return
+	}
+
+	detection, err := detectPlatform(ctx, s.plugins, c.URL)
+	if err != nil {
+		log.Printf("Warning: config %s: %v", c.Name, err)
+		return
+	}
+
+	now := time.Now().UTC()
+	c.DetectedSourceType = detection.SourceType
+	c.DetectionConfidence = detection.Confidence
+	c.DetectedAt = &now
+}
+
+// detectPlatform probes siteURL for its platform. A detection that is not
+// confident enough to scrape with is returned along with an error asking
+// for an explicit source_type.
+func detectPlatform(ctx context.Context, plugins *scraper.Manager, siteURL string) (*scraper.Detection, error) {
+	detection, err := plugins.Detect(ctx, siteURL)
+	if err != nil {
+		return nil, fmt.Errorf("failed to detect platform: %w", err)
+	}
+	if detection.Confidence < scraper.MinDetectConfidence {
+		if detection.SourceType == "" {
+			return detection, fmt.Errorf("could not detect the platform of %s; set source_type", siteURL)
+		}
+		return detection, fmt.Errorf("could not detect the platform of %s (best guess %s at %.2f); set source_type",
+			siteURL, detection.SourceType, detection.Confidence)
+	}
+
+	log.Printf("Detected %s for %s with confidence %.2f: %s",
+		detection.SourceType, siteURL, detection.Confidence, strings.Join(detection.Signals, ", "))
+	return detection, nil
+}
+
+func setString(field *string, value *string) {
+	if value != nil {
+		*field = *value
+	}
+}
+
+// siteOrigin returns the scheme and host of an absolute http(s) URL
+func siteOrigin(rawURL string) (string, error) {
+	u, err := url.Parse(strings.TrimSpace(rawURL))
+	if err != nil || u.Host == "" || (u.Scheme != "http" && u.Scheme != "https") {
+		return "", fmt.Errorf("%q is not an absolute http(s) URL", rawURL)
+	}
+	return u.Scheme + "://" + strings.ToLower(u.Host), nil
+}
+
+// normalizeConfigURL makes URLs that scrape the same listing compare equal
+func normalizeConfigURL(rawURL string) string {
+	u, err := url.Parse(strings.TrimSpace(rawURL))
+	if err != nil {
+		return rawURL
+	}
+	return strings.ToLower(u.Host) + strings.TrimSuffix(u.Path, "/")
+}
+
+// nameFromHost turns https://www.meckeys.com into Meckeys
+func nameFromHost(origin string) string {
+	host := strings.TrimPrefix(origin[strings.Index(origin, "://")+3:], "www.")
+	if i := strings.Index(host, "."); i > 0 {
+		host = host[:i]
+	}
+	if host == "" {
+		return origin
+	}
+
+	runes := []rune(host)
+	runes[0] = unicode.ToUpper(runes[0])
+	return string(runes)
+}
+
+// suggestCategory returns the category a collection most likely holds, or
+// an empty string when its name says nothing about it
+func suggestCategory(name string) string {
+	words := make(map[string]bool)
+	for _, word := range strings.FieldsFunc(strings.ToLower(name), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	}) {
+		words[word] = true
+	}
+
+	for _, keywords := range categoryKeywords {
+		for _, word := range keywords.words {
+			if words[word] {
+				return keywords.category
+			}
+		}
+	}
+
+	// "deskmat" is often written as two words
+	if words["desk"] && (words["mat"] || words["mats"]) {
+		return "DESKMATS"
+	}
+	return ""
+}
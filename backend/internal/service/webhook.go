@@ -0,0 +1,261 @@
+package service
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/meta-boy/mech-alligator/internal/domain/event"
+	"github.com/meta-boy/mech-alligator/internal/domain/job"
+	"github.com/meta-boy/mech-alligator/internal/domain/webhook"
+	"github.com/meta-boy/mech-alligator/internal/repository/postgres"
+)
+
+// WebhookService manages webhook subscriptions and turns product change
+// events into deliveries, which the worker sends as webhook_delivery jobs
+type WebhookService struct {
+	webhooks *postgres.WebhookRepository
+	queue    job.Queue
+}
+
+func NewWebhookService(webhooks *postgres.WebhookRepository, queue job.Queue) *WebhookService {
+	return &WebhookService{
+		webhooks: webhooks,
+		queue:    queue,
+	}
+}
+
+// ListSubscriptions returns every subscription, without its secret
+func (s *WebhookService) ListSubscriptions(ctx context.Context) ([]webhook.Subscription, error) {
+	subscriptions, err := s.webhooks.ListSubscriptions(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list webhook subscriptions: %w", err)
+	}
+	if subscriptions == nil {
+		subscriptions = []webhook.Subscription{}
+	}
+	for i := range subscriptions {
+		subscriptions[i].Secret = ""
+	}
+	return subscriptions, nil
+}
+
+// GetSubscription returns a subscription without its secret
+func (s *WebhookService) GetSubscription(ctx context.Context, id string) (*webhook.Subscription, error) {
+	subscription, err := s.getSubscription(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	subscription.Secret = ""
+	return subscription, nil
+}
+
+// CreateSubscription saves a subscription, generating a secret if it has
+// none. The secret is only ever returned here.
+func (s *WebhookService) CreateSubscription(ctx context.Context, subscription *webhook.Subscription) error {
+	var problems []string
+
+	subscription.ID = ""
+	subscription.URL = strings.TrimSpace(subscription.URL)
+	if err := webhook.CheckURL(ctx, subscription.URL); err != nil {
+		problems = append(problems, "url: "+err.Error())
+	}
+
+	if len(subscription.EventTypes) == 0 {
+		problems = append(problems, "event_types is required")
+	}
+	for _, t := range subscription.EventTypes {
+		if !event.Valid(t) {
+			problems = append(problems, fmt.Sprintf("unknown event type %q", t))
+		}
+	}
+
+	subscription.Categories = trimAll(subscription.Categories)
+	subscription.Brands = trimAll(subscription.Brands)
+	subscription.Resellers = trimAll(subscription.Resellers)
+
+	subscription.Secret = strings.TrimSpace(subscription.Secret)
+	if subscription.Secret == "" {
+		secret := make([]byte, 32)
+		if _, err := rand.Read(secret); err != nil {
+			return fmt.Errorf("failed to generate secret: %w", err)
+		}
+		subscription.Secret = hex.EncodeToString(secret)
+	}
+
+	if len(problems) > 0 {
+		return &ValidationError{Problems: problems}
+	}
+
+	if err := s.webhooks.CreateSubscription(ctx, subscription); err != nil {
+		return fmt.Errorf("failed to create webhook subscription: %w", err)
+	}
+	return nil
+}
+
+func (s *WebhookService) DeleteSubscription(ctx context.Context, id string) error {
+	found, err := s.webhooks.DeleteSubscription(ctx, id)
+	if err != nil {
+		return fmt.Errorf("failed to delete webhook subscription: %w", err)
+	}
+	if !found {
+		return ErrNotFound
+	}
+	return nil
+}
+
+// TestSubscription sends the subscription a webhook.test event
+func (s *WebhookService) TestSubscription(ctx context.Context, id string) (*webhook.Delivery, error) {
+	subscription, err := s.getSubscription(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	return s.deliver(ctx, subscription, &event.Event{
+		Type:      webhook.TestEvent,
+		CreatedAt: time.Now().UTC(),
+	})
+}
+
+// ListDeliveries returns a subscription's latest deliveries, only failed
+// ones if status is "failed"
+func (s *WebhookService) ListDeliveries(ctx context.Context, id string, status webhook.DeliveryStatus, limit int) ([]webhook.Delivery, error) {
+	if _, err := s.getSubscription(ctx, id); err != nil {
+		return nil, err
+	}
+	switch status {
+	case "", webhook.DeliveryPending, webhook.DeliverySucceeded, webhook.DeliveryFailed:
+	default:
+		return nil, &ValidationError{Problems: []string{fmt.Sprintf("unknown delivery status %q", status)}}
+	}
+	if limit < 1 || limit > 100 {
+		limit = 50
+	}
+
+	deliveries, err := s.webhooks.ListDeliveries(ctx, id, status, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list webhook deliveries: %w", err)
+	}
+	if deliveries == nil {
+		deliveries = []webhook.Delivery{}
+	}
+	return deliveries, nil
+}
+
+// ReplayDelivery sends a failed delivery again, with the same body
+func (s *WebhookService) ReplayDelivery(ctx context.Context, id string) (*webhook.Delivery, error) {
+	d, err := s.webhooks.GetDelivery(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if d == nil {
+		return nil, ErrNotFound
+	}
+	if d.Status != webhook.DeliveryFailed {
+		return nil, &ValidationError{Problems: []string{fmt.Sprintf("only failed deliveries can be replayed, this one is %s", d.Status)}}
+	}
+
+	d.Status = webhook.DeliveryPending
+	if err := s.enqueue(ctx, d); err != nil {
+		return nil, err
+	}
+	return d, nil
+}
+
+// Name and Consume make the service an event consumer, creating a
+// delivery for every subscription that wants the event
+func (s *WebhookService) Name() string {
+	return "webhooks"
+}
+
+func (s *WebhookService) Consume(ctx context.Context, e *event.Event) error {
+	subscriptions, err := s.webhooks.ListSubscriptions(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to list webhook subscriptions: %w", err)
+	}
+
+	for i := range subscriptions {
+		if !subscriptions[i].Matches(e) {
+			continue
+		}
+		if _, err := s.deliver(ctx, &subscriptions[i], e); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// deliver saves a delivery of the event to the subscription and queues
+// it. An event published again is not delivered twice, but a delivery
+// that was saved without being queued is queued now.
+func (s *WebhookService) deliver(ctx context.Context, subscription *webhook.Subscription, e *event.Event) (*webhook.Delivery, error) {
+	payload, err := json.Marshal(e)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal event: %w", err)
+	}
+
+	d := &webhook.Delivery{
+		SubscriptionID: subscription.ID,
+		EventID:        e.ID,
+		EventType:      e.Type,
+		Payload:        payload,
+	}
+	if err := s.webhooks.CreateDelivery(ctx, d); err != nil {
+		return nil, err
+	}
+	if d.JobID != "" {
+		return d, nil
+	}
+
+	if err := s.enqueue(ctx, d); err != nil {
+		return nil, err
+	}
+	return d, nil
+}
+
+// enqueue queues a webhook_delivery job to send the delivery
+func (s *WebhookService) enqueue(ctx context.Context, d *webhook.Delivery) error {
+	j := &job.Job{
+		ID:          fmt.Sprintf("webhook_%s_%d", d.ID, time.Now().UnixNano()),
+		Type:        job.JobTypeWebhookDelivery,
+		Status:      job.StatusPending,
+		Payload:     map[string]interface{}{"delivery_id": d.ID},
+		MaxAttempts: webhook.MaxAttempts,
+		ScheduledAt: time.Now(),
+	}
+	if err := s.queue.Enqueue(ctx, j); err != nil {
+		return fmt.Errorf("failed to enqueue webhook delivery: %w", err)
+	}
+
+	d.JobID = j.ID
+	if err := s.webhooks.UpdateDelivery(ctx, d); err != nil {
+		return fmt.Errorf("failed to update webhook delivery: %w", err)
+	}
+	return nil
+}
+
+func (s *WebhookService) getSubscription(ctx context.Context, id string) (*webhook.Subscription, error) {
+	subscription, err := s.webhooks.GetSubscription(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if subscription == nil {
+		return nil, ErrNotFound
+	}
+	return subscription, nil
+}
+
+// trimAll trims every value and drops empty ones
+func trimAll(values []string) []string {
+	trimmed := []string{}
+	for _, v := range values {
+		if v = strings.TrimSpace(v); v != "" {
+			trimmed = append(trimmed, v)
+		}
+	}
+	return trimmed
+}